@@ -0,0 +1,116 @@
+// Утилита чтения и проверки журнала аудита.
+//
+// Хранилище задается теми же флагами и переменными окружения, что и у
+// сервера (-storage, -f, -d, -sqlite-path, ...). Команды:
+//
+//	audit [флаги] query   - печатает записи журнала, по одной JSON-записи в строке;
+//	                        отбор задают флаги -after, -limit, -actor, -action, -target
+//	audit [флаги] verify  - проверяет цепочку хэшей и печатает результат;
+//	                        при нарушенной цепочке завершается с кодом 2
+//
+// Хэш последней записи (head_hash) из вывода verify стоит сохранять отдельно:
+// по нему видно, если из журнала удалили последние записи
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/physicist2018/url-shortener-go/internal/config"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repofactorymethod"
+	"github.com/physicist2018/url-shortener-go/internal/service"
+	"github.com/rs/zerolog"
+)
+
+// код завершения при нарушенной цепочке, чтобы отличать его от ошибок запуска
+const exitChainBroken = 2
+
+var errChainBroken = errors.New("цепочка журнала аудита нарушена")
+
+func main() {
+	var filter domain.AuditFilter
+	flag.Int64Var(&filter.AfterSeq, "after", 0, "query: печатать записи с номером больше заданного")
+	flag.IntVar(&filter.Limit, "limit", 0, "query: сколько записей напечатать, 0 - все")
+	flag.StringVar(&filter.Actor, "actor", "", "query: только записи этого автора (user:<id> или key:<имя>)")
+	flag.StringVar(&filter.Action, "action", "", "query: только записи с этим действием, например link.delete")
+	flag.StringVar(&filter.Target, "target", "", "query: только записи об этом объекте, например коде ссылки")
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "audit:", err)
+		os.Exit(1)
+	}
+
+	err = run(context.Background(), cfg, flag.Arg(0), filter)
+	switch {
+	case errors.Is(err, errChainBroken):
+		os.Exit(exitChainBroken)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "audit:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg *config.Config, command string, filter domain.AuditFilter) error {
+	if command != "query" && command != "verify" {
+		return fmt.Errorf("ожидается команда query или verify, получено %q", command)
+	}
+
+	repo, err := repofactorymethod.NewRepoFactoryMethod().CreateRepo(cfg.StorageBackend, cfg.StorageParams())
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+	auditRepo, ok := repo.(domain.AuditRepo)
+	if !ok {
+		return fmt.Errorf("бэкенд %s не поддерживает журнал аудита", cfg.StorageBackend)
+	}
+	audit := service.NewAuditLog(auditRepo, zerolog.New(os.Stderr).Level(zerolog.WarnLevel))
+
+	out := json.NewEncoder(os.Stdout)
+	if command == "verify" {
+		result, err := audit.Verify(ctx)
+		if err != nil {
+			return err
+		}
+		if err := out.Encode(result); err != nil {
+			return err
+		}
+		if !result.Valid {
+			return errChainBroken
+		}
+		return nil
+	}
+
+	// журнал читается страницами, пока не наберется limit записей или он не кончится
+	remaining := filter.Limit
+	for {
+		filter.Limit = service.MaxAuditPageSize
+		if remaining > 0 {
+			filter.Limit = min(remaining, service.MaxAuditPageSize)
+		}
+		entries, err := audit.Find(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := out.Encode(entry); err != nil {
+				return err
+			}
+		}
+		if remaining > 0 {
+			if remaining -= len(entries); remaining <= 0 {
+				return nil
+			}
+		}
+		if len(entries) < filter.Limit {
+			return nil
+		}
+		filter.AfterSeq = entries[len(entries)-1].Seq
+	}
+}
@@ -0,0 +1,74 @@
+// Утилита генерации ключей подписи сессионных кук.
+//
+// Без флагов печатает новый ключ в формате для AUTH_KEYS.
+// С флагом -file добавляет ключ в JSON-файл ключей (создает его при
+// отсутствии); новый ключ сразу становится ключом подписи, а старые
+// продолжают приниматься. Флаг -retire-after задает срок, после которого
+// старые ключи без срока действия перестанут приниматься.
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/authenticator"
+)
+
+func main() {
+	id := flag.String("id", "", "идентификатор ключа, по умолчанию - текущее время")
+	file := flag.String("file", "", "JSON-файл ключей, в который нужно добавить новый ключ")
+	retireAfter := flag.Duration("retire-after", 0, "через сколько перестать принимать старые ключи, 0 - не ограничивать")
+	flag.Parse()
+
+	if err := run(*id, *file, *retireAfter); err != nil {
+		fmt.Fprintln(os.Stderr, "keygen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(id, file string, retireAfter time.Duration) error {
+	now := time.Now()
+	key, err := authenticator.GenerateKey(id, now)
+	if err != nil {
+		return err
+	}
+
+	if file == "" {
+		fmt.Printf("%s:%s\n", key.ID, base64.StdEncoding.EncodeToString(key.Secret))
+		return nil
+	}
+
+	var keys []authenticator.Key
+	existing, err := authenticator.LoadKeysFile(file)
+	switch {
+	case err == nil:
+		keys = existing.Keys()
+	case errors.Is(err, os.ErrNotExist):
+	default:
+		return err
+	}
+
+	if retireAfter > 0 {
+		retireAt := now.Add(retireAfter).UTC()
+		for i := range keys {
+			if keys[i].ExpiresAt == nil {
+				keys[i].ExpiresAt = &retireAt
+			}
+		}
+	}
+
+	keys = append([]authenticator.Key{key}, keys...)
+	if _, err := authenticator.NewKeySet(keys...); err != nil {
+		return err
+	}
+	if err := authenticator.WriteKeysFile(file, keys); err != nil {
+		return err
+	}
+
+	fmt.Printf("ключ %s добавлен в %s\n", key.ID, file)
+	return nil
+}
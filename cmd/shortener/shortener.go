@@ -2,19 +2,32 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/physicist2018/url-shortener-go/internal/config"
 	"github.com/physicist2018/url-shortener-go/internal/deleter"
 	"github.com/physicist2018/url-shortener-go/internal/domain"
 	"github.com/physicist2018/url-shortener-go/internal/handler"
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/authenticator"
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/ratelimit"
+	"github.com/physicist2018/url-shortener-go/internal/repository/cache"
 	"github.com/physicist2018/url-shortener-go/internal/repository/repofactorymethod"
+	"github.com/physicist2018/url-shortener-go/internal/reputation"
 	"github.com/physicist2018/url-shortener-go/internal/router"
 	"github.com/physicist2018/url-shortener-go/internal/server"
 	"github.com/physicist2018/url-shortener-go/internal/service"
 	stringgenstategy "github.com/physicist2018/url-shortener-go/internal/stringgenstrategy"
+	"github.com/physicist2018/url-shortener-go/pkg/resp"
 	uniquestring "github.com/physicist2018/url-shortener-go/pkg/uniquestring"
+	"github.com/physicist2018/url-shortener-go/pkg/urlnorm"
 	"github.com/rs/zerolog"
 )
 
@@ -37,16 +50,61 @@ func main() {
 	stringGeneratorContext.SetStrategy(randomStringStrategy)
 
 	repofactory := repofactorymethod.NewRepoFactoryMethod()
-	var linkRepo domain.URLLinkRepo
+	linkRepo, err := repofactory.CreateRepo(cfg.StorageBackend, cfg.StorageParams())
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Ошибка инициализации репозитория")
+	}
 
-	if cfg.DatabaseDSN != "" {
-		linkRepo, err = repofactory.CreateRepo("postgres", cfg.DatabaseDSN)
-	} else {
-		linkRepo, err = repofactory.CreateRepo("inmemory", cfg.FileStoragePath)
+	// Кэши оборачивают только ссылки, остальные данные берутся из самого бэкенда
+	apiKeyRepo, ok := linkRepo.(domain.APIKeyRepo)
+	if !ok {
+		logger.Fatal().Str("backend", cfg.StorageBackend).Msg("Бэкенд хранилища не поддерживает API-ключи")
+	}
+	userRepo, ok := linkRepo.(domain.UserRepo)
+	if !ok {
+		logger.Fatal().Str("backend", cfg.StorageBackend).Msg("Бэкенд хранилища не поддерживает учетные записи")
+	}
+	workspaceRepo, ok := linkRepo.(domain.WorkspaceRepo)
+	if !ok {
+		logger.Fatal().Str("backend", cfg.StorageBackend).Msg("Бэкенд хранилища не поддерживает рабочие пространства")
+	}
+	adminRepo, ok := linkRepo.(domain.AdminRepo)
+	if !ok {
+		logger.Fatal().Str("backend", cfg.StorageBackend).Msg("Бэкенд хранилища не поддерживает административный API")
+	}
+	domainBlockRepo, ok := linkRepo.(domain.DomainBlockRepo)
+	if !ok {
+		logger.Fatal().Str("backend", cfg.StorageBackend).Msg("Бэкенд хранилища не поддерживает блокировку доменов")
+	}
+	auditRepo, ok := linkRepo.(domain.AuditRepo)
+	if !ok {
+		logger.Fatal().Str("backend", cfg.StorageBackend).Msg("Бэкенд хранилища не поддерживает журнал аудита")
 	}
 
-	if err != nil {
-		logger.Fatal().Err(err).Msg("Ошибка инициализации репозитория")
+	if cfg.RedisAddr != "" {
+		logger.Info().Str("addr", cfg.RedisAddr).Msg("подключение общего кэша коротких ссылок")
+		linkRepo, err = repofactory.Decorate("redis", linkRepo, cfg.StorageParams())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Ошибка инициализации общего кэша")
+		}
+	}
+
+	if cfg.CacheSize > 0 {
+		logger.Info().Int("size", cfg.CacheSize).Msg("включение кэша коротких ссылок")
+		cachedRepo := cache.NewCachedLinkRepository(linkRepo, cache.Options{
+			Size:        cfg.CacheSize,
+			TTL:         cfg.CacheTTL,
+			NegativeTTL: cfg.CacheNegativeTTL,
+		})
+		defer func() {
+			stats := cachedRepo.Stats()
+			logger.Info().
+				Uint64("hits", stats.Hits).
+				Uint64("misses", stats.Misses).
+				Int("entries", stats.Entries).
+				Msg("Статистика кэша коротких ссылок")
+		}()
+		linkRepo = cachedRepo
 	}
 	defer func() {
 		if err := linkRepo.Close(); err != nil {
@@ -58,13 +116,139 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	blocklist := service.NewDomainBlocklist(domainBlockRepo, cfg.BlocklistRefresh, logger)
+	// пароли ссылок и учетных записей хэшируются под одним семафором
+	hashSlots := service.NewHashSlots(service.DefaultConcurrentHashes)
 	linkService := service.NewURLLinkService(linkRepo, stringGeneratorContext, logger)
+	linkService.SetHashSlots(hashSlots)
+	if cfg.HostPolicyFile == "" {
+		linkService.SetBlocklist(blocklist)
+	} else {
+		hostPolicy, err := service.NewHostPolicy(cfg.HostPolicyFile, logger)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Ошибка загрузки политики хостов")
+		}
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		hostPolicy.Start(ctx, cfg.HostPolicyReload, reload)
+		// домены, заблокированные операторами, и правила из файла действуют вместе
+		linkService.SetBlocklist(service.HostBlocklists{blocklist, hostPolicy})
+	}
+	linkService.SetURLPolicy(service.URLPolicy{
+		Schemes:           strings.Split(strings.ReplaceAll(strings.ToLower(cfg.AllowedSchemes), " ", ""), ","),
+		MaxLength:         cfg.MaxURLLength,
+		AllowPrivateHosts: cfg.AllowPrivateHosts,
+	})
+	linkService.SetNormalizer(urlnorm.New(urlnorm.Options{
+		Lowercase:         cfg.NormalizeLowercase,
+		DropDefaultPort:   cfg.NormalizeDefaultPort,
+		NormalizeEncoding: cfg.NormalizeEncoding,
+		IDNToASCII:        cfg.NormalizeIDN,
+		SortQuery:         cfg.NormalizeSortQuery,
+		StripTracking:     cfg.NormalizeStripTracking,
+		TrackingParams:    strings.Split(cfg.TrackingParams, ","),
+	}))
+	var checkers reputation.Chain
+	if cfg.ReputationList != "" {
+		hashList, err := reputation.NewHashList(cfg.ReputationList)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Ошибка загрузки списка опасных адресов")
+		}
+		checkers = append(checkers, hashList)
+	}
+	if cfg.ReputationWebhook != "" {
+		checkers = append(checkers, reputation.NewWebhook(cfg.ReputationWebhook, cfg.ReputationWebhookToken, cfg.ReputationTimeout))
+	}
+	if len(checkers) > 0 {
+		linkService.SetReputationChecker(reputation.NewCache(checkers, cfg.ReputationCacheTTL, reputation.DefaultCacheSize), cfg.ReputationFailClosed)
+	}
+	quotas := service.Quotas{
+		MaxLinks:     cfg.MaxLinksPerUser,
+		MaxBatchSize: cfg.MaxBatchSize,
+	}
+	linkService.SetQuotas(quotas)
+	auditLog := service.NewAuditLog(auditRepo, logger)
+	linkService.SetAuditLog(auditLog)
 	linkDeleter := deleter.NewDeleter(linkService, logger)
 	linkDeleter.Start(ctx, &wg) //Запускаем горутину асинхронного удаления ссылок
 
 	linkHandler := handler.NewURLLinkHandler(linkService, cfg.BaseURLServer, logger, linkDeleter)
 
-	r := router.NewRouter(linkHandler, logger)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, logger)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService, logger)
+
+	keySet, err := authenticator.LoadKeySet(cfg.AuthKeys, cfg.AuthKeysFile)
+	if errors.Is(err, authenticator.ErrNoKeys) {
+		logger.Warn().Msg("ключи подписи сессий не заданы, используется временный ключ: сессии не переживут перезапуск")
+		var key authenticator.Key
+		if key, err = authenticator.GenerateKey("", time.Now()); err == nil {
+			keySet, err = authenticator.NewKeySet(key)
+		}
+	}
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Ошибка загрузки ключей подписи сессий")
+	}
+	sameSite, err := authenticator.ParseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Ошибка конфигурации сессионной куки")
+	}
+	auth := authenticator.NewAuthenticator(keySet, authenticator.CookieOptions{
+		Name:     cfg.CookieName,
+		Lifetime: cfg.CookieLifetime,
+		Secure:   cfg.CookieSecure,
+		SameSite: sameSite,
+		Domain:   cfg.CookieDomain,
+	})
+
+	if cfg.BearerEnabled() {
+		verifier, err := newBearerVerifier(cfg)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Ошибка конфигурации Bearer-токенов")
+		}
+		auth.SetBearerVerifier(verifier)
+	}
+
+	auth.SetAPIKeyResolver(apiKeyService)
+
+	if cfg.OIDCEnabled() {
+		logger.Info().Str("issuer", cfg.OIDCIssuer).Msg("подключение OIDC-провайдера")
+		provider, err := newOIDCProvider(ctx, cfg)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Ошибка конфигурации OIDC")
+		}
+		auth.SetOIDCProvider(provider)
+	}
+
+	// ссылки переносятся через декорированный репозиторий, чтобы сбросить кэши
+	userService := service.NewUserService(userRepo, linkRepo, logger)
+	userService.SetHashSlots(hashSlots)
+	userService.SetQuotas(quotas)
+	userService.SetAuditLog(auditLog)
+	userHandler := handler.NewUserHandler(userService, auth, logger)
+
+	workspaceService := service.NewWorkspaceService(workspaceRepo, linkRepo, linkService, logger)
+	workspaceService.SetAuditLog(auditLog)
+	workspaceHandler := handler.NewWorkspaceHandler(workspaceService, cfg.BaseURLServer, logger)
+
+	adminKeys, err := authenticator.ParseAdminKeys(cfg.AdminKeys)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Ошибка конфигурации административных ключей")
+	}
+	auth.SetAdmins(authenticator.AdminOptions{
+		UserIDs: strings.Split(cfg.AdminUsers, ","),
+		Keys:    adminKeys,
+	})
+
+	// удаление и очистка идут через декорированный репозиторий, чтобы сбросить кэши
+	adminService := service.NewAdminService(adminRepo, auditLog, userRepo, linkRepo, blocklist, logger)
+	adminHandler := handler.NewAdminHandler(adminService, logger)
+
+	limiter, err := newRateLimiter(cfg, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Ошибка конфигурации лимитов частоты запросов")
+	}
+
+	r := router.NewRouter(linkHandler, apiKeyHandler, userHandler, workspaceHandler, adminHandler, auth, limiter, logger)
 
 	srv := server.NewServer(cfg.ServerAddr, r, logger)
 	srv.Start()
@@ -73,3 +257,99 @@ func main() {
 	logger.Info().Msg("Closing link handler")
 	wg.Wait()
 }
+
+func newBearerVerifier(cfg *config.Config) (*authenticator.BearerVerifier, error) {
+	opts := authenticator.BearerOptions{
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		Leeway:   cfg.JWTLeeway,
+	}
+	if cfg.JWTSecret != "" {
+		secret, err := base64.StdEncoding.DecodeString(cfg.JWTSecret)
+		if err != nil {
+			return nil, fmt.Errorf("секрет JWT не в base64: %w", err)
+		}
+		opts.HMACSecret = secret
+	}
+	if cfg.JWTPublicKeysFile != "" {
+		keys, err := authenticator.LoadPublicKeys(cfg.JWTPublicKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.PublicKeys = keys
+	}
+	return authenticator.NewBearerVerifier(opts)
+}
+
+// newRateLimiter возвращает nil, если лимиты не заданы. Клиент общего
+// хранилища живет до завершения процесса
+func newRateLimiter(cfg *config.Config, logger zerolog.Logger) (*ratelimit.Limiter, error) {
+	limits, err := ratelimit.ParseLimits(cfg.RateLimits)
+	if err != nil {
+		return nil, err
+	}
+	failureLimits, err := ratelimit.ParseLimits(cfg.FailureLimits)
+	if err != nil {
+		return nil, err
+	}
+	for route := range limits {
+		if route == ratelimit.RoutePassword || route == ratelimit.RouteLogin {
+			return nil, fmt.Errorf("лимит %q задается через -failure-limits", route)
+		}
+	}
+	for route, limit := range failureLimits {
+		if route != ratelimit.RoutePassword && route != ratelimit.RouteLogin {
+			return nil, fmt.Errorf("в -failure-limits неизвестный маршрут %q, ожидается password или login", route)
+		}
+		limits[route] = limit
+	}
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	proxies, err := ratelimit.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный список доверенных прокси: %w", err)
+	}
+
+	var store ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	case "redis":
+		if cfg.RedisAddr == "" {
+			return nil, errors.New("для общего хранилища лимитов нужен адрес -redis-addr")
+		}
+		store = ratelimit.NewRESPStore(resp.NewClient(resp.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}))
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище лимитов %q, ожидается memory или redis", cfg.RateLimitStore)
+	}
+
+	if len(proxies) == 0 {
+		logger.Warn().Msg("лимиты частоты включены без -trusted-proxies: за балансировщиком все анонимные клиенты получат одну корзину его адреса")
+	}
+
+	limiter := ratelimit.NewLimiter(store, limits, logger)
+	limiter.SetTrustedProxies(proxies)
+	return limiter, nil
+}
+
+func newOIDCProvider(ctx context.Context, cfg *config.Config) (*authenticator.OIDCProvider, error) {
+	redirectURL := cfg.OIDCRedirectURL
+	if redirectURL == "" {
+		redirectURL = strings.TrimSuffix(cfg.BaseURLServer, "/") + "/api/auth/oidc/callback"
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return authenticator.NewOIDCProvider(ctx, authenticator.OIDCOptions{
+		IssuerURL:    cfg.OIDCIssuer,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       strings.Fields(cfg.OIDCScopes),
+		Leeway:       cfg.JWTLeeway,
+	})
+}
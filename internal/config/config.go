@@ -1,18 +1,80 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
-	ServerAddr        string
-	BaseURLServer     string
-	FileStoragePath   string
-	DatabaseDSN       string
-	MaxShortURLLength int
-	MaxShutdownTime   int
+	ServerAddr             string
+	BaseURLServer          string
+	FileStoragePath        string
+	DatabaseDSN            string
+	DatabaseReplicas       string
+	DBMaxOpenConns         int
+	DBMaxIdleConns         int
+	DBConnMaxLifetime      time.Duration
+	DBConnectTimeout       time.Duration
+	DBRetryAttempts        int
+	SQLitePath             string
+	StorageBackend         string
+	CacheSize              int
+	CacheTTL               time.Duration
+	CacheNegativeTTL       time.Duration
+	RedisAddr              string
+	RedisPassword          string
+	RedisDB                int
+	RedisTTL               time.Duration
+	AuthKeys               string
+	AuthKeysFile           string
+	CookieName             string
+	CookieLifetime         time.Duration
+	CookieSecure           bool
+	CookieSameSite         string
+	CookieDomain           string
+	JWTSecret              string
+	JWTPublicKeysFile      string
+	JWTIssuer              string
+	JWTAudience            string
+	JWTLeeway              time.Duration
+	OIDCIssuer             string
+	OIDCClientID           string
+	OIDCClientSecret       string
+	OIDCRedirectURL        string
+	OIDCScopes             string
+	AdminUsers             string
+	AdminKeys              string
+	BlocklistRefresh       time.Duration
+	HostPolicyFile         string
+	HostPolicyReload       time.Duration
+	NormalizeLowercase     bool
+	NormalizeDefaultPort   bool
+	NormalizeEncoding      bool
+	NormalizeIDN           bool
+	NormalizeSortQuery     bool
+	NormalizeStripTracking bool
+	TrackingParams         string
+	AllowedSchemes         string
+	MaxURLLength           int
+	AllowPrivateHosts      bool
+	ReputationList         string
+	ReputationWebhook      string
+	ReputationWebhookToken string
+	ReputationTimeout      time.Duration
+	ReputationCacheTTL     time.Duration
+	ReputationFailClosed   bool
+	RateLimits             string
+	FailureLimits          string
+	RateLimitStore         string
+	TrustedProxies         string
+	MaxLinksPerUser        int
+	MaxBatchSize           int
+	MaxShortURLLength      int
+	MaxShutdownTime        int
 }
 
 func NewConfig() *Config {
@@ -21,6 +83,70 @@ func NewConfig() *Config {
 	flag.StringVar(&cfg.BaseURLServer, "b", "http://localhost:8080", "префикс короткого URL")
 	flag.StringVar(&cfg.FileStoragePath, "f", "dbase.json", "имя файла персистентного хранилища коротких URL")
 	flag.StringVar(&cfg.DatabaseDSN, "d", "", "параметры подключения к базе данных")
+	flag.StringVar(&cfg.DatabaseReplicas, "database-replica-dsns", "", "параметры подключения к репликам базы данных для чтения, через запятую")
+	flag.IntVar(&cfg.DBMaxOpenConns, "database-max-open-conns", 20, "максимум открытых соединений с базой данных, 0 - без ограничения")
+	flag.IntVar(&cfg.DBMaxIdleConns, "database-max-idle-conns", 10, "максимум простаивающих соединений с базой данных")
+	flag.DurationVar(&cfg.DBConnMaxLifetime, "database-conn-max-lifetime", 30*time.Minute, "время жизни соединения с базой данных")
+	flag.DurationVar(&cfg.DBConnectTimeout, "database-connect-timeout", 10*time.Second, "сколько ждать базу данных при старте")
+	flag.IntVar(&cfg.DBRetryAttempts, "database-retry-attempts", 3, "число попыток запроса при временных ошибках базы данных")
+	flag.StringVar(&cfg.SQLitePath, "sqlite-path", "", "путь к файлу базы SQLite")
+	flag.StringVar(&cfg.StorageBackend, "storage", "", "бэкенд хранилища (inmemory, postgres, sqlite); по умолчанию выбирается по заданным параметрам")
+	flag.IntVar(&cfg.CacheSize, "cache-size", 0, "число коротких ссылок в локальном кэше перед хранилищем, 0 - кэш выключен; только для одного экземпляра сервиса, несовместим с -redis-addr и -database-replica-dsns")
+	flag.DurationVar(&cfg.CacheTTL, "cache-ttl", 5*time.Minute, "время жизни ссылки в кэше")
+	flag.DurationVar(&cfg.CacheNegativeTTL, "cache-negative-ttl", 30*time.Second, "время жизни в кэше записи об отсутствующей ссылке")
+	flag.StringVar(&cfg.RedisAddr, "redis-addr", "", "адрес Redis-совместимого общего кэша, пусто - кэш выключен")
+	flag.StringVar(&cfg.RedisPassword, "redis-password", "", "пароль общего кэша")
+	flag.IntVar(&cfg.RedisDB, "redis-db", 0, "номер базы общего кэша")
+	flag.DurationVar(&cfg.RedisTTL, "redis-ttl", 10*time.Minute, "время жизни ссылки в общем кэше, не больше 10m: столько может прожить запись, которую не удалось удалить")
+	flag.StringVar(&cfg.AuthKeys, "auth-keys", "", "ключи подписи сессий в формате id:base64secret через запятую, первый - самый новый")
+	flag.StringVar(&cfg.AuthKeysFile, "auth-keys-file", "", "JSON-файл с ключами подписи сессий (см. cmd/keygen)")
+	flag.StringVar(&cfg.CookieName, "cookie-name", "user_session", "имя сессионной куки")
+	flag.DurationVar(&cfg.CookieLifetime, "cookie-lifetime", 30*24*time.Hour, "срок жизни сессионной куки, после половины срока кука продлевается")
+	flag.BoolVar(&cfg.CookieSecure, "cookie-secure", false, "отправлять сессионную куку только по HTTPS")
+	flag.StringVar(&cfg.CookieSameSite, "cookie-samesite", "lax", "атрибут SameSite сессионной куки: lax, strict, none или пусто")
+	flag.StringVar(&cfg.CookieDomain, "cookie-domain", "", "атрибут Domain сессионной куки")
+	flag.StringVar(&cfg.JWTSecret, "jwt-secret", "", "секрет HS256 для Bearer-токенов в base64, пусто - HS256 не принимается")
+	flag.StringVar(&cfg.JWTPublicKeysFile, "jwt-public-keys-file", "", "PEM-файл с открытыми ключами RS256/EdDSA для Bearer-токенов")
+	flag.StringVar(&cfg.OIDCIssuer, "oidc-issuer", "", "адрес OIDC-провайдера для входа через SSO, пусто - вход выключен")
+	flag.StringVar(&cfg.OIDCClientID, "oidc-client-id", "", "идентификатор клиента у OIDC-провайдера")
+	flag.StringVar(&cfg.OIDCClientSecret, "oidc-client-secret", "", "секрет клиента у OIDC-провайдера, пусто - публичный клиент")
+	flag.StringVar(&cfg.OIDCRedirectURL, "oidc-redirect-url", "", "адрес обратного вызова OIDC, по умолчанию <префикс>/api/auth/oidc/callback")
+	flag.StringVar(&cfg.OIDCScopes, "oidc-scopes", "openid profile email", "запрашиваемые у OIDC-провайдера scope через пробел")
+	flag.StringVar(&cfg.JWTIssuer, "jwt-issuer", "", "ожидаемый издатель (iss) Bearer-токенов")
+	flag.StringVar(&cfg.JWTAudience, "jwt-audience", "", "ожидаемая аудитория (aud) Bearer-токенов")
+	flag.DurationVar(&cfg.JWTLeeway, "jwt-leeway", 30*time.Second, "допустимое расхождение часов при проверке сроков Bearer-токенов")
+	flag.StringVar(&cfg.AdminUsers, "admin-users", "", "идентификаторы пользователей с ролью администратора, через запятую")
+	flag.StringVar(&cfg.AdminKeys, "admin-keys", "", "ключи административного API в формате name:secret через запятую")
+	flag.DurationVar(&cfg.BlocklistRefresh, "blocklist-refresh", time.Minute, "как часто перечитывать список заблокированных доменов")
+	flag.StringVar(&cfg.HostPolicyFile, "host-policy-file", "", "файл правил для хостов назначения (allow/deny exact|suffix|regex), пусто - без правил")
+	flag.DurationVar(&cfg.HostPolicyReload, "host-policy-reload", 10*time.Second, "как часто проверять изменение файла правил хостов, 0 - только по SIGHUP")
+	flag.BoolVar(&cfg.NormalizeLowercase, "normalize-lowercase", true, "приводить схему и хост адреса назначения к нижнему регистру")
+	flag.BoolVar(&cfg.NormalizeDefaultPort, "normalize-default-port", true, "убирать из адреса назначения порт схемы по умолчанию")
+	flag.BoolVar(&cfg.NormalizeEncoding, "normalize-encoding", true, "приводить процентное кодирование адреса назначения к каноническому виду")
+	flag.BoolVar(&cfg.NormalizeIDN, "normalize-idn", true, "переводить интернационализированные домены в Punycode")
+	flag.BoolVar(&cfg.NormalizeSortQuery, "normalize-sort-query", false, "упорядочивать параметры запроса адреса назначения по имени")
+	flag.BoolVar(&cfg.NormalizeStripTracking, "normalize-strip-tracking", false, "убирать из адреса назначения параметры отслеживания")
+	flag.StringVar(&cfg.TrackingParams, "tracking-params", "utm_*,fbclid", "параметры отслеживания через запятую, * на конце - префикс")
+	flag.StringVar(&cfg.AllowedSchemes, "allowed-schemes", "http,https", "разрешенные схемы адресов назначения через запятую")
+	flag.IntVar(&cfg.MaxURLLength, "max-url-length", 512, "максимальная длина адреса назначения, не больше 512 - размера столбца в базе")
+	flag.BoolVar(&cfg.AllowPrivateHosts, "allow-private-hosts", false, "разрешить адреса назначения в локальной и внутренних сетях")
+	flag.StringVar(&cfg.ReputationList, "reputation-list", "", "файл префиксов SHA-256 опасных адресов в духе Safe Browsing, пусто - без списка")
+	flag.StringVar(&cfg.ReputationWebhook, "reputation-webhook", "", "адрес вебхука проверки репутации адресов назначения, пусто - без вебхука")
+	flag.StringVar(&cfg.ReputationWebhookToken, "reputation-webhook-token", "", "Bearer-токен для вебхука проверки репутации")
+	flag.DurationVar(&cfg.ReputationTimeout, "reputation-timeout", 2*time.Second, "сколько ждать ответа вебхука проверки репутации")
+	flag.DurationVar(&cfg.ReputationCacheTTL, "reputation-cache-ttl", 10*time.Minute, "время жизни результата проверки репутации в кэше")
+	flag.BoolVar(&cfg.ReputationFailClosed, "reputation-fail-closed", false, "не создавать ссылки, если репутацию адреса проверить не удалось")
+	// лимиты маршрутов выключены по умолчанию: за балансировщиком без -trusted-proxies
+	// все клиенты делили бы одну корзину адреса балансировщика
+	flag.StringVar(&cfg.RateLimits, "rate-limits", "", "лимиты частоты запросов маршрутов (create, batch, redirect) в формате маршрут=число/s|m|h[:burst] через запятую, например create=60/m:20,batch=10/m:5,redirect=1200/m:200; пусто - без лимитов. За балансировщиком задайте и -trusted-proxies")
+	// лимиты неудачных попыток включены всегда: без них пароли ссылок и учетных
+	// записей перебираются без ограничений, а корзина по цели попытки
+	// работает и без -trusted-proxies
+	flag.StringVar(&cfg.FailureLimits, "failure-limits", "password=20/h:5,login=20/h:5", "лимиты неверных паролей ссылок (password) и входа в учетную запись (login) в том же формате; пусто - без лимитов")
+	flag.StringVar(&cfg.RateLimitStore, "rate-limit-store", "memory", "где хранить состояние лимитов: memory - у каждой реплики свое, redis - общее в хранилище -redis-addr")
+	flag.IntVar(&cfg.MaxLinksPerUser, "max-links-per-user", 10000, "сколько неудаленных ссылок может быть у пользователя, 0 - без ограничения")
+	flag.IntVar(&cfg.MaxBatchSize, "max-batch-size", 1000, "сколько ссылок можно создать одним пакетным запросом, 0 - без ограничения")
+	flag.StringVar(&cfg.TrustedProxies, "trusted-proxies", "", "адреса и подсети прокси через запятую, для запросов от которых адрес клиента берется из X-Forwarded-For")
 	flag.IntVar(&cfg.MaxShortURLLength, "max-short-url-len", 5, "максимально допустимая длина короткой ссылки")
 	flag.IntVar(&cfg.MaxShutdownTime, "max-shutdown-time", 5, "время в секундах, кторое мы ждем прежде чем прекратим выключать сервер")
 	return cfg
@@ -28,11 +154,13 @@ func NewConfig() *Config {
 
 func Load() (*Config, error) {
 	cfg := NewConfig()
-	cfg.Parse()
+	if err := cfg.Parse(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
-func (c *Config) Parse() {
+func (c *Config) Parse() error {
 	flag.Parse()
 	if envServerAddr := os.Getenv("SERVER_ADDRESS"); envServerAddr != "" {
 		c.ServerAddr = envServerAddr
@@ -49,15 +177,428 @@ func (c *Config) Parse() {
 	if envDatabaseDSN := os.Getenv("DATABASE_DSN"); envDatabaseDSN != "" {
 		c.DatabaseDSN = envDatabaseDSN
 	}
+
+	if envDatabaseReplicas := os.Getenv("DATABASE_REPLICA_DSNS"); envDatabaseReplicas != "" {
+		c.DatabaseReplicas = envDatabaseReplicas
+	}
+
+	if envDBMaxOpenConns := os.Getenv("DATABASE_MAX_OPEN_CONNS"); envDBMaxOpenConns != "" {
+		n, err := strconv.Atoi(envDBMaxOpenConns)
+		if err != nil {
+			return fmt.Errorf("некорректное значение DATABASE_MAX_OPEN_CONNS: %w", err)
+		}
+		c.DBMaxOpenConns = n
+	}
+
+	if envDBMaxIdleConns := os.Getenv("DATABASE_MAX_IDLE_CONNS"); envDBMaxIdleConns != "" {
+		n, err := strconv.Atoi(envDBMaxIdleConns)
+		if err != nil {
+			return fmt.Errorf("некорректное значение DATABASE_MAX_IDLE_CONNS: %w", err)
+		}
+		c.DBMaxIdleConns = n
+	}
+
+	if envDBConnMaxLifetime := os.Getenv("DATABASE_CONN_MAX_LIFETIME"); envDBConnMaxLifetime != "" {
+		d, err := time.ParseDuration(envDBConnMaxLifetime)
+		if err != nil {
+			return fmt.Errorf("некорректное значение DATABASE_CONN_MAX_LIFETIME: %w", err)
+		}
+		c.DBConnMaxLifetime = d
+	}
+
+	if envDBConnectTimeout := os.Getenv("DATABASE_CONNECT_TIMEOUT"); envDBConnectTimeout != "" {
+		d, err := time.ParseDuration(envDBConnectTimeout)
+		if err != nil {
+			return fmt.Errorf("некорректное значение DATABASE_CONNECT_TIMEOUT: %w", err)
+		}
+		c.DBConnectTimeout = d
+	}
+
+	if envDBRetryAttempts := os.Getenv("DATABASE_RETRY_ATTEMPTS"); envDBRetryAttempts != "" {
+		n, err := strconv.Atoi(envDBRetryAttempts)
+		if err != nil {
+			return fmt.Errorf("некорректное значение DATABASE_RETRY_ATTEMPTS: %w", err)
+		}
+		c.DBRetryAttempts = n
+	}
+
+	if envSQLitePath := os.Getenv("SQLITE_PATH"); envSQLitePath != "" {
+		c.SQLitePath = envSQLitePath
+	}
+
+	if envStorageBackend := os.Getenv("STORAGE_BACKEND"); envStorageBackend != "" {
+		c.StorageBackend = envStorageBackend
+	}
+
+	// Если бэкенд не указан явно, сохраняем прежнее поведение:
+	// выбираем его по тому, какие параметры подключения заданы
+	if c.StorageBackend == "" {
+		switch {
+		case c.DatabaseDSN != "":
+			c.StorageBackend = "postgres"
+		case c.SQLitePath != "":
+			c.StorageBackend = "sqlite"
+		default:
+			c.StorageBackend = "inmemory"
+		}
+	}
+
+	if envCacheSize := os.Getenv("CACHE_SIZE"); envCacheSize != "" {
+		size, err := strconv.Atoi(envCacheSize)
+		if err != nil {
+			return fmt.Errorf("некорректное значение CACHE_SIZE: %w", err)
+		}
+		c.CacheSize = size
+	}
+
+	if envCacheTTL := os.Getenv("CACHE_TTL"); envCacheTTL != "" {
+		ttl, err := time.ParseDuration(envCacheTTL)
+		if err != nil {
+			return fmt.Errorf("некорректное значение CACHE_TTL: %w", err)
+		}
+		c.CacheTTL = ttl
+	}
+
+	if envCacheNegativeTTL := os.Getenv("CACHE_NEGATIVE_TTL"); envCacheNegativeTTL != "" {
+		ttl, err := time.ParseDuration(envCacheNegativeTTL)
+		if err != nil {
+			return fmt.Errorf("некорректное значение CACHE_NEGATIVE_TTL: %w", err)
+		}
+		c.CacheNegativeTTL = ttl
+	}
+
+	if envRedisAddr := os.Getenv("REDIS_ADDR"); envRedisAddr != "" {
+		c.RedisAddr = envRedisAddr
+	}
+
+	if envRedisPassword := os.Getenv("REDIS_PASSWORD"); envRedisPassword != "" {
+		c.RedisPassword = envRedisPassword
+	}
+
+	if envRedisDB := os.Getenv("REDIS_DB"); envRedisDB != "" {
+		db, err := strconv.Atoi(envRedisDB)
+		if err != nil {
+			return fmt.Errorf("некорректное значение REDIS_DB: %w", err)
+		}
+		c.RedisDB = db
+	}
+
+	if envRedisTTL := os.Getenv("REDIS_TTL"); envRedisTTL != "" {
+		ttl, err := time.ParseDuration(envRedisTTL)
+		if err != nil {
+			return fmt.Errorf("некорректное значение REDIS_TTL: %w", err)
+		}
+		c.RedisTTL = ttl
+	}
+
+	if envAuthKeys := os.Getenv("AUTH_KEYS"); envAuthKeys != "" {
+		c.AuthKeys = envAuthKeys
+	}
+
+	if envAuthKeysFile := os.Getenv("AUTH_KEYS_FILE"); envAuthKeysFile != "" {
+		c.AuthKeysFile = envAuthKeysFile
+	}
+
+	if envCookieName := os.Getenv("COOKIE_NAME"); envCookieName != "" {
+		c.CookieName = envCookieName
+	}
+
+	if envCookieLifetime := os.Getenv("COOKIE_LIFETIME"); envCookieLifetime != "" {
+		d, err := time.ParseDuration(envCookieLifetime)
+		if err != nil {
+			return fmt.Errorf("некорректное значение COOKIE_LIFETIME: %w", err)
+		}
+		c.CookieLifetime = d
+	}
+
+	if envCookieSecure := os.Getenv("COOKIE_SECURE"); envCookieSecure != "" {
+		secure, err := strconv.ParseBool(envCookieSecure)
+		if err != nil {
+			return fmt.Errorf("некорректное значение COOKIE_SECURE: %w", err)
+		}
+		c.CookieSecure = secure
+	}
+
+	if envCookieSameSite, ok := os.LookupEnv("COOKIE_SAMESITE"); ok {
+		c.CookieSameSite = envCookieSameSite
+	}
+
+	if envCookieDomain := os.Getenv("COOKIE_DOMAIN"); envCookieDomain != "" {
+		c.CookieDomain = envCookieDomain
+	}
+
+	if envJWTSecret := os.Getenv("JWT_SECRET"); envJWTSecret != "" {
+		c.JWTSecret = envJWTSecret
+	}
+
+	if envJWTPublicKeysFile := os.Getenv("JWT_PUBLIC_KEYS_FILE"); envJWTPublicKeysFile != "" {
+		c.JWTPublicKeysFile = envJWTPublicKeysFile
+	}
+
+	if envJWTIssuer := os.Getenv("JWT_ISSUER"); envJWTIssuer != "" {
+		c.JWTIssuer = envJWTIssuer
+	}
+
+	if envJWTAudience := os.Getenv("JWT_AUDIENCE"); envJWTAudience != "" {
+		c.JWTAudience = envJWTAudience
+	}
+
+	if envJWTLeeway := os.Getenv("JWT_LEEWAY"); envJWTLeeway != "" {
+		d, err := time.ParseDuration(envJWTLeeway)
+		if err != nil {
+			return fmt.Errorf("некорректное значение JWT_LEEWAY: %w", err)
+		}
+		c.JWTLeeway = d
+	}
+
+	if envOIDCIssuer := os.Getenv("OIDC_ISSUER"); envOIDCIssuer != "" {
+		c.OIDCIssuer = envOIDCIssuer
+	}
+
+	if envOIDCClientID := os.Getenv("OIDC_CLIENT_ID"); envOIDCClientID != "" {
+		c.OIDCClientID = envOIDCClientID
+	}
+
+	if envOIDCClientSecret := os.Getenv("OIDC_CLIENT_SECRET"); envOIDCClientSecret != "" {
+		c.OIDCClientSecret = envOIDCClientSecret
+	}
+
+	if envOIDCRedirectURL := os.Getenv("OIDC_REDIRECT_URL"); envOIDCRedirectURL != "" {
+		c.OIDCRedirectURL = envOIDCRedirectURL
+	}
+
+	if envOIDCScopes := os.Getenv("OIDC_SCOPES"); envOIDCScopes != "" {
+		c.OIDCScopes = envOIDCScopes
+	}
+
+	if envAdminUsers := os.Getenv("ADMIN_USERS"); envAdminUsers != "" {
+		c.AdminUsers = envAdminUsers
+	}
+
+	if envAdminKeys := os.Getenv("ADMIN_KEYS"); envAdminKeys != "" {
+		c.AdminKeys = envAdminKeys
+	}
+
+	if envBlocklistRefresh := os.Getenv("BLOCKLIST_REFRESH"); envBlocklistRefresh != "" {
+		d, err := time.ParseDuration(envBlocklistRefresh)
+		if err != nil {
+			return fmt.Errorf("некорректное значение BLOCKLIST_REFRESH: %w", err)
+		}
+		c.BlocklistRefresh = d
+	}
+
+	if envHostPolicyFile := os.Getenv("HOST_POLICY_FILE"); envHostPolicyFile != "" {
+		c.HostPolicyFile = envHostPolicyFile
+	}
+
+	if envHostPolicyReload := os.Getenv("HOST_POLICY_RELOAD"); envHostPolicyReload != "" {
+		d, err := time.ParseDuration(envHostPolicyReload)
+		if err != nil {
+			return fmt.Errorf("некорректное значение HOST_POLICY_RELOAD: %w", err)
+		}
+		c.HostPolicyReload = d
+	}
+
+	if envNormalizeLowercase := os.Getenv("NORMALIZE_LOWERCASE"); envNormalizeLowercase != "" {
+		value, err := strconv.ParseBool(envNormalizeLowercase)
+		if err != nil {
+			return fmt.Errorf("некорректное значение NORMALIZE_LOWERCASE: %w", err)
+		}
+		c.NormalizeLowercase = value
+	}
+
+	if envNormalizeDefaultPort := os.Getenv("NORMALIZE_DEFAULT_PORT"); envNormalizeDefaultPort != "" {
+		value, err := strconv.ParseBool(envNormalizeDefaultPort)
+		if err != nil {
+			return fmt.Errorf("некорректное значение NORMALIZE_DEFAULT_PORT: %w", err)
+		}
+		c.NormalizeDefaultPort = value
+	}
+
+	if envNormalizeEncoding := os.Getenv("NORMALIZE_ENCODING"); envNormalizeEncoding != "" {
+		value, err := strconv.ParseBool(envNormalizeEncoding)
+		if err != nil {
+			return fmt.Errorf("некорректное значение NORMALIZE_ENCODING: %w", err)
+		}
+		c.NormalizeEncoding = value
+	}
+
+	if envNormalizeIDN := os.Getenv("NORMALIZE_IDN"); envNormalizeIDN != "" {
+		value, err := strconv.ParseBool(envNormalizeIDN)
+		if err != nil {
+			return fmt.Errorf("некорректное значение NORMALIZE_IDN: %w", err)
+		}
+		c.NormalizeIDN = value
+	}
+
+	if envNormalizeSortQuery := os.Getenv("NORMALIZE_SORT_QUERY"); envNormalizeSortQuery != "" {
+		value, err := strconv.ParseBool(envNormalizeSortQuery)
+		if err != nil {
+			return fmt.Errorf("некорректное значение NORMALIZE_SORT_QUERY: %w", err)
+		}
+		c.NormalizeSortQuery = value
+	}
+
+	if envNormalizeStripTracking := os.Getenv("NORMALIZE_STRIP_TRACKING"); envNormalizeStripTracking != "" {
+		value, err := strconv.ParseBool(envNormalizeStripTracking)
+		if err != nil {
+			return fmt.Errorf("некорректное значение NORMALIZE_STRIP_TRACKING: %w", err)
+		}
+		c.NormalizeStripTracking = value
+	}
+
+	if envTrackingParams, ok := os.LookupEnv("TRACKING_PARAMS"); ok {
+		c.TrackingParams = envTrackingParams
+	}
+
+	if envAllowedSchemes := os.Getenv("ALLOWED_SCHEMES"); envAllowedSchemes != "" {
+		c.AllowedSchemes = envAllowedSchemes
+	}
+
+	if envMaxURLLength := os.Getenv("MAX_URL_LENGTH"); envMaxURLLength != "" {
+		length, err := strconv.Atoi(envMaxURLLength)
+		if err != nil {
+			return fmt.Errorf("некорректное значение MAX_URL_LENGTH: %w", err)
+		}
+		c.MaxURLLength = length
+	}
+
+	if envAllowPrivateHosts := os.Getenv("ALLOW_PRIVATE_HOSTS"); envAllowPrivateHosts != "" {
+		allow, err := strconv.ParseBool(envAllowPrivateHosts)
+		if err != nil {
+			return fmt.Errorf("некорректное значение ALLOW_PRIVATE_HOSTS: %w", err)
+		}
+		c.AllowPrivateHosts = allow
+	}
+
+	if envReputationList := os.Getenv("REPUTATION_LIST"); envReputationList != "" {
+		c.ReputationList = envReputationList
+	}
+
+	if envReputationWebhook := os.Getenv("REPUTATION_WEBHOOK"); envReputationWebhook != "" {
+		c.ReputationWebhook = envReputationWebhook
+	}
+
+	if envReputationWebhookToken := os.Getenv("REPUTATION_WEBHOOK_TOKEN"); envReputationWebhookToken != "" {
+		c.ReputationWebhookToken = envReputationWebhookToken
+	}
+
+	if envReputationTimeout := os.Getenv("REPUTATION_TIMEOUT"); envReputationTimeout != "" {
+		d, err := time.ParseDuration(envReputationTimeout)
+		if err != nil {
+			return fmt.Errorf("некорректное значение REPUTATION_TIMEOUT: %w", err)
+		}
+		c.ReputationTimeout = d
+	}
+
+	if envReputationCacheTTL := os.Getenv("REPUTATION_CACHE_TTL"); envReputationCacheTTL != "" {
+		d, err := time.ParseDuration(envReputationCacheTTL)
+		if err != nil {
+			return fmt.Errorf("некорректное значение REPUTATION_CACHE_TTL: %w", err)
+		}
+		c.ReputationCacheTTL = d
+	}
+
+	if envReputationFailClosed := os.Getenv("REPUTATION_FAIL_CLOSED"); envReputationFailClosed != "" {
+		failClosed, err := strconv.ParseBool(envReputationFailClosed)
+		if err != nil {
+			return fmt.Errorf("некорректное значение REPUTATION_FAIL_CLOSED: %w", err)
+		}
+		c.ReputationFailClosed = failClosed
+	}
+
+	if envRateLimits, ok := os.LookupEnv("RATE_LIMITS"); ok {
+		c.RateLimits = envRateLimits
+	}
+
+	if envFailureLimits, ok := os.LookupEnv("FAILURE_LIMITS"); ok {
+		c.FailureLimits = envFailureLimits
+	}
+
+	if envRateLimitStore := os.Getenv("RATE_LIMIT_STORE"); envRateLimitStore != "" {
+		c.RateLimitStore = envRateLimitStore
+	}
+
+	if envTrustedProxies := os.Getenv("TRUSTED_PROXIES"); envTrustedProxies != "" {
+		c.TrustedProxies = envTrustedProxies
+	}
+
+	if envMaxLinksPerUser := os.Getenv("MAX_LINKS_PER_USER"); envMaxLinksPerUser != "" {
+		limit, err := strconv.Atoi(envMaxLinksPerUser)
+		if err != nil {
+			return fmt.Errorf("некорректное значение MAX_LINKS_PER_USER: %w", err)
+		}
+		c.MaxLinksPerUser = limit
+	}
+
+	if envMaxBatchSize := os.Getenv("MAX_BATCH_SIZE"); envMaxBatchSize != "" {
+		limit, err := strconv.Atoi(envMaxBatchSize)
+		if err != nil {
+			return fmt.Errorf("некорректное значение MAX_BATCH_SIZE: %w", err)
+		}
+		c.MaxBatchSize = limit
+	}
+
+	// локальный кэш не узнает об изменениях, сделанных другими экземплярами,
+	// а с репликами может закэшировать отстающее чтение
+	if c.CacheSize > 0 && (c.RedisAddr != "" || c.DatabaseReplicas != "") {
+		return errors.New("локальный кэш (-cache-size) работает только в одном экземпляре сервиса: при -redis-addr или -database-replica-dsns используйте общий кэш Redis")
+	}
+
+	return nil
+}
+
+// BearerEnabled сообщает, заданы ли ключи проверки Bearer-токенов
+func (c *Config) BearerEnabled() bool {
+	return c.JWTSecret != "" || c.JWTPublicKeysFile != ""
+}
+
+// OIDCEnabled сообщает, настроен ли вход через OIDC-провайдер
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuer != ""
+}
+
+// Параметры для всех бэкендов хранилища, имена совпадают с именами флагов
+func (c *Config) StorageParams() map[string]string {
+	return map[string]string{
+		"file-storage-path":          c.FileStoragePath,
+		"database-dsn":               c.DatabaseDSN,
+		"database-replica-dsns":      c.DatabaseReplicas,
+		"database-max-open-conns":    strconv.Itoa(c.DBMaxOpenConns),
+		"database-max-idle-conns":    strconv.Itoa(c.DBMaxIdleConns),
+		"database-conn-max-lifetime": c.DBConnMaxLifetime.String(),
+		"database-connect-timeout":   c.DBConnectTimeout.String(),
+		"database-retry-attempts":    strconv.Itoa(c.DBRetryAttempts),
+		"sqlite-path":                c.SQLitePath,
+		"redis-addr":                 c.RedisAddr,
+		"redis-password":             c.RedisPassword,
+		"redis-db":                   strconv.Itoa(c.RedisDB),
+		"redis-ttl":                  c.RedisTTL.String(),
+		"cache-negative-ttl":         c.CacheNegativeTTL.String(),
+	}
 }
 
 func (c *Config) String() string {
 	return fmt.Sprintf(
-		"ServerAddr: %s, \nBaseURLServer: %s, \nFileStoragePath: %s, \nDatabaseDSN: %s, \nMaxShortURLLength: %d, \nMaxShutdownTime: %d",
+		"ServerAddr: %s, \nBaseURLServer: %s, \nFileStoragePath: %s, \nDatabaseDSN: %s, \nSQLitePath: %s, \nStorageBackend: %s, \nCacheSize: %d, \nCacheTTL: %s, \nCacheNegativeTTL: %s, \nRedisAddr: %s, \nRedisDB: %d, \nRedisTTL: %s, \nAuthKeysFile: %s, \nJWTPublicKeysFile: %s, \nJWTIssuer: %s, \nJWTAudience: %s, \nOIDCIssuer: %s, \nOIDCClientID: %s, \nMaxShortURLLength: %d, \nMaxShutdownTime: %d",
 		c.ServerAddr,
 		c.BaseURLServer,
 		c.FileStoragePath,
 		c.DatabaseDSN,
+		c.SQLitePath,
+		c.StorageBackend,
+		c.CacheSize,
+		c.CacheTTL,
+		c.CacheNegativeTTL,
+		c.RedisAddr,
+		c.RedisDB,
+		c.RedisTTL,
+		c.AuthKeysFile,
+		c.JWTPublicKeysFile,
+		c.JWTIssuer,
+		c.JWTAudience,
+		c.OIDCIssuer,
+		c.OIDCClientID,
 		c.MaxShortURLLength,
 		c.MaxShutdownTime,
 	)
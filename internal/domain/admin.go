@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// Домен назначения, на который запрещено создавать ссылки и переходить.
+// Блокировка распространяется и на поддомены
+type DomainBlock struct {
+	Domain    string    `json:"domain" db:"domain"`
+	Reason    string    `json:"reason,omitempty" db:"reason"`
+	CreatedBy string    `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Ссылка вместе со сведениями о владельце для оператора
+type AdminLinkInfo struct {
+	URLLink
+	OwnerLogin string `json:"owner_login,omitempty"` // пусто у анонимных пользователей
+}
+
+// Общесистемные счетчики
+type SystemStats struct {
+	Links          int64 `json:"links" db:"links"`
+	DeletedLinks   int64 `json:"deleted_links" db:"deleted_links"`
+	LinkOwners     int64 `json:"link_owners" db:"link_owners"` // различные UserID, включая анонимных
+	Users          int64 `json:"users" db:"users"`
+	Workspaces     int64 `json:"workspaces" db:"workspaces"`
+	ActiveAPIKeys  int64 `json:"active_api_keys" db:"active_api_keys"`
+	BlockedDomains int64 `json:"blocked_domains" db:"blocked_domains"`
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "context"
+
+// Чтение данных для операторов в обход проверки владельца
+type AdminRepo interface {
+	// все ссылки автора, включая ссылки пространств и удаленные
+	FindUserLinks(ctx context.Context, userID string) ([]URLLink, error)
+	SystemStats(ctx context.Context) (SystemStats, error)
+}
+
+type DomainBlockRepo interface {
+	// добавляет домен или обновляет причину блокировки
+	BlockDomain(ctx context.Context, block DomainBlock) error
+	UnblockDomain(ctx context.Context, domain string) error
+	FindBlockedDomains(ctx context.Context) ([]DomainBlock, error)
+}
+
+type AuditRepo interface {
+	// сохраняет запись со следующим номером, связав ее с последней записью
+	// журнала через Seal, и возвращает ее
+	AppendAudit(ctx context.Context, entry AuditEntry) (AuditEntry, error)
+	// возвращает до filter.Limit подходящих записей по возрастанию номера
+	FindAuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// Права, которые выдаются API-ключам
+const (
+	ScopeLinksCreate = "links:create"
+	ScopeLinksRead   = "links:read"
+	ScopeLinksUpdate = "links:update"
+	ScopeLinksDelete = "links:delete"
+	ScopeStatsRead   = "stats:read"
+)
+
+// Все права, которые можно выдать ключу
+var APIKeyScopes = []string{ScopeLinksCreate, ScopeLinksRead, ScopeLinksUpdate, ScopeLinksDelete, ScopeStatsRead}
+
+// API-ключ машинного клиента. Сам ключ не хранится, только его хэш
+type APIKey struct {
+	ID        string     `json:"id" db:"id"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	Name      string     `json:"name" db:"name"`
+	Prefix    string     `json:"prefix" db:"prefix"` // начало ключа, чтобы пользователь мог его узнать
+	Hash      string     `json:"hash" db:"hash"`
+	Scopes    []string   `json:"scopes" db:"-"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
@@ -0,0 +1,13 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type APIKeyRepo interface {
+	StoreAPIKey(ctx context.Context, key APIKey) error
+	FindAPIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	FindAPIKeys(ctx context.Context, userID string) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id string, revokedAt time.Time) error
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// События, которые попадают в журнал аудита
+const (
+	AuditLinkCreate    = "link.create"
+	AuditLinkLookup    = "link.lookup"
+	AuditLinkEdit      = "link.edit"
+	AuditLinkDelete    = "link.delete"
+	AuditLinkRestore   = "link.restore"
+	AuditLinkPurge     = "link.purge"
+	AuditLinkMove      = "link.move"
+	AuditLinksClaim    = "user.claim"
+	AuditUserLinks     = "user.links"
+	AuditDomainBlock   = "domain.block"
+	AuditDomainUnblock = "domain.unblock"
+	AuditDomainList    = "domain.list"
+	AuditStatsView     = "stats.view"
+	AuditLogView       = "audit.view"
+	AuditLogVerify     = "audit.verify"
+)
+
+// Результат успешного действия в журнале аудита, иначе - текст ошибки
+const AuditResultOK = "ok"
+
+// Запись журнала аудита. Seq, PrevHash и Hash назначает хранилище,
+// записи не изменяются. Hash связывает запись с предыдущей, поэтому
+// изменение или удаление любой записи обнаруживается при проверке цепочки
+type AuditEntry struct {
+	Seq       int64     `json:"seq" db:"seq"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	Actor     string    `json:"actor" db:"actor"` // user:<id> или key:<имя ключа>
+	Action    string    `json:"action" db:"action"`
+	Target    string    `json:"target" db:"target"`
+	RequestID string    `json:"request_id,omitempty" db:"request_id"`
+	Before    string    `json:"before,omitempty" db:"before_value"` // JSON состояния до действия
+	After     string    `json:"after,omitempty" db:"after_value"`   // JSON состояния после действия
+	Details   string    `json:"details,omitempty" db:"details"`
+	Result    string    `json:"result" db:"result"`
+	PrevHash  string    `json:"prev_hash" db:"prev_hash"` // пусто у первой записи
+	Hash      string    `json:"hash" db:"hash"`
+}
+
+// ChainHash вычисляет хэш записи вместе с PrevHash. Время берется
+// с точностью до микросекунды - столько сохраняют все хранилища
+func (e AuditEntry) ChainHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		strconv.FormatInt(e.Seq, 10),
+		e.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		e.Actor,
+		e.Action,
+		e.Target,
+		e.RequestID,
+		e.Before,
+		e.After,
+		e.Details,
+		e.Result,
+		e.PrevHash,
+	} {
+		// длина перед значением не дает сдвинуть границу между полями
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Seal связывает запись с предыдущей и вычисляет ее хэш
+func (e AuditEntry) Seal(prevHash string) AuditEntry {
+	e.CreatedAt = e.CreatedAt.UTC().Truncate(time.Microsecond)
+	e.PrevHash = prevHash
+	e.Hash = e.ChainHash()
+	return e
+}
+
+// Отбор записей журнала. Пустые строки не ограничивают выборку
+type AuditFilter struct {
+	AfterSeq int64 // только записи с номером больше
+	Limit    int
+	Actor    string
+	Action   string
+	Target   string
+}
+
+// Результат проверки цепочки журнала аудита
+type AuditVerification struct {
+	Valid     bool   `json:"valid"`
+	Checked   int64  `json:"checked"`
+	HeadSeq   int64  `json:"head_seq"`            // последняя проверенная запись
+	HeadHash  string `json:"head_hash,omitempty"` // стоит сохранять вне сервиса, чтобы заметить подмену всей цепочки
+	BrokenSeq int64  `json:"broken_seq,omitempty"`
+	Problem   string `json:"problem,omitempty"`
+}
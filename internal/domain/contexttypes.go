@@ -1,3 +1,24 @@
 package domain
 
 type UserIDKey struct{}
+
+// Права запроса, прошедшего аутентификацию по API-ключу.
+// Для сессий (кука, JWT) значение в контексте отсутствует
+type ScopesKey struct{}
+
+// Идентификатор API-ключа, которым аутентифицирован запрос
+type APIKeyIDKey struct{}
+
+// Запросу без сессии только что выдана новая анонимная личность.
+// Такой идентификатор ничего не говорит о клиенте, например для ограничения частоты запросов
+type NewIdentityKey struct{}
+
+// Запрос аутентифицирован кукой анонимной личности, выданной сервисом,
+// а не сессией учетной записи, OIDC, JWT или API-ключом
+type AnonymousIdentityKey struct{}
+
+// Оператор, выполняющий запрос к административному API: user:<id> или key:<имя ключа>
+type AdminActorKey struct{}
+
+// Идентификатор запроса из заголовка X-Request-ID, попадает в журнал аудита
+type RequestIDKey struct{}
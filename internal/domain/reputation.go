@@ -0,0 +1,15 @@
+package domain
+
+import "context"
+
+// Заключение проверки репутации адреса назначения
+type ReputationVerdict struct {
+	Flagged bool   `json:"flagged"`          // адрес признан опасным
+	Reason  string `json:"reason,omitempty"` // категория угрозы, например malware или phishing
+}
+
+// Проверка адреса назначения по спискам опасных сайтов. Ошибка означает,
+// что проверить адрес не удалось; как поступить в этом случае, решает сервис
+type ReputationChecker interface {
+	Check(ctx context.Context, rawURL string) (ReputationVerdict, error)
+}
@@ -6,8 +6,58 @@ import (
 
 type URLLinkService interface {
 	CreateShortURL(ctx context.Context, link URLLink) (URLLink, error)
+	// создает ссылки пакетного запроса все или ни одной, квоты проверяются для всего пакета
+	CreateShortURLBatch(ctx context.Context, links []URLLink) ([]URLLink, error)
 	GetOriginalURL(ctx context.Context, link URLLink) (URLLink, error)
 	MarkURLsAsDeleted(ctx context.Context, links []URLLink) error
 	FindAll(ctx context.Context, userID string) ([]URLLink, error)
+	// меняет ссылку владельца, прежний адрес остается в истории версий
+	UpdateLink(ctx context.Context, userID, shortURL string, update URLLinkUpdate) (URLLink, error)
+	LinkVersions(ctx context.Context, userID, shortURL string) ([]LinkVersion, error)
+	// квоты пользователя и их использование
+	Quota(ctx context.Context, userID string) (QuotaUsage, error)
 	Ping(ctx context.Context) error
 }
+
+type APIKeyService interface {
+	// возвращает созданный ключ и его значение, которое больше нигде не сохраняется
+	CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (APIKey, string, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+	ResolveAPIKey(ctx context.Context, rawKey string) (APIKey, error)
+}
+
+type UserService interface {
+	Register(ctx context.Context, login, password string) (User, error)
+	Login(ctx context.Context, login, password string) (User, error)
+	// входит в учетную запись и переносит в нее ссылки анонимной личности
+	ClaimLinks(ctx context.Context, anonymousUserID, login, password string) (User, int64, error)
+}
+
+type WorkspaceService interface {
+	CreateWorkspace(ctx context.Context, userID, name string) (Workspace, error)
+	ListWorkspaces(ctx context.Context, userID string) ([]WorkspaceMembership, error)
+	ListMembers(ctx context.Context, actorID, workspaceID string) ([]WorkspaceMember, error)
+	SetMemberRole(ctx context.Context, actorID, workspaceID, userID string, role WorkspaceRole) error
+	RemoveMember(ctx context.Context, actorID, workspaceID, userID string) error
+	CreateLink(ctx context.Context, actorID, workspaceID, longURL string) (URLLink, error)
+	ListLinks(ctx context.Context, actorID, workspaceID string) ([]URLLink, error)
+	DeleteLinks(ctx context.Context, actorID, workspaceID string, shortURLs []string) error
+	// переносит ссылку в пространство или, при пустом toWorkspaceID, в личные ссылки actorID
+	MoveLink(ctx context.Context, actorID, shortURL, toWorkspaceID string) error
+}
+
+// Операции операторов; actor - кто их выполняет, для журнала аудита
+type AdminService interface {
+	LookupLink(ctx context.Context, actor, shortURL string) (AdminLinkInfo, error)
+	ListUserLinks(ctx context.Context, actor, userID string) ([]URLLink, error)
+	DeleteLink(ctx context.Context, actor, shortURL string) error
+	RestoreLink(ctx context.Context, actor, shortURL string) error
+	PurgeLink(ctx context.Context, actor, shortURL string) error
+	BlockDomain(ctx context.Context, actor, domainName, reason string) (DomainBlock, error)
+	UnblockDomain(ctx context.Context, actor, domainName string) error
+	ListBlockedDomains(ctx context.Context, actor string) ([]DomainBlock, error)
+	Stats(ctx context.Context, actor string) (SystemStats, error)
+	AuditEntries(ctx context.Context, actor string, filter AuditFilter) ([]AuditEntry, error)
+	VerifyAudit(ctx context.Context, actor string) (AuditVerification, error)
+}
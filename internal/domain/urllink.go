@@ -1,8 +1,54 @@
 package domain
 
+import "time"
+
+// Ссылка без WorkspaceID - личная ссылка пользователя UserID,
+// иначе UserID - ее автор, а управляют ею участники пространства
 type URLLink struct {
 	UserID      string `json:"user_id" db:"user_id"`
+	WorkspaceID string `json:"workspace_id,omitempty" db:"workspace_id"`
 	ShortURL    string `json:"short_url" db:"short_url"`
 	LongURL     string `json:"original_url" db:"original_url"`
 	DeletedFlag bool   `json:"is_deleted" db:"is_deleted"`
+	// адрес назначения признан опасным проверкой репутации, переход запрещен
+	Quarantined bool `json:"is_quarantined,omitempty" db:"is_quarantined"`
+	// после MaxClicks успешных переходов ссылка считается удаленной, 0 - без ограничения
+	MaxClicks       int `json:"max_clicks,omitempty" db:"max_clicks"`
+	ClicksRemaining int `json:"clicks_remaining,omitempty" db:"clicks_remaining"`
+	// argon2id-хэш пароля, без которого переход не выполняется, пусто - пароля нет.
+	// В ответы API и журнал аудита не попадает, хранилища сохраняют его явно
+	PasswordHash string `json:"-" db:"password_hash"`
+	// пароль в открытом виде: задается при создании ссылки и проверяется
+	// при переходе, сам не сохраняется
+	Password string `json:"-" db:"-"`
+}
+
+// Restricted сообщает, ограничен ли доступ к ссылке паролем или числом
+// переходов. Такая ссылка всегда создается заново и не выдается повторно
+// при сокращении того же адреса
+func (l URLLink) Restricted() bool {
+	return l.PasswordHash != "" || l.MaxClicks > 0
+}
+
+// Изменение ссылки владельцем. nil - поле остается прежним
+type URLLinkUpdate struct {
+	LongURL  *string
+	Password *string // пустая строка снимает пароль
+}
+
+// Прежнее состояние ссылки, замененное при изменении.
+// Версии нумеруются с 1, текущее состояние имеет номер len(версий)+1
+type LinkVersion struct {
+	ShortURL   string    `json:"-" db:"short_url"`
+	Version    int       `json:"version" db:"version"`
+	LongURL    string    `json:"original_url" db:"original_url"`
+	ReplacedBy string    `json:"replaced_by" db:"replaced_by"`
+	ReplacedAt time.Time `json:"replaced_at" db:"replaced_at"`
+}
+
+// Квоты пользователя и их использование, 0 в пределе - без ограничения
+type QuotaUsage struct {
+	MaxLinks     int `json:"max_links"`
+	ActiveLinks  int `json:"active_links"`
+	MaxBatchSize int `json:"max_batch_size"`
 }
@@ -4,9 +4,45 @@ import "context"
 
 type URLLinkRepo interface {
 	Store(ctx context.Context, urlLink URLLink) (URLLink, error)
+	// сохраняет ссылки одного пользователя в одной транзакции: все или ни одной.
+	// Ссылка без ограничения доступа на уже сокращенный адрес заменяется
+	// существующей ссылкой без ограничений, Restricted-ссылка всегда сохраняется
+	// новой. При maxActive > 0
+	// вместе со вставкой проверяет, что у пользователя останется не больше
+	// maxActive неудаленных ссылок, иначе ErrorLinkQuotaExceeded
+	StoreBatch(ctx context.Context, links []URLLink, maxActive int) ([]URLLink, error)
 	Find(ctx context.Context, shortURL string) (URLLink, error)
+	// читает ссылку в обход кэшей и реплик. Нужен для проверки прав перед
+	// изменением ссылки, где устаревшее состояние недопустимо
+	FindConsistent(ctx context.Context, shortURL string) (URLLink, error)
+	// возвращает личные ссылки пользователя, без ссылок рабочих пространств
 	FindAll(ctx context.Context, userID string) ([]URLLink, error)
+	FindAllInWorkspace(ctx context.Context, workspaceID string) ([]URLLink, error)
+	// ссылка с WorkspaceID удаляется по пространству, без него - по пользователю
 	MarkDeletedBatch(ctx context.Context, links []URLLink) error
+	// меняет владельца ссылки from.ShortURL; пустой workspaceID делает ее личной
+	// ссылкой userID. Ссылка переносится, только если она не удалена и все еще
+	// принадлежит from.UserID и from.WorkspaceID, иначе ErrorShortLinkNotFound
+	MoveLink(ctx context.Context, from URLLink, userID, workspaceID string) error
+	// переносит все ссылки пользователя fromUserID к toUserID и возвращает их число.
+	// При maxActive > 0 переносит, только если у toUserID останется не больше
+	// maxActive неудаленных ссылок, иначе ErrorLinkQuotaExceeded
+	ReassignLinks(ctx context.Context, fromUserID, toUserID string, maxActive int) (int64, error)
+	// ставит или снимает пометку удаления без проверки владельца
+	SetDeleted(ctx context.Context, shortURL string, deleted bool) error
+	// удаляет ссылку безвозвратно вместе с историей версий
+	PurgeLink(ctx context.Context, shortURL string) error
+	// сохраняет изменяемые поля link (адрес назначения), а прежнее состояние
+	// добавляет в историю версий от имени editedBy. Если адрес уже сокращен
+	// другой ссылкой - ErrorShortLinkAlreadyInDB
+	Update(ctx context.Context, link URLLink, editedBy string) error
+	// прежние версии ссылки по возрастанию номера
+	FindVersions(ctx context.Context, shortURL string) ([]LinkVersion, error)
+	// число неудаленных ссылок, созданных пользователем, включая ссылки рабочих пространств
+	CountActiveLinks(ctx context.Context, userID string) (int, error)
+	// атомарно уменьшает число оставшихся переходов по ссылке и возвращает
+	// новое значение. Если переходов не осталось - ErrorClicksExhausted
+	ConsumeClick(ctx context.Context, shortURL string) (int, error)
 	Ping(context.Context) error
 	Close() error
 }
@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// Учетная запись пользователя. ID совпадает с UserID его ссылок
+type User struct {
+	ID           string    `json:"id" db:"id"`
+	Login        string    `json:"login" db:"login"`
+	PasswordHash string    `json:"password_hash" db:"password_hash"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,10 @@
+package domain
+
+import "context"
+
+type UserRepo interface {
+	StoreUser(ctx context.Context, user User) error
+	UpdatePasswordHash(ctx context.Context, userID, passwordHash string) error
+	FindUserByLogin(ctx context.Context, login string) (User, error)
+	FindUserByID(ctx context.Context, id string) (User, error)
+}
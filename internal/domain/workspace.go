@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+// Роль участника рабочего пространства
+type WorkspaceRole string
+
+const (
+	RoleOwner  WorkspaceRole = "owner"  // управляет участниками и ссылками, выносит ссылки из пространства
+	RoleEditor WorkspaceRole = "editor" // создает, удаляет и переносит ссылки в пространство
+	RoleViewer WorkspaceRole = "viewer" // только просматривает ссылки
+)
+
+func (r WorkspaceRole) Valid() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+func (r WorkspaceRole) CanEditLinks() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+func (r WorkspaceRole) CanManageMembers() bool {
+	return r == RoleOwner
+}
+
+// вынесенная ссылка перестает быть общей, поэтому это решает владелец
+func (r WorkspaceRole) CanMoveLinksOut() bool {
+	return r == RoleOwner
+}
+
+// Рабочее пространство команды. Ссылки с WorkspaceID принадлежат ему,
+// а не создавшему их пользователю
+type Workspace struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID string        `json:"workspace_id" db:"workspace_id"`
+	UserID      string        `json:"user_id" db:"user_id"`
+	Role        WorkspaceRole `json:"role" db:"role"`
+	AddedAt     time.Time     `json:"added_at" db:"added_at"`
+}
+
+// Рабочее пространство вместе с ролью в нем конкретного пользователя
+type WorkspaceMembership struct {
+	Workspace
+	Role WorkspaceRole `json:"role" db:"role"`
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "context"
+
+type WorkspaceRepo interface {
+	// создает рабочее пространство вместе с его первым владельцем
+	StoreWorkspace(ctx context.Context, workspace Workspace, owner WorkspaceMember) error
+	FindWorkspace(ctx context.Context, id string) (Workspace, error)
+	// возвращает пространства пользователя в порядке создания
+	FindUserWorkspaces(ctx context.Context, userID string) ([]WorkspaceMembership, error)
+	FindMember(ctx context.Context, workspaceID, userID string) (WorkspaceMember, error)
+	FindMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error)
+	// добавляет участника или меняет его роль
+	SaveMember(ctx context.Context, member WorkspaceMember) error
+	RemoveMember(ctx context.Context, workspaceID, userID string) error
+}
@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/service"
+	"github.com/rs/zerolog"
+)
+
+type blockDomainRequest struct {
+	Domain string `json:"domain"`
+	Reason string `json:"reason"`
+}
+
+// Ручки административного API. Оператор берется из контекста,
+// его кладет мидлварь authenticator.RequireAdmin
+type AdminHandler struct {
+	service domain.AdminService
+	log     zerolog.Logger
+}
+
+func NewAdminHandler(service domain.AdminService, logger zerolog.Logger) *AdminHandler {
+	return &AdminHandler{
+		service: service,
+		log:     logger,
+	}
+}
+
+func (h *AdminHandler) HandleLookupLink(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value(domain.AdminActorKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	info, err := h.service.LookupLink(ctx, actor, chi.URLParam(r, "shortURL"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (h *AdminHandler) HandleListUserLinks(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value(domain.AdminActorKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	links, err := h.service.ListUserLinks(ctx, actor, chi.URLParam(r, "userID"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if links == nil {
+		links = []domain.URLLink{}
+	}
+	writeJSON(w, http.StatusOK, links)
+}
+
+// HandleDeleteLink помечает ссылку удаленной, ее можно восстановить
+func (h *AdminHandler) HandleDeleteLink(w http.ResponseWriter, r *http.Request) {
+	h.linkAction(w, r, h.service.DeleteLink)
+}
+
+func (h *AdminHandler) HandleRestoreLink(w http.ResponseWriter, r *http.Request) {
+	h.linkAction(w, r, h.service.RestoreLink)
+}
+
+// HandlePurgeLink удаляет ссылку безвозвратно
+func (h *AdminHandler) HandlePurgeLink(w http.ResponseWriter, r *http.Request) {
+	h.linkAction(w, r, h.service.PurgeLink)
+}
+
+func (h *AdminHandler) linkAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, actor, shortURL string) error) {
+	actor := r.Context().Value(domain.AdminActorKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	if err := action(ctx, actor, chi.URLParam(r, "shortURL")); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) HandleListBlockedDomains(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value(domain.AdminActorKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	blocks, err := h.service.ListBlockedDomains(ctx, actor)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if blocks == nil {
+		blocks = []domain.DomainBlock{}
+	}
+	writeJSON(w, http.StatusOK, blocks)
+}
+
+func (h *AdminHandler) HandleBlockDomain(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value(domain.AdminActorKey{}).(string)
+
+	var req blockDomainRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	block, err := h.service.BlockDomain(ctx, actor, req.Domain, req.Reason)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, block)
+}
+
+func (h *AdminHandler) HandleUnblockDomain(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value(domain.AdminActorKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	if err := h.service.UnblockDomain(ctx, actor, chi.URLParam(r, "domain")); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value(domain.AdminActorKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	stats, err := h.service.Stats(ctx, actor)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// HandleAuditEntries отдает страницу журнала аудита. Параметры запроса:
+// after - номер, после которого читать, limit, actor, action и target
+func (h *AdminHandler) HandleAuditEntries(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value(domain.AdminActorKey{}).(string)
+
+	query := r.URL.Query()
+	filter := domain.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+	}
+	var err error
+	if after := query.Get("after"); after != "" {
+		if filter.AfterSeq, err = strconv.ParseInt(after, 10, 64); err != nil {
+			http.Error(w, "некорректный параметр after", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, "некорректный параметр limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	entries, err := h.service.AuditEntries(ctx, actor, filter)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if entries == nil {
+		entries = []domain.AuditEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// HandleVerifyAudit проверяет цепочку хэшей журнала. Нарушенная цепочка -
+// не ошибка запроса: ответ 200 с valid=false и номером первой испорченной записи
+func (h *AdminHandler) HandleVerifyAudit(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value(domain.AdminActorKey{}).(string)
+
+	// журнал читается целиком, обычного таймаута запроса может не хватить
+	result, err := h.service.VerifyAudit(r.Context(), actor)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// Без записи в журнале аудита действие считается неуспешным, даже если оно выполнено
+func (h *AdminHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrAuditFailed):
+		h.log.Error().Err(err).Msg("Действие оператора не записано в журнал аудита")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	case errors.Is(err, service.ErrLinkNotFound), errors.Is(err, service.ErrDomainNotBlocked):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidDomain):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.log.Error().Err(err).Msg("Ошибка административного API")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
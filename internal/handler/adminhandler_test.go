@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/golang/mock/gomock"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/mocks"
+	"github.com/physicist2018/url-shortener-go/internal/service"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAdminTestRouter(h *AdminHandler) *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), domain.AdminActorKey{}, "key:ops")
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	router.Get("/api/admin/links/{shortURL}", h.HandleLookupLink)
+	router.Delete("/api/admin/links/{shortURL}", h.HandleDeleteLink)
+	router.Post("/api/admin/links/{shortURL}/purge", h.HandlePurgeLink)
+	router.Get("/api/admin/users/{userID}/links", h.HandleListUserLinks)
+	router.Post("/api/admin/blocked-domains", h.HandleBlockDomain)
+	router.Delete("/api/admin/blocked-domains/{domain}", h.HandleUnblockDomain)
+	router.Get("/api/admin/audit", h.HandleAuditEntries)
+	router.Get("/api/admin/audit/verify", h.HandleVerifyAudit)
+	return router
+}
+
+func TestHandleLookupLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockAdminService(ctrl)
+	router := newAdminTestRouter(NewAdminHandler(mockService, zerolog.New(nil)))
+
+	mockService.EXPECT().LookupLink(gomock.Any(), "key:ops", "abc12").Return(domain.AdminLinkInfo{
+		URLLink:    domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"},
+		OwnerLogin: "alice",
+	}, nil)
+	mockService.EXPECT().LookupLink(gomock.Any(), "key:ops", "missing").Return(domain.AdminLinkInfo{}, service.ErrLinkNotFound)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/links/abc12", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":"u1","short_url":"abc12","original_url":"https://example.com","is_deleted":false,"owner_login":"alice"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/links/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandleAdminLinkActions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockAdminService(ctrl)
+	router := newAdminTestRouter(NewAdminHandler(mockService, zerolog.New(nil)))
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"Success", nil, http.StatusNoContent},
+		{"Not found", service.ErrLinkNotFound, http.StatusNotFound},
+		// ссылка удалена, но след не записан - оператор должен узнать о сбое
+		{"Audit failure", errors.Join(service.ErrAuditFailed, errors.New("disk full")), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService.EXPECT().DeleteLink(gomock.Any(), "key:ops", "abc12").Return(tt.err)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/admin/links/abc12", nil))
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+
+	mockService.EXPECT().PurgeLink(gomock.Any(), "key:ops", "abc12").Return(nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/links/abc12/purge", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	mockService.EXPECT().ListUserLinks(gomock.Any(), "key:ops", "u1").Return(nil, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/users/u1/links", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
+
+func TestHandleBlockDomain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockAdminService(ctrl)
+	router := newAdminTestRouter(NewAdminHandler(mockService, zerolog.New(nil)))
+
+	mockService.EXPECT().BlockDomain(gomock.Any(), "key:ops", "Evil.Example", "phishing").
+		Return(domain.DomainBlock{Domain: "evil.example", Reason: "phishing", CreatedBy: "key:ops"}, nil)
+	mockService.EXPECT().BlockDomain(gomock.Any(), "key:ops", "bad/domain", "").
+		Return(domain.DomainBlock{}, service.ErrInvalidDomain)
+	mockService.EXPECT().UnblockDomain(gomock.Any(), "key:ops", "evil.example").Return(service.ErrDomainNotBlocked)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, jsonRequest(http.MethodPost, "/api/admin/blocked-domains", `{"domain":"Evil.Example","reason":"phishing"}`))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"domain":"evil.example"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, jsonRequest(http.MethodPost, "/api/admin/blocked-domains", `{"domain":"bad/domain"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/admin/blocked-domains/evil.example", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandleAuditEntries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockAdminService(ctrl)
+	router := newAdminTestRouter(NewAdminHandler(mockService, zerolog.New(nil)))
+
+	mockService.EXPECT().
+		AuditEntries(gomock.Any(), "key:ops", domain.AuditFilter{AfterSeq: 10, Limit: 5, Action: domain.AuditLinkCreate, Target: "abc12"}).
+		Return([]domain.AuditEntry{{Seq: 11, Actor: "user:u1", Action: domain.AuditLinkCreate, Target: "abc12", Result: domain.AuditResultOK, Hash: "h11"}}, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/audit?after=10&limit=5&action=link.create&target=abc12", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"seq":11`)
+	assert.Contains(t, w.Body.String(), `"hash":"h11"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/audit?after=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.EXPECT().VerifyAudit(gomock.Any(), "key:ops").
+		Return(domain.AuditVerification{Checked: 4, HeadSeq: 4, BrokenSeq: 5, Problem: "хэш не совпадает с содержимым записи"}, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/audit/verify", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"valid":false`)
+	assert.Contains(t, w.Body.String(), `"broken_seq":5`)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/service"
+	"github.com/rs/zerolog"
+)
+
+type (
+	createAPIKeyRequest struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+
+	apiKeyResponse struct {
+		ID        string     `json:"id"`
+		Name      string     `json:"name"`
+		Prefix    string     `json:"prefix"`
+		Scopes    []string   `json:"scopes"`
+		CreatedAt time.Time  `json:"created_at"`
+		RevokedAt *time.Time `json:"revoked_at,omitempty"`
+		Key       string     `json:"key,omitempty"` // только в ответе на создание
+	}
+)
+
+// Ручки управления API-ключами текущего пользователя
+type APIKeyHandler struct {
+	service domain.APIKeyService
+	log     zerolog.Logger
+}
+
+func NewAPIKeyHandler(service domain.APIKeyService, logger zerolog.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		service: service,
+		log:     logger,
+	}
+}
+
+func (h *APIKeyHandler) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "Content-Type должен быть application/json", http.StatusBadRequest)
+		return
+	}
+
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	key, rawKey, err := h.service.CreateAPIKey(ctx, userID, req.Name, req.Scopes)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidScope),
+			errors.Is(err, service.ErrInvalidKeyName),
+			errors.Is(err, service.ErrNoScopesGranted):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.log.Error().Err(err).Msg("Ошибка создания API-ключа")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := toAPIKeyResponse(key)
+	resp.Key = rawKey
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (h *APIKeyHandler) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	keys, err := h.service.ListAPIKeys(ctx, userID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Ошибка получения API-ключей")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]apiKeyResponse, len(keys))
+	for i, k := range keys {
+		resp[i] = toAPIKeyResponse(k)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *APIKeyHandler) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	err := h.service.RevokeAPIKey(ctx, userID, chi.URLParam(r, "id"))
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	default:
+		h.log.Error().Err(err).Msg("Ошибка отзыва API-ключа")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func toAPIKeyResponse(k domain.APIKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:        k.ID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt,
+		RevokedAt: k.RevokedAt,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/golang/mock/gomock"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/mocks"
+	"github.com/physicist2018/url-shortener-go/internal/service"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withUser(r *http.Request, userID string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), domain.UserIDKey{}, userID))
+}
+
+func TestHandleCreateAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockAPIKeyService(ctrl)
+	h := NewAPIKeyHandler(mockService, zerolog.New(nil))
+
+	t.Run("Success", func(t *testing.T) {
+		created := domain.APIKey{
+			ID:        "key-1",
+			UserID:    "user-1",
+			Name:      "ci",
+			Prefix:    "sk_abcdefgh",
+			Hash:      "secret-hash",
+			Scopes:    []string{domain.ScopeLinksCreate},
+			CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		mockService.EXPECT().
+			CreateAPIKey(gomock.Any(), "user-1", "ci", []string{domain.ScopeLinksCreate}).
+			Return(created, "sk_abcdefghrest", nil)
+
+		body, _ := json.Marshal(createAPIKeyRequest{Name: "ci", Scopes: []string{domain.ScopeLinksCreate}})
+		r := httptest.NewRequest(http.MethodPost, "/api/user/keys", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		h.HandleCreateAPIKey(w, withUser(r, "user-1"))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var resp apiKeyResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, "key-1", resp.ID)
+		assert.Equal(t, "sk_abcdefghrest", resp.Key)
+		assert.NotContains(t, w.Body.String(), "secret-hash")
+	})
+
+	t.Run("Invalid scope", func(t *testing.T) {
+		mockService.EXPECT().
+			CreateAPIKey(gomock.Any(), "user-1", "", []string{"admin"}).
+			Return(domain.APIKey{}, "", service.ErrInvalidScope)
+
+		body, _ := json.Marshal(createAPIKeyRequest{Scopes: []string{"admin"}})
+		r := httptest.NewRequest(http.MethodPost, "/api/user/keys", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		h.HandleCreateAPIKey(w, withUser(r, "user-1"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandleListAPIKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockAPIKeyService(ctrl)
+	h := NewAPIKeyHandler(mockService, zerolog.New(nil))
+
+	mockService.EXPECT().
+		ListAPIKeys(gomock.Any(), "user-1").
+		Return([]domain.APIKey{{ID: "key-1", Hash: "secret-hash", Scopes: []string{domain.ScopeLinksRead}}}, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/user/keys", nil)
+	w := httptest.NewRecorder()
+	h.HandleListAPIKeys(w, withUser(r, "user-1"))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp []apiKeyResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp, 1)
+	assert.Empty(t, resp[0].Key)
+	assert.NotContains(t, w.Body.String(), "secret-hash")
+}
+
+func TestHandleRevokeAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockAPIKeyService(ctrl)
+	h := NewAPIKeyHandler(mockService, zerolog.New(nil))
+
+	router := chi.NewRouter()
+	router.Delete("/api/user/keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		h.HandleRevokeAPIKey(w, withUser(r, "user-1"))
+	})
+
+	mockService.EXPECT().RevokeAPIKey(gomock.Any(), "user-1", "key-1").Return(nil)
+	mockService.EXPECT().RevokeAPIKey(gomock.Any(), "user-1", "missing").Return(service.ErrAPIKeyNotFound)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/user/keys/key-1", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/user/keys/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"errors"
+	"html/template"
 	"io"
 	"net/http"
 	"net/url"
@@ -11,16 +12,62 @@ import (
 
 	"github.com/physicist2018/url-shortener-go/internal/deleter"
 	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/ratelimit"
 	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/internal/service"
 	"github.com/rs/zerolog"
 )
 
+var blockedPage = template.Must(template.New("blocked").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Ссылка заблокирована</title>
+</head>
+<body>
+<h1>Ссылка заблокирована</h1>
+{{if .Quarantined -}}
+<p>Короткая ссылка {{.ShortURL}} ведет на {{if .Host}}домен {{.Host}}{{else}}адрес{{end}}, который проверка безопасности признала опасным. Ссылка помещена в карантин.</p>
+{{- else -}}
+<p>Короткая ссылка {{.ShortURL}} ведет на {{if .Host}}домен {{.Host}}{{else}}адрес{{end}}, переход на который запрещен правилами сервиса.</p>
+{{- end}}
+<p>Если вы получили эту ссылку в письме или сообщении, не вводите на связанных с ней сайтах пароли и платежные данные.</p>
+</body>
+</html>
+`))
+
+var passwordPage = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Ссылка защищена паролем</title>
+</head>
+<body>
+<h1>Ссылка защищена паролем</h1>
+<p>Чтобы перейти по короткой ссылке {{.ShortURL}}, введите пароль.</p>
+{{if .Wrong}}<p>Неверный пароль, попробуйте еще раз.</p>
+{{end -}}
+<form method="post">
+<input type="password" name="password" autocomplete="current-password" required autofocus>
+<button type="submit">Перейти</button>
+</form>
+</body>
+</html>
+`))
+
+// Заголовок, в котором API-клиенты передают пароль защищенной ссылки
+const LinkPasswordHeader = "X-Link-Password"
+
 const (
 	RequestResponseTimeout = 5 * time.Second
 	PingTimeout            = 3 * time.Second
 	MaxQueueCapacity       = 100
 	processingInterval     = 5 * time.Second
 	batchSize              = 10
+	// больше форме ввода пароля не нужно
+	maxPasswordFormSize = 4 << 10
 )
 
 type URLLinkHandler struct {
@@ -59,15 +106,12 @@ func (h *URLLinkHandler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 
 	longURL := string(longURLBytes)
 	longURL = strings.TrimSpace(longURL)
-	_, err = url.ParseRequestURI(longURL)
-	if err != nil {
-		h.log.Error().Msg("Некорректный URL")
-		http.Error(w, "Некорректный URL", http.StatusBadRequest)
-		return
-	}
 
 	urllink, err := h.service.CreateShortURL(ctx, domain.URLLink{LongURL: longURL, UserID: userID})
 
+	if writeRejectedURL(w, err) || writeQuotaExceeded(w, err) {
+		return
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, repoerrors.ErrorShortLinkAlreadyInDB):
@@ -75,6 +119,9 @@ func (h *URLLinkHandler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusConflict)
 			w.Write([]byte(fullURL))
 
+		case errors.Is(err, service.ErrDomainBlocked):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
 		case errors.Is(err, repoerrors.ErrorSQLInternal):
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
@@ -97,27 +144,134 @@ func (h *URLLinkHandler) Redirect(w http.ResponseWriter, r *http.Request) {
 
 	path := r.URL.Path
 	shortURL := strings.TrimPrefix(path, "/")
-	urllink, err := h.service.GetOriginalURL(ctx, domain.URLLink{ShortURL: shortURL})
+	password := r.Header.Get(LinkPasswordHeader)
+	if password == "" && r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, maxPasswordFormSize)
+		password = r.PostFormValue("password")
+	}
+	// попытка пароля расходует лимит до проверки, а верный пароль его возвращает
+	finish := func(bool) {}
+	if password != "" {
+		var retryAfter time.Duration
+		var ok bool
+		if finish, retryAfter, ok = ratelimit.Attempt(r, shortURL); !ok {
+			ratelimit.TooManyRequests(w, retryAfter)
+			return
+		}
+	}
+	urllink, err := h.service.GetOriginalURL(ctx, domain.URLLink{ShortURL: shortURL, Password: password})
+	finish(errors.Is(err, service.ErrWrongPassword))
 
-	if err != nil {
+	if errors.Is(err, service.ErrPasswordCheckBusy) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, service.ErrPasswordCheckBusy.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, service.ErrPasswordRequired) || errors.Is(err, service.ErrWrongPassword) {
+		h.writePasswordPrompt(w, r, shortURL, err)
+		return
+	}
+	if errors.Is(err, service.ErrDomainBlocked) {
+		h.writeBlockedPage(w, shortURL, urllink.LongURL, false)
+		return
+	}
+	if errors.Is(err, service.ErrLinkQuarantined) {
+		h.writeBlockedPage(w, shortURL, urllink.LongURL, true)
+		return
+	}
+	if err != nil && !errors.Is(err, service.ErrLinkExhausted) {
 
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 		return
 	}
 
-	if urllink.DeletedFlag {
+	if urllink.DeletedFlag || errors.Is(err, service.ErrLinkExhausted) {
 		http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
 		return
 	}
 
+	status := http.StatusTemporaryRedirect
+	if urllink.PasswordHash != "" {
+		// переход доступен только знающим пароль, кэшировать его нельзя
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method == http.MethodPost {
+			// после формы браузер должен перейти GET-запросом, не отправляя пароль дальше
+			status = http.StatusSeeOther
+		}
+	}
 	w.Header().Set("Location", urllink.LongURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	w.WriteHeader(status)
 	h.log.Info().
 		Str("shortURL", shortURL).
 		Str("longURL", urllink.LongURL).
 		Msg("Перенаправление выполнено успешно")
 }
 
+// writeBlockedPage показывает вместо перехода страницу-предупреждение:
+// 451 для заблокированного домена, 403 для ссылки в карантине.
+// Адрес назначения не выводится целиком и не делается ссылкой,
+// чтобы страница сама не вела на фишинговый сайт
+func (h *URLLinkHandler) writeBlockedPage(w http.ResponseWriter, shortURL, longURL string, quarantined bool) {
+	var host string
+	if parsed, err := url.Parse(longURL); err == nil {
+		host = parsed.Hostname()
+	}
+	status := http.StatusUnavailableForLegalReasons
+	if quarantined {
+		status = http.StatusForbidden
+		h.log.Info().Str("shortURL", shortURL).Str("host", host).Msg("Переход по ссылке в карантине")
+	} else {
+		h.log.Info().Str("shortURL", shortURL).Str("host", host).Msg("Переход по ссылке на заблокированный домен")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	blockedPage.Execute(w, struct {
+		ShortURL, Host string
+		Quarantined    bool
+	}{shortURL, host, quarantined})
+}
+
+// writePasswordPrompt отвечает на переход по защищенной ссылке без верного
+// пароля: 401, если пароль не передан, 403, если он неверный. API-клиент,
+// передавший пароль в заголовке, получает текст ошибки, браузер - форму ввода пароля
+func (h *URLLinkHandler) writePasswordPrompt(w http.ResponseWriter, r *http.Request, shortURL string, err error) {
+	wrong := errors.Is(err, service.ErrWrongPassword)
+	status := http.StatusUnauthorized
+	if wrong {
+		status = http.StatusForbidden
+		h.log.Info().Str("shortURL", shortURL).Msg("Неверный пароль ссылки")
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Header.Get(LinkPasswordHeader) != "" {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	passwordPage.Execute(w, struct {
+		ShortURL string
+		Wrong    bool
+	}{shortURL, wrong})
+}
+
+// HandleGetQuotaJSON возвращает квоты пользователя и их использование
+func (h *URLLinkHandler) HandleGetQuotaJSON(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	usage, err := h.service.Quota(ctx, userID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("Ошибка подсчета использования квот")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
 func (h *URLLinkHandler) PingHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), PingTimeout)
 	defer cancel()
@@ -140,3 +294,43 @@ func (h *URLLinkHandler) Close() {
 		h.deleter.Close()
 	}
 }
+
+// writeRejectedURL отвечает на отказ в адресе назначения одинаково во всех
+// ручках: 400 с причиной отказа или 503, если не удалось проверить репутацию
+// адреса или заняты все слоты хэширования пароля. Возвращает false, если ошибка другая
+func writeRejectedURL(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, service.ErrReputationUnavailable) {
+		http.Error(w, service.ErrReputationUnavailable.Error(), http.StatusServiceUnavailable)
+		return true
+	}
+	if errors.Is(err, service.ErrPasswordCheckBusy) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, service.ErrPasswordCheckBusy.Error(), http.StatusServiceUnavailable)
+		return true
+	}
+	var invalid *service.InvalidURLError
+	if !errors.As(err, &invalid) {
+		return false
+	}
+	http.Error(w, invalid.Error(), http.StatusBadRequest)
+	return true
+}
+
+// writeQuotaExceeded отвечает на превышение квоты: 403 для числа ссылок,
+// 413 для размера пакета. В теле - предел и текущее использование.
+// Возвращает false, если ошибка другая
+func writeQuotaExceeded(w http.ResponseWriter, err error) bool {
+	var exceeded *service.QuotaError
+	if !errors.As(err, &exceeded) {
+		return false
+	}
+	status := http.StatusForbidden
+	if exceeded.Quota == service.QuotaBatch {
+		status = http.StatusRequestEntityTooLarge
+	}
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+		*service.QuotaError
+	}{exceeded.Error(), exceeded})
+	return true
+}
@@ -3,8 +3,11 @@ package handler
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 
@@ -16,6 +19,7 @@ import (
 	"github.com/physicist2018/url-shortener-go/internal/domain"
 	"github.com/physicist2018/url-shortener-go/internal/mocks"
 	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/internal/service"
 )
 
 func TestShortenURL_Success(t *testing.T) {
@@ -187,3 +191,169 @@ func TestShortenURL_InternalServerError(t *testing.T) {
 	h.Close()
 	wg.Wait()
 }
+
+// Отказ в адресе назначения выглядит одинаково во всех ручках создания ссылки
+func TestInvalidURLResponse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	h := NewURLLinkHandler(mockService, "http://localhost", zerolog.New(nil), nil)
+	invalid := &service.InvalidURLError{Reason: fmt.Errorf("%w: javascript", service.ErrURLScheme)}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		request *http.Request
+		batch   bool
+	}{
+		{"Plain", h.ShortenURL, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("javascript:alert(1)")), false},
+		{"JSON", h.HandleGenerateShortURLJson, jsonRequest(http.MethodPost, "/api/shorten", `{"url":"javascript:alert(1)"}`), false},
+		{"Batch", h.HandleGenerateShortURLJsonBatch, jsonRequest(http.MethodPost, "/api/shorten/batch", `[{"correlation_id":"1","original_url":"javascript:alert(1)"}]`), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.batch {
+				mockService.EXPECT().CreateShortURLBatch(gomock.Any(), gomock.Any()).Return(nil, invalid)
+			} else {
+				mockService.EXPECT().CreateShortURL(gomock.Any(), gomock.Any()).Return(domain.URLLink{}, invalid)
+			}
+
+			w := httptest.NewRecorder()
+			tt.handler(w, withUser(tt.request, "u1"))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, invalid.Error()+"\n", w.Body.String())
+		})
+	}
+}
+
+func TestRedirect_BlockedDomain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	h := NewURLLinkHandler(mockService, "http://localhost", zerolog.New(nil), nil)
+
+	mockService.EXPECT().GetOriginalURL(gomock.Any(), domain.URLLink{ShortURL: "abc12"}).
+		Return(domain.URLLink{ShortURL: "abc12", LongURL: "https://login.evil.example/<script>"}, service.ErrDomainBlocked)
+
+	w := httptest.NewRecorder()
+	h.Redirect(w, httptest.NewRequest(http.MethodGet, "/abc12", nil))
+
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "login.evil.example")
+	assert.NotContains(t, w.Body.String(), "<script>")
+}
+
+func TestRedirect_Quarantined(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	h := NewURLLinkHandler(mockService, "http://localhost", zerolog.New(nil), nil)
+
+	mockService.EXPECT().GetOriginalURL(gomock.Any(), domain.URLLink{ShortURL: "abc12"}).
+		Return(domain.URLLink{ShortURL: "abc12", LongURL: "https://phish.example/login", Quarantined: true}, service.ErrLinkQuarantined)
+
+	w := httptest.NewRecorder()
+	h.Redirect(w, httptest.NewRequest(http.MethodGet, "/abc12", nil))
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), "карантин")
+	assert.Contains(t, w.Body.String(), "phish.example")
+	assert.NotContains(t, w.Body.String(), "/login")
+}
+
+func TestShortenURL_ReputationUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	h := NewURLLinkHandler(mockService, "http://localhost", zerolog.New(nil), nil)
+
+	mockService.EXPECT().CreateShortURL(gomock.Any(), gomock.Any()).
+		Return(domain.URLLink{}, errors.Join(service.ErrReputationUnavailable, errors.New("timeout")))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("https://example.com"))
+	h.ShortenURL(w, withUser(r, "u1"))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, service.ErrReputationUnavailable.Error()+"\n", w.Body.String())
+}
+
+func TestRedirect_Password(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	h := NewURLLinkHandler(mockService, "http://localhost", zerolog.New(nil), nil)
+	protected := domain.URLLink{ShortURL: "abc12", LongURL: "https://preview.example/", PasswordHash: "$argon2id$..."}
+
+	// без пароля - форма
+	mockService.EXPECT().GetOriginalURL(gomock.Any(), domain.URLLink{ShortURL: "abc12"}).
+		Return(domain.URLLink{ShortURL: "abc12"}, service.ErrPasswordRequired)
+	w := httptest.NewRecorder()
+	h.Redirect(w, httptest.NewRequest(http.MethodGet, "/abc12", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `<form method="post">`)
+	assert.NotContains(t, w.Body.String(), "Неверный пароль")
+
+	// неверный пароль из формы
+	mockService.EXPECT().GetOriginalURL(gomock.Any(), domain.URLLink{ShortURL: "abc12", Password: "wrong"}).
+		Return(domain.URLLink{ShortURL: "abc12"}, service.ErrWrongPassword)
+	r := httptest.NewRequest(http.MethodPost, "/abc12", strings.NewReader("password=wrong"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Неверный пароль")
+
+	// верный пароль из формы - переход GET-запросом
+	mockService.EXPECT().GetOriginalURL(gomock.Any(), domain.URLLink{ShortURL: "abc12", Password: "secret"}).
+		Return(protected, nil)
+	r = httptest.NewRequest(http.MethodPost, "/abc12", strings.NewReader("password=secret"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "https://preview.example/", w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	// API-клиент передает пароль в заголовке
+	mockService.EXPECT().GetOriginalURL(gomock.Any(), domain.URLLink{ShortURL: "abc12", Password: "secret"}).
+		Return(protected, nil)
+	r = httptest.NewRequest(http.MethodGet, "/abc12", nil)
+	r.Header.Set(LinkPasswordHeader, "secret")
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	mockService.EXPECT().GetOriginalURL(gomock.Any(), domain.URLLink{ShortURL: "abc12", Password: "wrong"}).
+		Return(domain.URLLink{ShortURL: "abc12"}, service.ErrWrongPassword)
+	r = httptest.NewRequest(http.MethodGet, "/abc12", nil)
+	r.Header.Set(LinkPasswordHeader, "wrong")
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "<form")
+
+	// все слоты проверки паролей заняты
+	mockService.EXPECT().GetOriginalURL(gomock.Any(), domain.URLLink{ShortURL: "abc12", Password: "secret"}).
+		Return(domain.URLLink{}, service.ErrPasswordCheckBusy)
+	r = httptest.NewRequest(http.MethodGet, "/abc12", nil)
+	r.Header.Set(LinkPasswordHeader, "secret")
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
+
+func TestRedirect_ClicksExhausted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	h := NewURLLinkHandler(mockService, "http://localhost", zerolog.New(nil), nil)
+
+	mockService.EXPECT().GetOriginalURL(gomock.Any(), domain.URLLink{ShortURL: "abc12"}).
+		Return(domain.URLLink{ShortURL: "abc12", LongURL: "https://example.com/once", MaxClicks: 1}, service.ErrLinkExhausted)
+
+	w := httptest.NewRecorder()
+	h.Redirect(w, httptest.NewRequest(http.MethodGet, "/abc12", nil))
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+}
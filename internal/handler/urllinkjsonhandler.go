@@ -7,12 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/physicist2018/url-shortener-go/internal/domain"
 	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/internal/service"
 )
 
 const (
@@ -21,7 +22,9 @@ const (
 
 type (
 	requestBody struct {
-		URL string `json:"url"`
+		URL       string `json:"url"`
+		Password  string `json:"password,omitempty"`   // пароль для перехода по ссылке
+		MaxClicks int    `json:"max_clicks,omitempty"` // число переходов, после которого ссылка исчезает
 	}
 
 	responseBody struct {
@@ -29,8 +32,10 @@ type (
 	}
 
 	batchRequestItem struct {
-		ID  string `json:"correlation_id"`
-		URL string `json:"original_url"`
+		ID        string `json:"correlation_id"`
+		URL       string `json:"original_url"`
+		Password  string `json:"password,omitempty"`
+		MaxClicks int    `json:"max_clicks,omitempty"`
 	}
 
 	batchResponseItem struct {
@@ -39,8 +44,15 @@ type (
 	}
 
 	batchResponseListPerUser struct {
-		ShortURL string `json:"short_url"`
-		LongURL  string `json:"original_url"`
+		ShortURL        string `json:"short_url"`
+		LongURL         string `json:"original_url"`
+		ClicksRemaining *int   `json:"clicks_remaining,omitempty"` // только у ссылок с max_clicks
+	}
+
+	// отсутствующее поле не меняется
+	updateLinkRequest struct {
+		URL      *string `json:"url"`
+		Password *string `json:"password"` // пустая строка снимает пароль
 	}
 )
 
@@ -61,14 +73,16 @@ func (h *URLLinkHandler) HandleGenerateShortURLJson(w http.ResponseWriter, r *ht
 	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
 	defer cancel()
 
-	parsedURL, err := url.ParseRequestURI(reqBody.URL)
-	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
-		http.Error(w, "Некорректный URL", http.StatusBadRequest)
-		return
-	}
-
-	urlModel, err := h.service.CreateShortURL(ctx, domain.URLLink{LongURL: reqBody.URL})
+	userID, _ := r.Context().Value(domain.UserIDKey{}).(string)
+	urlModel, err := h.service.CreateShortURL(ctx, domain.URLLink{LongURL: reqBody.URL, UserID: userID, Password: reqBody.Password, MaxClicks: reqBody.MaxClicks})
 	if err != nil {
+		if writeRejectedURL(w, err) || writeQuotaExceeded(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrWeakPassword) || errors.Is(err, service.ErrInvalidMaxClicks) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if errors.Is(err, repoerrors.ErrorShortLinkAlreadyInDB) {
 			h.sendJSONResponse(w, http.StatusConflict, urlModel.ShortURL)
 			return
@@ -92,15 +106,28 @@ func (h *URLLinkHandler) HandleGenerateShortURLJsonBatch(w http.ResponseWriter,
 		return
 	}
 
-	respBody := make([]batchResponseItem, len(reqBody))
+	userID, _ := r.Context().Value(domain.UserIDKey{}).(string)
+	links := make([]domain.URLLink, len(reqBody))
 	for i, req := range reqBody {
-		urlModel, err := h.service.CreateShortURL(r.Context(), domain.URLLink{LongURL: req.URL})
-		if err != nil {
-			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		links[i] = domain.URLLink{LongURL: req.URL, UserID: userID, Password: req.Password, MaxClicks: req.MaxClicks}
+	}
+	created, err := h.service.CreateShortURLBatch(r.Context(), links)
+	if err != nil {
+		if writeRejectedURL(w, err) || writeQuotaExceeded(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrWeakPassword) || errors.Is(err, service.ErrInvalidMaxClicks) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	respBody := make([]batchResponseItem, len(created))
+	for i, urlModel := range created {
 		respBody[i] = batchResponseItem{
-			ID:     req.ID,
+			ID:     reqBody[i].ID,
 			Result: fmt.Sprintf("%s/%s", h.baseURL, urlModel.ShortURL),
 		}
 	}
@@ -127,8 +154,9 @@ func (h *URLLinkHandler) HandleGetAllShortedURLsForUserJSON(w http.ResponseWrite
 	urlsPerUser := make([]batchResponseListPerUser, len(urls))
 	for i, url := range urls {
 		urlsPerUser[i] = batchResponseListPerUser{
-			ShortURL: fmt.Sprintf("%s/%s", h.baseURL, url.ShortURL),
-			LongURL:  url.LongURL,
+			ShortURL:        fmt.Sprintf("%s/%s", h.baseURL, url.ShortURL),
+			LongURL:         url.LongURL,
+			ClicksRemaining: clicksRemaining(url),
 		}
 	}
 
@@ -172,8 +200,64 @@ func (h *URLLinkHandler) HandleDeleteShortedURLsForUserJSON(w http.ResponseWrite
 
 }
 
+// HandleUpdateShortedURLForUserJSON меняет адрес назначения ссылки пользователя.
+// Прежний адрес сохраняется в истории версий
+func (h *URLLinkHandler) HandleUpdateShortedURLForUserJSON(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	var req updateLinkRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	if req.URL == nil && req.Password == nil {
+		http.Error(w, "Нет изменяемых полей", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	link, err := h.service.UpdateLink(ctx, userID, chi.URLParam(r, "shortURL"), domain.URLLinkUpdate{LongURL: req.URL, Password: req.Password})
+	if err != nil {
+		h.writeLinkError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, batchResponseListPerUser{
+		ShortURL:        fmt.Sprintf("%s/%s", h.baseURL, link.ShortURL),
+		LongURL:         link.LongURL,
+		ClicksRemaining: clicksRemaining(link),
+	})
+}
+
+// HandleGetShortedURLVersionsForUserJSON отдает прежние адреса ссылки пользователя
+func (h *URLLinkHandler) HandleGetShortedURLVersionsForUserJSON(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	versions, err := h.service.LinkVersions(ctx, userID, chi.URLParam(r, "shortURL"))
+	if err != nil {
+		h.writeLinkError(w, err)
+		return
+	}
+	if versions == nil {
+		versions = []domain.LinkVersion{}
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
+
 // Вспомогательные методы
 
+// clicksRemaining возвращает остаток переходов для ответа, nil - переходы не ограничены
+func clicksRemaining(link domain.URLLink) *int {
+	if link.MaxClicks <= 0 {
+		return nil
+	}
+	remaining := link.ClicksRemaining
+	return &remaining
+}
+
 func (h *URLLinkHandler) isContentTypeJSON(r *http.Request) bool {
 	return r.Header.Get("Content-Type") == "application/json"
 }
@@ -205,6 +289,23 @@ func (h *URLLinkHandler) sendBatchJSONResponseForUser(w http.ResponseWriter, sta
 	}
 }
 
+func (h *URLLinkHandler) writeLinkError(w http.ResponseWriter, err error) {
+	if writeRejectedURL(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrLinkNotFound):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, repoerrors.ErrorShortLinkAlreadyInDB):
+		http.Error(w, "Этот адрес уже сокращен другой ссылкой", http.StatusConflict)
+	case errors.Is(err, service.ErrDomainBlocked), errors.Is(err, service.ErrWeakPassword):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.log.Error().Err(err).Msg("Ошибка изменения ссылки")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
 func (h *URLLinkHandler) decodeArrayOfShortLinks(r *http.Request, v interface{}) error {
 	return json.NewDecoder(r.Body).Decode(v)
 }
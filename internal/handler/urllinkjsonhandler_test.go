@@ -9,13 +9,16 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/go-chi/chi"
 	"github.com/golang/mock/gomock"
 	"github.com/physicist2018/url-shortener-go/internal/deleter"
 	"github.com/physicist2018/url-shortener-go/internal/domain"
 	"github.com/physicist2018/url-shortener-go/internal/mocks"
 	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/internal/service"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHandleGenerateShortURLJson_Success(t *testing.T) {
@@ -143,12 +146,8 @@ func TestHandleGenerateShortURLJsonBatch_Success(t *testing.T) {
 	// Ожидаемая модель URLLink
 	mockService.
 		EXPECT().
-		CreateShortURL(gomock.Any(), domain.URLLink{LongURL: "https://example.com"}).
-		Return(domain.URLLink{ShortURL: "abc123"}, nil)
-	mockService.
-		EXPECT().
-		CreateShortURL(gomock.Any(), domain.URLLink{LongURL: "https://test.com"}).
-		Return(domain.URLLink{ShortURL: "xyz789"}, nil)
+		CreateShortURLBatch(gomock.Any(), []domain.URLLink{{LongURL: "https://example.com"}, {LongURL: "https://test.com"}}).
+		Return([]domain.URLLink{{ShortURL: "abc123"}, {ShortURL: "xyz789"}}, nil)
 
 	w := httptest.NewRecorder()
 	h.HandleGenerateShortURLJsonBatch(w, r)
@@ -200,3 +199,111 @@ func TestHandleGenerateShortURLJson_BadRequest(t *testing.T) {
 	h.Close()
 	wg.Wait()
 }
+
+func TestHandleUpdateShortedURLForUserJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	h := NewURLLinkHandler(mockService, "http://localhost", zerolog.New(nil), nil)
+	router := chi.NewRouter()
+	router.Patch("/api/user/urls/{shortURL}", h.HandleUpdateShortedURLForUserJSON)
+	router.Get("/api/user/urls/{shortURL}/versions", h.HandleGetShortedURLVersionsForUserJSON)
+
+	fixed := "https://example.com/fixed"
+	mockService.EXPECT().
+		UpdateLink(gomock.Any(), "u1", "abc12", domain.URLLinkUpdate{LongURL: &fixed}).
+		Return(domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: fixed}, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, withUser(jsonRequest(http.MethodPatch, "/api/user/urls/abc12", `{"url":"https://example.com/fixed"}`), "u1"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"short_url":"http://localhost/abc12","original_url":"https://example.com/fixed"}`, w.Body.String())
+
+	tests := []struct {
+		name string
+		body string
+		err  error
+		want int
+	}{
+		{"No fields", `{}`, nil, http.StatusBadRequest},
+		{"Invalid URL", `{"url":"not a url"}`, &service.InvalidURLError{Reason: service.ErrURLMalformed}, http.StatusBadRequest},
+		{"Not owner", `{"url":"https://example.com/x"}`, service.ErrLinkNotFound, http.StatusNotFound},
+		{"Already shortened", `{"url":"https://example.com/x"}`, repoerrors.ErrorShortLinkAlreadyInDB, http.StatusConflict},
+		{"Blocked domain", `{"url":"https://example.com/x"}`, service.ErrDomainBlocked, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err != nil {
+				mockService.EXPECT().UpdateLink(gomock.Any(), "u1", "abc12", gomock.Any()).Return(domain.URLLink{}, tt.err)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, withUser(jsonRequest(http.MethodPatch, "/api/user/urls/abc12", tt.body), "u1"))
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+
+	mockService.EXPECT().LinkVersions(gomock.Any(), "u1", "abc12").
+		Return([]domain.LinkVersion{{Version: 1, LongURL: "https://example.com/typo", ReplacedBy: "u1"}}, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls/abc12/versions", nil), "u1"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"version":1`)
+	assert.Contains(t, w.Body.String(), `"original_url":"https://example.com/typo"`)
+}
+
+func TestQuotaExceededResponse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	h := NewURLLinkHandler(mockService, "http://localhost", zerolog.New(nil), nil)
+
+	mockService.EXPECT().CreateShortURL(gomock.Any(), gomock.Any()).
+		Return(domain.URLLink{}, &service.QuotaError{Quota: service.QuotaLinks, Limit: 10, Used: 10, Requested: 1})
+	w := httptest.NewRecorder()
+	h.HandleGenerateShortURLJson(w, withUser(jsonRequest(http.MethodPost, "/api/shorten", `{"url":"https://example.com"}`), "u1"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "links", body["quota"])
+	assert.EqualValues(t, 10, body["limit"])
+	assert.EqualValues(t, 10, body["used"])
+	assert.NotEmpty(t, body["error"])
+
+	mockService.EXPECT().CreateShortURLBatch(gomock.Any(), gomock.Any()).
+		Return(nil, &service.QuotaError{Quota: service.QuotaBatch, Limit: 1, Requested: 2})
+	w = httptest.NewRecorder()
+	h.HandleGenerateShortURLJsonBatch(w, withUser(jsonRequest(http.MethodPost, "/api/shorten/batch",
+		`[{"correlation_id":"1","original_url":"https://a.example"},{"correlation_id":"2","original_url":"https://b.example"}]`), "u1"))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), `"quota":"batch"`)
+}
+
+func TestHandleGetQuotaJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	h := NewURLLinkHandler(mockService, "http://localhost", zerolog.New(nil), nil)
+
+	mockService.EXPECT().Quota(gomock.Any(), "u1").
+		Return(domain.QuotaUsage{MaxLinks: 100, ActiveLinks: 7, MaxBatchSize: 10}, nil)
+	w := httptest.NewRecorder()
+	h.HandleGetQuotaJSON(w, withUser(httptest.NewRequest(http.MethodGet, "/api/user/quota", nil), "u1"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"max_links":100,"active_links":7,"max_batch_size":10}`, w.Body.String())
+}
+
+func TestHandleGetAllShortedURLsForUserJSON_ClicksRemaining(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	h := NewURLLinkHandler(mockService, "http://localhost", zerolog.New(nil), nil)
+
+	mockService.EXPECT().FindAll(gomock.Any(), "u1").Return([]domain.URLLink{
+		{ShortURL: "abc12", LongURL: "https://example.com/once", MaxClicks: 1},
+		{ShortURL: "xyz78", LongURL: "https://example.com/many"},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	h.HandleGetAllShortedURLsForUserJSON(w, withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls", nil), "u1"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"short_url":"http://localhost/abc12","original_url":"https://example.com/once","clicks_remaining":0},
+		{"short_url":"http://localhost/xyz78","original_url":"https://example.com/many"}
+	]`, w.Body.String())
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/ratelimit"
+	"github.com/physicist2018/url-shortener-go/internal/service"
+	"github.com/rs/zerolog"
+)
+
+type (
+	credentialsRequest struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+
+	userResponse struct {
+		ID      string `json:"id"`
+		Login   string `json:"login"`
+		Claimed *int64 `json:"claimed,omitempty"` // число перенесенных ссылок
+	}
+)
+
+// Выдача сессии пользователю после входа
+type SessionIssuer interface {
+	IssueSession(w http.ResponseWriter, userID string) error
+}
+
+// Ручки регистрации и входа в учетную запись
+type UserHandler struct {
+	service  domain.UserService
+	sessions SessionIssuer
+	log      zerolog.Logger
+}
+
+func NewUserHandler(service domain.UserService, sessions SessionIssuer, logger zerolog.Logger) *UserHandler {
+	return &UserHandler{
+		service:  service,
+		sessions: sessions,
+		log:      logger,
+	}
+}
+
+func (h *UserHandler) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	creds, ok := h.decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	user, err := h.service.Register(ctx, creds.Login, creds.Password)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.startSession(w, http.StatusCreated, userResponse{ID: user.ID, Login: user.Login})
+}
+
+func (h *UserHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	creds, ok := h.decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	finish, ok := h.attempt(w, r, creds.Login)
+	if !ok {
+		return
+	}
+	user, err := h.service.Login(ctx, creds.Login, creds.Password)
+	finish(errors.Is(err, service.ErrInvalidCredentials))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.startSession(w, http.StatusOK, userResponse{ID: user.ID, Login: user.Login})
+}
+
+// HandleClaim входит в учетную запись и переносит в нее ссылки текущей
+// анонимной сессии. Сессии учетных записей, OIDC и JWT ссылки не отдают
+func (h *UserHandler) HandleClaim(w http.ResponseWriter, r *http.Request) {
+	anonymousUserID := r.Context().Value(domain.UserIDKey{}).(string)
+	if anonymous, _ := r.Context().Value(domain.AnonymousIdentityKey{}).(bool); !anonymous {
+		h.writeError(w, service.ErrNotAnonymous)
+		return
+	}
+
+	creds, ok := h.decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	finish, ok := h.attempt(w, r, creds.Login)
+	if !ok {
+		return
+	}
+	user, n, err := h.service.ClaimLinks(ctx, anonymousUserID, creds.Login, creds.Password)
+	finish(errors.Is(err, service.ErrInvalidCredentials))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.startSession(w, http.StatusOK, userResponse{ID: user.ID, Login: user.Login, Claimed: &n})
+}
+
+// attempt расходует попытку входа клиента и логина до проверки пароля,
+// см. ratelimit.Attempt. Если попытки исчерпаны, отвечает 429
+func (h *UserHandler) attempt(w http.ResponseWriter, r *http.Request, login string) (func(failed bool), bool) {
+	finish, retryAfter, ok := ratelimit.Attempt(r, strings.ToLower(strings.TrimSpace(login)))
+	if !ok {
+		h.log.Info().Str("login", login).Msg("Превышен лимит попыток входа")
+		ratelimit.TooManyRequests(w, retryAfter)
+	}
+	return finish, ok
+}
+
+func (h *UserHandler) decodeCredentials(w http.ResponseWriter, r *http.Request) (credentialsRequest, bool) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "Content-Type должен быть application/json", http.StatusBadRequest)
+		return credentialsRequest{}, false
+	}
+	var creds credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return credentialsRequest{}, false
+	}
+	return creds, true
+}
+
+func (h *UserHandler) startSession(w http.ResponseWriter, statusCode int, resp userResponse) {
+	if err := h.sessions.IssueSession(w, resp.ID); err != nil {
+		h.log.Error().Err(err).Msg("Ошибка выдачи сессии")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, statusCode, resp)
+}
+
+func (h *UserHandler) writeError(w http.ResponseWriter, err error) {
+	if writeQuotaExceeded(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidLogin), errors.Is(err, service.ErrWeakPassword):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidCredentials):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, service.ErrLoginTaken), errors.Is(err, service.ErrNotAnonymous):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrPasswordCheckBusy):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, service.ErrPasswordCheckBusy.Error(), http.StatusServiceUnavailable)
+	default:
+		h.log.Error().Err(err).Msg("Ошибка работы с учетной записью")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
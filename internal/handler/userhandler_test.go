@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/ratelimit"
+	"github.com/physicist2018/url-shortener-go/internal/mocks"
+	"github.com/physicist2018/url-shortener-go/internal/service"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Запоминает, кому выдана сессия
+type recordingSessions struct {
+	issued []string
+}
+
+func (s *recordingSessions) IssueSession(w http.ResponseWriter, userID string) error {
+	s.issued = append(s.issued, userID)
+	return nil
+}
+
+func credentialsBody(login, password string) *bytes.Buffer {
+	body, _ := json.Marshal(credentialsRequest{Login: login, Password: password})
+	return bytes.NewBuffer(body)
+}
+
+func TestHandleRegister(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockUserService(ctrl)
+	sessions := &recordingSessions{}
+	h := NewUserHandler(mockService, sessions, zerolog.New(nil))
+
+	mockService.EXPECT().Register(gomock.Any(), "alice", "correct horse").Return(domain.User{ID: "u1", Login: "alice", PasswordHash: "secret"}, nil)
+	mockService.EXPECT().Register(gomock.Any(), "alice", "correct horse").Return(domain.User{}, service.ErrLoginTaken)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/user/register", credentialsBody("alice", "correct horse"))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.HandleRegister(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []string{"u1"}, sessions.issued)
+	assert.NotContains(t, w.Body.String(), "secret")
+
+	r = httptest.NewRequest(http.MethodPost, "/api/user/register", credentialsBody("alice", "correct horse"))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.HandleRegister(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Len(t, sessions.issued, 1)
+}
+
+func TestHandleLogin_InvalidCredentials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockUserService(ctrl)
+	sessions := &recordingSessions{}
+	h := NewUserHandler(mockService, sessions, zerolog.New(nil))
+
+	mockService.EXPECT().Login(gomock.Any(), "alice", "wrong").Return(domain.User{}, service.ErrInvalidCredentials)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/user/login", credentialsBody("alice", "wrong"))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.HandleLogin(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, sessions.issued)
+
+	mockService.EXPECT().Login(gomock.Any(), "alice", "correct horse").Return(domain.User{}, service.ErrPasswordCheckBusy)
+
+	r = httptest.NewRequest(http.MethodPost, "/api/user/login", credentialsBody("alice", "correct horse"))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.HandleLogin(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Empty(t, sessions.issued)
+}
+
+func TestHandleLogin_AttemptLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockUserService(ctrl)
+	h := NewUserHandler(mockService, &recordingSessions{}, zerolog.New(nil))
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{ratelimit.RouteLogin: {Rate: 2.0 / 3600, Burst: 2}}, zerolog.New(nil))
+	login := limiter.Failures(ratelimit.RouteLogin, h.HandleLogin)
+
+	mockService.EXPECT().Login(gomock.Any(), "alice", "wrong").Return(domain.User{}, service.ErrInvalidCredentials).Times(2)
+
+	call := func(ip, name string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/user/login", credentialsBody(name, "wrong"))
+		r.Header.Set("Content-Type", "application/json")
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		login(w, r)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, call("198.51.100.1", "alice"))
+	assert.Equal(t, http.StatusUnauthorized, call("198.51.100.1", "alice"))
+	// пароль больше не проверяется ни с этого адреса, ни для этого логина с другого
+	assert.Equal(t, http.StatusTooManyRequests, call("198.51.100.1", "bob"))
+	assert.Equal(t, http.StatusTooManyRequests, call("198.51.100.2", " Alice"))
+}
+
+func TestHandleClaim(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockUserService(ctrl)
+	sessions := &recordingSessions{}
+	h := NewUserHandler(mockService, sessions, zerolog.New(nil))
+
+	mockService.EXPECT().
+		ClaimLinks(gomock.Any(), "anon", "alice", "correct horse").
+		Return(domain.User{ID: "u1", Login: "alice"}, int64(3), nil)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/user/claim", credentialsBody("alice", "correct horse"))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r = withUser(r, "anon")
+	h.HandleClaim(w, r.WithContext(context.WithValue(r.Context(), domain.AnonymousIdentityKey{}, true)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"u1"}, sessions.issued)
+
+	var resp userResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(t, resp.Claimed)
+	assert.Equal(t, int64(3), *resp.Claimed)
+
+	// ссылки OIDC-, JWT-сессии или учетной записи забрать нельзя
+	r = httptest.NewRequest(http.MethodPost, "/api/user/claim", credentialsBody("alice", "correct horse"))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.HandleClaim(w, withUser(r, "oidc-user"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
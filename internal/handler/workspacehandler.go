@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/internal/service"
+	"github.com/rs/zerolog"
+)
+
+type (
+	createWorkspaceRequest struct {
+		Name string `json:"name"`
+	}
+
+	setMemberRequest struct {
+		Role domain.WorkspaceRole `json:"role"`
+	}
+
+	moveLinkRequest struct {
+		WorkspaceID string `json:"workspace_id"` // пусто - в личные ссылки
+	}
+
+	workspaceLinkResponse struct {
+		ShortURL        string `json:"short_url"`
+		LongURL         string `json:"original_url"`
+		AuthorID        string `json:"author_id"`
+		IsDeleted       bool   `json:"is_deleted"`
+		ClicksRemaining *int   `json:"clicks_remaining,omitempty"`
+	}
+)
+
+// Ручки рабочих пространств и их ссылок
+type WorkspaceHandler struct {
+	service domain.WorkspaceService
+	baseURL string
+	log     zerolog.Logger
+}
+
+func NewWorkspaceHandler(service domain.WorkspaceService, baseURL string, logger zerolog.Logger) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		service: service,
+		baseURL: baseURL,
+		log:     logger,
+	}
+}
+
+func (h *WorkspaceHandler) HandleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	var req createWorkspaceRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	workspace, err := h.service.CreateWorkspace(ctx, userID, req.Name)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, workspace)
+}
+
+func (h *WorkspaceHandler) HandleListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	memberships, err := h.service.ListWorkspaces(ctx, userID)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if memberships == nil {
+		memberships = []domain.WorkspaceMembership{}
+	}
+	writeJSON(w, http.StatusOK, memberships)
+}
+
+func (h *WorkspaceHandler) HandleListMembers(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	members, err := h.service.ListMembers(ctx, userID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, members)
+}
+
+func (h *WorkspaceHandler) HandleSetMember(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	var req setMemberRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	err := h.service.SetMemberRole(ctx, userID, chi.URLParam(r, "id"), chi.URLParam(r, "userID"), req.Role)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WorkspaceHandler) HandleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	if err := h.service.RemoveMember(ctx, userID, chi.URLParam(r, "id"), chi.URLParam(r, "userID")); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WorkspaceHandler) HandleCreateLink(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	var req requestBody
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	link, err := h.service.CreateLink(ctx, userID, chi.URLParam(r, "id"), req.URL)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrorShortLinkAlreadyInDB) {
+			writeJSON(w, http.StatusConflict, responseBody{Result: h.fullURL(link.ShortURL)})
+			return
+		}
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, responseBody{Result: h.fullURL(link.ShortURL)})
+}
+
+func (h *WorkspaceHandler) HandleListLinks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	links, err := h.service.ListLinks(ctx, userID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if len(links) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]workspaceLinkResponse, len(links))
+	for i, l := range links {
+		resp[i] = workspaceLinkResponse{
+			ShortURL:        h.fullURL(l.ShortURL),
+			LongURL:         l.LongURL,
+			AuthorID:        l.UserID,
+			IsDeleted:       l.DeletedFlag,
+			ClicksRemaining: clicksRemaining(l),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *WorkspaceHandler) HandleDeleteLinks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	var shortURLs []string
+	if !decodeJSONRequest(w, r, &shortURLs) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	if err := h.service.DeleteLinks(ctx, userID, chi.URLParam(r, "id"), shortURLs); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleMoveLink переносит ссылку между пространствами или в личные ссылки
+func (h *WorkspaceHandler) HandleMoveLink(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(domain.UserIDKey{}).(string)
+
+	var req moveLinkRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestResponseTimeout)
+	defer cancel()
+
+	if err := h.service.MoveLink(ctx, userID, chi.URLParam(r, "shortURL"), req.WorkspaceID); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WorkspaceHandler) fullURL(shortURL string) string {
+	return strings.Join([]string{h.baseURL, shortURL}, "/")
+}
+
+func (h *WorkspaceHandler) writeError(w http.ResponseWriter, err error) {
+	if writeRejectedURL(w, err) || writeQuotaExceeded(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrWorkspaceNotFound), errors.Is(err, service.ErrLinkNotFound):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, service.ErrWorkspaceForbidden):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidWorkspaceName), errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrDomainBlocked):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrLastOwner):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		h.log.Error().Err(err).Msg("Ошибка работы с рабочим пространством")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// decodeJSONRequest проверяет тип содержимого и разбирает тело запроса.
+// При ошибке сам отвечает 400 и возвращает false
+func decodeJSONRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "Content-Type должен быть application/json", http.StatusBadRequest)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
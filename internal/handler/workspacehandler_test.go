@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/golang/mock/gomock"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/mocks"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/internal/service"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWorkspaceTestRouter(h *WorkspaceHandler) *chi.Mux {
+	asUser := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { next(w, withUser(r, "user-1")) }
+	}
+	router := chi.NewRouter()
+	router.Post("/api/workspaces", asUser(h.HandleCreateWorkspace))
+	router.Put("/api/workspaces/{id}/members/{userID}", asUser(h.HandleSetMember))
+	router.Post("/api/workspaces/{id}/links", asUser(h.HandleCreateLink))
+	router.Get("/api/workspaces/{id}/links", asUser(h.HandleListLinks))
+	router.Put("/api/user/urls/{shortURL}/workspace", asUser(h.HandleMoveLink))
+	return router
+}
+
+func jsonRequest(method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestHandleCreateWorkspace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockWorkspaceService(ctrl)
+	router := newWorkspaceTestRouter(NewWorkspaceHandler(mockService, "http://localhost:8080", zerolog.New(nil)))
+
+	mockService.EXPECT().CreateWorkspace(gomock.Any(), "user-1", "team").Return(domain.Workspace{ID: "ws-1", Name: "team"}, nil)
+	mockService.EXPECT().CreateWorkspace(gomock.Any(), "user-1", "").Return(domain.Workspace{}, service.ErrInvalidWorkspaceName)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, jsonRequest(http.MethodPost, "/api/workspaces", `{"name":"team"}`))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp domain.Workspace
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "ws-1", resp.ID)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, jsonRequest(http.MethodPost, "/api/workspaces", `{"name":""}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandleSetMember(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockWorkspaceService(ctrl)
+	router := newWorkspaceTestRouter(NewWorkspaceHandler(mockService, "http://localhost:8080", zerolog.New(nil)))
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"Success", nil, http.StatusNoContent},
+		{"Not owner", service.ErrWorkspaceForbidden, http.StatusForbidden},
+		{"Not member", service.ErrWorkspaceNotFound, http.StatusNotFound},
+		{"Last owner", service.ErrLastOwner, http.StatusConflict},
+		{"Storage failure", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService.EXPECT().SetMemberRole(gomock.Any(), "user-1", "ws-1", "user-2", domain.RoleEditor).Return(tt.err)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, jsonRequest(http.MethodPut, "/api/workspaces/ws-1/members/user-2", `{"role":"editor"}`))
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestHandleWorkspaceLinks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockWorkspaceService(ctrl)
+	router := newWorkspaceTestRouter(NewWorkspaceHandler(mockService, "http://localhost:8080", zerolog.New(nil)))
+
+	t.Run("Create", func(t *testing.T) {
+		mockService.EXPECT().CreateLink(gomock.Any(), "user-1", "ws-1", "https://example.com").
+			Return(domain.URLLink{ShortURL: "abc12", WorkspaceID: "ws-1"}, nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, jsonRequest(http.MethodPost, "/api/workspaces/ws-1/links", `{"url":"https://example.com"}`))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `{"result":"http://localhost:8080/abc12"}`, w.Body.String())
+	})
+
+	t.Run("Create conflict", func(t *testing.T) {
+		mockService.EXPECT().CreateLink(gomock.Any(), "user-1", "ws-1", "https://example.com").
+			Return(domain.URLLink{ShortURL: "abc12"}, repoerrors.ErrorShortLinkAlreadyInDB)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, jsonRequest(http.MethodPost, "/api/workspaces/ws-1/links", `{"url":"https://example.com"}`))
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Create invalid URL", func(t *testing.T) {
+		mockService.EXPECT().CreateLink(gomock.Any(), "user-1", "ws-1", "not a url").
+			Return(domain.URLLink{}, &service.InvalidURLError{Reason: service.ErrURLMalformed})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, jsonRequest(http.MethodPost, "/api/workspaces/ws-1/links", `{"url":"not a url"}`))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		mockService.EXPECT().ListLinks(gomock.Any(), "user-1", "ws-1").
+			Return([]domain.URLLink{{UserID: "user-2", WorkspaceID: "ws-1", ShortURL: "abc12", LongURL: "https://example.com"}}, nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/workspaces/ws-1/links", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[{"short_url":"http://localhost:8080/abc12","original_url":"https://example.com","author_id":"user-2","is_deleted":false}]`, w.Body.String())
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		mockService.EXPECT().MoveLink(gomock.Any(), "user-1", "abc12", "ws-2").Return(nil)
+		mockService.EXPECT().MoveLink(gomock.Any(), "user-1", "other", "").Return(service.ErrLinkNotFound)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, jsonRequest(http.MethodPut, "/api/user/urls/abc12/workspace", `{"workspace_id":"ws-2"}`))
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, jsonRequest(http.MethodPut, "/api/user/urls/other/workspace", `{"workspace_id":""}`))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,125 @@
+package authenticator
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+)
+
+// Заголовок, в котором операторы передают административный ключ
+const AdminKeyHeader = "X-Admin-Key"
+
+// минимальная длина административного ключа в символах
+const MinAdminKeyLength = 24
+
+var ErrInvalidAdminKey = errors.New("некорректный административный ключ")
+
+// Административный ключ. Хранится только хэш значения
+type AdminKey struct {
+	Name string // попадает в журнал аудита как key:<Name>
+	hash [sha256.Size]byte
+}
+
+func NewAdminKey(name, secret string) (AdminKey, error) {
+	if name == "" || strings.ContainsAny(name, ":, ") {
+		return AdminKey{}, fmt.Errorf("%w: имя %q пусто или содержит ':', ',' или пробел", ErrInvalidAdminKey, name)
+	}
+	if len(secret) < MinAdminKeyLength {
+		return AdminKey{}, fmt.Errorf("%w: ключ %q короче %d символов", ErrInvalidAdminKey, name, MinAdminKeyLength)
+	}
+	return AdminKey{Name: name, hash: sha256.Sum256([]byte(secret))}, nil
+}
+
+// ParseAdminKeys разбирает ключи в формате "name:secret,name:secret"
+func ParseAdminKeys(spec string) ([]AdminKey, error) {
+	var keys []AdminKey
+	seen := make(map[string]struct{})
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, secret, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: ожидается формат name:secret", ErrInvalidAdminKey)
+		}
+		key, err := NewAdminKey(name, secret)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("%w: ключ %q задан дважды", ErrInvalidAdminKey, name)
+		}
+		seen[name] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Кто допускается к административному API
+type AdminOptions struct {
+	UserIDs []string   // пользователи с ролью администратора, входят через сессию
+	Keys    []AdminKey // ключи для скриптов и дежурных без учетной записи
+}
+
+// SetAdmins задает администраторов. Без них административный API закрыт
+func (a *Authenticator) SetAdmins(opts AdminOptions) {
+	a.adminUsers = make(map[string]struct{}, len(opts.UserIDs))
+	for _, id := range opts.UserIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			a.adminUsers[id] = struct{}{}
+		}
+	}
+	a.adminKeys = opts.Keys
+}
+
+// RequireAdmin пропускает запросы с административным ключом или от сессии
+// администратора и кладет в контекст оператора для журнала аудита.
+// API-ключи пользователей к административному API не допускаются
+func (a *Authenticator) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var actor string
+		if rawKey := r.Header.Get(AdminKeyHeader); rawKey != "" {
+			key, ok := a.matchAdminKey(rawKey)
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			actor = "key:" + key.Name
+		} else {
+			id, err := a.authenticate(w, r)
+			if err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if _, ok := a.adminUsers[id.userID]; !ok || id.scopes != nil {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			actor = "user:" + id.userID
+		}
+
+		ctx := context.WithValue(r.Context(), domain.AdminActorKey{}, actor)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Сравниваются хэши за постоянное время, все ключи проверяются всегда
+func (a *Authenticator) matchAdminKey(rawKey string) (AdminKey, bool) {
+	hash := sha256.Sum256([]byte(rawKey))
+	var found AdminKey
+	matched := 0
+	for _, key := range a.adminKeys {
+		if subtle.ConstantTimeCompare(hash[:], key.hash[:]) == 1 {
+			found = key
+			matched = 1
+		}
+	}
+	return found, matched == 1
+}
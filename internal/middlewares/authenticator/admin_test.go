@@ -0,0 +1,126 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// API-ключ "user-key" принадлежит администратору
+type adminTestKeys struct{}
+
+func (adminTestKeys) ResolveAPIKey(_ context.Context, rawKey string) (domain.APIKey, error) {
+	if rawKey == "user-key" {
+		return domain.APIKey{UserID: "admin-1", Scopes: domain.APIKeyScopes}, nil
+	}
+	return domain.APIKey{}, errors.New("not found")
+}
+
+func TestParseAdminKeys(t *testing.T) {
+	keys, err := ParseAdminKeys(" ops:0123456789abcdef01234567 , ci:zyxwvutsrqponmlkjihgfedcba ")
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "ops", keys[0].Name)
+
+	for _, spec := range []string{
+		"ops",
+		"ops:short",
+		":0123456789abcdef01234567",
+		"ops:0123456789abcdef01234567,ops:zyxwvutsrqponmlkjihgfedcba",
+	} {
+		_, err := ParseAdminKeys(spec)
+		assert.True(t, errors.Is(err, ErrInvalidAdminKey), spec)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	auth := newTestAuthenticator(t)
+	auth.SetAPIKeyResolver(adminTestKeys{})
+	keys, err := ParseAdminKeys("ops:0123456789abcdef01234567")
+	require.NoError(t, err)
+	auth.SetAdmins(AdminOptions{UserIDs: []string{"admin-1"}, Keys: keys})
+
+	var actor string
+	handler := auth.RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = r.Context().Value(domain.AdminActorKey{}).(string)
+	}))
+
+	tests := []struct {
+		name      string
+		prepare   func(r *http.Request)
+		wantCode  int
+		wantActor string
+	}{
+		{
+			name:      "Admin key",
+			prepare:   func(r *http.Request) { r.Header.Set(AdminKeyHeader, "0123456789abcdef01234567") },
+			wantCode:  http.StatusOK,
+			wantActor: "key:ops",
+		},
+		{
+			name:     "Wrong admin key",
+			prepare:  func(r *http.Request) { r.Header.Set(AdminKeyHeader, "0123456789abcdef0123456x") },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:      "Admin session",
+			prepare:   func(r *http.Request) { r.AddCookie(signedCookie(t, auth, "admin-1")) },
+			wantCode:  http.StatusOK,
+			wantActor: "user:admin-1",
+		},
+		{
+			name:     "Regular session",
+			prepare:  func(r *http.Request) { r.AddCookie(signedCookie(t, auth, "user-2")) },
+			wantCode: http.StatusForbidden,
+		},
+		{
+			// даже ключ администратора со всеми правами не дает доступа к операторским ручкам
+			name:     "Admin API key",
+			prepare:  func(r *http.Request) { r.Header.Set(APIKeyHeader, "user-key") },
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "No credentials",
+			prepare:  func(r *http.Request) {},
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor = ""
+			r := httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil)
+			tt.prepare(r)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantActor, actor)
+			assert.Empty(t, w.Result().Cookies())
+		})
+	}
+}
+
+func TestRequireAdmin_NotConfigured(t *testing.T) {
+	auth := newTestAuthenticator(t)
+	handler := auth.RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("обработчик не должен вызываться")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil)
+	r.AddCookie(signedCookie(t, auth, "user-1"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil)
+	r.Header.Set(AdminKeyHeader, "0123456789abcdef01234567")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,58 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+)
+
+// Заголовок, в котором машинные клиенты передают API-ключ
+const APIKeyHeader = "X-API-Key"
+
+// Поиск действующего API-ключа по его значению
+type APIKeyResolver interface {
+	ResolveAPIKey(ctx context.Context, rawKey string) (domain.APIKey, error)
+}
+
+func (a *Authenticator) resolveAPIKey(r *http.Request, rawKey string) (identity, error) {
+	if a.apiKeys == nil {
+		return identity{}, ErrInvalidSession
+	}
+	key, err := a.apiKeys.ResolveAPIKey(r.Context(), rawKey)
+	if err != nil {
+		return identity{}, errors.Join(ErrInvalidSession, err)
+	}
+	// пустой список прав не должен превращаться в сессию со всеми правами
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return identity{userID: key.UserID, scopes: scopes, keyID: key.ID}, nil
+}
+
+// RequireScope пропускает запрос, если у API-ключа есть право scope.
+// Сессии пользователя (кука, JWT) имеют все права. Ставится после мидлвари аутентификации
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if scopes, ok := r.Context().Value(domain.ScopesKey{}).([]string); ok && !slices.Contains(scopes, scope) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireSession пропускает только запросы от сессии пользователя:
+// API-ключом нельзя управлять самими ключами
+func RequireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(domain.ScopesKey{}).([]string); ok {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,90 +16,314 @@ import (
 	"github.com/physicist2018/url-shortener-go/internal/domain"
 )
 
-var verySecretKey = []byte("я памятник себе воздвиг нерукотоврный")
+var (
+	ErrNoSession      = errors.New("сессия отсутствует")
+	ErrInvalidSession = errors.New("некорректная сессия")
+)
+
+// Атрибуты сессионной куки
+type CookieOptions struct {
+	Name     string
+	Lifetime time.Duration // после половины срока кука перевыпускается
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+}
+
+func DefaultCookieOptions() CookieOptions {
+	return CookieOptions{
+		Name:     "user_session",
+		Lifetime: 30 * 24 * time.Hour,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// ParseSameSite разбирает значение атрибута SameSite: lax, strict, none или пусто
+func ParseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return http.SameSiteDefaultMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("некорректное значение SameSite: %q", value)
+	}
+}
+
+// Результат аутентификации запроса
+type identity struct {
+	userID string
+	scopes []string // права API-ключа; nil - сессия пользователя со всеми правами
+	keyID  string   // API-ключ, которым аутентифицирован запрос
+	fresh  bool     // личность выдана этим запросом
+	// кука анонимной личности, выданной сервисом, а не сессии учетной записи,
+	// OIDC или JWT: только ее ссылки можно перенести в учетную запись
+	anonymous bool
+}
+
+// Сессия из проверенной куки
+type cookieSession struct {
+	userID    string
+	issuedAt  time.Time
+	anonymous bool
+}
+
+// Вид сессии в куке
+const (
+	sessionAnonymous = "a" // анонимная личность, выданная сервисом
+	sessionAccount   = "u" // вход в учетную запись или через OIDC
+)
+
+type Authenticator struct {
+	keys    *KeySet
+	cookie  CookieOptions
+	bearer  *BearerVerifier // nil - токены не принимаются
+	apiKeys APIKeyResolver  // nil - API-ключи не принимаются
+	oidc    *OIDCProvider   // nil - вход через OIDC выключен
+	now     func() time.Time
+
+	adminUsers map[string]struct{}
+	adminKeys  []AdminKey
+}
+
+func NewAuthenticator(keys *KeySet, cookie CookieOptions) *Authenticator {
+	defaults := DefaultCookieOptions()
+	if cookie.Name == "" {
+		cookie.Name = defaults.Name
+	}
+	if cookie.Lifetime <= 0 {
+		cookie.Lifetime = defaults.Lifetime
+	}
+	return &Authenticator{
+		keys:   keys,
+		cookie: cookie,
+		now:    time.Now,
+	}
+}
+
+// SetBearerVerifier включает аутентификацию по заголовку Authorization: Bearer <JWT>.
+// Куки при этом продолжают приниматься
+func (a *Authenticator) SetBearerVerifier(v *BearerVerifier) {
+	a.bearer = v
+}
+
+// SetAPIKeyResolver включает аутентификацию по заголовку X-API-Key
+func (a *Authenticator) SetAPIKeyResolver(r APIKeyResolver) {
+	a.apiKeys = r
+}
+
+// Вспомогательная функция для проверки куки и получения userID.
+// Если куки нет, то выдается новая с новым пользователем
+func (a *Authenticator) checkUserCookie(w http.ResponseWriter, r *http.Request) (identity, error) {
+	id, err := a.authenticate(w, r)
+	if !errors.Is(err, ErrNoSession) {
+		return id, err
+	}
+
+	cookie, userID, err := a.createUserCookie()
+	if err != nil {
+		return identity{}, err
+	}
+	http.SetCookie(w, cookie)
+	return identity{userID: userID, fresh: true, anonymous: true}, nil
+}
+
+// Проверка API-ключа, токена или куки без выдачи новой.
+// Запрос с заголовком X-API-Key или Authorization проверяется только
+// по нему: новая личность такому запросу не выдается
+func (a *Authenticator) authenticate(w http.ResponseWriter, r *http.Request) (identity, error) {
+	if rawKey := r.Header.Get(APIKeyHeader); rawKey != "" {
+		return a.resolveAPIKey(r, rawKey)
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		session, err := a.requireUserCookie(w, r)
+		return identity{userID: session.userID, anonymous: session.anonymous}, err
+	}
+	if a.bearer == nil || token == "" {
+		return identity{}, ErrInvalidSession
+	}
+	userID, err := a.bearer.Verify(token)
+	if err != nil {
+		return identity{}, errors.Join(ErrInvalidSession, err)
+	}
+	return identity{userID: userID}, nil
+}
 
-// Вспомогательная функция для проверки куки и получения userID
-func checkUserCookie(w http.ResponseWriter, r *http.Request) (string, error) {
-	cookie, err := r.Cookie("user_session")
+// Проверка куки без выдачи новой: если куки нет, то возвращается ErrNoSession
+func (a *Authenticator) requireUserCookie(w http.ResponseWriter, r *http.Request) (cookieSession, error) {
+	cookie, err := r.Cookie(a.cookie.Name)
 	if err != nil || cookie == nil {
-		cookie, userID := createUserCookie()
-		http.SetCookie(w, cookie)
-		return userID, nil
+		return cookieSession{}, ErrNoSession
 	}
 
-	userID, valid := validateUserCookie(cookie)
+	session, valid := a.validateUserCookie(cookie)
 	if !valid {
-		return "", fmt.Errorf("unauthorized")
+		return cookieSession{}, ErrInvalidSession
 	}
-	return userID, nil
-}
 
-// Мидлварь для авторизации на уровне роутера
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID, err := checkUserCookie(w, r)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
+	// Скользящее продление: после половины срока выдаем новую куку
+	// с тем же пользователем
+	if a.now().Sub(session.issuedAt) > a.cookie.Lifetime/2 {
+		if renewed, err := a.issueCookie(session.userID, session.anonymous); err == nil {
+			http.SetCookie(w, renewed)
 		}
+	}
+	return session, nil
+}
 
-		ctx := context.WithValue(r.Context(), domain.UserIDKey{}, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// Мидлварь для авторизации на уровне роутера.
+// Пользователю без куки выдается новая личность - для ручек создания ссылок
+func (a *Authenticator) AuthMiddleware(next http.Handler) http.Handler {
+	return a.middleware(a.checkUserCookie, next)
+}
+
+// Мидлварь для авторизации на уровне конкретной ручки.
+// Пользователю без куки выдается новая личность - для ручек создания ссылок
+func (a *Authenticator) AuthMiddlewareFunc(next http.HandlerFunc) http.HandlerFunc {
+	return a.middleware(a.checkUserCookie, next).ServeHTTP
+}
+
+// Мидлварь, требующая существующую сессию, на уровне роутера.
+// Без API-ключа, токена или куки запрос отклоняется с 401 - для ручек, работающих с данными пользователя
+func (a *Authenticator) RequireAuthMiddleware(next http.Handler) http.Handler {
+	return a.middleware(a.authenticate, next)
+}
+
+// Мидлварь, требующая существующую сессию, на уровне конкретной ручки
+func (a *Authenticator) RequireAuthMiddlewareFunc(next http.HandlerFunc) http.HandlerFunc {
+	return a.middleware(a.authenticate, next).ServeHTTP
 }
 
-// Мидлварь для авторизации на уровне конкретной ручки
-func AuthMiddlewareFunc(next http.HandlerFunc) http.HandlerFunc {
+func (a *Authenticator) middleware(identify func(http.ResponseWriter, *http.Request) (identity, error), next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID, err := checkUserCookie(w, r)
+		id, err := identify(w, r)
 		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), domain.UserIDKey{}, userID)
-		next(w, r.WithContext(ctx))
+		ctx := context.WithValue(r.Context(), domain.UserIDKey{}, id.userID)
+		if id.scopes != nil {
+			ctx = context.WithValue(ctx, domain.ScopesKey{}, id.scopes)
+		}
+		if id.keyID != "" {
+			ctx = context.WithValue(ctx, domain.APIKeyIDKey{}, id.keyID)
+		}
+		if id.fresh {
+			ctx = context.WithValue(ctx, domain.NewIdentityKey{}, true)
+		}
+		if id.anonymous {
+			ctx = context.WithValue(ctx, domain.AnonymousIdentityKey{}, true)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func createUserCookie() (*http.Cookie, string) {
+// IssueSession выдает сессионную куку для пользователя,
+// например после входа в учетную запись
+func (a *Authenticator) IssueSession(w http.ResponseWriter, userID string) error {
+	cookie, err := a.issueCookie(userID, false)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+func (a *Authenticator) createUserCookie() (*http.Cookie, string, error) {
 	userID := uuid.New().String()
-	mac := hmac.New(sha256.New, verySecretKey)
-	mac.Write([]byte(userID))
-	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
-	cookieValue := fmt.Sprintf("%s.%s", userID, signature)
+	cookie, err := a.issueCookie(userID, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return cookie, userID, nil
+}
 
-	cookie := &http.Cookie{
-		Name:     "user_session",
+func (a *Authenticator) issueCookie(userID string, anonymous bool) (*http.Cookie, error) {
+	now := a.now()
+	key, err := a.keys.signingKey(now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Формат значения: <id ключа>.<userID>.<время выдачи>.<вид сессии>.<подпись>
+	kind := sessionAccount
+	if anonymous {
+		kind = sessionAnonymous
+	}
+	payload := fmt.Sprintf("%s.%d.%s", userID, now.Unix(), kind)
+	cookieValue := fmt.Sprintf("%s.%s.%s", key.ID, payload, sign(key, payload))
+
+	return &http.Cookie{
+		Name:     a.cookie.Name,
 		Value:    cookieValue,
-		Expires:  time.Now().Add(1 * time.Hour),
+		Expires:  now.Add(a.cookie.Lifetime),
+		MaxAge:   int(a.cookie.Lifetime.Seconds()),
 		HttpOnly: true,
+		Secure:   a.cookie.Secure,
+		SameSite: a.cookie.SameSite,
+		Domain:   a.cookie.Domain,
 		Path:     "/",
-	}
-
-	return cookie, userID
+	}, nil
 }
 
-func validateUserCookie(cookie *http.Cookie) (string, bool) {
+// validateUserCookie проверяет подпись и срок куки и возвращает сессию
+func (a *Authenticator) validateUserCookie(cookie *http.Cookie) (cookieSession, bool) {
 	if cookie == nil {
-		return "", false
+		return cookieSession{}, false
 	}
 
 	parts := strings.Split(cookie.Value, ".")
-	if len(parts) != 2 {
-		return "", false
+	if len(parts) != 4 && (len(parts) != 5 || parts[3] != sessionAnonymous && parts[3] != sessionAccount) {
+		return cookieSession{}, false
 	}
 
-	userID := parts[0]
-	signature := parts[1]
+	keyID, userID, issuedAtRaw, signature := parts[0], parts[1], parts[2], parts[len(parts)-1]
 
-	mac := hmac.New(sha256.New, verySecretKey)
-	mac.Write([]byte(userID))
-	expectedSignature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	now := a.now()
+	key, ok := a.keys.lookup(keyID, now)
+	if !ok {
+		return cookieSession{}, false
+	}
+
+	payload := strings.Join(parts[1:len(parts)-1], ".")
+	if !hmac.Equal([]byte(signature), []byte(sign(key, payload))) {
+		return cookieSession{}, false
+	}
 
-	if !hmac.Equal([]byte(signature), []byte(expectedSignature)) {
-		return "", false
+	issuedAtUnix, err := strconv.ParseInt(issuedAtRaw, 10, 64)
+	if err != nil {
+		return cookieSession{}, false
 	}
+	issuedAt := time.Unix(issuedAtUnix, 0)
+	if now.Sub(issuedAt) > a.cookie.Lifetime {
+		return cookieSession{}, false
+	}
+
+	anonymous := len(parts) == 5 && parts[3] == sessionAnonymous
+	if len(parts) == 4 {
+		// куки, выданные до появления вида сессии, не различали анонимные
+		// личности и учетные записи. Анонимные личности - случайные UUIDv4,
+		// OIDC-пользователи - UUIDv5; зарегистрированных пользователей
+		// с UUIDv4 отсекает проверка по таблице пользователей
+		id, err := uuid.Parse(userID)
+		anonymous = err == nil && id.Version() == 4
+	}
+	return cookieSession{userID: userID, issuedAt: issuedAt, anonymous: anonymous}, true
+}
 
-	return userID, true
+// Подпись включает идентификатор ключа, чтобы куку нельзя было
+// перенести под другой ключ
+func sign(key Key, payload string) string {
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write([]byte(key.ID))
+	mac.Write([]byte{'.'})
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
 }
@@ -1,9 +1,7 @@
 package authenticator
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/base64"
+	"bytes"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -14,25 +12,41 @@ import (
 	"github.com/google/uuid"
 	"github.com/physicist2018/url-shortener-go/internal/domain"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+func testKey(id string, fill byte) Key {
+	return Key{ID: id, Secret: bytes.Repeat([]byte{fill}, MinSecretLength)}
+}
+
+func newTestAuthenticator(t *testing.T, keys ...Key) *Authenticator {
+	if len(keys) == 0 {
+		keys = []Key{testKey("current", 'a')}
+	}
+	keySet, err := NewKeySet(keys...)
+	require.NoError(t, err)
+	return NewAuthenticator(keySet, DefaultCookieOptions())
+}
+
+func signedCookie(t *testing.T, auth *Authenticator, userID string) *http.Cookie {
+	cookie, err := auth.issueCookie(userID, false)
+	require.NoError(t, err)
+	return cookie
+}
+
 func TestCheckUserCookie(t *testing.T) {
+	auth := newTestAuthenticator(t)
+
 	t.Run("Valid Cookie", func(t *testing.T) {
 		userID := uuid.New().String()
-		mac := hmac.New(sha256.New, verySecretKey)
-		mac.Write([]byte(userID))
-		signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
-		cookieValue := fmt.Sprintf("%s.%s", userID, signature)
-		cookie := &http.Cookie{
-			Name:  "user_session",
-			Value: cookieValue,
-		}
+		cookie := signedCookie(t, auth, userID)
 
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req.AddCookie(cookie)
 		w := httptest.NewRecorder()
 
-		returnedUserID, err := checkUserCookie(w, req)
+		id, err := auth.checkUserCookie(w, req)
+		returnedUserID := id.userID
 		assert.NoError(t, err)
 		assert.Equal(t, userID, returnedUserID)
 	})
@@ -47,7 +61,7 @@ func TestCheckUserCookie(t *testing.T) {
 		req.AddCookie(cookie)
 		w := httptest.NewRecorder()
 
-		_, err := checkUserCookie(w, req)
+		_, err := auth.checkUserCookie(w, req)
 		assert.Error(t, err)
 	})
 
@@ -55,13 +69,16 @@ func TestCheckUserCookie(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		w := httptest.NewRecorder()
 
-		userID, err := checkUserCookie(w, req)
+		id, err := auth.checkUserCookie(w, req)
+		userID := id.userID
 		assert.NoError(t, err)
 		assert.NotEmpty(t, userID)
 	})
 }
 
 func TestAuthMiddleware(t *testing.T) {
+	auth := newTestAuthenticator(t)
+
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value(domain.UserIDKey{}).(string)
 		w.Write([]byte(userID))
@@ -69,20 +86,13 @@ func TestAuthMiddleware(t *testing.T) {
 
 	t.Run("Valid Cookie", func(t *testing.T) {
 		userID := uuid.New().String()
-		mac := hmac.New(sha256.New, verySecretKey)
-		mac.Write([]byte(userID))
-		signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
-		cookieValue := fmt.Sprintf("%s.%s", userID, signature)
-		cookie := &http.Cookie{
-			Name:  "user_session",
-			Value: cookieValue,
-		}
+		cookie := signedCookie(t, auth, userID)
 
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req.AddCookie(cookie)
 		w := httptest.NewRecorder()
 
-		handler := AuthMiddleware(nextHandler)
+		handler := auth.AuthMiddleware(nextHandler)
 		handler.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
@@ -99,7 +109,7 @@ func TestAuthMiddleware(t *testing.T) {
 		req.AddCookie(cookie)
 		w := httptest.NewRecorder()
 
-		handler := AuthMiddleware(nextHandler)
+		handler := auth.AuthMiddleware(nextHandler)
 		handler.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
@@ -109,7 +119,7 @@ func TestAuthMiddleware(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		w := httptest.NewRecorder()
 
-		handler := AuthMiddleware(nextHandler)
+		handler := auth.AuthMiddleware(nextHandler)
 		handler.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
@@ -118,6 +128,8 @@ func TestAuthMiddleware(t *testing.T) {
 }
 
 func TestAuthMiddlewareFunc(t *testing.T) {
+	auth := newTestAuthenticator(t)
+
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value(domain.UserIDKey{}).(string)
 		w.Write([]byte(userID))
@@ -125,20 +137,13 @@ func TestAuthMiddlewareFunc(t *testing.T) {
 
 	t.Run("Valid Cookie", func(t *testing.T) {
 		userID := uuid.New().String()
-		mac := hmac.New(sha256.New, verySecretKey)
-		mac.Write([]byte(userID))
-		signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
-		cookieValue := fmt.Sprintf("%s.%s", userID, signature)
-		cookie := &http.Cookie{
-			Name:  "user_session",
-			Value: cookieValue,
-		}
+		cookie := signedCookie(t, auth, userID)
 
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req.AddCookie(cookie)
 		w := httptest.NewRecorder()
 
-		handler := AuthMiddlewareFunc(nextHandler)
+		handler := auth.AuthMiddlewareFunc(nextHandler)
 		handler.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
@@ -155,7 +160,7 @@ func TestAuthMiddlewareFunc(t *testing.T) {
 		req.AddCookie(cookie)
 		w := httptest.NewRecorder()
 
-		handler := AuthMiddlewareFunc(nextHandler)
+		handler := auth.AuthMiddlewareFunc(nextHandler)
 		handler.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
@@ -165,7 +170,7 @@ func TestAuthMiddlewareFunc(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		w := httptest.NewRecorder()
 
-		handler := AuthMiddlewareFunc(nextHandler)
+		handler := auth.AuthMiddlewareFunc(nextHandler)
 		handler.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
@@ -174,8 +179,10 @@ func TestAuthMiddlewareFunc(t *testing.T) {
 }
 
 func TestCreateUserCookie(t *testing.T) {
-	cookie, userID := createUserCookie()
+	auth := newTestAuthenticator(t)
+	cookie, userID, err := auth.createUserCookie()
 
+	assert.NoError(t, err)
 	assert.NotNil(t, cookie)
 	assert.NotEmpty(t, userID)
 	assert.Equal(t, "user_session", cookie.Name)
@@ -183,24 +190,61 @@ func TestCreateUserCookie(t *testing.T) {
 	assert.True(t, cookie.HttpOnly)
 	assert.Equal(t, "/", cookie.Path)
 
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
 	parts := strings.Split(cookie.Value, ".")
-	assert.Len(t, parts, 2)
-	assert.Equal(t, userID, parts[0])
+	assert.Len(t, parts, 5)
+	assert.Equal(t, "current", parts[0])
+	assert.Equal(t, userID, parts[1])
+	assert.Equal(t, sessionAnonymous, parts[3])
+}
+
+func TestAnonymousSession(t *testing.T) {
+	auth := newTestAuthenticator(t)
+
+	anonymous, _, err := auth.createUserCookie()
+	require.NoError(t, err)
+	session, valid := auth.validateUserCookie(anonymous)
+	assert.True(t, valid)
+	assert.True(t, session.anonymous)
+
+	// сессия после входа - не анонимная, даже для случайного UUID
+	session, valid = auth.validateUserCookie(signedCookie(t, auth, uuid.New().String()))
+	assert.True(t, valid)
+	assert.False(t, session.anonymous)
+
+	// вид сессии подписан вместе с пользователем
+	forged := signedCookie(t, auth, uuid.New().String())
+	parts := strings.Split(forged.Value, ".")
+	parts[3] = sessionAnonymous
+	forged.Value = strings.Join(parts, ".")
+	_, valid = auth.validateUserCookie(forged)
+	assert.False(t, valid)
+
+	// в куках старого формата анонимны только UUIDv4
+	legacy := func(userID string) *http.Cookie {
+		key, err := auth.keys.signingKey(time.Now())
+		require.NoError(t, err)
+		payload := fmt.Sprintf("%s.%d", userID, time.Now().Unix())
+		return &http.Cookie{Name: "user_session", Value: key.ID + "." + payload + "." + sign(key, payload)}
+	}
+	session, valid = auth.validateUserCookie(legacy(uuid.New().String()))
+	assert.True(t, valid)
+	assert.True(t, session.anonymous)
+	session, valid = auth.validateUserCookie(legacy(DefaultSubjectMapper("https://idp.example", "alice")))
+	assert.True(t, valid)
+	assert.False(t, session.anonymous)
 }
 
 func TestValidateUserCookie(t *testing.T) {
+	auth := newTestAuthenticator(t)
+
 	t.Run("Valid Cookie", func(t *testing.T) {
 		userID := uuid.New().String()
-		mac := hmac.New(sha256.New, verySecretKey)
-		mac.Write([]byte(userID))
-		signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
-		cookieValue := fmt.Sprintf("%s.%s", userID, signature)
-		cookie := &http.Cookie{
-			Name:  "user_session",
-			Value: cookieValue,
-		}
+		cookie := signedCookie(t, auth, userID)
 
-		returnedUserID, valid := validateUserCookie(cookie)
+		session, valid := auth.validateUserCookie(cookie)
+		returnedUserID := session.userID
 		assert.True(t, valid)
 		assert.Equal(t, userID, returnedUserID)
 	})
@@ -211,12 +255,191 @@ func TestValidateUserCookie(t *testing.T) {
 			Value: "invalid.cookie.value",
 		}
 
-		_, valid := validateUserCookie(cookie)
+		_, valid := auth.validateUserCookie(cookie)
 		assert.False(t, valid)
 	})
 
 	t.Run("No Cookie", func(t *testing.T) {
-		_, valid := validateUserCookie(nil)
+		_, valid := auth.validateUserCookie(nil)
+		assert.False(t, valid)
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := testKey("old", 'o')
+	newKey := testKey("new", 'n')
+	userID := uuid.New().String()
+
+	oldAuth := newTestAuthenticator(t, oldKey)
+	oldCookie := signedCookie(t, oldAuth, userID)
+
+	t.Run("Old key still accepted", func(t *testing.T) {
+		auth := newTestAuthenticator(t, newKey, oldKey)
+		session, valid := auth.validateUserCookie(oldCookie)
+		returnedUserID := session.userID
+		assert.True(t, valid)
+		assert.Equal(t, userID, returnedUserID)
+	})
+
+	t.Run("New cookies signed with newest key", func(t *testing.T) {
+		auth := newTestAuthenticator(t, newKey, oldKey)
+		cookie, _, err := auth.createUserCookie()
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(cookie.Value, "new."))
+	})
+
+	t.Run("Expired key rejected", func(t *testing.T) {
+		expired := oldKey
+		expiresAt := time.Now().Add(-time.Minute)
+		expired.ExpiresAt = &expiresAt
+		auth := newTestAuthenticator(t, newKey, expired)
+		_, valid := auth.validateUserCookie(oldCookie)
 		assert.False(t, valid)
 	})
+
+	t.Run("Removed key rejected", func(t *testing.T) {
+		auth := newTestAuthenticator(t, newKey)
+		_, valid := auth.validateUserCookie(oldCookie)
+		assert.False(t, valid)
+	})
+
+	t.Run("Key id cannot be swapped", func(t *testing.T) {
+		auth := newTestAuthenticator(t, newKey, oldKey)
+		forged := &http.Cookie{Name: "user_session", Value: "new" + strings.TrimPrefix(oldCookie.Value, "old")}
+		_, valid := auth.validateUserCookie(forged)
+		assert.False(t, valid)
+	})
+}
+
+func TestCookieOptions(t *testing.T) {
+	keySet, err := NewKeySet(testKey("current", 'a'))
+	require.NoError(t, err)
+	auth := NewAuthenticator(keySet, CookieOptions{
+		Name:     "sid",
+		Lifetime: 2 * time.Hour,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Domain:   "short.example",
+	})
+
+	cookie, _, err := auth.createUserCookie()
+	require.NoError(t, err)
+	assert.Equal(t, "sid", cookie.Name)
+	assert.Equal(t, 7200, cookie.MaxAge)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.Equal(t, "short.example", cookie.Domain)
+}
+
+func TestSlidingRenewal(t *testing.T) {
+	keySet, err := NewKeySet(testKey("current", 'a'))
+	require.NoError(t, err)
+	auth := NewAuthenticator(keySet, CookieOptions{Lifetime: time.Hour})
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+
+	userID := uuid.New().String()
+	cookie := signedCookie(t, auth, userID)
+
+	check := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		id, err := auth.checkUserCookie(w, req)
+		returnedUserID := id.userID
+		require.NoError(t, err)
+		assert.Equal(t, userID, returnedUserID)
+		return w
+	}
+
+	t.Run("Fresh cookie not renewed", func(t *testing.T) {
+		now = now.Add(10 * time.Minute)
+		assert.Empty(t, check().Result().Cookies())
+	})
+
+	t.Run("Cookie renewed after half lifetime", func(t *testing.T) {
+		now = now.Add(25 * time.Minute)
+		renewed := check().Result().Cookies()
+		require.Len(t, renewed, 1)
+
+		session, valid := auth.validateUserCookie(renewed[0])
+		returnedUserID, issuedAt := session.userID, session.issuedAt
+		assert.True(t, valid)
+		assert.Equal(t, userID, returnedUserID)
+		assert.Equal(t, now.Unix(), issuedAt.Unix())
+	})
+
+	t.Run("Expired cookie rejected", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		_, err := auth.checkUserCookie(httptest.NewRecorder(), req)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseSameSite(t *testing.T) {
+	for value, want := range map[string]http.SameSite{
+		"":       http.SameSiteDefaultMode,
+		"Lax":    http.SameSiteLaxMode,
+		"strict": http.SameSiteStrictMode,
+		"none":   http.SameSiteNoneMode,
+	} {
+		got, err := ParseSameSite(value)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := ParseSameSite("sometimes")
+	assert.Error(t, err)
+}
+
+func TestRequireAuthMiddleware(t *testing.T) {
+	auth := newTestAuthenticator(t)
+
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(domain.UserIDKey{}).(string)
+		w.Write([]byte(userID))
+	})
+
+	middlewares := map[string]http.Handler{
+		"Router level":  auth.RequireAuthMiddleware(nextHandler),
+		"Handler level": auth.RequireAuthMiddlewareFunc(nextHandler),
+	}
+
+	for name, handler := range middlewares {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Valid Cookie", func(t *testing.T) {
+				userID := uuid.New().String()
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.AddCookie(signedCookie(t, auth, userID))
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, userID, w.Body.String())
+			})
+
+			t.Run("Invalid Cookie", func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.AddCookie(&http.Cookie{Name: "user_session", Value: "invalid.cookie.value"})
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+			})
+
+			t.Run("No Cookie", func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+				assert.Empty(t, w.Result().Cookies())
+			})
+		})
+	}
 }
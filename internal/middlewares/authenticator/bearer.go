@@ -0,0 +1,165 @@
+package authenticator
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoVerificationKeys = errors.New("не заданы ключи проверки токенов")
+	ErrInvalidToken       = errors.New("некорректный токен")
+)
+
+// Параметры проверки токенов из заголовка Authorization: Bearer <JWT>
+type BearerOptions struct {
+	Issuer     string             // если задан, то claim iss обязан совпадать
+	Audience   string             // если задан, то claim aud обязан его содержать
+	HMACSecret []byte             // секрет для HS256
+	PublicKeys []crypto.PublicKey // *rsa.PublicKey для RS256, ed25519.PublicKey для EdDSA
+	Leeway     time.Duration      // допустимое расхождение часов
+}
+
+// Проверка JWT: пользователь берется из claim sub,
+// срок действия (exp) обязателен
+type BearerVerifier struct {
+	opts    BearerOptions
+	methods []string
+	now     func() time.Time
+}
+
+func NewBearerVerifier(opts BearerOptions) (*BearerVerifier, error) {
+	var methods []string
+	if len(opts.HMACSecret) > 0 {
+		if len(opts.HMACSecret) < MinSecretLength {
+			return nil, fmt.Errorf("%w: секрет HS256 короче %d байт", ErrInvalidKey, MinSecretLength)
+		}
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	var hasRSA, hasEd25519 bool
+	for _, k := range opts.PublicKeys {
+		switch k.(type) {
+		case *rsa.PublicKey:
+			hasRSA = true
+		case ed25519.PublicKey:
+			hasEd25519 = true
+		default:
+			return nil, fmt.Errorf("%w: неподдерживаемый тип открытого ключа %T", ErrInvalidKey, k)
+		}
+	}
+	if hasRSA {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if hasEd25519 {
+		methods = append(methods, jwt.SigningMethodEdDSA.Alg())
+	}
+
+	if len(methods) == 0 {
+		return nil, ErrNoVerificationKeys
+	}
+	return &BearerVerifier{opts: opts, methods: methods, now: time.Now}, nil
+}
+
+// LoadPublicKeys читает открытые ключи RSA и Ed25519 из PEM-файла.
+// В файле может быть несколько блоков PUBLIC KEY
+func LoadPublicKeys(path string) ([]crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: в %s нет блоков PUBLIC KEY", ErrNoVerificationKeys, path)
+	}
+	return keys, nil
+}
+
+// Verify проверяет подпись и claims токена и возвращает пользователя из sub
+func (v *BearerVerifier) Verify(raw string) (string, error) {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(v.methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.opts.Leeway),
+		jwt.WithTimeFunc(v.now),
+	}
+	if v.opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(v.opts.Issuer))
+	}
+	if v.opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(v.opts.Audience))
+	}
+
+	var claims jwt.RegisteredClaims
+	if _, err := jwt.ParseWithClaims(raw, &claims, v.keyFunc, parserOpts...); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("%w: отсутствует sub", ErrInvalidToken)
+	}
+	return claims.Subject, nil
+}
+
+// ключи подбираются по алгоритму токена: HMAC-секрет никогда
+// не используется для проверки асимметричной подписи и наоборот
+func (v *BearerVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.opts.HMACSecret, nil
+	case *jwt.SigningMethodRSA:
+		return v.keySet(func(k crypto.PublicKey) bool { _, ok := k.(*rsa.PublicKey); return ok }), nil
+	case *jwt.SigningMethodEd25519:
+		return v.keySet(func(k crypto.PublicKey) bool { _, ok := k.(ed25519.PublicKey); return ok }), nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемый алгоритм %s", token.Method.Alg())
+	}
+}
+
+func (v *BearerVerifier) keySet(match func(crypto.PublicKey) bool) jwt.VerificationKeySet {
+	var set jwt.VerificationKeySet
+	for _, k := range v.opts.PublicKeys {
+		if match(k) {
+			set.Keys = append(set.Keys, k)
+		}
+	}
+	return set
+}
+
+// токен из заголовка Authorization; второе значение - был ли заголовок
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", true
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,197 @@
+package authenticator
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testHMACSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func testClaims(sub string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   sub,
+		Issuer:    "https://issuer.example",
+		Audience:  jwt.ClaimStrings{"shortener"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.NoError(t, err)
+	return token
+}
+
+func TestBearerVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	v, err := NewBearerVerifier(BearerOptions{
+		Issuer:     "https://issuer.example",
+		Audience:   "shortener",
+		HMACSecret: testHMACSecret,
+		PublicKeys: []crypto.PublicKey{&rsaKey.PublicKey, edPub},
+	})
+	require.NoError(t, err)
+
+	t.Run("Algorithms", func(t *testing.T) {
+		for name, token := range map[string]string{
+			"HS256": signToken(t, jwt.SigningMethodHS256, testHMACSecret, testClaims("user-1")),
+			"RS256": signToken(t, jwt.SigningMethodRS256, rsaKey, testClaims("user-1")),
+			"EdDSA": signToken(t, jwt.SigningMethodEdDSA, edPriv, testClaims("user-1")),
+		} {
+			userID, err := v.Verify(token)
+			assert.NoError(t, err, name)
+			assert.Equal(t, "user-1", userID, name)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		expired := testClaims("user-1")
+		expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		noExp := testClaims("user-1")
+		noExp.ExpiresAt = nil
+		wrongIssuer := testClaims("user-1")
+		wrongIssuer.Issuer = "https://evil.example"
+		wrongAudience := testClaims("user-1")
+		wrongAudience.Audience = jwt.ClaimStrings{"other"}
+
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		for name, token := range map[string]string{
+			"expired":        signToken(t, jwt.SigningMethodHS256, testHMACSecret, expired),
+			"no exp":         signToken(t, jwt.SigningMethodHS256, testHMACSecret, noExp),
+			"wrong issuer":   signToken(t, jwt.SigningMethodHS256, testHMACSecret, wrongIssuer),
+			"wrong audience": signToken(t, jwt.SigningMethodHS256, testHMACSecret, wrongAudience),
+			"no sub":         signToken(t, jwt.SigningMethodHS256, testHMACSecret, testClaims("")),
+			"unknown key":    signToken(t, jwt.SigningMethodRS256, otherKey, testClaims("user-1")),
+			"alg none":       signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, testClaims("user-1")),
+			"HS384":          signToken(t, jwt.SigningMethodHS384, testHMACSecret, testClaims("user-1")),
+			"garbage":        "not.a.token",
+		} {
+			_, err := v.Verify(token)
+			assert.ErrorIs(t, err, ErrInvalidToken, name)
+		}
+	})
+
+	t.Run("Leeway", func(t *testing.T) {
+		lenient, err := NewBearerVerifier(BearerOptions{HMACSecret: testHMACSecret, Leeway: time.Minute})
+		require.NoError(t, err)
+
+		claims := testClaims("user-1")
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-30 * time.Second))
+		_, err = lenient.Verify(signToken(t, jwt.SigningMethodHS256, testHMACSecret, claims))
+		assert.NoError(t, err)
+	})
+}
+
+func TestNewBearerVerifier_Errors(t *testing.T) {
+	_, err := NewBearerVerifier(BearerOptions{})
+	assert.ErrorIs(t, err, ErrNoVerificationKeys)
+
+	_, err = NewBearerVerifier(BearerOptions{HMACSecret: []byte("short")})
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestLoadPublicKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var data []byte
+	for _, k := range []crypto.PublicKey{&rsaKey.PublicKey, edPub} {
+		der, err := x509.MarshalPKIXPublicKey(k)
+		require.NoError(t, err)
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...)
+	}
+	path := filepath.Join(t.TempDir(), "keys.pem")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	keys, err := LoadPublicKeys(path)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.IsType(t, &rsa.PublicKey{}, keys[0])
+	assert.IsType(t, ed25519.PublicKey{}, keys[1])
+}
+
+func TestBearerAuthentication(t *testing.T) {
+	auth := newTestAuthenticator(t)
+	v, err := NewBearerVerifier(BearerOptions{HMACSecret: testHMACSecret})
+	require.NoError(t, err)
+	auth.SetBearerVerifier(v)
+
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Context().Value(domain.UserIDKey{}).(string)))
+	})
+
+	for name, handler := range map[string]http.Handler{
+		"Issue if absent":  auth.AuthMiddleware(nextHandler),
+		"Require existing": auth.RequireAuthMiddleware(nextHandler),
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Valid Token", func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, testHMACSecret, testClaims("svc-user")))
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, "svc-user", w.Body.String())
+				assert.Empty(t, w.Result().Cookies())
+			})
+
+			// неверный токен не подменяется новой личностью
+			t.Run("Invalid Token", func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", "Bearer not.a.token")
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+				assert.Empty(t, w.Result().Cookies())
+			})
+
+			t.Run("Cookie still works", func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.AddCookie(signedCookie(t, auth, "cookie-user"))
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, "cookie-user", w.Body.String())
+			})
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, testHMACSecret, testClaims("svc-user")))
+		w := httptest.NewRecorder()
+
+		newTestAuthenticator(t).AuthMiddleware(nextHandler).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
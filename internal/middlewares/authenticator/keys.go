@@ -0,0 +1,176 @@
+package authenticator
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// минимальная длина секрета подписи в байтах
+const MinSecretLength = 32
+
+var (
+	ErrNoKeys      = errors.New("не заданы ключи подписи сессий")
+	ErrInvalidKey  = errors.New("некорректный ключ подписи сессий")
+	ErrNoActiveKey = errors.New("нет действующего ключа подписи сессий")
+)
+
+// Ключ подписи сессионных кук
+type Key struct {
+	ID        string     `json:"id"`
+	Secret    []byte     `json:"secret"` // в JSON - base64
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // после этого момента ключ не принимается
+}
+
+func (k Key) active(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// Файл с ключами
+type keysFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// Набор ключей: подписывается самым новым действующим,
+// проверяются все действующие
+type KeySet struct {
+	keys []Key // от новых к старым
+}
+
+// NewKeySet создает набор из ключей, перечисленных от новых к старым
+func NewKeySet(keys ...Key) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	seen := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		if k.ID == "" || strings.ContainsAny(k.ID, ".:,") {
+			return nil, fmt.Errorf("%w: идентификатор %q пуст или содержит '.', ':' или ','", ErrInvalidKey, k.ID)
+		}
+		if len(k.Secret) < MinSecretLength {
+			return nil, fmt.Errorf("%w: секрет ключа %q короче %d байт", ErrInvalidKey, k.ID, MinSecretLength)
+		}
+		if _, ok := seen[k.ID]; ok {
+			return nil, fmt.Errorf("%w: ключ %q задан дважды", ErrInvalidKey, k.ID)
+		}
+		seen[k.ID] = struct{}{}
+	}
+
+	return &KeySet{keys: keys}, nil
+}
+
+// ParseKeys разбирает ключи в формате "id:base64secret,id:base64secret".
+// Первый ключ в списке считается самым новым
+func ParseKeys(spec string) (*KeySet, error) {
+	var keys []Key
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: ожидается формат id:secret", ErrInvalidKey)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: секрет ключа %q не в base64: %v", ErrInvalidKey, id, err)
+		}
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+	return NewKeySet(keys...)
+}
+
+// LoadKeysFile читает ключи из JSON-файла вида {"keys": [...]}.
+// Порядок ключей определяется полем created_at
+func LoadKeysFile(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f keysFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	sort.SliceStable(f.Keys, func(i, j int) bool {
+		return f.Keys[i].CreatedAt.After(f.Keys[j].CreatedAt)
+	})
+	return NewKeySet(f.Keys...)
+}
+
+// LoadKeySet загружает ключи из файла и/или строки конфигурации.
+// Ключи из файла считаются более новыми
+func LoadKeySet(spec, path string) (*KeySet, error) {
+	var keys []Key
+	if path != "" {
+		fromFile, err := LoadKeysFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fromFile.keys...)
+	}
+	if spec != "" {
+		fromSpec, err := ParseKeys(spec)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fromSpec.keys...)
+	}
+	return NewKeySet(keys...)
+}
+
+// WriteKeysFile сохраняет ключи в JSON-файл
+func WriteKeysFile(path string, keys []Key) error {
+	data, err := json.MarshalIndent(keysFile{Keys: keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// GenerateKey создает ключ со случайным секретом
+func GenerateKey(id string, now time.Time) (Key, error) {
+	secret := make([]byte, MinSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, err
+	}
+	if id == "" {
+		id = now.UTC().Format("20060102T150405")
+	}
+	return Key{ID: id, Secret: secret, CreatedAt: now.UTC()}, nil
+}
+
+// Keys возвращает копию ключей набора, от новых к старым
+func (ks *KeySet) Keys() []Key {
+	return append([]Key(nil), ks.keys...)
+}
+
+// ключ для подписи новых кук
+func (ks *KeySet) signingKey(now time.Time) (Key, error) {
+	for _, k := range ks.keys {
+		if k.active(now) {
+			return k, nil
+		}
+	}
+	return Key{}, ErrNoActiveKey
+}
+
+// действующий ключ по идентификатору
+func (ks *KeySet) lookup(id string, now time.Time) (Key, bool) {
+	for _, k := range ks.keys {
+		if k.ID == id {
+			return k, k.active(now)
+		}
+	}
+	return Key{}, false
+}
@@ -0,0 +1,55 @@
+package authenticator
+
+import (
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeys(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", MinSecretLength)))
+
+	keys, err := ParseKeys("k2:" + secret + ", k1:" + secret)
+	require.NoError(t, err)
+	ids := []string{}
+	for _, k := range keys.Keys() {
+		ids = append(ids, k.ID)
+	}
+	assert.Equal(t, []string{"k2", "k1"}, ids)
+
+	_, err = ParseKeys("k1:" + base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+
+	_, err = ParseKeys("no-secret")
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+
+	_, err = ParseKeys("")
+	assert.True(t, errors.Is(err, ErrNoKeys))
+}
+
+func TestKeysFile_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	now := time.Now()
+
+	older, err := GenerateKey("older", now.Add(-time.Hour))
+	require.NoError(t, err)
+	newer, err := GenerateKey("newer", now)
+	require.NoError(t, err)
+
+	// порядок в файле не важен, важен created_at
+	require.NoError(t, WriteKeysFile(path, []Key{older, newer}))
+
+	keys, err := LoadKeysFile(path)
+	require.NoError(t, err)
+
+	signing, err := keys.signingKey(now)
+	require.NoError(t, err)
+	assert.Equal(t, "newer", signing.ID)
+	assert.Equal(t, newer.Secret, signing.Secret)
+}
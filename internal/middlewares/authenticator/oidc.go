@@ -0,0 +1,372 @@
+package authenticator
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultJWKSTTL = time.Hour
+	// не чаще этого обновляем JWKS из-за токена с незнакомым kid
+	jwksMinRefreshInterval = 10 * time.Second
+	maxOIDCResponseSize    = 1 << 20
+)
+
+var (
+	ErrOIDCDiscovery     = errors.New("ошибка получения конфигурации OIDC-провайдера")
+	ErrOIDCExchange      = errors.New("ошибка обмена кода авторизации на токены")
+	ErrInvalidIDToken    = errors.New("некорректный ID-токен")
+	ErrUnknownSigningKey = errors.New("неизвестный ключ подписи ID-токена")
+)
+
+// Параметры входа через OIDC (authorization code + PKCE)
+type OIDCOptions struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string // пусто - публичный клиент, защищенный только PKCE
+	RedirectURL  string // адрес ручки обратного вызова, зарегистрированный у провайдера
+	Scopes       []string
+	HTTPClient   *http.Client
+	Leeway       time.Duration
+	PostLoginURL string // куда вернуть пользователя после входа, по умолчанию "/"
+	// MapSubject переводит пару (iss, sub) в UserID. По умолчанию - UUIDv5,
+	// чтобы идентификатор был стабильным, укладывался в 36 символов
+	// и не пересекался с пользователями других провайдеров
+	MapSubject func(issuer, subject string) string
+}
+
+// Конфигурация провайдера из /.well-known/openid-configuration
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims ID-токена, которые нужны сервису
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce string `json:"nonce"`
+	AZP   string `json:"azp,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+type OIDCProvider struct {
+	opts      OIDCOptions
+	discovery oidcDiscovery
+	jwks      *jwksCache
+	now       func() time.Time
+}
+
+// NewOIDCProvider загружает конфигурацию провайдера. Издатель в конфигурации
+// обязан совпадать с IssuerURL
+func NewOIDCProvider(ctx context.Context, opts OIDCOptions) (*OIDCProvider, error) {
+	if opts.IssuerURL == "" || opts.ClientID == "" || opts.RedirectURL == "" {
+		return nil, fmt.Errorf("%w: не заданы издатель, client_id или redirect_url", ErrOIDCDiscovery)
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(opts.Scopes) == 0 {
+		opts.Scopes = []string{"openid"}
+	}
+	if opts.PostLoginURL == "" {
+		opts.PostLoginURL = "/"
+	}
+	if opts.MapSubject == nil {
+		opts.MapSubject = DefaultSubjectMapper
+	}
+
+	wellKnown := strings.TrimSuffix(opts.IssuerURL, "/") + "/.well-known/openid-configuration"
+	var d oidcDiscovery
+	if err := getJSON(ctx, opts.HTTPClient, wellKnown, &d); err != nil {
+		return nil, errors.Join(ErrOIDCDiscovery, err)
+	}
+	if d.Issuer != opts.IssuerURL {
+		return nil, fmt.Errorf("%w: издатель %q не совпадает с ожидаемым %q", ErrOIDCDiscovery, d.Issuer, opts.IssuerURL)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: в конфигурации нет обязательных адресов", ErrOIDCDiscovery)
+	}
+
+	return &OIDCProvider{
+		opts:      opts,
+		discovery: d,
+		jwks:      newJWKSCache(d.JWKSURI, opts.HTTPClient),
+		now:       time.Now,
+	}, nil
+}
+
+// DefaultSubjectMapper строит UserID как UUIDv5 от издателя и sub
+func DefaultSubjectMapper(issuer, subject string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(issuer+"#"+subject)).String()
+}
+
+// AuthCodeURL возвращает адрес страницы входа у провайдера
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeVerifier string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.opts.ClientID},
+		"redirect_uri":          {p.opts.RedirectURL},
+		"scope":                 {strings.Join(p.opts.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {pkceChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.discovery.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange обменивает код авторизации на ID-токен
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.opts.RedirectURL},
+		"client_id":     {p.opts.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	if p.opts.ClientSecret != "" {
+		form.Set("client_secret", p.opts.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Join(ErrOIDCExchange, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.opts.HTTPClient.Do(req)
+	if err != nil {
+		return "", errors.Join(ErrOIDCExchange, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOIDCResponseSize)).Decode(&body); err != nil {
+		return "", errors.Join(ErrOIDCExchange, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: %s %s", ErrOIDCExchange, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("%w: в ответе нет id_token", ErrOIDCExchange)
+	}
+	return body.IDToken, nil
+}
+
+// VerifyIDToken проверяет подпись ключом из JWKS, издателя, аудиторию,
+// срок действия и nonce
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, raw, nonce string) (IDTokenClaims, error) {
+	var claims IDTokenClaims
+	_, err := jwt.ParseWithClaims(raw, &claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.jwks.key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.discovery.Issuer),
+		jwt.WithAudience(p.opts.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(p.opts.Leeway),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return IDTokenClaims{}, errors.Join(ErrInvalidIDToken, err)
+	}
+	if claims.Subject == "" {
+		return IDTokenClaims{}, fmt.Errorf("%w: отсутствует sub", ErrInvalidIDToken)
+	}
+	if claims.Nonce != nonce {
+		return IDTokenClaims{}, fmt.Errorf("%w: nonce не совпадает", ErrInvalidIDToken)
+	}
+	// при нескольких аудиториях токен должен быть выдан именно нам
+	if len(claims.Audience) > 1 && claims.AZP != p.opts.ClientID {
+		return IDTokenClaims{}, fmt.Errorf("%w: azp не совпадает с client_id", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// UserID переводит sub из ID-токена в идентификатор пользователя сервиса
+func (p *OIDCProvider) UserID(claims IDTokenClaims) string {
+	return p.opts.MapSubject(claims.Issuer, claims.Subject)
+}
+
+// Кэш ключей провайдера. Обновляется по истечении срока или
+// при появлении токена с незнакомым kid
+type jwksCache struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+func newJWKSCache(url string, client *http.Client) *jwksCache {
+	return &jwksCache{url: url, client: client, ttl: defaultJWKSTTL, now: time.Now}
+}
+
+func (c *jwksCache) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	expired := c.keys == nil || now.Sub(c.fetchedAt) > c.ttl
+	if k, ok := c.lookup(kid); ok && !expired {
+		return k, nil
+	}
+	if expired || now.Sub(c.fetchedAt) > jwksMinRefreshInterval {
+		if err := c.refresh(ctx); err != nil && c.keys == nil {
+			return nil, err
+		}
+	}
+	if k, ok := c.lookup(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrUnknownSigningKey, kid)
+}
+
+// вызывается под мьютексом. Токен без kid принимается, только если ключ один
+func (c *jwksCache) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, k := range c.keys {
+			return k, true
+		}
+	}
+	k, ok := c.keys[kid]
+	return k, ok
+}
+
+// вызывается под мьютексом
+func (c *jwksCache) refresh(ctx context.Context) error {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, c.client, c.url, &set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// ключи неподдерживаемых типов пропускаются
+		if k, err := jwk.publicKey(); err == nil {
+			keys[jwk.KID] = k
+		}
+	}
+	c.keys = keys
+	c.fetchedAt = c.now()
+	return nil
+}
+
+// Открытый ключ в формате JWK (RFC 7517)
+type jsonWebKey struct {
+	KTY string `json:"kty"`
+	KID string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	CRV string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.KTY {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.CRV != "P-256" {
+			return nil, fmt.Errorf("неподдерживаемая кривая %q", k.CRV)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "OKP":
+		if k.CRV != "Ed25519" {
+			return nil, fmt.Errorf("неподдерживаемая кривая %q", k.CRV)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("некорректный ключ Ed25519")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип ключа %q", k.KTY)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("некорректное число в JWK")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxOIDCResponseSize)).Decode(v)
+}
@@ -0,0 +1,258 @@
+package authenticator
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/authenticator/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Сервис с ручками входа через OIDC и защищенной страницей,
+// которая возвращает идентификатор пользователя
+func newOIDCTestApp(t *testing.T, provider *oidctest.Provider) (*httptest.Server, *Authenticator, *OIDCProvider) {
+	auth := newTestAuthenticator(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/oidc/login", auth.OIDCLogin)
+	mux.HandleFunc("/api/auth/oidc/callback", auth.OIDCCallback)
+	mux.Handle("/", auth.RequireAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Context().Value(domain.UserIDKey{}).(string))
+	})))
+	app := httptest.NewServer(mux)
+	t.Cleanup(app.Close)
+
+	p, err := NewOIDCProvider(context.Background(), OIDCOptions{
+		IssuerURL:    provider.Issuer(),
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  app.URL + "/api/auth/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+	})
+	require.NoError(t, err)
+	auth.SetOIDCProvider(p)
+	return app, auth, p
+}
+
+func newOIDCTestProvider(t *testing.T) *oidctest.Provider {
+	provider := oidctest.NewProvider("shortener")
+	provider.ClientSecret = "s3cret"
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+func TestOIDCLogin_EndToEnd(t *testing.T) {
+	provider := newOIDCTestProvider(t)
+	provider.Subject = "alice@corp"
+	app, _, _ := newOIDCTestApp(t, provider)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+
+	// логин -> провайдер -> обратный вызов -> защищенная страница
+	resp, err := client.Get(app.URL + "/api/auth/oidc/login")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, DefaultSubjectMapper(provider.Issuer(), "alice@corp"), string(body))
+	assert.Equal(t, 1, provider.JWKSRequests())
+
+	// повторный вход тем же пользователем дает тот же UserID, ключи берутся из кэша
+	resp, err = client.Get(app.URL + "/api/auth/oidc/login")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	again, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, again)
+	assert.Equal(t, 1, provider.JWKSRequests())
+}
+
+func TestOIDCLogin_Redirect(t *testing.T) {
+	provider := newOIDCTestProvider(t)
+	app, auth, _ := newOIDCTestApp(t, provider)
+
+	w := httptest.NewRecorder()
+	auth.OIDCLogin(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	q := location.Query()
+	assert.Equal(t, provider.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	assert.Equal(t, "openid email", q.Get("scope"))
+	assert.Equal(t, app.URL+"/api/auth/oidc/callback", q.Get("redirect_uri"))
+	assert.NotEmpty(t, q.Get("state"))
+	assert.NotEmpty(t, q.Get("nonce"))
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, oidcFlowCookieName, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+}
+
+func TestOIDCCallback_Rejected(t *testing.T) {
+	provider := newOIDCTestProvider(t)
+	_, auth, _ := newOIDCTestApp(t, provider)
+
+	login := httptest.NewRecorder()
+	auth.OIDCLogin(login, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	flowCookie := login.Result().Cookies()[0]
+	location, err := url.Parse(login.Header().Get("Location"))
+	require.NoError(t, err)
+	state := location.Query().Get("state")
+
+	tampered := *flowCookie
+	tampered.Value += "x"
+
+	tests := []struct {
+		name   string
+		query  string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"без куки", "?code=abc&state=" + state, nil, http.StatusBadRequest},
+		{"чужой state", "?code=abc&state=other", flowCookie, http.StatusBadRequest},
+		{"подделанная кука", "?code=abc&state=" + state, &tampered, http.StatusBadRequest},
+		{"ошибка провайдера", "?error=access_denied&state=" + state, flowCookie, http.StatusUnauthorized},
+		{"неизвестный код", "?code=abc&state=" + state, flowCookie, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback"+tt.query, nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+			auth.OIDCCallback(w, r)
+
+			assert.Equal(t, tt.want, w.Code)
+			for _, c := range w.Result().Cookies() {
+				assert.NotEqual(t, DefaultCookieOptions().Name, c.Name, "сессия не должна выдаваться")
+			}
+		})
+	}
+}
+
+func TestOIDCCallback_Disabled(t *testing.T) {
+	auth := newTestAuthenticator(t)
+	w := httptest.NewRecorder()
+	auth.OIDCLogin(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOIDCProvider_Exchange_RequiresPKCE(t *testing.T) {
+	provider := newOIDCTestProvider(t)
+	_, _, p := newOIDCTestApp(t, provider)
+
+	// получаем код, но обмениваем его с другим code_verifier
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirect.Get(p.AuthCodeURL("state", "nonce", "verifier-verifier-verifier-verifier-verifier"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	_, err = p.Exchange(context.Background(), location.Query().Get("code"), "another-verifier-another-verifier-another")
+	assert.ErrorIs(t, err, ErrOIDCExchange)
+}
+
+func TestOIDCProvider_VerifyIDToken(t *testing.T) {
+	provider := newOIDCTestProvider(t)
+	_, _, p := newOIDCTestApp(t, provider)
+	ctx := context.Background()
+
+	claims := func(mutate func(jwt.MapClaims)) string {
+		c := jwt.MapClaims{
+			"iss":   provider.Issuer(),
+			"sub":   "user-1",
+			"aud":   "shortener",
+			"nonce": "n1",
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}
+		if mutate != nil {
+			mutate(c)
+		}
+		return provider.SignIDToken(c)
+	}
+
+	got, err := p.VerifyIDToken(ctx, claims(nil), "n1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", got.Subject)
+
+	for name, token := range map[string]string{
+		"издатель":    claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }),
+		"аудитория":   claims(func(c jwt.MapClaims) { c["aud"] = "other" }),
+		"azp":         claims(func(c jwt.MapClaims) { c["aud"] = []string{"shortener", "other"} }),
+		"истек":       claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }),
+		"без exp":     claims(func(c jwt.MapClaims) { delete(c, "exp") }),
+		"без sub":     claims(func(c jwt.MapClaims) { delete(c, "sub") }),
+		"чужой nonce": claims(func(c jwt.MapClaims) { c["nonce"] = "n2" }),
+	} {
+		_, err := p.VerifyIDToken(ctx, token, "n1")
+		assert.ErrorIs(t, err, ErrInvalidIDToken, name)
+	}
+}
+
+func TestOIDCProvider_JWKSRotation(t *testing.T) {
+	provider := newOIDCTestProvider(t)
+	_, _, p := newOIDCTestApp(t, provider)
+	ctx := context.Background()
+	now := time.Now()
+	p.jwks.now = func() time.Time { return now }
+
+	token := func() string {
+		return provider.SignIDToken(jwt.MapClaims{
+			"iss": provider.Issuer(), "sub": "user-1", "aud": "shortener",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+	}
+
+	_, err := p.VerifyIDToken(ctx, token(), "")
+	require.NoError(t, err)
+	_, err = p.VerifyIDToken(ctx, token(), "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.JWKSRequests())
+
+	// сразу после загрузки незнакомый kid не вызывает повторный запрос
+	provider.RotateKey()
+	rotated := token()
+	_, err = p.VerifyIDToken(ctx, rotated, "")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+	assert.Equal(t, 1, provider.JWKSRequests())
+
+	now = now.Add(jwksMinRefreshInterval + time.Second)
+	_, err = p.VerifyIDToken(ctx, rotated, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, provider.JWKSRequests())
+}
+
+func TestNewOIDCProvider_Errors(t *testing.T) {
+	provider := newOIDCTestProvider(t)
+	ctx := context.Background()
+
+	_, err := NewOIDCProvider(ctx, OIDCOptions{IssuerURL: provider.Issuer(), ClientID: "shortener"})
+	assert.ErrorIs(t, err, ErrOIDCDiscovery)
+
+	_, err = NewOIDCProvider(ctx, OIDCOptions{
+		IssuerURL:   provider.Issuer() + "/other",
+		ClientID:    "shortener",
+		RedirectURL: "http://localhost/callback",
+	})
+	assert.ErrorIs(t, err, ErrOIDCDiscovery)
+}
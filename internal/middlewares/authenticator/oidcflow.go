@@ -0,0 +1,182 @@
+package authenticator
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	oidcFlowCookieName = "oidc_flow"
+	oidcFlowCookiePath = "/api/auth/oidc"
+	// время на вход у провайдера
+	oidcFlowLifetime = 10 * time.Minute
+)
+
+var ErrOIDCState = errors.New("некорректное состояние входа через OIDC")
+
+// Данные незавершенного входа, хранятся в подписанной куке до возврата от провайдера
+type oidcFlow struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	IssuedAt int64  `json:"t"`
+}
+
+// SetOIDCProvider включает вход через внешний OIDC-провайдер
+func (a *Authenticator) SetOIDCProvider(p *OIDCProvider) {
+	a.oidc = p
+}
+
+// OIDCLogin перенаправляет пользователя на страницу входа провайдера
+func (a *Authenticator) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if a.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	flow := oidcFlow{IssuedAt: a.now().Unix()}
+	for _, v := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		s, err := randomToken()
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		*v = s
+	}
+
+	cookie, err := a.flowCookie(flow)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, cookie)
+	http.Redirect(w, r, a.oidc.AuthCodeURL(flow.State, flow.Nonce, flow.Verifier), http.StatusFound)
+}
+
+// OIDCCallback завершает вход: обменивает код на ID-токен,
+// проверяет его и выдает сессию пользователю, соответствующему sub
+func (a *Authenticator) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if a.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// кука одноразовая при любом исходе
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookieName,
+		Path:     oidcFlowCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.cookie.Secure,
+	})
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flow, err := a.readFlowCookie(r)
+	if err != nil || query.Get("code") == "" ||
+		!hmac.Equal([]byte(query.Get("state")), []byte(flow.State)) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	rawIDToken, err := a.oidc.Exchange(r.Context(), query.Get("code"), flow.Verifier)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	claims, err := a.oidc.VerifyIDToken(r.Context(), rawIDToken, flow.Nonce)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := a.IssueSession(w, a.oidc.UserID(claims)); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, a.oidc.opts.PostLoginURL, http.StatusFound)
+}
+
+// Формат значения: <id ключа>.<base64url(JSON)>.<подпись>
+func (a *Authenticator) flowCookie(flow oidcFlow) (*http.Cookie, error) {
+	key, err := a.keys.signingKey(a.now())
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(flow)
+	if err != nil {
+		return nil, err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return &http.Cookie{
+		Name:     oidcFlowCookieName,
+		Value:    fmt.Sprintf("%s.%s.%s", key.ID, payload, sign(key, payload)),
+		MaxAge:   int(oidcFlowLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   a.cookie.Secure,
+		// провайдер возвращает пользователя межсайтовым переходом,
+		// со Strict кука до обратного вызова не дойдет
+		SameSite: http.SameSiteLaxMode,
+		Path:     oidcFlowCookiePath,
+	}, nil
+}
+
+func (a *Authenticator) readFlowCookie(r *http.Request) (oidcFlow, error) {
+	cookie, err := r.Cookie(oidcFlowCookieName)
+	if err != nil {
+		return oidcFlow{}, ErrOIDCState
+	}
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		return oidcFlow{}, ErrOIDCState
+	}
+	keyID, payload, signature := parts[0], parts[1], parts[2]
+
+	now := a.now()
+	key, ok := a.keys.lookup(keyID, now)
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(key, payload))) {
+		return oidcFlow{}, ErrOIDCState
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return oidcFlow{}, ErrOIDCState
+	}
+	var flow oidcFlow
+	if err := json.Unmarshal(data, &flow); err != nil {
+		return oidcFlow{}, ErrOIDCState
+	}
+	if now.Sub(time.Unix(flow.IssuedAt, 0)) > oidcFlowLifetime {
+		return oidcFlow{}, ErrOIDCState
+	}
+	return flow, nil
+}
+
+// 32 случайных байта в base64url - 43 символа, подходит и как code_verifier (RFC 7636)
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Метод S256: BASE64URL(SHA256(code_verifier))
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
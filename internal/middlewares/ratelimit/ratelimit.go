@@ -0,0 +1,322 @@
+// Пакет ratelimit ограничивает частоту запросов к маршрутам по алгоритму
+// корзины токенов. Корзина своя у каждого API-ключа, пользователя или
+// IP-адреса клиента, лимиты задаются отдельно для каждого маршрута
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+// Маршруты, для которых задаются лимиты
+const (
+	RouteCreate   = "create"   // создание одной ссылки
+	RouteBatch    = "batch"    // пакетное создание ссылок
+	RouteRedirect = "redirect" // переход по короткой ссылке
+	RoutePassword = "password" // неверные пароли защищенной ссылки
+	RouteLogin    = "login"    // неверные пароли при входе в учетную запись
+)
+
+var ErrInvalidLimit = errors.New("некорректный лимит частоты запросов")
+
+// Лимит корзины: Rate токенов в секунду, не больше Burst сразу
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// время, за которое в корзину добавится tokens токенов
+func (l Limit) fill(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens / l.Rate * float64(time.Second)))
+}
+
+var limitUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// ParseLimit разбирает лимит вида 20/m или 20/m:40 - 20 запросов
+// в минуту, до 40 подряд. Без второго числа Burst равен числу запросов
+func ParseLimit(spec string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(spec), ":")
+	count, unit, ok := strings.Cut(rate, "/")
+	period, known := limitUnits[unit]
+	n, err := strconv.Atoi(count)
+	if !ok || !known || err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q, ожидается число/s|m|h[:burst]", ErrInvalidLimit, spec)
+	}
+	limit := Limit{Rate: float64(n) / period.Seconds(), Burst: n}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst <= 0 {
+			return Limit{}, fmt.Errorf("%w: %q, burst должен быть положительным числом", ErrInvalidLimit, spec)
+		}
+	}
+	return limit, nil
+}
+
+// ParseLimits разбирает лимиты маршрутов через запятую:
+// create=20/m,batch=5/m,redirect=600/m:100
+func ParseLimits(spec string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(item, "=")
+		route = strings.TrimSpace(route)
+		if !ok || route == "" {
+			return nil, fmt.Errorf("%w: %q, ожидается маршрут=лимит", ErrInvalidLimit, item)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[route] = limit
+	}
+	return limits, nil
+}
+
+// ParseTrustedProxies разбирает адреса и подсети доверенных прокси через запятую
+func ParseTrustedProxies(spec string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, err
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+type Limiter struct {
+	log     zerolog.Logger
+	store   Store
+	limits  map[string]Limit
+	proxies []netip.Prefix // nil - X-Forwarded-For не учитывается
+}
+
+func NewLimiter(store Store, limits map[string]Limit, logger zerolog.Logger) *Limiter {
+	return &Limiter{
+		log:    logger,
+		store:  store,
+		limits: limits,
+	}
+}
+
+// SetTrustedProxies задает прокси, за которыми стоит сервис. Для запросов
+// от них адрес клиента берется из X-Forwarded-For
+func (l *Limiter) SetTrustedProxies(proxies []netip.Prefix) {
+	l.proxies = proxies
+}
+
+// Route ограничивает частоту запросов к маршруту route. Без лимита
+// для маршрута, как и у nil-ограничителя, запросы проходят как есть.
+// Ставится после мидлвари аутентификации, чтобы знать пользователя
+func (l *Limiter) Route(route string, next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	limit, ok := l.limits[route]
+	if !ok {
+		return next
+	}
+	policy := fmt.Sprintf("%d;w=%d", limit.Burst, int(math.Ceil(limit.fill(float64(limit.Burst)).Seconds())))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := l.clientKey(r)
+		result, err := l.store.Take(r.Context(), route+":"+client, limit)
+		if err != nil {
+			// недоступное хранилище лимитов не должно останавливать сервис
+			l.log.Error().Err(err).Str("route", route).Msg("не удалось проверить лимит частоты запросов")
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Policy", policy)
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", seconds(result.Reset))
+		if !result.Allowed {
+			l.log.Info().Str("route", route).Str("client", client).Msg("превышен лимит частоты запросов")
+			TooManyRequests(w, result.RetryAfter)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ключ контекста, под которым Failures передает обработчику состояние попыток
+type attemptsKey struct{}
+
+type attempts struct {
+	limiter *Limiter
+	route   string
+	limit   Limit
+	client  string
+}
+
+// Failures ограничивает частоту неудачных попыток на маршруте, например
+// неверных паролей. Сам маршрут не ограничивается: обработчик вызывает
+// Attempt перед каждой проверкой
+func (l *Limiter) Failures(route string, next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	limit, ok := l.limits[route]
+	if !ok {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// попытки считаются по адресу: анонимную личность ничего не стоит получить заново
+		a := &attempts{limiter: l, route: route, limit: limit, client: "ip:" + l.clientIP(r)}
+		next(w, r.WithContext(context.WithValue(r.Context(), attemptsKey{}, a)))
+	}
+}
+
+// Attempt до проверки берет по токену из корзины клиента и из общей
+// корзины объекта target (например, короткого кода), чтобы подбор
+// с многих адресов тоже упирался в лимит. Токен берется атомарно, поэтому
+// параллельные попытки не проходят сверх лимита. Если одна из корзин пуста,
+// ok равно false, а retryAfter - время до следующей попытки.
+// После проверки вызывается finish: при успехе токены возвращаются,
+// и лимит расходуют только неудачные попытки. Вне Failures попытки
+// не ограничиваются
+func Attempt(r *http.Request, target string) (finish func(failed bool), retryAfter time.Duration, ok bool) {
+	a, _ := r.Context().Value(attemptsKey{}).(*attempts)
+	if a == nil {
+		return func(bool) {}, 0, true
+	}
+	l := a.limiter
+	ctx := r.Context()
+	keys := []string{a.route + ":" + a.client, a.route + ":target:" + target}
+
+	var taken []Result
+	for _, key := range keys {
+		result, err := l.store.Take(ctx, key, a.limit)
+		if err != nil {
+			// недоступное хранилище лимитов не должно останавливать сервис
+			l.log.Error().Err(err).Str("route", a.route).Msg("не удалось проверить лимит неудачных попыток")
+			taken = append(taken, Result{})
+			continue
+		}
+		if !result.Allowed {
+			l.log.Info().Str("route", a.route).Str("key", key).Msg("превышен лимит неудачных попыток")
+			l.give(ctx, a, keys, taken)
+			return nil, result.RetryAfter, false
+		}
+		taken = append(taken, result)
+	}
+
+	return func(failed bool) {
+		if !failed {
+			l.give(context.WithoutCancel(ctx), a, keys, taken)
+		}
+	}, 0, true
+}
+
+// give возвращает взятые токены в корзины
+func (l *Limiter) give(ctx context.Context, a *attempts, keys []string, taken []Result) {
+	for i, result := range taken {
+		if !result.Allowed {
+			continue
+		}
+		if err := l.store.Give(ctx, keys[i], a.limit, result); err != nil {
+			l.log.Error().Err(err).Str("route", a.route).Msg("не удалось вернуть токен в корзину")
+		}
+	}
+}
+
+// TooManyRequests отвечает 429 с заголовком Retry-After
+func TooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", seconds(retryAfter))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+// clientKey выбирает, чью корзину расходует запрос: API-ключа, пользователя
+// или IP-адреса. Новая анонимная личность выдается каждому запросу без куки,
+// поэтому такие запросы считаются по адресу
+func (l *Limiter) clientKey(r *http.Request) string {
+	ctx := r.Context()
+	if keyID, ok := ctx.Value(domain.APIKeyIDKey{}).(string); ok && keyID != "" {
+		return "key:" + keyID
+	}
+	fresh, _ := ctx.Value(domain.NewIdentityKey{}).(bool)
+	if userID, ok := ctx.Value(domain.UserIDKey{}).(string); ok && userID != "" && !fresh {
+		return "user:" + userID
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+// clientIP возвращает адрес клиента. Если запрос пришел от доверенного
+// прокси, X-Forwarded-For читается справа налево до первого адреса
+// не из доверенных: левее него значения мог подставить сам клиент
+func (l *Limiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+	if !l.trusted(addr) {
+		return addr.String()
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !l.trusted(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func (l *Limiter) trusted(addr netip.Addr) bool {
+	for _, prefix := range l.proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// целое число секунд с округлением вверх, как в Retry-After
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
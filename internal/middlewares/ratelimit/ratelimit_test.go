@@ -0,0 +1,270 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/pkg/resp"
+	"github.com/physicist2018/url-shortener-go/pkg/resp/resptest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("create=20/m, batch=5/s:10 ,redirect=3600/h")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Limit{
+		"create":   {Rate: 20.0 / 60, Burst: 20},
+		"batch":    {Rate: 5, Burst: 10},
+		"redirect": {Rate: 1, Burst: 3600},
+	}, limits)
+
+	limits, err = ParseLimits("")
+	require.NoError(t, err)
+	assert.Empty(t, limits)
+
+	for _, spec := range []string{"create", "create=20", "create=20/d", "create=0/m", "create=20/m:0", "=20/m"} {
+		_, err := ParseLimits(spec)
+		assert.True(t, errors.Is(err, ErrInvalidLimit), spec)
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 2}
+
+	for i, want := range []bool{true, true, false} {
+		result, err := store.Take(ctx, "k", limit)
+		require.NoError(t, err)
+		assert.Equal(t, want, result.Allowed, i)
+	}
+	result, err := store.Take(ctx, "k", limit)
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 2*time.Second, result.Reset)
+
+	// у другого ключа своя корзина
+	result, err = store.Take(ctx, "other", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	now = now.Add(1500 * time.Millisecond)
+	result, err = store.Take(ctx, "k", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	// наполнившиеся корзины удаляются
+	now = now.Add(time.Hour)
+	_, err = store.Take(ctx, "k", limit)
+	require.NoError(t, err)
+	assert.Len(t, store.buckets, 1)
+}
+
+// Две реплики с общим хранилищем расходуют одну корзину
+func TestRESPStore_Shared(t *testing.T) {
+	ctx := context.Background()
+	srv := resptest.NewServer()
+	defer srv.Close()
+	now := time.Unix(1_700_000_080, 0) // за 20 секунд до конца минутного окна
+	newStore := func() *RESPStore {
+		client := resp.NewClient(resp.Options{Addr: srv.Addr})
+		t.Cleanup(func() { client.Close() })
+		store := NewRESPStore(client)
+		store.now = func() time.Time { return now }
+		return store
+	}
+	replicaA, replicaB := newStore(), newStore()
+	limit := Limit{Rate: 2.0 / 60, Burst: 2} // окно - минута
+
+	result, err := replicaA.Take(ctx, "create:ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, result.Remaining)
+	result, err = replicaB.Take(ctx, "create:ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	result, err = replicaA.Take(ctx, "create:ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 20*time.Second, result.RetryAfter)
+
+	// в следующем окне счет начинается заново
+	now = now.Add(20 * time.Second)
+	result, err = replicaB.Take(ctx, "create:ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (Result, error) {
+	return Result{}, resp.ErrClosed
+}
+
+func (failingStore) Give(context.Context, string, Limit, Result) error {
+	return resp.ErrClosed
+}
+
+func TestLimiter_Route(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), map[string]Limit{RouteCreate: {Rate: 1.0 / 60, Burst: 1}}, zerolog.New(nil))
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.10")
+	require.NoError(t, err)
+	limiter.SetTrustedProxies(proxies)
+	handler := limiter.Route(RouteCreate, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	call := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+	request := func(remoteAddr string, values map[any]any) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.RemoteAddr = remoteAddr
+		ctx := r.Context()
+		for k, v := range values {
+			ctx = context.WithValue(ctx, k, v)
+		}
+		return r.WithContext(ctx)
+	}
+
+	w := call(request("198.51.100.1:1234", map[any]any{domain.UserIDKey{}: "u1"}))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "1;w=60", w.Header().Get("RateLimit-Policy"))
+
+	w = call(request("198.51.100.2:1234", map[any]any{domain.UserIDKey{}: "u1"}))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// API-ключ того же пользователя расходует свою корзину
+	w = call(request("198.51.100.1:1234", map[any]any{domain.UserIDKey{}: "u1", domain.APIKeyIDKey{}: "key1"}))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// новые анонимные личности считаются по адресу
+	w = call(request("198.51.100.3:1234", map[any]any{domain.UserIDKey{}: "anon1", domain.NewIdentityKey{}: true}))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = call(request("198.51.100.3:1234", map[any]any{domain.UserIDKey{}: "anon2", domain.NewIdentityKey{}: true}))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// за доверенным прокси адрес берется из X-Forwarded-For, подставленное клиентом левее не учитывается
+	r := request("10.1.2.3:1234", nil)
+	r.Header.Set("X-Forwarded-For", "198.51.100.3, 203.0.113.7, 192.0.2.10")
+	assert.Equal(t, "203.0.113.7", limiter.clientIP(r))
+	assert.Equal(t, http.StatusCreated, call(r).Code)
+	// от недоверенного адреса заголовок игнорируется
+	r = request("203.0.113.9:1234", nil)
+	r.Header.Set("X-Forwarded-For", "198.51.100.3")
+	assert.Equal(t, "203.0.113.9", limiter.clientIP(r))
+}
+
+func TestLimiter_FailOpen(t *testing.T) {
+	limiter := NewLimiter(failingStore{}, map[string]Limit{RouteRedirect: {Rate: 1, Burst: 1}}, zerolog.New(nil))
+	handler := limiter.Route(RouteRedirect, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/abc12", nil))
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	}
+
+	// маршрут без лимита и nil-ограничитель пропускают запросы
+	var nilLimiter *Limiter
+	assert.NotNil(t, nilLimiter.Route(RouteCreate, handler))
+	assert.NotNil(t, limiter.Route(RouteBatch, handler))
+}
+
+func TestLimiter_Failures(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	client := resp.NewClient(resp.Options{Addr: srv.Addr})
+	defer client.Close()
+
+	stores := map[string]func() Store{
+		"memory": func() Store { return NewMemoryStore() },
+		"resp":   func() Store { return NewRESPStore(client) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			newHandler := func() http.HandlerFunc {
+				limiter := NewLimiter(newStore(), map[string]Limit{RoutePassword: {Rate: 2.0 / 3600, Burst: 2}}, zerolog.New(nil))
+				return limiter.Failures(RoutePassword, func(w http.ResponseWriter, r *http.Request) {
+					finish, retryAfter, ok := Attempt(r, r.URL.Path)
+					if !ok {
+						TooManyRequests(w, retryAfter)
+						return
+					}
+					failed := r.URL.Query().Get("password") != "secret"
+					finish(failed)
+					if failed {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					w.WriteHeader(http.StatusSeeOther)
+				})
+			}
+			call := func(handler http.HandlerFunc, ip, code, password string) int {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodPost, "/"+code+"?password="+password, nil)
+				r.RemoteAddr = ip + ":1234"
+				handler(w, r)
+				return w.Code
+			}
+
+			handler := newHandler()
+			// удачные попытки не расходуют токены
+			for i := 0; i < 3; i++ {
+				assert.Equal(t, http.StatusSeeOther, call(handler, "198.51.100.1", "abc12", "secret"))
+			}
+			assert.Equal(t, http.StatusForbidden, call(handler, "198.51.100.1", "abc12", "wrong"))
+			assert.Equal(t, http.StatusForbidden, call(handler, "198.51.100.1", "abc12", "wrong"))
+			// после исчерпания лимита не проверяется даже верный пароль
+			assert.Equal(t, http.StatusTooManyRequests, call(handler, "198.51.100.1", "abc12", "secret"))
+			// подбор к той же ссылке с другого адреса упирается в лимит ссылки
+			assert.Equal(t, http.StatusTooManyRequests, call(handler, "198.51.100.2", "abc12", "secret"))
+			// отказ по лимиту ссылки не расходует лимит клиента
+			assert.Equal(t, http.StatusSeeOther, call(handler, "198.51.100.2", "xyz34", "secret"))
+
+			// параллельные попытки не проходят сверх лимита
+			handler = newHandler()
+			codes := make(chan int, 10)
+			var wg sync.WaitGroup
+			for i := 0; i < cap(codes); i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					codes <- call(handler, "198.51.100.3", "par56", "wrong")
+				}()
+			}
+			wg.Wait()
+			close(codes)
+			forbidden := 0
+			for code := range codes {
+				if code == http.StatusForbidden {
+					forbidden++
+				}
+			}
+			assert.Equal(t, 2, forbidden)
+		})
+	}
+
+	// Attempt вне Failures не ограничивает попытки
+	finish, _, ok := Attempt(httptest.NewRequest(http.MethodGet, "/", nil), "abc12")
+	assert.True(t, ok)
+	finish(true)
+}
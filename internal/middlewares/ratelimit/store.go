@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/pkg/resp"
+)
+
+// как часто память очищается от корзин, которые уже наполнились
+const sweepInterval = time.Minute
+
+// Результат попытки взять токен
+type Result struct {
+	Allowed    bool
+	Remaining  int           // сколько запросов еще можно сделать сразу
+	Reset      time.Duration // через сколько корзина наполнится целиком
+	RetryAfter time.Duration // через сколько появится токен, если запрос отклонен
+	counter    string        // ключ счетчика окна в RESPStore, в которое взят токен
+}
+
+// Хранилище состояния корзин
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Give возвращает токен, взятый Take с результатом taken
+	Give(ctx context.Context, key string, limit Limit, taken Result) error
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	fullAt  time.Time // с этого момента корзина не отличается от новой
+}
+
+// Корзины в памяти процесса: у каждой реплики сервиса свои
+type MemoryStore struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+
+	result := Result{Allowed: b.tokens >= 1}
+	if result.Allowed {
+		b.tokens--
+	} else {
+		result.RetryAfter = limit.fill(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = limit.fill(float64(limit.Burst) - b.tokens)
+	b.fullAt = now.Add(result.Reset)
+	return result, nil
+}
+
+func (s *MemoryStore) Give(ctx context.Context, key string, limit Limit, taken Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// корзину могли убрать как наполнившуюся
+	if b, ok := s.buckets[key]; ok {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+		b.fullAt = b.updated.Add(limit.fill(float64(limit.Burst) - b.tokens))
+	}
+	return nil
+}
+
+// sweep удаляет корзины, которые уже наполнились целиком. Вызывается под s.mu
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// Корзины в Redis-совместимом хранилище, общие для всех реплик.
+// Команды RESP, которые поддерживает клиент, не позволяют атомарно
+// прочитать и изменить корзину, поэтому она заменяется счетчиком
+// запросов в окне, за которое корзина наполняется целиком: в окне
+// пропускается Burst запросов. Счетчик увеличивается атомарно INCR
+type RESPStore struct {
+	client *resp.Client
+	prefix string
+	now    func() time.Time
+}
+
+func NewRESPStore(client *resp.Client) *RESPStore {
+	return &RESPStore{
+		client: client,
+		prefix: "shortener:ratelimit:",
+		now:    time.Now,
+	}
+}
+
+func (s *RESPStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	counterKey, window, reset := s.window(key, limit)
+	count, err := s.client.IncrBy(ctx, counterKey, 1)
+	if err != nil {
+		return Result{}, err
+	}
+	if count == 1 {
+		// счетчик прошлого окна должен исчезнуть сам
+		if err := s.client.PExpire(ctx, counterKey, window+time.Second); err != nil {
+			return Result{}, err
+		}
+	}
+	result := windowResult(count, limit, reset)
+	result.counter = counterKey
+	return result, nil
+}
+
+// Give уменьшает счетчик того окна, в котором взят токен. Если окно
+// уже закончилось, токен вернулся вместе с новым окном, а счетчик
+// старого мог истечь: уменьшать его нельзя
+func (s *RESPStore) Give(ctx context.Context, key string, limit Limit, taken Result) error {
+	if counterKey, _, _ := s.window(key, limit); taken.counter != counterKey {
+		return nil
+	}
+	_, err := s.client.IncrBy(ctx, taken.counter, -1)
+	return err
+}
+
+// window возвращает ключ счетчика текущего окна, длину окна и время до его конца
+func (s *RESPStore) window(key string, limit Limit) (string, time.Duration, time.Duration) {
+	window := limit.fill(float64(limit.Burst))
+	now := s.now()
+	index := now.UnixNano() / int64(window)
+	reset := time.Duration((index+1)*int64(window) - now.UnixNano())
+	return s.prefix + key + ":" + strconv.FormatInt(index, 10), window, reset
+}
+
+// результат для count-го запроса в окне
+func windowResult(count int64, limit Limit, reset time.Duration) Result {
+	result := Result{
+		Allowed:   count <= int64(limit.Burst),
+		Remaining: max(limit.Burst-int(count), 0),
+		Reset:     reset,
+	}
+	if !result.Allowed {
+		result.RetryAfter = reset
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+)
+
+// Заголовок с идентификатором запроса во входящем запросе и в ответе
+const Header = "X-Request-ID"
+
+// максимальная длина идентификатора, который принимается от клиента
+const maxLength = 64
+
+// Middleware кладет в контекст идентификатор запроса: пришедший от клиента
+// или прокси, если он допустимый, иначе новый. Идентификатор возвращается
+// в ответе, чтобы по нему можно было найти запрос в журнале аудита
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = generate()
+		}
+		w.Header().Set(Header, id)
+		ctx := context.WithValue(r.Context(), domain.RequestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// допускаются только буквы, цифры и символы "-_.:", чтобы идентификатор
+// нельзя было использовать для подделки строк журнала
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func generate() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -50,6 +50,21 @@ func (mr *MockURLLinkServiceMockRecorder) CreateShortURL(ctx, link interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShortURL", reflect.TypeOf((*MockURLLinkService)(nil).CreateShortURL), ctx, link)
 }
 
+// CreateShortURLBatch mocks base method.
+func (m *MockURLLinkService) CreateShortURLBatch(ctx context.Context, links []domain.URLLink) ([]domain.URLLink, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateShortURLBatch", ctx, links)
+	ret0, _ := ret[0].([]domain.URLLink)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateShortURLBatch indicates an expected call of CreateShortURLBatch.
+func (mr *MockURLLinkServiceMockRecorder) CreateShortURLBatch(ctx, links interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShortURLBatch", reflect.TypeOf((*MockURLLinkService)(nil).CreateShortURLBatch), ctx, links)
+}
+
 // FindAll mocks base method.
 func (m *MockURLLinkService) FindAll(ctx context.Context, userID string) ([]domain.URLLink, error) {
 	m.ctrl.T.Helper()
@@ -80,6 +95,21 @@ func (mr *MockURLLinkServiceMockRecorder) GetOriginalURL(ctx, link interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOriginalURL", reflect.TypeOf((*MockURLLinkService)(nil).GetOriginalURL), ctx, link)
 }
 
+// LinkVersions mocks base method.
+func (m *MockURLLinkService) LinkVersions(ctx context.Context, userID, shortURL string) ([]domain.LinkVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkVersions", ctx, userID, shortURL)
+	ret0, _ := ret[0].([]domain.LinkVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LinkVersions indicates an expected call of LinkVersions.
+func (mr *MockURLLinkServiceMockRecorder) LinkVersions(ctx, userID, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkVersions", reflect.TypeOf((*MockURLLinkService)(nil).LinkVersions), ctx, userID, shortURL)
+}
+
 // MarkURLsAsDeleted mocks base method.
 func (m *MockURLLinkService) MarkURLsAsDeleted(ctx context.Context, links []domain.URLLink) error {
 	m.ctrl.T.Helper()
@@ -107,3 +137,523 @@ func (mr *MockURLLinkServiceMockRecorder) Ping(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockURLLinkService)(nil).Ping), ctx)
 }
+
+// Quota mocks base method.
+func (m *MockURLLinkService) Quota(ctx context.Context, userID string) (domain.QuotaUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Quota", ctx, userID)
+	ret0, _ := ret[0].(domain.QuotaUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Quota indicates an expected call of Quota.
+func (mr *MockURLLinkServiceMockRecorder) Quota(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Quota", reflect.TypeOf((*MockURLLinkService)(nil).Quota), ctx, userID)
+}
+
+// UpdateLink mocks base method.
+func (m *MockURLLinkService) UpdateLink(ctx context.Context, userID, shortURL string, update domain.URLLinkUpdate) (domain.URLLink, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLink", ctx, userID, shortURL, update)
+	ret0, _ := ret[0].(domain.URLLink)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateLink indicates an expected call of UpdateLink.
+func (mr *MockURLLinkServiceMockRecorder) UpdateLink(ctx, userID, shortURL, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLink", reflect.TypeOf((*MockURLLinkService)(nil).UpdateLink), ctx, userID, shortURL, update)
+}
+
+// MockAPIKeyService is a mock of APIKeyService interface.
+type MockAPIKeyService struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyServiceMockRecorder
+}
+
+// MockAPIKeyServiceMockRecorder is the mock recorder for MockAPIKeyService.
+type MockAPIKeyServiceMockRecorder struct {
+	mock *MockAPIKeyService
+}
+
+// NewMockAPIKeyService creates a new mock instance.
+func NewMockAPIKeyService(ctrl *gomock.Controller) *MockAPIKeyService {
+	mock := &MockAPIKeyService{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyService) EXPECT() *MockAPIKeyServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockAPIKeyService) CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (domain.APIKey, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, userID, name, scopes)
+	ret0, _ := ret[0].(domain.APIKey)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockAPIKeyServiceMockRecorder) CreateAPIKey(ctx, userID, name, scopes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockAPIKeyService)(nil).CreateAPIKey), ctx, userID, name, scopes)
+}
+
+// ListAPIKeys mocks base method.
+func (m *MockAPIKeyService) ListAPIKeys(ctx context.Context, userID string) ([]domain.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAPIKeys", ctx, userID)
+	ret0, _ := ret[0].([]domain.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAPIKeys indicates an expected call of ListAPIKeys.
+func (mr *MockAPIKeyServiceMockRecorder) ListAPIKeys(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAPIKeys", reflect.TypeOf((*MockAPIKeyService)(nil).ListAPIKeys), ctx, userID)
+}
+
+// ResolveAPIKey mocks base method.
+func (m *MockAPIKeyService) ResolveAPIKey(ctx context.Context, rawKey string) (domain.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveAPIKey", ctx, rawKey)
+	ret0, _ := ret[0].(domain.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolveAPIKey indicates an expected call of ResolveAPIKey.
+func (mr *MockAPIKeyServiceMockRecorder) ResolveAPIKey(ctx, rawKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveAPIKey", reflect.TypeOf((*MockAPIKeyService)(nil).ResolveAPIKey), ctx, rawKey)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockAPIKeyService) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, userID, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockAPIKeyServiceMockRecorder) RevokeAPIKey(ctx, userID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockAPIKeyService)(nil).RevokeAPIKey), ctx, userID, id)
+}
+
+// MockUserService is a mock of UserService interface.
+type MockUserService struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserServiceMockRecorder
+}
+
+// MockUserServiceMockRecorder is the mock recorder for MockUserService.
+type MockUserServiceMockRecorder struct {
+	mock *MockUserService
+}
+
+// NewMockUserService creates a new mock instance.
+func NewMockUserService(ctrl *gomock.Controller) *MockUserService {
+	mock := &MockUserService{ctrl: ctrl}
+	mock.recorder = &MockUserServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserService) EXPECT() *MockUserServiceMockRecorder {
+	return m.recorder
+}
+
+// ClaimLinks mocks base method.
+func (m *MockUserService) ClaimLinks(ctx context.Context, anonymousUserID, login, password string) (domain.User, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimLinks", ctx, anonymousUserID, login, password)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ClaimLinks indicates an expected call of ClaimLinks.
+func (mr *MockUserServiceMockRecorder) ClaimLinks(ctx, anonymousUserID, login, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimLinks", reflect.TypeOf((*MockUserService)(nil).ClaimLinks), ctx, anonymousUserID, login, password)
+}
+
+// Login mocks base method.
+func (m *MockUserService) Login(ctx context.Context, login, password string) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Login", ctx, login, password)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Login indicates an expected call of Login.
+func (mr *MockUserServiceMockRecorder) Login(ctx, login, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUserService)(nil).Login), ctx, login, password)
+}
+
+// Register mocks base method.
+func (m *MockUserService) Register(ctx context.Context, login, password string) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Register", ctx, login, password)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Register indicates an expected call of Register.
+func (mr *MockUserServiceMockRecorder) Register(ctx, login, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockUserService)(nil).Register), ctx, login, password)
+}
+
+// MockWorkspaceService is a mock of WorkspaceService interface.
+type MockWorkspaceService struct {
+	ctrl     *gomock.Controller
+	recorder *MockWorkspaceServiceMockRecorder
+}
+
+// MockWorkspaceServiceMockRecorder is the mock recorder for MockWorkspaceService.
+type MockWorkspaceServiceMockRecorder struct {
+	mock *MockWorkspaceService
+}
+
+// NewMockWorkspaceService creates a new mock instance.
+func NewMockWorkspaceService(ctrl *gomock.Controller) *MockWorkspaceService {
+	mock := &MockWorkspaceService{ctrl: ctrl}
+	mock.recorder = &MockWorkspaceServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWorkspaceService) EXPECT() *MockWorkspaceServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateLink mocks base method.
+func (m *MockWorkspaceService) CreateLink(ctx context.Context, actorID, workspaceID, longURL string) (domain.URLLink, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateLink", ctx, actorID, workspaceID, longURL)
+	ret0, _ := ret[0].(domain.URLLink)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateLink indicates an expected call of CreateLink.
+func (mr *MockWorkspaceServiceMockRecorder) CreateLink(ctx, actorID, workspaceID, longURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLink", reflect.TypeOf((*MockWorkspaceService)(nil).CreateLink), ctx, actorID, workspaceID, longURL)
+}
+
+// CreateWorkspace mocks base method.
+func (m *MockWorkspaceService) CreateWorkspace(ctx context.Context, userID, name string) (domain.Workspace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWorkspace", ctx, userID, name)
+	ret0, _ := ret[0].(domain.Workspace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWorkspace indicates an expected call of CreateWorkspace.
+func (mr *MockWorkspaceServiceMockRecorder) CreateWorkspace(ctx, userID, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWorkspace", reflect.TypeOf((*MockWorkspaceService)(nil).CreateWorkspace), ctx, userID, name)
+}
+
+// DeleteLinks mocks base method.
+func (m *MockWorkspaceService) DeleteLinks(ctx context.Context, actorID, workspaceID string, shortURLs []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLinks", ctx, actorID, workspaceID, shortURLs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLinks indicates an expected call of DeleteLinks.
+func (mr *MockWorkspaceServiceMockRecorder) DeleteLinks(ctx, actorID, workspaceID, shortURLs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLinks", reflect.TypeOf((*MockWorkspaceService)(nil).DeleteLinks), ctx, actorID, workspaceID, shortURLs)
+}
+
+// ListLinks mocks base method.
+func (m *MockWorkspaceService) ListLinks(ctx context.Context, actorID, workspaceID string) ([]domain.URLLink, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLinks", ctx, actorID, workspaceID)
+	ret0, _ := ret[0].([]domain.URLLink)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLinks indicates an expected call of ListLinks.
+func (mr *MockWorkspaceServiceMockRecorder) ListLinks(ctx, actorID, workspaceID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLinks", reflect.TypeOf((*MockWorkspaceService)(nil).ListLinks), ctx, actorID, workspaceID)
+}
+
+// ListMembers mocks base method.
+func (m *MockWorkspaceService) ListMembers(ctx context.Context, actorID, workspaceID string) ([]domain.WorkspaceMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMembers", ctx, actorID, workspaceID)
+	ret0, _ := ret[0].([]domain.WorkspaceMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMembers indicates an expected call of ListMembers.
+func (mr *MockWorkspaceServiceMockRecorder) ListMembers(ctx, actorID, workspaceID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMembers", reflect.TypeOf((*MockWorkspaceService)(nil).ListMembers), ctx, actorID, workspaceID)
+}
+
+// ListWorkspaces mocks base method.
+func (m *MockWorkspaceService) ListWorkspaces(ctx context.Context, userID string) ([]domain.WorkspaceMembership, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWorkspaces", ctx, userID)
+	ret0, _ := ret[0].([]domain.WorkspaceMembership)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWorkspaces indicates an expected call of ListWorkspaces.
+func (mr *MockWorkspaceServiceMockRecorder) ListWorkspaces(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWorkspaces", reflect.TypeOf((*MockWorkspaceService)(nil).ListWorkspaces), ctx, userID)
+}
+
+// MoveLink mocks base method.
+func (m *MockWorkspaceService) MoveLink(ctx context.Context, actorID, shortURL, toWorkspaceID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveLink", ctx, actorID, shortURL, toWorkspaceID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MoveLink indicates an expected call of MoveLink.
+func (mr *MockWorkspaceServiceMockRecorder) MoveLink(ctx, actorID, shortURL, toWorkspaceID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveLink", reflect.TypeOf((*MockWorkspaceService)(nil).MoveLink), ctx, actorID, shortURL, toWorkspaceID)
+}
+
+// RemoveMember mocks base method.
+func (m *MockWorkspaceService) RemoveMember(ctx context.Context, actorID, workspaceID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMember", ctx, actorID, workspaceID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMember indicates an expected call of RemoveMember.
+func (mr *MockWorkspaceServiceMockRecorder) RemoveMember(ctx, actorID, workspaceID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMember", reflect.TypeOf((*MockWorkspaceService)(nil).RemoveMember), ctx, actorID, workspaceID, userID)
+}
+
+// SetMemberRole mocks base method.
+func (m *MockWorkspaceService) SetMemberRole(ctx context.Context, actorID, workspaceID, userID string, role domain.WorkspaceRole) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMemberRole", ctx, actorID, workspaceID, userID, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMemberRole indicates an expected call of SetMemberRole.
+func (mr *MockWorkspaceServiceMockRecorder) SetMemberRole(ctx, actorID, workspaceID, userID, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMemberRole", reflect.TypeOf((*MockWorkspaceService)(nil).SetMemberRole), ctx, actorID, workspaceID, userID, role)
+}
+
+// MockAdminService is a mock of AdminService interface.
+type MockAdminService struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminServiceMockRecorder
+}
+
+// MockAdminServiceMockRecorder is the mock recorder for MockAdminService.
+type MockAdminServiceMockRecorder struct {
+	mock *MockAdminService
+}
+
+// NewMockAdminService creates a new mock instance.
+func NewMockAdminService(ctrl *gomock.Controller) *MockAdminService {
+	mock := &MockAdminService{ctrl: ctrl}
+	mock.recorder = &MockAdminServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminService) EXPECT() *MockAdminServiceMockRecorder {
+	return m.recorder
+}
+
+// AuditEntries mocks base method.
+func (m *MockAdminService) AuditEntries(ctx context.Context, actor string, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuditEntries", ctx, actor, filter)
+	ret0, _ := ret[0].([]domain.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuditEntries indicates an expected call of AuditEntries.
+func (mr *MockAdminServiceMockRecorder) AuditEntries(ctx, actor, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditEntries", reflect.TypeOf((*MockAdminService)(nil).AuditEntries), ctx, actor, filter)
+}
+
+// BlockDomain mocks base method.
+func (m *MockAdminService) BlockDomain(ctx context.Context, actor, domainName, reason string) (domain.DomainBlock, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockDomain", ctx, actor, domainName, reason)
+	ret0, _ := ret[0].(domain.DomainBlock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockDomain indicates an expected call of BlockDomain.
+func (mr *MockAdminServiceMockRecorder) BlockDomain(ctx, actor, domainName, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockDomain", reflect.TypeOf((*MockAdminService)(nil).BlockDomain), ctx, actor, domainName, reason)
+}
+
+// DeleteLink mocks base method.
+func (m *MockAdminService) DeleteLink(ctx context.Context, actor, shortURL string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLink", ctx, actor, shortURL)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLink indicates an expected call of DeleteLink.
+func (mr *MockAdminServiceMockRecorder) DeleteLink(ctx, actor, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLink", reflect.TypeOf((*MockAdminService)(nil).DeleteLink), ctx, actor, shortURL)
+}
+
+// ListBlockedDomains mocks base method.
+func (m *MockAdminService) ListBlockedDomains(ctx context.Context, actor string) ([]domain.DomainBlock, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBlockedDomains", ctx, actor)
+	ret0, _ := ret[0].([]domain.DomainBlock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBlockedDomains indicates an expected call of ListBlockedDomains.
+func (mr *MockAdminServiceMockRecorder) ListBlockedDomains(ctx, actor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBlockedDomains", reflect.TypeOf((*MockAdminService)(nil).ListBlockedDomains), ctx, actor)
+}
+
+// ListUserLinks mocks base method.
+func (m *MockAdminService) ListUserLinks(ctx context.Context, actor, userID string) ([]domain.URLLink, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUserLinks", ctx, actor, userID)
+	ret0, _ := ret[0].([]domain.URLLink)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUserLinks indicates an expected call of ListUserLinks.
+func (mr *MockAdminServiceMockRecorder) ListUserLinks(ctx, actor, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUserLinks", reflect.TypeOf((*MockAdminService)(nil).ListUserLinks), ctx, actor, userID)
+}
+
+// LookupLink mocks base method.
+func (m *MockAdminService) LookupLink(ctx context.Context, actor, shortURL string) (domain.AdminLinkInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LookupLink", ctx, actor, shortURL)
+	ret0, _ := ret[0].(domain.AdminLinkInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LookupLink indicates an expected call of LookupLink.
+func (mr *MockAdminServiceMockRecorder) LookupLink(ctx, actor, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupLink", reflect.TypeOf((*MockAdminService)(nil).LookupLink), ctx, actor, shortURL)
+}
+
+// PurgeLink mocks base method.
+func (m *MockAdminService) PurgeLink(ctx context.Context, actor, shortURL string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeLink", ctx, actor, shortURL)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeLink indicates an expected call of PurgeLink.
+func (mr *MockAdminServiceMockRecorder) PurgeLink(ctx, actor, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeLink", reflect.TypeOf((*MockAdminService)(nil).PurgeLink), ctx, actor, shortURL)
+}
+
+// RestoreLink mocks base method.
+func (m *MockAdminService) RestoreLink(ctx context.Context, actor, shortURL string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreLink", ctx, actor, shortURL)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreLink indicates an expected call of RestoreLink.
+func (mr *MockAdminServiceMockRecorder) RestoreLink(ctx, actor, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreLink", reflect.TypeOf((*MockAdminService)(nil).RestoreLink), ctx, actor, shortURL)
+}
+
+// Stats mocks base method.
+func (m *MockAdminService) Stats(ctx context.Context, actor string) (domain.SystemStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", ctx, actor)
+	ret0, _ := ret[0].(domain.SystemStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockAdminServiceMockRecorder) Stats(ctx, actor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockAdminService)(nil).Stats), ctx, actor)
+}
+
+// UnblockDomain mocks base method.
+func (m *MockAdminService) UnblockDomain(ctx context.Context, actor, domainName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnblockDomain", ctx, actor, domainName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnblockDomain indicates an expected call of UnblockDomain.
+func (mr *MockAdminServiceMockRecorder) UnblockDomain(ctx, actor, domainName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnblockDomain", reflect.TypeOf((*MockAdminService)(nil).UnblockDomain), ctx, actor, domainName)
+}
+
+// VerifyAudit mocks base method.
+func (m *MockAdminService) VerifyAudit(ctx context.Context, actor string) (domain.AuditVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAudit", ctx, actor)
+	ret0, _ := ret[0].(domain.AuditVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAudit indicates an expected call of VerifyAudit.
+func (mr *MockAdminServiceMockRecorder) VerifyAudit(ctx, actor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAudit", reflect.TypeOf((*MockAdminService)(nil).VerifyAudit), ctx, actor)
+}
@@ -0,0 +1,300 @@
+// Пакет cache содержит кэширующий декоратор для любого domain.URLLinkRepo
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Параметры кэша
+type Options struct {
+	Size        int           // максимальное число записей
+	TTL         time.Duration // время жизни найденной ссылки, 0 - без ограничения
+	NegativeTTL time.Duration // время жизни записи о промахе, 0 - промахи не кэшируются
+}
+
+// Значения счетчиков кэша
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+type entry struct {
+	shortURL  string
+	link      domain.URLLink
+	notFound  bool
+	expiresAt time.Time
+}
+
+// Декоратор с LRU-кэшем коротких ссылок поверх другого репозитория.
+// Кэшируется только Find, остальные методы проксируются и при необходимости
+// сбрасывают затронутые записи.
+// Кэш живет в памяти процесса и не узнает об изменениях, сделанных другими
+// экземплярами сервиса, поэтому подходит только для единственного экземпляра;
+// для нескольких реплик есть общий кэш из пакета rediscache
+type CachedLinkRepository struct {
+	repo    domain.URLLinkRepo
+	opts    Options
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List         // в начале - самые свежие записи
+	flights map[string]*flight // коды, которые сейчас читаются из хранилища
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	now     func() time.Time
+}
+
+func NewCachedLinkRepository(repo domain.URLLinkRepo, opts Options) *CachedLinkRepository {
+	return &CachedLinkRepository{
+		repo:    repo,
+		opts:    opts,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		flights: make(map[string]*flight),
+		now:     time.Now,
+	}
+}
+
+func (c *CachedLinkRepository) Store(ctx context.Context, urllink domain.URLLink) (domain.URLLink, error) {
+	link, err := c.repo.Store(ctx, urllink)
+	// код мог быть закэширован как отсутствующий
+	c.Invalidate(urllink.ShortURL)
+	return link, err
+}
+
+func (c *CachedLinkRepository) StoreBatch(ctx context.Context, links []domain.URLLink, maxActive int) ([]domain.URLLink, error) {
+	stored, err := c.repo.StoreBatch(ctx, links, maxActive)
+	for _, link := range links {
+		c.Invalidate(link.ShortURL)
+	}
+	return stored, err
+}
+
+// FindConsistent идет в хранилище мимо кэша
+func (c *CachedLinkRepository) FindConsistent(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	return c.repo.FindConsistent(ctx, shortURL)
+}
+
+func (c *CachedLinkRepository) Find(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	if e, ok := c.get(shortURL); ok {
+		c.hits.Add(1)
+		if e.notFound {
+			return domain.URLLink{}, repoerrors.ErrorShortLinkNotFound
+		}
+		return e.link, nil
+	}
+	c.misses.Add(1)
+
+	fl, gen := c.startFlight(shortURL)
+	link, err := c.repo.Find(ctx, shortURL)
+	switch {
+	case err == nil:
+		c.finishFlight(fl, gen, entry{shortURL: shortURL, link: link}, c.opts.TTL)
+	case errors.Is(err, repoerrors.ErrorShortLinkNotFound) && c.opts.NegativeTTL > 0:
+		c.finishFlight(fl, gen, entry{shortURL: shortURL, notFound: true}, c.opts.NegativeTTL)
+	default:
+		c.finishFlight(fl, gen, entry{}, 0)
+	}
+	return link, err
+}
+
+func (c *CachedLinkRepository) FindAll(ctx context.Context, userID string) ([]domain.URLLink, error) {
+	return c.repo.FindAll(ctx, userID)
+}
+
+func (c *CachedLinkRepository) FindAllInWorkspace(ctx context.Context, workspaceID string) ([]domain.URLLink, error) {
+	return c.repo.FindAllInWorkspace(ctx, workspaceID)
+}
+
+func (c *CachedLinkRepository) MarkDeletedBatch(ctx context.Context, links []domain.URLLink) error {
+	err := c.repo.MarkDeletedBatch(ctx, links)
+	// сбрасываем записи даже при ошибке: часть ссылок могла быть удалена
+	for _, l := range links {
+		c.Invalidate(l.ShortURL)
+	}
+	return err
+}
+
+func (c *CachedLinkRepository) ReassignLinks(ctx context.Context, fromUserID, toUserID string, maxActive int) (int64, error) {
+	n, err := c.repo.ReassignLinks(ctx, fromUserID, toUserID, maxActive)
+	c.invalidateUser(fromUserID)
+	return n, err
+}
+
+func (c *CachedLinkRepository) MoveLink(ctx context.Context, from domain.URLLink, userID, workspaceID string) error {
+	err := c.repo.MoveLink(ctx, from, userID, workspaceID)
+	c.Invalidate(from.ShortURL)
+	return err
+}
+
+func (c *CachedLinkRepository) SetDeleted(ctx context.Context, shortURL string, deleted bool) error {
+	err := c.repo.SetDeleted(ctx, shortURL, deleted)
+	c.Invalidate(shortURL)
+	return err
+}
+
+func (c *CachedLinkRepository) PurgeLink(ctx context.Context, shortURL string) error {
+	err := c.repo.PurgeLink(ctx, shortURL)
+	c.Invalidate(shortURL)
+	return err
+}
+
+func (c *CachedLinkRepository) Update(ctx context.Context, link domain.URLLink, editedBy string) error {
+	err := c.repo.Update(ctx, link, editedBy)
+	c.Invalidate(link.ShortURL)
+	return err
+}
+
+func (c *CachedLinkRepository) FindVersions(ctx context.Context, shortURL string) ([]domain.LinkVersion, error) {
+	return c.repo.FindVersions(ctx, shortURL)
+}
+
+func (c *CachedLinkRepository) CountActiveLinks(ctx context.Context, userID string) (int, error) {
+	return c.repo.CountActiveLinks(ctx, userID)
+}
+
+func (c *CachedLinkRepository) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	remaining, err := c.repo.ConsumeClick(ctx, shortURL)
+	c.Invalidate(shortURL)
+	return remaining, err
+}
+
+func (c *CachedLinkRepository) Ping(ctx context.Context) error {
+	return c.repo.Ping(ctx)
+}
+
+func (c *CachedLinkRepository) Close() error {
+	return c.repo.Close()
+}
+
+// Invalidate удаляет запись из кэша
+func (c *CachedLinkRepository) Invalidate(shortURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[shortURL]; ok {
+		c.removeElement(el)
+	}
+	if fl, ok := c.flights[shortURL]; ok {
+		fl.gen++
+	}
+}
+
+// удаляет из кэша все ссылки пользователя
+func (c *CachedLinkRepository) invalidateUser(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, el := range c.entries {
+		if e := el.Value.(*entry); !e.notFound && e.link.UserID == userID {
+			c.removeElement(el)
+		}
+	}
+	// владелец еще читаемых ссылок неизвестен, поэтому устаревают все чтения
+	for _, fl := range c.flights {
+		fl.gen++
+	}
+}
+
+// Чтение кода из хранилища при промахе. gen растет при каждом сбросе кода,
+// пока чтение идет: такой результат мог быть прочитан до изменения
+// и в кэш не попадает, иначе удаленная ссылка жила бы в кэше весь TTL
+type flight struct {
+	shortURL string
+	gen      uint64
+	refs     int
+}
+
+func (c *CachedLinkRepository) startFlight(shortURL string) (*flight, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fl, ok := c.flights[shortURL]
+	if !ok {
+		fl = &flight{shortURL: shortURL}
+		c.flights[shortURL] = fl
+	}
+	fl.refs++
+	return fl, fl.gen
+}
+
+// finishFlight кладет результат чтения в кэш, если код не сбрасывался
+// с начала чтения. Пустой e.shortURL - результат не кэшируется
+func (c *CachedLinkRepository) finishFlight(fl *flight, gen uint64, e entry, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fl.refs--
+	if fl.refs == 0 {
+		delete(c.flights, fl.shortURL)
+	}
+	if e.shortURL != "" && fl.gen == gen {
+		c.put(e, ttl)
+	}
+}
+
+// Stats возвращает счетчики попаданий и промахов
+func (c *CachedLinkRepository) Stats() Stats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: entries,
+	}
+}
+
+func (c *CachedLinkRepository) get(shortURL string) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[shortURL]
+	if !ok {
+		return entry{}, false
+	}
+
+	e := el.Value.(*entry)
+	if !e.expiresAt.IsZero() && c.now().After(e.expiresAt) {
+		c.removeElement(el)
+		return entry{}, false
+	}
+
+	c.order.MoveToFront(el)
+	return *e, true
+}
+
+// put добавляет запись в кэш, вызывается под c.mu
+func (c *CachedLinkRepository) put(e entry, ttl time.Duration) {
+	if c.opts.Size <= 0 {
+		return
+	}
+	if ttl > 0 {
+		e.expiresAt = c.now().Add(ttl)
+	}
+
+	if el, ok := c.entries[e.shortURL]; ok {
+		el.Value = &e
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[e.shortURL] = c.order.PushFront(&e)
+	for c.order.Len() > c.opts.Size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *CachedLinkRepository) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry).shortURL)
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/inmemory"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repotest"
+)
+
+// Репозиторий, считающий обращения к Find
+type countingRepo struct {
+	domain.URLLinkRepo
+	finds int
+}
+
+func (c *countingRepo) Find(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	c.finds++
+	return c.URLLinkRepo.Find(ctx, shortURL)
+}
+
+func newCountingRepo(t *testing.T) *countingRepo {
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	return &countingRepo{URLLinkRepo: repo}
+}
+
+func TestCachedLinkRepository_Conformance(t *testing.T) {
+	repotest.RunConformance(t, func(t *testing.T) domain.URLLinkRepo {
+		return NewCachedLinkRepository(newCountingRepo(t), Options{Size: 10, NegativeTTL: time.Minute})
+	})
+}
+
+func TestCachedLinkRepository_HitAndMiss(t *testing.T) {
+	ctx := context.Background()
+	backend := newCountingRepo(t)
+	repo := NewCachedLinkRepository(backend, Options{Size: 10})
+
+	_, err := repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		link, err := repo.Find(ctx, "abc12")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", link.LongURL)
+	}
+
+	assert.Equal(t, 1, backend.finds)
+	assert.Equal(t, Stats{Hits: 2, Misses: 1, Entries: 1}, repo.Stats())
+}
+
+func TestCachedLinkRepository_NegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	backend := newCountingRepo(t)
+	repo := NewCachedLinkRepository(backend, Options{Size: 10, NegativeTTL: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		_, err := repo.Find(ctx, "nope1")
+		assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+	}
+	assert.Equal(t, 1, backend.finds)
+
+	// после сохранения ссылки промах не должен оставаться в кэше
+	_, err := repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "nope1", LongURL: "https://example.com"})
+	require.NoError(t, err)
+
+	_, err = repo.Find(ctx, "nope1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, backend.finds)
+}
+
+func TestCachedLinkRepository_TTL(t *testing.T) {
+	ctx := context.Background()
+	backend := newCountingRepo(t)
+	repo := NewCachedLinkRepository(backend, Options{Size: 10, TTL: time.Minute})
+	now := time.Now()
+	repo.now = func() time.Time { return now }
+
+	_, err := repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"})
+	require.NoError(t, err)
+
+	repo.Find(ctx, "abc12")
+	repo.Find(ctx, "abc12")
+	assert.Equal(t, 1, backend.finds)
+
+	now = now.Add(2 * time.Minute)
+	repo.Find(ctx, "abc12")
+	assert.Equal(t, 2, backend.finds)
+}
+
+func TestCachedLinkRepository_Eviction(t *testing.T) {
+	ctx := context.Background()
+	backend := newCountingRepo(t)
+	repo := NewCachedLinkRepository(backend, Options{Size: 2})
+
+	for _, code := range []string{"aaaaa", "bbbbb", "ccccc"} {
+		_, err := repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: code, LongURL: "https://example.com/" + code})
+		require.NoError(t, err)
+		_, err = repo.Find(ctx, code)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, repo.Stats().Entries)
+
+	// самая старая запись вытеснена
+	repo.Find(ctx, "aaaaa")
+	assert.Equal(t, 4, backend.finds)
+	repo.Find(ctx, "ccccc")
+	assert.Equal(t, 4, backend.finds)
+}
+
+func TestCachedLinkRepository_InvalidateOnDelete(t *testing.T) {
+	ctx := context.Background()
+	backend := newCountingRepo(t)
+	repo := NewCachedLinkRepository(backend, Options{Size: 10})
+
+	link := domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"}
+	_, err := repo.Store(ctx, link)
+	require.NoError(t, err)
+
+	found, err := repo.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+	assert.False(t, found.DeletedFlag)
+
+	require.NoError(t, repo.MarkDeletedBatch(ctx, []domain.URLLink{link}))
+
+	found, err = repo.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+	assert.True(t, found.DeletedFlag)
+}
+
+// Репозиторий, в котором между чтением ссылки и возвратом результата
+// успевает выполниться afterRead
+type racingRepo struct {
+	domain.URLLinkRepo
+	afterRead func()
+}
+
+func (r *racingRepo) Find(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	link, err := r.URLLinkRepo.Find(ctx, shortURL)
+	if r.afterRead != nil {
+		hook := r.afterRead
+		r.afterRead = nil
+		hook()
+	}
+	return link, err
+}
+
+// Удаление во время чтения при промахе не оставляет в кэше прочитанную до него ссылку
+func TestCachedLinkRepository_InvalidateDuringMiss(t *testing.T) {
+	ctx := context.Background()
+	backend := &racingRepo{URLLinkRepo: newCountingRepo(t)}
+	repo := NewCachedLinkRepository(backend, Options{Size: 10, TTL: time.Hour})
+
+	link := domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"}
+	_, err := repo.Store(ctx, link)
+	require.NoError(t, err)
+
+	backend.afterRead = func() {
+		require.NoError(t, repo.MarkDeletedBatch(ctx, []domain.URLLink{link}))
+	}
+	found, err := repo.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+	assert.False(t, found.DeletedFlag)
+
+	found, err = repo.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+	assert.True(t, found.DeletedFlag)
+	assert.Equal(t, 1, repo.Stats().Entries)
+}
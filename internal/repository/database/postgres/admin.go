@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/database/sqlcommon"
+)
+
+// Ссылки и счетчики для оператора допускают отставание реплики,
+// блокировки и журнал аудита читаются и пишутся только на основном узле
+
+func (d *PostgresDBLinkRepository) FindUserLinks(ctx context.Context, userID string) ([]domain.URLLink, error) {
+	var urllinks []domain.URLLink
+	err := d.read(ctx, func(db *sqlx.DB) (err error) {
+		urllinks, err = sqlcommon.FindUserLinks(ctx, db, userID)
+		return err
+	})
+	return urllinks, err
+}
+
+func (d *PostgresDBLinkRepository) SystemStats(ctx context.Context) (domain.SystemStats, error) {
+	var stats domain.SystemStats
+	err := d.read(ctx, func(db *sqlx.DB) (err error) {
+		stats, err = sqlcommon.SystemStats(ctx, db)
+		return err
+	})
+	return stats, err
+}
+
+func (d *PostgresDBLinkRepository) BlockDomain(ctx context.Context, block domain.DomainBlock) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.BlockDomain(ctx, d.db, block)
+	})
+}
+
+func (d *PostgresDBLinkRepository) UnblockDomain(ctx context.Context, domainName string) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.UnblockDomain(ctx, d.db, domainName)
+	})
+}
+
+func (d *PostgresDBLinkRepository) FindBlockedDomains(ctx context.Context) ([]domain.DomainBlock, error) {
+	var blocks []domain.DomainBlock
+	err := d.retry(ctx, func() (err error) {
+		blocks, err = sqlcommon.FindBlockedDomains(ctx, d.db)
+		return err
+	})
+	return blocks, err
+}
+
+func (d *PostgresDBLinkRepository) AppendAudit(ctx context.Context, entry domain.AuditEntry) (domain.AuditEntry, error) {
+	var stored domain.AuditEntry
+	err := d.retry(ctx, func() (err error) {
+		stored, err = sqlcommon.AppendAudit(ctx, d.db, pqClassifier{}, entry)
+		return err
+	})
+	return stored, err
+}
+
+func (d *PostgresDBLinkRepository) FindAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	var entries []domain.AuditEntry
+	err := d.retry(ctx, func() (err error) {
+		entries, err = sqlcommon.FindAuditEntries(ctx, d.db, filter)
+		return err
+	})
+	return entries, err
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/database/sqlcommon"
+)
+
+// API-ключи читаются только с основного узла: отставшая реплика
+// не должна принимать уже отозванный ключ
+
+func (d *PostgresDBLinkRepository) StoreAPIKey(ctx context.Context, key domain.APIKey) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.StoreAPIKey(ctx, d.db, key)
+	})
+}
+
+func (d *PostgresDBLinkRepository) FindAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	var key domain.APIKey
+	err := d.retry(ctx, func() (err error) {
+		key, err = sqlcommon.FindAPIKeyByHash(ctx, d.db, hash)
+		return err
+	})
+	return key, err
+}
+
+func (d *PostgresDBLinkRepository) FindAPIKeys(ctx context.Context, userID string) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	err := d.retry(ctx, func() (err error) {
+		keys, err = sqlcommon.FindAPIKeys(ctx, d.db, userID)
+		return err
+	})
+	return keys, err
+}
+
+func (d *PostgresDBLinkRepository) RevokeAPIKey(ctx context.Context, userID, id string, revokedAt time.Time) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.RevokeAPIKey(ctx, d.db, userID, id, revokedAt)
+	})
+}
@@ -2,9 +2,9 @@ package postgres
 
 import (
 	"context"
-	"database/sql"
-	_ "embed"
 	"errors"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/google/uuid"
@@ -12,36 +12,91 @@ import (
 	"github.com/lib/pq"
 
 	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/database/sqlcommon"
 	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
 )
 
-//go:embed linktable.sql
-var queryCreateTable string
-
 type PostgresDBLinkRepository struct {
-	db *sqlx.DB
+	db       *sqlx.DB // основной узел, принимает запись
+	replicas []*node  // реплики для чтения
+	next     atomic.Uint64
+	opts     Options
+}
+
+// Параметры подключения и устойчивости к сбоям
+type Options struct {
+	ReplicaDSNs     []string      // реплики для чтения
+	MaxOpenConns    int           // 0 - без ограничения
+	MaxIdleConns    int           // 0 - значение database/sql по умолчанию
+	ConnMaxLifetime time.Duration // 0 - соединения не пересоздаются
+	ConnectTimeout  time.Duration // сколько ждать основной узел при старте
+	RetryAttempts   int           // число попыток для временных ошибок
+}
+
+// NewDBLinkRepository подключается к основному узлу и, если заданы, к репликам
+// с параметрами по умолчанию
+func NewDBLinkRepository(connStr string, replicaConnStrs ...string) (*PostgresDBLinkRepository, error) {
+	return NewDBLinkRepositoryWithOptions(connStr, Options{
+		ReplicaDSNs:    replicaConnStrs,
+		ConnectTimeout: 5 * time.Second,
+		RetryAttempts:  defaultRetryAttempts,
+	})
 }
 
-func NewDBLinkRepository(connStr string) (*PostgresDBLinkRepository, error) {
+// NewDBLinkRepositoryWithOptions подключается к основному узлу, повторяя попытки
+// с экспоненциальной задержкой до истечения opts.ConnectTimeout.
+// Недоступная при старте реплика не мешает запуску, а помечается неисправной
+func NewDBLinkRepositoryWithOptions(connStr string, opts Options) (*PostgresDBLinkRepository, error) {
+	if opts.RetryAttempts <= 0 {
+		opts.RetryAttempts = 1
+	}
+
 	db, err := sqlx.Open("postgres", connStr)
 	if err != nil {
 		return nil, errors.Join(repoerrors.ErrorConnectingDB, err)
 	}
+	configurePool(db, opts)
 
-	if err = db.Ping(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.ConnectTimeout)
+	defer cancel()
+	if err = waitForDB(ctx, db); err != nil {
+		db.Close()
 		return nil, errors.Join(repoerrors.ErrorPingDB, err)
 	}
 
-	dblink := &PostgresDBLinkRepository{db: db}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := dblink.create(ctx); err != nil {
+	dblink := &PostgresDBLinkRepository{db: db, opts: opts}
+	for i, replicaConnStr := range opts.ReplicaDSNs {
+		replica, err := sqlx.Open("postgres", replicaConnStr)
+		if err != nil {
+			dblink.Close()
+			return nil, errors.Join(repoerrors.ErrorConnectingDB, err)
+		}
+		configurePool(replica, opts)
+		n := newNode(fmt.Sprintf("replica[%d]", i), replica)
+		if err := replica.PingContext(ctx); err != nil {
+			n.markDown()
+		}
+		dblink.replicas = append(dblink.replicas, n)
+	}
+
+	createCtx, createCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer createCancel()
+	if err := dblink.create(createCtx); err != nil {
+		dblink.Close()
 		return nil, err
 	}
 
 	return dblink, nil
 }
 
+func configurePool(db *sqlx.DB, opts Options) {
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+}
+
 // Store is a function that stores a URL link in the database.
 // It takes a context and a URL link as arguments and returns an error.
 // It inserts the URL link into the database using a prepared SQL query.
@@ -49,99 +104,207 @@ func NewDBLinkRepository(connStr string) (*PostgresDBLinkRepository, error) {
 // If it is a unique constraint violation, it retrieves the short URL for the original URL from the database and returns a custom error.
 // If there is any other error, it returns a formatted error with the original error.
 func (d *PostgresDBLinkRepository) Store(ctx context.Context, urllink domain.URLLink) (domain.URLLink, error) {
-	query := `INSERT INTO links(user_id, short_url, original_url) VALUES($1, $2, $3);`
-	_, err := d.db.ExecContext(ctx, query, urllink.UserID, urllink.ShortURL, urllink.LongURL)
-
-	if err == nil {
-		return urllink, nil
-	}
-
-	var pqError *pq.Error
-	if !errors.As(err, &pqError) {
-		return domain.URLLink{}, errors.Join(repoerrors.ErrorInsertShortLink, err)
-	}
-
-	if pqError.Code == "23505" {
-		querySelect := `SELECT user_id, short_url, original_url FROM links WHERE original_url = $1 LIMIT 1;`
-		if err := d.db.GetContext(ctx, &urllink, querySelect, urllink.LongURL); err != nil {
-			return domain.URLLink{}, errors.Join(repoerrors.ErrorSelectExistedShortLink, err)
-		}
-		return urllink, errors.Join(repoerrors.ErrorShortLinkAlreadyInDB, err)
-	}
-
-	// Обработка других ошибок Postgres
-	return domain.URLLink{}, errors.Join(repoerrors.ErrorSQLInternal, err)
+	var stored domain.URLLink
+	err := d.retry(ctx, func() (err error) {
+		stored, err = sqlcommon.Store(ctx, d.db, pqClassifier{}, urllink)
+		return err
+	})
+	return stored, err
 }
 
 // TODO change function input parameters
 func (d *PostgresDBLinkRepository) Find(ctx context.Context, shortURL string) (domain.URLLink, error) {
-	query := `SELECT user_id, short_url, original_url, is_deleted FROM links WHERE short_url=$1 LIMIT 1;`
 	var urllink domain.URLLink
-	if err := d.db.GetContext(ctx, &urllink, query, shortURL); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			// не найден короткий URL
-			return domain.URLLink{}, errors.Join(repoerrors.ErrorShortLinkNotFound, err)
-		}
-		// при извлечении произошла ошибка
-		return urllink, errors.Join(repoerrors.ErrorSelectExistedShortLink, err)
-	}
-	return urllink, nil
+	err := d.read(ctx, func(db *sqlx.DB) (err error) {
+		urllink, err = sqlcommon.Find(ctx, db, shortURL)
+		return err
+	})
+	return urllink, err
+}
+
+// FindConsistent читает ссылку только с основного узла
+func (d *PostgresDBLinkRepository) FindConsistent(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	var urllink domain.URLLink
+	err := d.retry(ctx, func() (err error) {
+		urllink, err = sqlcommon.Find(ctx, d.db, shortURL)
+		return err
+	})
+	return urllink, err
 }
 
 func (d *PostgresDBLinkRepository) FindAll(ctx context.Context, userID string) ([]domain.URLLink, error) {
-	query := `SELECT user_id, short_url, original_url FROM links WHERE user_id=$1;`
 	var urllinks []domain.URLLink
-	if err := d.db.SelectContext(ctx, &urllinks, query, userID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			// не найден короткий URL
-			return nil, errors.Join(repoerrors.ErrorShortLinkNotFound, err)
-		}
-		return nil, errors.Join(repoerrors.ErrorSelectShortLinks, err)
-	}
-	return urllinks, nil
-
+	err := d.read(ctx, func(db *sqlx.DB) (err error) {
+		urllinks, err = sqlcommon.FindAll(ctx, db, userID)
+		return err
+	})
+	return urllinks, err
 }
 
+// Ping проверяет все узлы и возвращает ошибку с перечнем неисправных
 func (d *PostgresDBLinkRepository) Ping(ctx context.Context) error {
-	if err := d.db.PingContext(ctx); err != nil {
-		return errors.Join(repoerrors.ErrorPingDB, err)
+	var errs []error
+	for _, h := range d.Health(ctx) {
+		if h.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.Name, h.Err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(append([]error{repoerrors.ErrorPingDB}, errs...)...)
 	}
 	return nil
 }
 
+// Таблица ссылок, созданная до частичного индекса по адресу, хранит
+// глобальное ограничение уникальности адреса
+const queryDropLegacyOriginalUnique = `ALTER TABLE links DROP CONSTRAINT IF EXISTS links_original_url_key;`
+
 func (d *PostgresDBLinkRepository) create(ctx context.Context) error {
-	if _, err := d.db.ExecContext(ctx, queryCreateTable); err != nil {
+	if err := sqlcommon.CreateTable(ctx, d.db); err != nil {
+		return err
+	}
+	if _, err := d.db.ExecContext(ctx, queryDropLegacyOriginalUnique); err != nil {
 		return errors.Join(repoerrors.ErrorTableCreate, err)
 	}
 	return nil
 }
 
 func (d *PostgresDBLinkRepository) Close() error {
+	var errs []error
 	if d.db != nil {
-		return d.db.Close()
+		errs = append(errs, d.db.Close())
 	}
-	return nil
+	for _, r := range d.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
 }
 
+func (d *PostgresDBLinkRepository) FindAllInWorkspace(ctx context.Context, workspaceID string) ([]domain.URLLink, error) {
+	var urllinks []domain.URLLink
+	err := d.read(ctx, func(db *sqlx.DB) (err error) {
+		urllinks, err = sqlcommon.FindAllInWorkspace(ctx, db, workspaceID)
+		return err
+	})
+	return urllinks, err
+}
+
+// Ссылки рабочих пространств удаляются по пространству, личные - по пользователю
 func (d *PostgresDBLinkRepository) MarkDeletedBatch(ctx context.Context, links []domain.URLLink) error {
 	queryDelete := `
 		UPDATE links l
 		SET is_deleted = TRUE
-		FROM unnest($1::VARCHAR[], $2::VARCHAR[]) AS ud(user_id, short_url)
-		WHERE l.user_id = ud.user_id AND l.short_url = ud.short_url;
+		FROM unnest($1::VARCHAR[], $2::VARCHAR[], $3::VARCHAR[]) AS ud(user_id, workspace_id, short_url)
+		WHERE l.short_url = ud.short_url AND (
+			(ud.workspace_id = '' AND l.workspace_id = '' AND l.user_id = ud.user_id) OR
+			(ud.workspace_id <> '' AND l.workspace_id = ud.workspace_id));
 		`
 
 	userIds := make([]string, len(links))
+	workspaceIds := make([]string, len(links))
 	shortLinks := make([]string, len(links))
 
 	for i, l := range links {
 		userIds[i] = l.UserID
+		workspaceIds[i] = l.WorkspaceID
 		shortLinks[i] = l.ShortURL
 	}
 
-	_, err := d.db.ExecContext(ctx, queryDelete, pq.Array(userIds), pq.Array(shortLinks))
+	err := d.retry(ctx, func() error {
+		_, err := d.db.ExecContext(ctx, queryDelete, pq.Array(userIds), pq.Array(workspaceIds), pq.Array(shortLinks))
+		return err
+	})
 	if err != nil {
 		return errors.Join(repoerrors.ErrorMarkDeletedBatch, err)
 	}
 	return nil
 }
+
+func (d *PostgresDBLinkRepository) ReassignLinks(ctx context.Context, fromUserID, toUserID string, maxActive int) (int64, error) {
+	var n int64
+	err := d.retry(ctx, func() (err error) {
+		n, err = sqlcommon.ReassignLinks(ctx, d.db, fromUserID, toUserID, maxActive)
+		return err
+	})
+	return n, err
+}
+
+func (d *PostgresDBLinkRepository) MoveLink(ctx context.Context, from domain.URLLink, userID, workspaceID string) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.MoveLink(ctx, d.db, from, userID, workspaceID)
+	})
+}
+
+func (d *PostgresDBLinkRepository) SetDeleted(ctx context.Context, shortURL string, deleted bool) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.SetDeleted(ctx, d.db, shortURL, deleted)
+	})
+}
+
+func (d *PostgresDBLinkRepository) PurgeLink(ctx context.Context, shortURL string) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.PurgeLink(ctx, d.db, shortURL)
+	})
+}
+
+func (d *PostgresDBLinkRepository) Update(ctx context.Context, link domain.URLLink, editedBy string) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.Update(ctx, d.db, pqClassifier{}, link, editedBy)
+	})
+}
+
+func (d *PostgresDBLinkRepository) FindVersions(ctx context.Context, shortURL string) ([]domain.LinkVersion, error) {
+	var versions []domain.LinkVersion
+	err := d.read(ctx, func(db *sqlx.DB) (err error) {
+		versions, err = sqlcommon.FindVersions(ctx, db, shortURL)
+		return err
+	})
+	return versions, err
+}
+
+// StoreBatch выполняется на основном узле одной транзакцией, которую
+// безопасно повторить целиком после обрыва соединения
+func (d *PostgresDBLinkRepository) StoreBatch(ctx context.Context, links []domain.URLLink, maxActive int) ([]domain.URLLink, error) {
+	var stored []domain.URLLink
+	err := d.retry(ctx, func() (err error) {
+		stored, err = sqlcommon.StoreBatch(ctx, d.db, links, maxActive)
+		return err
+	})
+	return stored, err
+}
+
+// CountActiveLinks выполняется на основном узле: по нему проверяется квота,
+// а реплика могла еще не получить только что созданные ссылки
+func (d *PostgresDBLinkRepository) CountActiveLinks(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := d.retry(ctx, func() (err error) {
+		count, err = sqlcommon.CountActiveLinks(ctx, d.db, userID)
+		return err
+	})
+	return count, err
+}
+
+// ConsumeClick выполняется на основном узле: реплика могла отстать.
+// Повтор после обрыва соединения может списать лишний переход, но не
+// пропустит переход сверх лимита
+func (d *PostgresDBLinkRepository) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	var remaining int
+	err := d.retry(ctx, func() (err error) {
+		remaining, err = sqlcommon.ConsumeClick(ctx, d.db, shortURL)
+		return err
+	})
+	return remaining, err
+}
+
+// Классификация ошибок драйвера lib/pq
+type pqClassifier struct{}
+
+func (pqClassifier) IsUniqueViolation(err error) bool {
+	var pqError *pq.Error
+	return errors.As(err, &pqError) && pqError.Code == "23505"
+}
+
+func (pqClassifier) IsDriverError(err error) bool {
+	var pqError *pq.Error
+	return errors.As(err, &pqError)
+}
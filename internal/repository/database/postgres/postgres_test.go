@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repotest"
+)
+
+// Тесты требуют запущенного Postgres, строка подключения берется из TEST_DATABASE_DSN
+func TestPostgresDBLinkRepository_Conformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан")
+	}
+
+	repotest.RunConformance(t, func(t *testing.T) domain.URLLinkRepo {
+		repo, err := NewDBLinkRepository(dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestPostgresDBLinkRepository_APIKeyConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан")
+	}
+
+	repotest.RunAPIKeyConformance(t, func(t *testing.T) domain.APIKeyRepo {
+		repo, err := NewDBLinkRepository(dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestPostgresDBLinkRepository_UserConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан")
+	}
+
+	repotest.RunUserConformance(t, func(t *testing.T) domain.UserRepo {
+		repo, err := NewDBLinkRepository(dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestPostgresDBLinkRepository_WorkspaceConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан")
+	}
+	repotest.RunWorkspaceConformance(t, func(t *testing.T) domain.WorkspaceRepo {
+		repo, err := NewDBLinkRepository(dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestPostgresDBLinkRepository_AdminConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан")
+	}
+	repotest.RunAdminConformance(t, func(t *testing.T) repotest.AdminStore {
+		repo, err := NewDBLinkRepository(dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+// В качестве реплики используется тот же сервер
+func TestPostgresDBLinkRepository_ReplicasConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан")
+	}
+
+	repotest.RunConformance(t, func(t *testing.T) domain.URLLinkRepo {
+		repo, err := NewDBLinkRepository(dsn, dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestPostgresDBLinkRepository_UnreachableReplica(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан")
+	}
+
+	repo, err := NewDBLinkRepository(dsn, "postgres://nobody@127.0.0.1:1/none?sslmode=disable&connect_timeout=1")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	id := uuid.New().String()
+	link := domain.URLLink{UserID: id, ShortURL: id[:8], LongURL: "https://example.com/" + id}
+	_, err = repo.Store(ctx, link)
+	require.NoError(t, err)
+
+	found, err := repo.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+	assert.Equal(t, link.LongURL, found.LongURL)
+
+	err = repo.Ping(ctx)
+	assert.ErrorContains(t, err, "replica[0]")
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// время, на которое неисправная реплика исключается из чтения
+const replicaDownInterval = 5 * time.Second
+
+// Узел кластера, доступный для чтения
+type node struct {
+	name      string
+	db        *sqlx.DB
+	downUntil atomic.Int64 // unix nano; до этого момента узел не используется
+}
+
+func newNode(name string, db *sqlx.DB) *node {
+	return &node{name: name, db: db}
+}
+
+func (n *node) healthy() bool {
+	return time.Now().UnixNano() >= n.downUntil.Load()
+}
+
+func (n *node) markDown() {
+	n.downUntil.Store(time.Now().Add(replicaDownInterval).UnixNano())
+}
+
+func (n *node) markUp() {
+	n.downUntil.Store(0)
+}
+
+// Состояние узла на момент проверки
+type NodeHealth struct {
+	Name string
+	Err  error
+}
+
+// Health пингует основной узел и все реплики и обновляет их состояние
+func (d *PostgresDBLinkRepository) Health(ctx context.Context) []NodeHealth {
+	result := make([]NodeHealth, 0, len(d.replicas)+1)
+	result = append(result, NodeHealth{Name: "primary", Err: d.db.PingContext(ctx)})
+
+	for _, r := range d.replicas {
+		err := r.db.PingContext(ctx)
+		if err != nil {
+			r.markDown()
+		} else {
+			r.markUp()
+		}
+		result = append(result, NodeHealth{Name: r.name, Err: err})
+	}
+	return result
+}
+
+// Исправные реплики по кругу, начиная со следующей
+func (d *PostgresDBLinkRepository) readOrder() []*node {
+	if len(d.replicas) == 0 {
+		return nil
+	}
+
+	start := int(d.next.Add(1)-1) % len(d.replicas)
+	order := make([]*node, 0, len(d.replicas))
+	for i := range d.replicas {
+		n := d.replicas[(start+i)%len(d.replicas)]
+		if n.healthy() {
+			order = append(order, n)
+		}
+	}
+	return order
+}
+
+// read выполняет запрос на репликах, а при их отказе - на основном узле.
+// Отсутствие данных отказом узла не считается, но запрос повторяется на
+// основном узле: только что созданная ссылка могла еще не дойти до реплики
+func (d *PostgresDBLinkRepository) read(ctx context.Context, query func(db *sqlx.DB) error) error {
+	for _, n := range d.readOrder() {
+		err := query(n.db)
+		if errors.Is(err, repoerrors.ErrorShortLinkNotFound) {
+			break
+		}
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		n.markDown()
+	}
+	return d.retry(ctx, func() error {
+		return query(d.db)
+	})
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+func newRoutingRepo(replicas int) *PostgresDBLinkRepository {
+	d := &PostgresDBLinkRepository{db: &sqlx.DB{}}
+	for i := 0; i < replicas; i++ {
+		d.replicas = append(d.replicas, newNode("replica", &sqlx.DB{}))
+	}
+	return d
+}
+
+func TestRead_RoundRobin(t *testing.T) {
+	d := newRoutingRepo(2)
+	var used []*sqlx.DB
+
+	for i := 0; i < 4; i++ {
+		d.read(context.Background(), func(db *sqlx.DB) error {
+			used = append(used, db)
+			return nil
+		})
+	}
+
+	assert.Equal(t, []*sqlx.DB{d.replicas[0].db, d.replicas[1].db, d.replicas[0].db, d.replicas[1].db}, used)
+}
+
+func TestRead_FallbackToPrimary(t *testing.T) {
+	d := newRoutingRepo(2)
+	var used []*sqlx.DB
+
+	err := d.read(context.Background(), func(db *sqlx.DB) error {
+		used = append(used, db)
+		if db != d.db {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*sqlx.DB{d.replicas[0].db, d.replicas[1].db, d.db}, used)
+	assert.False(t, d.replicas[0].healthy())
+	assert.False(t, d.replicas[1].healthy())
+
+	// неисправные реплики пропускаются, пока не истечет интервал
+	used = nil
+	d.read(context.Background(), func(db *sqlx.DB) error {
+		used = append(used, db)
+		return nil
+	})
+	assert.Equal(t, []*sqlx.DB{d.db}, used)
+}
+
+func TestRead_NotFoundIsNotFailure(t *testing.T) {
+	d := newRoutingRepo(2)
+	var used []*sqlx.DB
+
+	err := d.read(context.Background(), func(db *sqlx.DB) error {
+		used = append(used, db)
+		return repoerrors.ErrorShortLinkNotFound
+	})
+
+	// отставшая реплика не исключается, а ссылка ищется на основном узле
+	assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+	assert.Equal(t, []*sqlx.DB{d.replicas[0].db, d.db}, used)
+	assert.True(t, d.replicas[0].healthy())
+}
+
+func TestRead_NotFoundOnLaggingReplica(t *testing.T) {
+	d := newRoutingRepo(1)
+
+	err := d.read(context.Background(), func(db *sqlx.DB) error {
+		if db != d.db {
+			return repoerrors.ErrorShortLinkNotFound
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, d.replicas[0].healthy())
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+const (
+	defaultRetryAttempts = 3
+	initialBackoff       = 50 * time.Millisecond
+	maxBackoff           = 2 * time.Second
+)
+
+// Экспоненциальная задержка перед попыткой с номером attempt (с нуля)
+func backoff(attempt int) time.Duration {
+	d := initialBackoff << attempt
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// sleep ждет d или отмены контекста
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retry повторяет операцию, пока она завершается временной ошибкой
+func (d *PostgresDBLinkRepository) retry(ctx context.Context, op func() error) error {
+	attempts := d.opts.RetryAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if err = op(); err == nil || !repoerrors.IsTransient(err) {
+			return err
+		}
+		if attempt+1 < attempts {
+			if sleepErr := sleep(ctx, backoff(attempt)); sleepErr != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// waitForDB пингует базу с экспоненциальной задержкой до успеха или отмены контекста
+func waitForDB(ctx context.Context, db *sqlx.DB) error {
+	for attempt := 0; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if sleepErr := sleep(ctx, backoff(attempt)); sleepErr != nil {
+			return err
+		}
+	}
+}
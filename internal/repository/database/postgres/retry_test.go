@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+func TestRetry_TransientErrors(t *testing.T) {
+	d := &PostgresDBLinkRepository{opts: Options{RetryAttempts: 3}}
+	calls := 0
+
+	err := d.retry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errors.Join(repoerrors.ErrorSQLInternal, &pq.Error{Code: "40001"})
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	d := &PostgresDBLinkRepository{opts: Options{RetryAttempts: 2}}
+	calls := 0
+
+	err := d.retry(context.Background(), func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+
+	assert.ErrorIs(t, err, driver.ErrBadConn)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRetry_ConstraintViolationNotRetried(t *testing.T) {
+	d := &PostgresDBLinkRepository{opts: Options{RetryAttempts: 3}}
+	calls := 0
+
+	err := d.retry(context.Background(), func() error {
+		calls++
+		return errors.Join(repoerrors.ErrorShortLinkAlreadyInDB, &pq.Error{Code: "23505"})
+	})
+
+	assert.ErrorIs(t, err, repoerrors.ErrorShortLinkAlreadyInDB)
+	assert.Equal(t, 1, calls)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, initialBackoff, backoff(0))
+	assert.Equal(t, 2*initialBackoff, backoff(1))
+	assert.Equal(t, maxBackoff, backoff(20))
+	assert.Equal(t, maxBackoff, backoff(100))
+}
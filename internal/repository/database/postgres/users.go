@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/database/sqlcommon"
+)
+
+// Учетные записи читаются с основного узла: только что
+// зарегистрированный пользователь должен сразу войти
+
+func (d *PostgresDBLinkRepository) StoreUser(ctx context.Context, user domain.User) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.StoreUser(ctx, d.db, pqClassifier{}, user)
+	})
+}
+
+func (d *PostgresDBLinkRepository) UpdatePasswordHash(ctx context.Context, userID, passwordHash string) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.UpdatePasswordHash(ctx, d.db, userID, passwordHash)
+	})
+}
+
+func (d *PostgresDBLinkRepository) FindUserByLogin(ctx context.Context, login string) (domain.User, error) {
+	var user domain.User
+	err := d.retry(ctx, func() (err error) {
+		user, err = sqlcommon.FindUserByLogin(ctx, d.db, login)
+		return err
+	})
+	return user, err
+}
+
+func (d *PostgresDBLinkRepository) FindUserByID(ctx context.Context, id string) (domain.User, error) {
+	var user domain.User
+	err := d.retry(ctx, func() (err error) {
+		user, err = sqlcommon.FindUserByID(ctx, d.db, id)
+		return err
+	})
+	return user, err
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/database/sqlcommon"
+)
+
+// Членство читается с основного узла: проверка прав не должна
+// отставать от только что выданной или отозванной роли
+
+func (d *PostgresDBLinkRepository) StoreWorkspace(ctx context.Context, workspace domain.Workspace, owner domain.WorkspaceMember) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.StoreWorkspace(ctx, d.db, workspace, owner)
+	})
+}
+
+func (d *PostgresDBLinkRepository) FindWorkspace(ctx context.Context, id string) (domain.Workspace, error) {
+	var workspace domain.Workspace
+	err := d.retry(ctx, func() (err error) {
+		workspace, err = sqlcommon.FindWorkspace(ctx, d.db, id)
+		return err
+	})
+	return workspace, err
+}
+
+func (d *PostgresDBLinkRepository) FindUserWorkspaces(ctx context.Context, userID string) ([]domain.WorkspaceMembership, error) {
+	var memberships []domain.WorkspaceMembership
+	err := d.retry(ctx, func() (err error) {
+		memberships, err = sqlcommon.FindUserWorkspaces(ctx, d.db, userID)
+		return err
+	})
+	return memberships, err
+}
+
+func (d *PostgresDBLinkRepository) FindMember(ctx context.Context, workspaceID, userID string) (domain.WorkspaceMember, error) {
+	var member domain.WorkspaceMember
+	err := d.retry(ctx, func() (err error) {
+		member, err = sqlcommon.FindMember(ctx, d.db, workspaceID, userID)
+		return err
+	})
+	return member, err
+}
+
+func (d *PostgresDBLinkRepository) FindMembers(ctx context.Context, workspaceID string) ([]domain.WorkspaceMember, error) {
+	var members []domain.WorkspaceMember
+	err := d.retry(ctx, func() (err error) {
+		members, err = sqlcommon.FindMembers(ctx, d.db, workspaceID)
+		return err
+	})
+	return members, err
+}
+
+func (d *PostgresDBLinkRepository) SaveMember(ctx context.Context, member domain.WorkspaceMember) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.SaveMember(ctx, d.db, member)
+	})
+}
+
+func (d *PostgresDBLinkRepository) RemoveMember(ctx context.Context, workspaceID, userID string) error {
+	return d.retry(ctx, func() error {
+		return sqlcommon.RemoveMember(ctx, d.db, workspaceID, userID)
+	})
+}
@@ -0,0 +1,189 @@
+package sqlcommon
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Схема хранения заблокированных доменов и журнала аудита
+//
+//go:embed admintable.sql
+var QueryCreateAdminTable string
+
+const (
+	querySelectUserLinks = `SELECT user_id, workspace_id, short_url, original_url, is_deleted, is_quarantined, password_hash, max_clicks, clicks_remaining FROM links WHERE user_id = ?;`
+	querySelectStats     = `SELECT
+		(SELECT COUNT(*) FROM links) AS links,
+		(SELECT COUNT(*) FROM links WHERE is_deleted = TRUE) AS deleted_links,
+		(SELECT COUNT(DISTINCT user_id) FROM links) AS link_owners,
+		(SELECT COUNT(*) FROM users) AS users,
+		(SELECT COUNT(*) FROM workspaces) AS workspaces,
+		(SELECT COUNT(*) FROM api_keys WHERE revoked_at IS NULL) AS active_api_keys,
+		(SELECT COUNT(*) FROM blocked_domains) AS blocked_domains;`
+	queryUpsertDomainBlock = `INSERT INTO blocked_domains(domain, reason, created_by, created_at) VALUES(?, ?, ?, ?)
+		ON CONFLICT(domain) DO UPDATE SET reason = excluded.reason, created_by = excluded.created_by, created_at = excluded.created_at;`
+	queryDeleteDomainBlock  = `DELETE FROM blocked_domains WHERE domain = ?;`
+	querySelectDomainBlocks = `SELECT domain, reason, created_by, created_at FROM blocked_domains ORDER BY domain;`
+	auditColumns            = `seq, created_at, actor, action, target, request_id, before_value, after_value, details, result, prev_hash, hash`
+	queryLastAudit          = `SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1;`
+	queryInsertAudit        = `INSERT INTO audit_log(` + auditColumns + `) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	querySelectAuditEntries = `SELECT ` + auditColumns + ` FROM audit_log WHERE seq > ?`
+	queryHasAuditHashColumn = `SELECT hash FROM audit_log WHERE 1 = 0;`
+	querySelectLegacyAudit  = `SELECT seq, created_at, actor, action, target, details, result FROM audit_log ORDER BY seq;`
+	queryUpdateAuditHash    = `UPDATE audit_log SET prev_hash = ?, hash = ? WHERE seq = ?;`
+	auditAppendAttempts     = 5
+)
+
+// Столбцы журнала аудита, появившиеся вместе с цепочкой хэшей
+var queriesAddAuditChainColumns = []string{
+	`ALTER TABLE audit_log ADD COLUMN request_id VARCHAR(64) NOT NULL DEFAULT '';`,
+	`ALTER TABLE audit_log ADD COLUMN before_value TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE audit_log ADD COLUMN after_value TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE audit_log ADD COLUMN prev_hash VARCHAR(64) NOT NULL DEFAULT '';`,
+	`ALTER TABLE audit_log ADD COLUMN hash VARCHAR(64) NOT NULL DEFAULT '';`,
+}
+
+// FindUserLinks возвращает все ссылки автора, включая ссылки пространств
+func FindUserLinks(ctx context.Context, db sqlx.ExtContext, userID string) ([]domain.URLLink, error) {
+	var urllinks []domain.URLLink
+	if err := sqlx.SelectContext(ctx, db, &urllinks, db.Rebind(querySelectUserLinks), userID); err != nil {
+		return nil, errors.Join(repoerrors.ErrorSelectShortLinks, err)
+	}
+	return urllinks, nil
+}
+
+func SystemStats(ctx context.Context, db sqlx.ExtContext) (domain.SystemStats, error) {
+	var stats domain.SystemStats
+	if err := sqlx.GetContext(ctx, db, &stats, querySelectStats); err != nil {
+		return domain.SystemStats{}, errors.Join(repoerrors.ErrorSelectStats, err)
+	}
+	return stats, nil
+}
+
+func BlockDomain(ctx context.Context, db sqlx.ExtContext, block domain.DomainBlock) error {
+	_, err := db.ExecContext(ctx, db.Rebind(queryUpsertDomainBlock), block.Domain, block.Reason, block.CreatedBy, block.CreatedAt.UTC())
+	if err != nil {
+		return errors.Join(repoerrors.ErrorSaveDomainBlock, err)
+	}
+	return nil
+}
+
+func UnblockDomain(ctx context.Context, db sqlx.ExtContext, domainName string) error {
+	res, err := db.ExecContext(ctx, db.Rebind(queryDeleteDomainBlock), domainName)
+	if err != nil {
+		return errors.Join(repoerrors.ErrorSaveDomainBlock, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Join(repoerrors.ErrorSaveDomainBlock, err)
+	}
+	if n == 0 {
+		return repoerrors.ErrorDomainBlockNotFound
+	}
+	return nil
+}
+
+func FindBlockedDomains(ctx context.Context, db sqlx.ExtContext) ([]domain.DomainBlock, error) {
+	var blocks []domain.DomainBlock
+	if err := sqlx.SelectContext(ctx, db, &blocks, querySelectDomainBlocks); err != nil {
+		return nil, errors.Join(repoerrors.ErrorSelectDomainBlocks, err)
+	}
+	for i := range blocks {
+		blocks[i].CreatedAt = blocks[i].CreatedAt.UTC()
+	}
+	return blocks, nil
+}
+
+// AppendAudit присваивает записи следующий номер, связывает ее с последней
+// записью журнала и сохраняет. Если другой экземпляр сервиса успел занять
+// тот же номер, попытка повторяется с новой последней записью
+func AppendAudit(ctx context.Context, db *sqlx.DB, classifier ErrorClassifier, entry domain.AuditEntry) (domain.AuditEntry, error) {
+	var err error
+	for attempt := 0; attempt < auditAppendAttempts; attempt++ {
+		var last struct {
+			Seq  int64  `db:"seq"`
+			Hash string `db:"hash"`
+		}
+		if err = db.GetContext(ctx, &last, queryLastAudit); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return domain.AuditEntry{}, errors.Join(repoerrors.ErrorInsertAudit, err)
+		}
+		entry.Seq = last.Seq + 1
+		sealed := entry.Seal(last.Hash)
+		_, err = db.ExecContext(ctx, db.Rebind(queryInsertAudit),
+			sealed.Seq, sealed.CreatedAt, sealed.Actor, sealed.Action, sealed.Target, sealed.RequestID,
+			sealed.Before, sealed.After, sealed.Details, sealed.Result, sealed.PrevHash, sealed.Hash)
+		if err == nil {
+			return sealed, nil
+		}
+		if !classifier.IsUniqueViolation(err) {
+			break
+		}
+	}
+	return domain.AuditEntry{}, errors.Join(repoerrors.ErrorInsertAudit, err)
+}
+
+func FindAuditEntries(ctx context.Context, db sqlx.ExtContext, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	query, args := querySelectAuditEntries, []any{filter.AfterSeq}
+	for _, cond := range []struct{ column, value string }{
+		{"actor", filter.Actor},
+		{"action", filter.Action},
+		{"target", filter.Target},
+	} {
+		if cond.value != "" {
+			query += " AND " + cond.column + " = ?"
+			args = append(args, cond.value)
+		}
+	}
+	query += " ORDER BY seq LIMIT ?;"
+	args = append(args, filter.Limit)
+
+	var entries []domain.AuditEntry
+	if err := sqlx.SelectContext(ctx, db, &entries, db.Rebind(query), args...); err != nil {
+		return nil, errors.Join(repoerrors.ErrorSelectAudit, err)
+	}
+	for i := range entries {
+		entries[i].CreatedAt = entries[i].CreatedAt.UTC()
+	}
+	return entries, nil
+}
+
+// migrateAuditChain добавляет в журнал, созданный до появления цепочки
+// хэшей, новые столбцы и связывает уже сохраненные записи в цепочку.
+// Все делается в одной транзакции, чтобы не оставить журнал наполовину связанным
+func migrateAuditChain(ctx context.Context, db *sqlx.DB) error {
+	if _, err := db.ExecContext(ctx, queryHasAuditHashColumn); err == nil {
+		return nil
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range queriesAddAuditChainColumns {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	var entries []domain.AuditEntry
+	if err := tx.SelectContext(ctx, &entries, querySelectLegacyAudit); err != nil {
+		return err
+	}
+	prevHash := ""
+	for _, entry := range entries {
+		sealed := entry.Seal(prevHash)
+		if _, err := tx.ExecContext(ctx, tx.Rebind(queryUpdateAuditHash), sealed.PrevHash, sealed.Hash, sealed.Seq); err != nil {
+			return err
+		}
+		prevHash = sealed.Hash
+	}
+	return tx.Commit()
+}
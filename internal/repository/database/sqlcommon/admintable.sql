@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS blocked_domains (
+    domain VARCHAR(253) PRIMARY KEY,
+    reason VARCHAR(512) NOT NULL,
+    created_by VARCHAR(128) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS audit_log (
+    seq BIGINT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    actor VARCHAR(128) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target VARCHAR(512) NOT NULL,
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    before_value TEXT NOT NULL DEFAULT '',
+    after_value TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL,
+    result TEXT NOT NULL,
+    prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+    hash VARCHAR(64) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS audit_log_target ON audit_log(target);
+CREATE INDEX IF NOT EXISTS links_user_id ON links(user_id);
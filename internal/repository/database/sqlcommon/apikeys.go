@@ -0,0 +1,109 @@
+package sqlcommon
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Схема хранения API-ключей
+//
+//go:embed apikeytable.sql
+var QueryCreateAPIKeyTable string
+
+const (
+	queryInsertAPIKey = `INSERT INTO api_keys(id, user_id, name, prefix, hash, scopes, created_at) VALUES(?, ?, ?, ?, ?, ?, ?);`
+	queryAPIKeyByHash = `SELECT id, user_id, name, prefix, hash, scopes, created_at, revoked_at FROM api_keys WHERE hash = ? LIMIT 1;`
+	queryAPIKeyByUser = `SELECT id, user_id, name, prefix, hash, scopes, created_at, revoked_at FROM api_keys WHERE user_id = ? ORDER BY created_at;`
+	queryRevokeAPIKey = `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE user_id = ? AND id = ?;`
+)
+
+// Права хранятся строкой через запятую
+type apiKeyRow struct {
+	ID        string       `db:"id"`
+	UserID    string       `db:"user_id"`
+	Name      string       `db:"name"`
+	Prefix    string       `db:"prefix"`
+	Hash      string       `db:"hash"`
+	Scopes    string       `db:"scopes"`
+	CreatedAt time.Time    `db:"created_at"`
+	RevokedAt sql.NullTime `db:"revoked_at"`
+}
+
+func (r apiKeyRow) toDomain() domain.APIKey {
+	key := domain.APIKey{
+		ID:        r.ID,
+		UserID:    r.UserID,
+		Name:      r.Name,
+		Prefix:    r.Prefix,
+		Hash:      r.Hash,
+		CreatedAt: r.CreatedAt.UTC(),
+	}
+	if r.Scopes != "" {
+		key.Scopes = strings.Split(r.Scopes, ",")
+	}
+	if r.RevokedAt.Valid {
+		revokedAt := r.RevokedAt.Time.UTC()
+		key.RevokedAt = &revokedAt
+	}
+	return key
+}
+
+// StoreAPIKey сохраняет новый API-ключ
+func StoreAPIKey(ctx context.Context, db sqlx.ExtContext, key domain.APIKey) error {
+	_, err := db.ExecContext(ctx, db.Rebind(queryInsertAPIKey),
+		key.ID, key.UserID, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, ","), key.CreatedAt.UTC())
+	if err != nil {
+		return errors.Join(repoerrors.ErrorInsertAPIKey, err)
+	}
+	return nil
+}
+
+// FindAPIKeyByHash ищет ключ по хэшу, в том числе отозванный
+func FindAPIKeyByHash(ctx context.Context, db sqlx.ExtContext, hash string) (domain.APIKey, error) {
+	var row apiKeyRow
+	if err := sqlx.GetContext(ctx, db, &row, db.Rebind(queryAPIKeyByHash), hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.APIKey{}, errors.Join(repoerrors.ErrorAPIKeyNotFound, err)
+		}
+		return domain.APIKey{}, errors.Join(repoerrors.ErrorSelectAPIKeys, err)
+	}
+	return row.toDomain(), nil
+}
+
+// FindAPIKeys возвращает все ключи пользователя в порядке создания
+func FindAPIKeys(ctx context.Context, db sqlx.ExtContext, userID string) ([]domain.APIKey, error) {
+	var rows []apiKeyRow
+	if err := sqlx.SelectContext(ctx, db, &rows, db.Rebind(queryAPIKeyByUser), userID); err != nil {
+		return nil, errors.Join(repoerrors.ErrorSelectAPIKeys, err)
+	}
+	keys := make([]domain.APIKey, len(rows))
+	for i, r := range rows {
+		keys[i] = r.toDomain()
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает ключ пользователя. Повторный отзыв не меняет время отзыва
+func RevokeAPIKey(ctx context.Context, db sqlx.ExtContext, userID, id string, revokedAt time.Time) error {
+	res, err := db.ExecContext(ctx, db.Rebind(queryRevokeAPIKey), revokedAt.UTC(), userID, id)
+	if err != nil {
+		return errors.Join(repoerrors.ErrorRevokeAPIKey, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Join(repoerrors.ErrorRevokeAPIKey, err)
+	}
+	if n == 0 {
+		return repoerrors.ErrorAPIKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS links (
+    user_id VARCHAR(36) NOT NULL,
+    workspace_id VARCHAR(36) NOT NULL DEFAULT '',
+    short_url VARCHAR(36) NOT NULL,
+    original_url VARCHAR(512) NOT NULL,
+    is_deleted BOOLEAN DEFAULT FALSE,
+    is_quarantined BOOLEAN NOT NULL DEFAULT FALSE,
+    password_hash VARCHAR(255) NOT NULL DEFAULT '',
+    max_clicks INTEGER NOT NULL DEFAULT 0,
+    clicks_remaining INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS link_quota_locks (
+    user_id VARCHAR(36) PRIMARY KEY
+);
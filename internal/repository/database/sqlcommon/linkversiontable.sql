@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS link_versions (
+    short_url VARCHAR(36) NOT NULL,
+    version INTEGER NOT NULL,
+    original_url VARCHAR(512) NOT NULL,
+    replaced_by VARCHAR(128) NOT NULL,
+    replaced_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (short_url, version)
+);
@@ -0,0 +1,450 @@
+package sqlcommon
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Общая для всех SQL-бэкендов схема хранения ссылок
+//
+//go:embed linktable.sql
+var QueryCreateTable string
+
+// Схема истории версий ссылок
+//
+//go:embed linkversiontable.sql
+var QueryCreateLinkVersionTable string
+
+// Один адрес сокращается одной ссылкой без ограничения доступа; ссылки
+// с паролем или лимитом переходов всегда создаются отдельными строками.
+// Индекс создается после добавления столбцов в старые таблицы
+const QueryCreateOriginalURLIndex = `CREATE UNIQUE INDEX IF NOT EXISTS links_original_url_unrestricted ON links(original_url) WHERE password_hash = '' AND max_clicks = 0;`
+
+// Запросы записаны с плейсхолдерами "?" и приводятся к синтаксису
+// конкретной СУБД через Rebind
+const (
+	queryInsertLink             = `INSERT INTO links(user_id, workspace_id, short_url, original_url, is_quarantined, password_hash, max_clicks, clicks_remaining) VALUES(?, ?, ?, ?, ?, ?, ?, ?);`
+	queryInsertLinkIfNew        = `INSERT INTO links(user_id, workspace_id, short_url, original_url, is_quarantined, password_hash, max_clicks, clicks_remaining) VALUES(?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (original_url) WHERE password_hash = '' AND max_clicks = 0 DO NOTHING;`
+	queryInsertQuotaLock        = `INSERT INTO link_quota_locks(user_id) VALUES(?) ON CONFLICT (user_id) DO NOTHING;`
+	queryLockQuota              = `UPDATE link_quota_locks SET user_id = user_id WHERE user_id = ?;`
+	querySelectByOriginal       = `SELECT user_id, workspace_id, short_url, original_url, is_quarantined, password_hash, max_clicks, clicks_remaining FROM links WHERE original_url = ? AND password_hash = '' AND max_clicks = 0 LIMIT 1;`
+	querySelectByShort          = `SELECT user_id, workspace_id, short_url, original_url, is_deleted, is_quarantined, password_hash, max_clicks, clicks_remaining FROM links WHERE short_url = ? LIMIT 1;`
+	querySelectByUser           = `SELECT user_id, workspace_id, short_url, original_url, is_quarantined, password_hash, max_clicks, clicks_remaining FROM links WHERE user_id = ? AND workspace_id = '';`
+	querySelectByWorkspace      = `SELECT user_id, workspace_id, short_url, original_url, is_deleted, is_quarantined, password_hash, max_clicks, clicks_remaining FROM links WHERE workspace_id = ?;`
+	queryMarkDeleted            = `UPDATE links SET is_deleted = TRUE WHERE user_id = ? AND workspace_id = '' AND short_url = ?;`
+	queryMarkDeletedInWorkspace = `UPDATE links SET is_deleted = TRUE WHERE workspace_id = ? AND short_url = ?;`
+	queryReassignLinks          = `UPDATE links SET user_id = ? WHERE user_id = ?;`
+	queryMoveLink               = `UPDATE links SET user_id = ?, workspace_id = ? WHERE short_url = ? AND user_id = ? AND workspace_id = ? AND is_deleted = FALSE;`
+	querySetDeleted             = `UPDATE links SET is_deleted = ? WHERE short_url = ?;`
+	queryPurgeLink              = `DELETE FROM links WHERE short_url = ?;`
+	queryPurgeLinkVersions      = `DELETE FROM link_versions WHERE short_url = ?;`
+	queryLockLink               = `UPDATE links SET original_url = original_url WHERE short_url = ?;`
+	queryLastLinkVersion        = `SELECT COALESCE(MAX(version), 0) FROM link_versions WHERE short_url = ?;`
+	queryInsertLinkVersion      = `INSERT INTO link_versions(short_url, version, original_url, replaced_by, replaced_at) VALUES(?, ?, ?, ?, ?);`
+	queryUpdateLink             = `UPDATE links SET original_url = ?, is_quarantined = ?, password_hash = ? WHERE short_url = ?;`
+	querySelectLinkVersions     = `SELECT short_url, version, original_url, replaced_by, replaced_at FROM link_versions WHERE short_url = ? ORDER BY version;`
+	queryCountActiveLinks       = `SELECT COUNT(*) FROM links WHERE user_id = ? AND is_deleted = FALSE;`
+	queryConsumeClick           = `UPDATE links SET clicks_remaining = clicks_remaining - 1 WHERE short_url = ? AND clicks_remaining > 0 RETURNING clicks_remaining;`
+	queryHasWorkspaceColumn     = `SELECT workspace_id FROM links WHERE 1 = 0;`
+	queryAddWorkspaceColumn     = `ALTER TABLE links ADD COLUMN workspace_id VARCHAR(36) NOT NULL DEFAULT '';`
+	queryHasQuarantineColumn    = `SELECT is_quarantined FROM links WHERE 1 = 0;`
+	queryAddQuarantineColumn    = `ALTER TABLE links ADD COLUMN is_quarantined BOOLEAN NOT NULL DEFAULT FALSE;`
+	queryHasPasswordColumn      = `SELECT password_hash FROM links WHERE 1 = 0;`
+	queryAddPasswordColumn      = `ALTER TABLE links ADD COLUMN password_hash VARCHAR(255) NOT NULL DEFAULT '';`
+	queryHasClicksColumns       = `SELECT max_clicks, clicks_remaining FROM links WHERE 1 = 0;`
+	queryAddMaxClicksColumn     = `ALTER TABLE links ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0;`
+	queryAddClicksColumn        = `ALTER TABLE links ADD COLUMN clicks_remaining INTEGER NOT NULL DEFAULT 0;`
+)
+
+// Особенности обработки ошибок конкретного драйвера
+type ErrorClassifier interface {
+	// ошибка вызвана нарушением ограничения уникальности
+	IsUniqueViolation(err error) bool
+	// ошибка пришла от самой СУБД, а не от сетевого уровня или драйвера
+	IsDriverError(err error) bool
+}
+
+// CreateTable применяет схему хранения ссылок и их версий, API-ключей,
+// пользователей, рабочих пространств и административных данных
+func CreateTable(ctx context.Context, db *sqlx.DB) error {
+	if _, err := db.ExecContext(ctx, QueryCreateTable); err != nil {
+		return errors.Join(repoerrors.ErrorTableCreate, err)
+	}
+	// таблица ссылок могла быть создана до появления рабочих пространств, карантина,
+	// паролей и ограничения числа переходов
+	if _, err := db.ExecContext(ctx, queryHasWorkspaceColumn); err != nil {
+		if _, err := db.ExecContext(ctx, queryAddWorkspaceColumn); err != nil {
+			return errors.Join(repoerrors.ErrorTableCreate, err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, queryHasQuarantineColumn); err != nil {
+		if _, err := db.ExecContext(ctx, queryAddQuarantineColumn); err != nil {
+			return errors.Join(repoerrors.ErrorTableCreate, err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, queryHasPasswordColumn); err != nil {
+		if _, err := db.ExecContext(ctx, queryAddPasswordColumn); err != nil {
+			return errors.Join(repoerrors.ErrorTableCreate, err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, queryHasClicksColumns); err != nil {
+		for _, query := range []string{queryAddMaxClicksColumn, queryAddClicksColumn} {
+			if _, err := db.ExecContext(ctx, query); err != nil {
+				return errors.Join(repoerrors.ErrorTableCreate, err)
+			}
+		}
+	}
+	if _, err := db.ExecContext(ctx, QueryCreateOriginalURLIndex); err != nil {
+		return errors.Join(repoerrors.ErrorTableCreate, err)
+	}
+	for _, query := range []string{QueryCreateLinkVersionTable, QueryCreateAPIKeyTable, QueryCreateUserTable, QueryCreateWorkspaceTable, QueryCreateAdminTable} {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return errors.Join(repoerrors.ErrorTableCreate, err)
+		}
+	}
+	if err := migrateAuditChain(ctx, db); err != nil {
+		return errors.Join(repoerrors.ErrorTableCreate, err)
+	}
+	return nil
+}
+
+// Store сохраняет ссылку. Если оригинальный URL уже сокращался ссылкой без
+// ограничения доступа, то возвращает ее вместе с ошибкой ErrorShortLinkAlreadyInDB
+func Store(ctx context.Context, db sqlx.ExtContext, classifier ErrorClassifier, urllink domain.URLLink) (domain.URLLink, error) {
+	_, err := db.ExecContext(ctx, db.Rebind(queryInsertLink), urllink.UserID, urllink.WorkspaceID, urllink.ShortURL, urllink.LongURL, urllink.Quarantined, urllink.PasswordHash, urllink.MaxClicks, urllink.ClicksRemaining)
+	if err == nil {
+		return urllink, nil
+	}
+
+	if !classifier.IsDriverError(err) {
+		return domain.URLLink{}, errors.Join(repoerrors.ErrorInsertShortLink, err)
+	}
+
+	if classifier.IsUniqueViolation(err) {
+		if err := sqlx.GetContext(ctx, db, &urllink, db.Rebind(querySelectByOriginal), urllink.LongURL); err != nil {
+			return domain.URLLink{}, errors.Join(repoerrors.ErrorSelectExistedShortLink, err)
+		}
+		return urllink, errors.Join(repoerrors.ErrorShortLinkAlreadyInDB, err)
+	}
+
+	// Обработка других ошибок СУБД
+	return domain.URLLink{}, errors.Join(repoerrors.ErrorSQLInternal, err)
+}
+
+// StoreBatch сохраняет ссылки одного пользователя в одной транзакции.
+// Квота проверяется подсчетом после вставки под блокировкой строки
+// пользователя в link_quota_locks, поэтому параллельные вставки ссылок
+// одного пользователя выполняются по очереди и не превышают ее. Ссылка
+// с ограничением доступа всегда вставляется новой строкой, остальные
+// заменяются уже существующей ссылкой без ограничений на тот же адрес
+func StoreBatch(ctx context.Context, db *sqlx.DB, links []domain.URLLink, maxActive int) ([]domain.URLLink, error) {
+	if len(links) == 0 {
+		return nil, nil
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Join(repoerrors.ErrorInsertShortLink, err)
+	}
+	defer tx.Rollback()
+
+	userID := links[0].UserID
+	if maxActive > 0 {
+		if err := lockQuota(ctx, tx, userID); err != nil {
+			return nil, errors.Join(repoerrors.ErrorInsertShortLink, err)
+		}
+	}
+
+	stored := make([]domain.URLLink, len(links))
+	created := 0
+	for i, link := range links {
+		query := queryInsertLinkIfNew
+		if link.Restricted() {
+			query = queryInsertLink
+		}
+		res, err := tx.ExecContext(ctx, tx.Rebind(query), link.UserID, link.WorkspaceID, link.ShortURL, link.LongURL, link.Quarantined, link.PasswordHash, link.MaxClicks, link.ClicksRemaining)
+		if err != nil {
+			return nil, errors.Join(repoerrors.ErrorInsertShortLink, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, errors.Join(repoerrors.ErrorInsertShortLink, err)
+		}
+		if n == 1 {
+			stored[i] = link
+			created++
+			continue
+		}
+		// адрес уже сокращен, в том числе предыдущей ссылкой пакета
+		var existing domain.URLLink
+		if err := tx.GetContext(ctx, &existing, tx.Rebind(querySelectByOriginal), link.LongURL); err != nil {
+			return nil, errors.Join(repoerrors.ErrorSelectExistedShortLink, err)
+		}
+		stored[i] = existing
+	}
+
+	if maxActive > 0 && created > 0 {
+		count, err := CountActiveLinks(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if count > maxActive {
+			return nil, repoerrors.ErrorLinkQuotaExceeded
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Join(repoerrors.ErrorInsertShortLink, err)
+	}
+	return stored, nil
+}
+
+// lockQuota блокирует до конца транзакции строку пользователя в link_quota_locks
+func lockQuota(ctx context.Context, tx *sqlx.Tx, userID string) error {
+	if _, err := tx.ExecContext(ctx, tx.Rebind(queryInsertQuotaLock), userID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, tx.Rebind(queryLockQuota), userID)
+	return err
+}
+
+// Find ищет ссылку по короткому идентификатору
+func Find(ctx context.Context, db sqlx.ExtContext, shortURL string) (domain.URLLink, error) {
+	var urllink domain.URLLink
+	if err := sqlx.GetContext(ctx, db, &urllink, db.Rebind(querySelectByShort), shortURL); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// не найден короткий URL
+			return domain.URLLink{}, errors.Join(repoerrors.ErrorShortLinkNotFound, err)
+		}
+		// при извлечении произошла ошибка
+		return urllink, errors.Join(repoerrors.ErrorSelectExistedShortLink, err)
+	}
+	return urllink, nil
+}
+
+// FindAll возвращает личные ссылки пользователя
+func FindAll(ctx context.Context, db sqlx.ExtContext, userID string) ([]domain.URLLink, error) {
+	var urllinks []domain.URLLink
+	if err := sqlx.SelectContext(ctx, db, &urllinks, db.Rebind(querySelectByUser), userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// не найден короткий URL
+			return nil, errors.Join(repoerrors.ErrorShortLinkNotFound, err)
+		}
+		return nil, errors.Join(repoerrors.ErrorSelectShortLinks, err)
+	}
+	return urllinks, nil
+}
+
+// FindAllInWorkspace возвращает все ссылки рабочего пространства
+func FindAllInWorkspace(ctx context.Context, db sqlx.ExtContext, workspaceID string) ([]domain.URLLink, error) {
+	var urllinks []domain.URLLink
+	if err := sqlx.SelectContext(ctx, db, &urllinks, db.Rebind(querySelectByWorkspace), workspaceID); err != nil {
+		return nil, errors.Join(repoerrors.ErrorSelectShortLinks, err)
+	}
+	return urllinks, nil
+}
+
+// MarkDeletedBatch помечает ссылки удаленными построчно в одной транзакции.
+// Подходит для СУБД без поддержки массивов в параметрах запроса
+func MarkDeletedBatch(ctx context.Context, db *sqlx.DB, links []domain.URLLink) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Join(repoerrors.ErrorMarkDeletedBatch, err)
+	}
+	defer tx.Rollback()
+
+	for _, l := range links {
+		query, owner := queryMarkDeleted, l.UserID
+		if l.WorkspaceID != "" {
+			query, owner = queryMarkDeletedInWorkspace, l.WorkspaceID
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind(query), owner, l.ShortURL); err != nil {
+			return errors.Join(repoerrors.ErrorMarkDeletedBatch, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Join(repoerrors.ErrorMarkDeletedBatch, err)
+	}
+	return nil
+}
+
+// ReassignLinks переносит все ссылки одного пользователя другому. Квота
+// получателя проверяется, как в StoreBatch, подсчетом после переноса
+// под блокировкой его строки в link_quota_locks
+func ReassignLinks(ctx context.Context, db *sqlx.DB, fromUserID, toUserID string, maxActive int) (int64, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.Join(repoerrors.ErrorReassignLinks, err)
+	}
+	defer tx.Rollback()
+
+	if maxActive > 0 {
+		if err := lockQuota(ctx, tx, toUserID); err != nil {
+			return 0, errors.Join(repoerrors.ErrorReassignLinks, err)
+		}
+	}
+	res, err := tx.ExecContext(ctx, tx.Rebind(queryReassignLinks), toUserID, fromUserID)
+	if err != nil {
+		return 0, errors.Join(repoerrors.ErrorReassignLinks, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Join(repoerrors.ErrorReassignLinks, err)
+	}
+	if maxActive > 0 && n > 0 {
+		count, err := CountActiveLinks(ctx, tx, toUserID)
+		if err != nil {
+			return 0, err
+		}
+		if count > maxActive {
+			return 0, repoerrors.ErrorLinkQuotaExceeded
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Join(repoerrors.ErrorReassignLinks, err)
+	}
+	return n, nil
+}
+
+// MoveLink меняет пользователя и рабочее пространство ссылки. Условие на
+// прежнего владельца в том же UPDATE не дает перенести ссылку, которую
+// удалили или перенесли после проверки прав
+func MoveLink(ctx context.Context, db sqlx.ExtContext, from domain.URLLink, userID, workspaceID string) error {
+	res, err := db.ExecContext(ctx, db.Rebind(queryMoveLink), userID, workspaceID, from.ShortURL, from.UserID, from.WorkspaceID)
+	if err != nil {
+		return errors.Join(repoerrors.ErrorMoveLink, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Join(repoerrors.ErrorMoveLink, err)
+	}
+	if n == 0 {
+		return repoerrors.ErrorShortLinkNotFound
+	}
+	return nil
+}
+
+// SetDeleted ставит или снимает пометку удаления независимо от владельца
+func SetDeleted(ctx context.Context, db sqlx.ExtContext, shortURL string, deleted bool) error {
+	return execOnLink(ctx, db, querySetDeleted, deleted, shortURL)
+}
+
+// PurgeLink удаляет ссылку из таблицы вместе с историей версий
+func PurgeLink(ctx context.Context, db *sqlx.DB, shortURL string) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, tx.Rebind(queryPurgeLinkVersions), shortURL); err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	if err := execOnLink(ctx, tx, queryPurgeLink, shortURL); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	return nil
+}
+
+// Update меняет адрес назначения ссылки и сохраняет прежний в истории версий.
+// Строка ссылки блокируется первым же запросом, поэтому параллельные изменения
+// одной ссылки выполняются по очереди и не получают одинаковых номеров версий
+func Update(ctx context.Context, db *sqlx.DB, classifier ErrorClassifier, link domain.URLLink, editedBy string) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	defer tx.Rollback()
+
+	if err := execOnLink(ctx, tx, queryLockLink, link.ShortURL); err != nil {
+		return err
+	}
+	current, err := Find(ctx, tx, link.ShortURL)
+	if err != nil {
+		return err
+	}
+	if current.LongURL == link.LongURL && current.Quarantined == link.Quarantined && current.PasswordHash == link.PasswordHash {
+		return nil
+	}
+
+	var version int
+	if err := tx.GetContext(ctx, &version, tx.Rebind(queryLastLinkVersion), link.ShortURL); err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	_, err = tx.ExecContext(ctx, tx.Rebind(queryInsertLinkVersion),
+		link.ShortURL, version+1, current.LongURL, editedBy, time.Now().UTC())
+	if err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind(queryUpdateLink), link.LongURL, link.Quarantined, link.PasswordHash, link.ShortURL); err != nil {
+		if classifier.IsUniqueViolation(err) {
+			return errors.Join(repoerrors.ErrorShortLinkAlreadyInDB, err)
+		}
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	return nil
+}
+
+// ConsumeClick уменьшает счетчик оставшихся переходов одним запросом:
+// условие в WHERE не дает параллельным переходам увести его ниже нуля
+func ConsumeClick(ctx context.Context, db sqlx.ExtContext, shortURL string) (int, error) {
+	var remaining int
+	if err := sqlx.GetContext(ctx, db, &remaining, db.Rebind(queryConsumeClick), shortURL); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errors.Join(repoerrors.ErrorClicksExhausted, err)
+		}
+		return 0, errors.Join(repoerrors.ErrorConsumeClick, err)
+	}
+	return remaining, nil
+}
+
+func CountActiveLinks(ctx context.Context, db sqlx.ExtContext, userID string) (int, error) {
+	var count int
+	if err := sqlx.GetContext(ctx, db, &count, db.Rebind(queryCountActiveLinks), userID); err != nil {
+		return 0, errors.Join(repoerrors.ErrorCountLinks, err)
+	}
+	return count, nil
+}
+
+func FindVersions(ctx context.Context, db sqlx.ExtContext, shortURL string) ([]domain.LinkVersion, error) {
+	var versions []domain.LinkVersion
+	if err := sqlx.SelectContext(ctx, db, &versions, db.Rebind(querySelectLinkVersions), shortURL); err != nil {
+		return nil, errors.Join(repoerrors.ErrorSelectLinkVersions, err)
+	}
+	for i := range versions {
+		versions[i].ReplacedAt = versions[i].ReplacedAt.UTC()
+	}
+	return versions, nil
+}
+
+// выполняет запрос над одной ссылкой; если ссылки нет - ErrorShortLinkNotFound
+func execOnLink(ctx context.Context, db sqlx.ExtContext, query string, args ...interface{}) error {
+	res, err := db.ExecContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	if n == 0 {
+		return repoerrors.ErrorShortLinkNotFound
+	}
+	return nil
+}
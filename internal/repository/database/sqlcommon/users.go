@@ -0,0 +1,73 @@
+package sqlcommon
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Схема хранения учетных записей
+//
+//go:embed usertable.sql
+var QueryCreateUserTable string
+
+const (
+	queryInsertUser      = `INSERT INTO users(id, login, password_hash, created_at) VALUES(?, ?, ?, ?);`
+	queryUpdatePassword  = `UPDATE users SET password_hash = ? WHERE id = ?;`
+	querySelectUserLogin = `SELECT id, login, password_hash, created_at FROM users WHERE login = ? LIMIT 1;`
+	querySelectUserByID  = `SELECT id, login, password_hash, created_at FROM users WHERE id = ? LIMIT 1;`
+)
+
+// StoreUser сохраняет учетную запись. Занятый логин - ErrorUserAlreadyExists
+func StoreUser(ctx context.Context, db sqlx.ExtContext, classifier ErrorClassifier, user domain.User) error {
+	_, err := db.ExecContext(ctx, db.Rebind(queryInsertUser), user.ID, user.Login, user.PasswordHash, user.CreatedAt.UTC())
+	if err == nil {
+		return nil
+	}
+	if classifier.IsUniqueViolation(err) {
+		return errors.Join(repoerrors.ErrorUserAlreadyExists, err)
+	}
+	return errors.Join(repoerrors.ErrorInsertUser, err)
+}
+
+// UpdatePasswordHash заменяет хэш пароля, например при смене параметров хэширования
+func UpdatePasswordHash(ctx context.Context, db sqlx.ExtContext, userID, passwordHash string) error {
+	res, err := db.ExecContext(ctx, db.Rebind(queryUpdatePassword), passwordHash, userID)
+	if err != nil {
+		return errors.Join(repoerrors.ErrorUpdateUser, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Join(repoerrors.ErrorUpdateUser, err)
+	}
+	if n == 0 {
+		return repoerrors.ErrorUserNotFound
+	}
+	return nil
+}
+
+func FindUserByLogin(ctx context.Context, db sqlx.ExtContext, login string) (domain.User, error) {
+	return findUser(ctx, db, querySelectUserLogin, login)
+}
+
+func FindUserByID(ctx context.Context, db sqlx.ExtContext, id string) (domain.User, error) {
+	return findUser(ctx, db, querySelectUserByID, id)
+}
+
+func findUser(ctx context.Context, db sqlx.ExtContext, query, arg string) (domain.User, error) {
+	var user domain.User
+	if err := sqlx.GetContext(ctx, db, &user, db.Rebind(query), arg); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.User{}, errors.Join(repoerrors.ErrorUserNotFound, err)
+		}
+		return domain.User{}, errors.Join(repoerrors.ErrorSelectUser, err)
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+	return user, nil
+}
@@ -0,0 +1,121 @@
+package sqlcommon
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Схема хранения рабочих пространств и их участников
+//
+//go:embed workspacetable.sql
+var QueryCreateWorkspaceTable string
+
+const (
+	queryInsertWorkspace      = `INSERT INTO workspaces(id, name, created_at) VALUES(?, ?, ?);`
+	querySelectWorkspace      = `SELECT id, name, created_at FROM workspaces WHERE id = ? LIMIT 1;`
+	querySelectUserWorkspaces = `SELECT w.id, w.name, w.created_at, m.role FROM workspace_members m
+		JOIN workspaces w ON w.id = m.workspace_id WHERE m.user_id = ? ORDER BY w.created_at, w.id;`
+	queryUpsertMember = `INSERT INTO workspace_members(workspace_id, user_id, role, added_at) VALUES(?, ?, ?, ?)
+		ON CONFLICT(workspace_id, user_id) DO UPDATE SET role = excluded.role;`
+	querySelectMember  = `SELECT workspace_id, user_id, role, added_at FROM workspace_members WHERE workspace_id = ? AND user_id = ? LIMIT 1;`
+	querySelectMembers = `SELECT workspace_id, user_id, role, added_at FROM workspace_members WHERE workspace_id = ? ORDER BY added_at, user_id;`
+	queryDeleteMember  = `DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?;`
+)
+
+// StoreWorkspace сохраняет рабочее пространство и его владельца в одной транзакции
+func StoreWorkspace(ctx context.Context, db *sqlx.DB, workspace domain.Workspace, owner domain.WorkspaceMember) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Join(repoerrors.ErrorInsertWorkspace, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, tx.Rebind(queryInsertWorkspace), workspace.ID, workspace.Name, workspace.CreatedAt.UTC()); err != nil {
+		return errors.Join(repoerrors.ErrorInsertWorkspace, err)
+	}
+	if err := SaveMember(ctx, tx, owner); err != nil {
+		return errors.Join(repoerrors.ErrorInsertWorkspace, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Join(repoerrors.ErrorInsertWorkspace, err)
+	}
+	return nil
+}
+
+func FindWorkspace(ctx context.Context, db sqlx.ExtContext, id string) (domain.Workspace, error) {
+	var workspace domain.Workspace
+	if err := sqlx.GetContext(ctx, db, &workspace, db.Rebind(querySelectWorkspace), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Workspace{}, errors.Join(repoerrors.ErrorWorkspaceNotFound, err)
+		}
+		return domain.Workspace{}, errors.Join(repoerrors.ErrorSelectWorkspaces, err)
+	}
+	workspace.CreatedAt = workspace.CreatedAt.UTC()
+	return workspace, nil
+}
+
+func FindUserWorkspaces(ctx context.Context, db sqlx.ExtContext, userID string) ([]domain.WorkspaceMembership, error) {
+	var memberships []domain.WorkspaceMembership
+	if err := sqlx.SelectContext(ctx, db, &memberships, db.Rebind(querySelectUserWorkspaces), userID); err != nil {
+		return nil, errors.Join(repoerrors.ErrorSelectWorkspaces, err)
+	}
+	for i := range memberships {
+		memberships[i].CreatedAt = memberships[i].CreatedAt.UTC()
+	}
+	return memberships, nil
+}
+
+func FindMember(ctx context.Context, db sqlx.ExtContext, workspaceID, userID string) (domain.WorkspaceMember, error) {
+	var member domain.WorkspaceMember
+	if err := sqlx.GetContext(ctx, db, &member, db.Rebind(querySelectMember), workspaceID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WorkspaceMember{}, errors.Join(repoerrors.ErrorMemberNotFound, err)
+		}
+		return domain.WorkspaceMember{}, errors.Join(repoerrors.ErrorSelectWorkspaces, err)
+	}
+	member.AddedAt = member.AddedAt.UTC()
+	return member, nil
+}
+
+func FindMembers(ctx context.Context, db sqlx.ExtContext, workspaceID string) ([]domain.WorkspaceMember, error) {
+	var members []domain.WorkspaceMember
+	if err := sqlx.SelectContext(ctx, db, &members, db.Rebind(querySelectMembers), workspaceID); err != nil {
+		return nil, errors.Join(repoerrors.ErrorSelectWorkspaces, err)
+	}
+	for i := range members {
+		members[i].AddedAt = members[i].AddedAt.UTC()
+	}
+	return members, nil
+}
+
+// SaveMember добавляет участника или меняет роль существующего,
+// время добавления при этом не меняется
+func SaveMember(ctx context.Context, db sqlx.ExtContext, member domain.WorkspaceMember) error {
+	_, err := db.ExecContext(ctx, db.Rebind(queryUpsertMember), member.WorkspaceID, member.UserID, string(member.Role), member.AddedAt.UTC())
+	if err != nil {
+		return errors.Join(repoerrors.ErrorSaveMember, err)
+	}
+	return nil
+}
+
+func RemoveMember(ctx context.Context, db sqlx.ExtContext, workspaceID, userID string) error {
+	res, err := db.ExecContext(ctx, db.Rebind(queryDeleteMember), workspaceID, userID)
+	if err != nil {
+		return errors.Join(repoerrors.ErrorSaveMember, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Join(repoerrors.ErrorSaveMember, err)
+	}
+	if n == 0 {
+		return repoerrors.ErrorMemberNotFound
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS workspaces (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(128) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS workspace_members (
+    workspace_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    added_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (workspace_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS workspace_members_user_id ON workspace_members(user_id);
+CREATE INDEX IF NOT EXISTS links_workspace_id ON links(workspace_id);
@@ -0,0 +1,276 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/database/sqlcommon"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Таблица ссылок, созданная до частичного индекса по адресу, хранит
+// ограничение UNIQUE в самом определении столбца. SQLite не умеет удалять
+// такие ограничения, поэтому таблица пересоздается
+const (
+	queryHasLegacyOriginalUnique = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'links' AND name LIKE 'sqlite_autoindex_links_%';`
+	queryRebuildLinksTable       = `CREATE TABLE links_rebuilt (
+    user_id VARCHAR(36) NOT NULL,
+    workspace_id VARCHAR(36) NOT NULL DEFAULT '',
+    short_url VARCHAR(36) NOT NULL,
+    original_url VARCHAR(512) NOT NULL,
+    is_deleted BOOLEAN DEFAULT FALSE,
+    is_quarantined BOOLEAN NOT NULL DEFAULT FALSE,
+    password_hash VARCHAR(255) NOT NULL DEFAULT '',
+    max_clicks INTEGER NOT NULL DEFAULT 0,
+    clicks_remaining INTEGER NOT NULL DEFAULT 0
+);
+INSERT INTO links_rebuilt(user_id, workspace_id, short_url, original_url, is_deleted, is_quarantined, password_hash, max_clicks, clicks_remaining)
+    SELECT user_id, workspace_id, short_url, original_url, is_deleted, is_quarantined, password_hash, max_clicks, clicks_remaining FROM links;
+DROP TABLE links;
+ALTER TABLE links_rebuilt RENAME TO links;`
+)
+
+type SQLiteLinkRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLiteLinkRepository открывает (или создает) файл базы SQLite.
+// Для тестов можно передать ":memory:"
+func NewSQLiteLinkRepository(dbPath string) (*SQLiteLinkRepository, error) {
+	db, err := sqlx.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, errors.Join(repoerrors.ErrorConnectingDB, err)
+	}
+
+	// SQLite не поддерживает конкурентную запись, а база ":memory:"
+	// существует только в рамках одного соединения
+	db.SetMaxOpenConns(1)
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, errors.Join(repoerrors.ErrorPingDB, err)
+	}
+
+	repo := &SQLiteLinkRepository{db: db}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sqlcommon.CreateTable(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := dropLegacyOriginalUnique(ctx, db); err != nil {
+		db.Close()
+		return nil, errors.Join(repoerrors.ErrorTableCreate, err)
+	}
+
+	return repo, nil
+}
+
+// dropLegacyOriginalUnique пересоздает таблицу ссылок без UNIQUE на адресе,
+// чтобы один адрес можно было сократить и ссылками с ограничением доступа
+func dropLegacyOriginalUnique(ctx context.Context, db *sqlx.DB) error {
+	var legacy int
+	if err := db.GetContext(ctx, &legacy, queryHasLegacyOriginalUnique); err != nil {
+		return err
+	}
+	if legacy == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// индекс удаляется вместе со старой таблицей
+	for _, query := range []string{queryRebuildLinksTable, sqlcommon.QueryCreateOriginalURLIndex} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteLinkRepository) Store(ctx context.Context, urllink domain.URLLink) (domain.URLLink, error) {
+	return sqlcommon.Store(ctx, s.db, sqliteClassifier{}, urllink)
+}
+
+func (s *SQLiteLinkRepository) Find(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	return sqlcommon.Find(ctx, s.db, shortURL)
+}
+
+// FindConsistent совпадает с Find: у SQLite нет ни реплик, ни кэша
+func (s *SQLiteLinkRepository) FindConsistent(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	return sqlcommon.Find(ctx, s.db, shortURL)
+}
+
+func (s *SQLiteLinkRepository) FindAll(ctx context.Context, userID string) ([]domain.URLLink, error) {
+	return sqlcommon.FindAll(ctx, s.db, userID)
+}
+
+func (s *SQLiteLinkRepository) FindAllInWorkspace(ctx context.Context, workspaceID string) ([]domain.URLLink, error) {
+	return sqlcommon.FindAllInWorkspace(ctx, s.db, workspaceID)
+}
+
+func (s *SQLiteLinkRepository) MarkDeletedBatch(ctx context.Context, links []domain.URLLink) error {
+	return sqlcommon.MarkDeletedBatch(ctx, s.db, links)
+}
+
+func (s *SQLiteLinkRepository) ReassignLinks(ctx context.Context, fromUserID, toUserID string, maxActive int) (int64, error) {
+	return sqlcommon.ReassignLinks(ctx, s.db, fromUserID, toUserID, maxActive)
+}
+
+func (s *SQLiteLinkRepository) MoveLink(ctx context.Context, from domain.URLLink, userID, workspaceID string) error {
+	return sqlcommon.MoveLink(ctx, s.db, from, userID, workspaceID)
+}
+
+func (s *SQLiteLinkRepository) SetDeleted(ctx context.Context, shortURL string, deleted bool) error {
+	return sqlcommon.SetDeleted(ctx, s.db, shortURL, deleted)
+}
+
+func (s *SQLiteLinkRepository) PurgeLink(ctx context.Context, shortURL string) error {
+	return sqlcommon.PurgeLink(ctx, s.db, shortURL)
+}
+
+// Запись в SQLite идет по одной, а условие в UPDATE ... RETURNING
+// не дает счетчику уйти ниже нуля
+func (s *SQLiteLinkRepository) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	return sqlcommon.ConsumeClick(ctx, s.db, shortURL)
+}
+
+func (s *SQLiteLinkRepository) Update(ctx context.Context, link domain.URLLink, editedBy string) error {
+	return sqlcommon.Update(ctx, s.db, sqliteClassifier{}, link, editedBy)
+}
+
+func (s *SQLiteLinkRepository) FindVersions(ctx context.Context, shortURL string) ([]domain.LinkVersion, error) {
+	return sqlcommon.FindVersions(ctx, s.db, shortURL)
+}
+
+func (s *SQLiteLinkRepository) StoreBatch(ctx context.Context, links []domain.URLLink, maxActive int) ([]domain.URLLink, error) {
+	return sqlcommon.StoreBatch(ctx, s.db, links, maxActive)
+}
+
+func (s *SQLiteLinkRepository) CountActiveLinks(ctx context.Context, userID string) (int, error) {
+	return sqlcommon.CountActiveLinks(ctx, s.db, userID)
+}
+
+func (s *SQLiteLinkRepository) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return errors.Join(repoerrors.ErrorPingDB, err)
+	}
+	return nil
+}
+
+func (s *SQLiteLinkRepository) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+func (s *SQLiteLinkRepository) StoreAPIKey(ctx context.Context, key domain.APIKey) error {
+	return sqlcommon.StoreAPIKey(ctx, s.db, key)
+}
+
+func (s *SQLiteLinkRepository) FindAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	return sqlcommon.FindAPIKeyByHash(ctx, s.db, hash)
+}
+
+func (s *SQLiteLinkRepository) FindAPIKeys(ctx context.Context, userID string) ([]domain.APIKey, error) {
+	return sqlcommon.FindAPIKeys(ctx, s.db, userID)
+}
+
+func (s *SQLiteLinkRepository) RevokeAPIKey(ctx context.Context, userID, id string, revokedAt time.Time) error {
+	return sqlcommon.RevokeAPIKey(ctx, s.db, userID, id, revokedAt)
+}
+
+func (s *SQLiteLinkRepository) StoreUser(ctx context.Context, user domain.User) error {
+	return sqlcommon.StoreUser(ctx, s.db, sqliteClassifier{}, user)
+}
+
+func (s *SQLiteLinkRepository) UpdatePasswordHash(ctx context.Context, userID, passwordHash string) error {
+	return sqlcommon.UpdatePasswordHash(ctx, s.db, userID, passwordHash)
+}
+
+func (s *SQLiteLinkRepository) FindUserByLogin(ctx context.Context, login string) (domain.User, error) {
+	return sqlcommon.FindUserByLogin(ctx, s.db, login)
+}
+
+func (s *SQLiteLinkRepository) FindUserByID(ctx context.Context, id string) (domain.User, error) {
+	return sqlcommon.FindUserByID(ctx, s.db, id)
+}
+
+func (s *SQLiteLinkRepository) StoreWorkspace(ctx context.Context, workspace domain.Workspace, owner domain.WorkspaceMember) error {
+	return sqlcommon.StoreWorkspace(ctx, s.db, workspace, owner)
+}
+
+func (s *SQLiteLinkRepository) FindWorkspace(ctx context.Context, id string) (domain.Workspace, error) {
+	return sqlcommon.FindWorkspace(ctx, s.db, id)
+}
+
+func (s *SQLiteLinkRepository) FindUserWorkspaces(ctx context.Context, userID string) ([]domain.WorkspaceMembership, error) {
+	return sqlcommon.FindUserWorkspaces(ctx, s.db, userID)
+}
+
+func (s *SQLiteLinkRepository) FindMember(ctx context.Context, workspaceID, userID string) (domain.WorkspaceMember, error) {
+	return sqlcommon.FindMember(ctx, s.db, workspaceID, userID)
+}
+
+func (s *SQLiteLinkRepository) FindMembers(ctx context.Context, workspaceID string) ([]domain.WorkspaceMember, error) {
+	return sqlcommon.FindMembers(ctx, s.db, workspaceID)
+}
+
+func (s *SQLiteLinkRepository) SaveMember(ctx context.Context, member domain.WorkspaceMember) error {
+	return sqlcommon.SaveMember(ctx, s.db, member)
+}
+
+func (s *SQLiteLinkRepository) RemoveMember(ctx context.Context, workspaceID, userID string) error {
+	return sqlcommon.RemoveMember(ctx, s.db, workspaceID, userID)
+}
+
+func (s *SQLiteLinkRepository) FindUserLinks(ctx context.Context, userID string) ([]domain.URLLink, error) {
+	return sqlcommon.FindUserLinks(ctx, s.db, userID)
+}
+
+func (s *SQLiteLinkRepository) SystemStats(ctx context.Context) (domain.SystemStats, error) {
+	return sqlcommon.SystemStats(ctx, s.db)
+}
+
+func (s *SQLiteLinkRepository) BlockDomain(ctx context.Context, block domain.DomainBlock) error {
+	return sqlcommon.BlockDomain(ctx, s.db, block)
+}
+
+func (s *SQLiteLinkRepository) UnblockDomain(ctx context.Context, domainName string) error {
+	return sqlcommon.UnblockDomain(ctx, s.db, domainName)
+}
+
+func (s *SQLiteLinkRepository) FindBlockedDomains(ctx context.Context) ([]domain.DomainBlock, error) {
+	return sqlcommon.FindBlockedDomains(ctx, s.db)
+}
+
+func (s *SQLiteLinkRepository) AppendAudit(ctx context.Context, entry domain.AuditEntry) (domain.AuditEntry, error) {
+	return sqlcommon.AppendAudit(ctx, s.db, sqliteClassifier{}, entry)
+}
+
+func (s *SQLiteLinkRepository) FindAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	return sqlcommon.FindAuditEntries(ctx, s.db, filter)
+}
+
+// Классификация ошибок драйвера mattn/go-sqlite3
+type sqliteClassifier struct{}
+
+func (sqliteClassifier) IsUniqueViolation(err error) bool {
+	var sqliteError sqlite3.Error
+	return errors.As(err, &sqliteError) && sqliteError.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+func (sqliteClassifier) IsDriverError(err error) bool {
+	var sqliteError sqlite3.Error
+	return errors.As(err, &sqliteError)
+}
@@ -0,0 +1,163 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repotest"
+)
+
+func newTestRepo(t *testing.T) *SQLiteLinkRepository {
+	repo, err := NewSQLiteLinkRepository(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestSQLiteLinkRepository_Conformance(t *testing.T) {
+	repotest.RunConformance(t, func(t *testing.T) domain.URLLinkRepo {
+		return newTestRepo(t)
+	})
+}
+
+func TestSQLiteLinkRepository_APIKeyConformance(t *testing.T) {
+	repotest.RunAPIKeyConformance(t, func(t *testing.T) domain.APIKeyRepo {
+		return newTestRepo(t)
+	})
+}
+
+func TestSQLiteLinkRepository_UserConformance(t *testing.T) {
+	repotest.RunUserConformance(t, func(t *testing.T) domain.UserRepo {
+		return newTestRepo(t)
+	})
+}
+
+func TestSQLiteLinkRepository_WorkspaceConformance(t *testing.T) {
+	repotest.RunWorkspaceConformance(t, func(t *testing.T) domain.WorkspaceRepo {
+		return newTestRepo(t)
+	})
+}
+
+func TestSQLiteLinkRepository_AdminConformance(t *testing.T) {
+	repotest.RunAdminConformance(t, func(t *testing.T) repotest.AdminStore {
+		return newTestRepo(t)
+	})
+}
+
+// База, созданная до появления рабочих пространств, получает столбец workspace_id
+func TestSQLiteLinkRepository_MigratesLinksTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.db")
+	db, err := sqlx.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE links (
+		user_id VARCHAR(36) NOT NULL,
+		short_url VARCHAR(36) NOT NULL,
+		original_url VARCHAR(512) NOT NULL UNIQUE,
+		is_deleted BOOLEAN DEFAULT FALSE
+	);
+	INSERT INTO links(user_id, short_url, original_url) VALUES('u1', 'abc', 'https://example.com');`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	repo, err := NewSQLiteLinkRepository(path)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	links, err := repo.FindAll(context.Background(), "u1")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Empty(t, links[0].WorkspaceID)
+
+	// уникальность адреса остается только среди ссылок без ограничения доступа
+	stored, err := repo.StoreBatch(context.Background(), []domain.URLLink{
+		{UserID: "u2", ShortURL: "def", LongURL: "https://example.com"},
+		{UserID: "u2", ShortURL: "ghi", LongURL: "https://example.com", MaxClicks: 1, ClicksRemaining: 1},
+	}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", stored[0].ShortURL)
+	assert.Equal(t, "ghi", stored[1].ShortURL)
+}
+
+// Журнал аудита, созданный до появления цепочки хэшей, получает новые
+// столбцы, а уже сохраненные записи связываются в цепочку
+func TestSQLiteLinkRepository_MigratesAuditLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.db")
+	repo, err := NewSQLiteLinkRepository(path)
+	require.NoError(t, err)
+	_, err = repo.db.Exec(`DROP TABLE audit_log;
+	CREATE TABLE audit_log (
+		seq BIGINT PRIMARY KEY,
+		created_at TIMESTAMP NOT NULL,
+		actor VARCHAR(128) NOT NULL,
+		action VARCHAR(64) NOT NULL,
+		target VARCHAR(512) NOT NULL,
+		details TEXT NOT NULL,
+		result TEXT NOT NULL
+	);
+	INSERT INTO audit_log VALUES(1, '2024-05-01 10:00:00+00:00', 'key:ops', 'link.delete', 'abc', '', 'ok');
+	INSERT INTO audit_log VALUES(2, '2024-05-01 10:05:00+00:00', 'key:ops', 'stats.view', '', '', 'ok');`)
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	repo, err = NewSQLiteLinkRepository(path)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	third, err := repo.AppendAudit(ctx, domain.AuditEntry{Actor: "key:ops", Action: domain.AuditStatsView, Result: domain.AuditResultOK})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), third.Seq)
+
+	entries, err := repo.FindAuditEntries(ctx, domain.AuditFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	prevHash := ""
+	for _, entry := range entries {
+		assert.Equal(t, prevHash, entry.PrevHash, entry.Seq)
+		assert.Equal(t, entry.ChainHash(), entry.Hash, entry.Seq)
+		prevHash = entry.Hash
+	}
+}
+
+func TestSQLiteLinkRepository_StoreDuplicateOriginalURL(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	first := domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"}
+	_, err := repo.Store(ctx, first)
+	require.NoError(t, err)
+
+	existing, err := repo.Store(ctx, domain.URLLink{UserID: "u2", ShortURL: "xyz34", LongURL: "https://example.com"})
+	assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkAlreadyInDB))
+	assert.Equal(t, first.ShortURL, existing.ShortURL)
+}
+
+// Адрес, который уже сокращен другой ссылкой, назначить нельзя
+func TestSQLiteLinkRepository_UpdateDuplicateOriginalURL(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	for _, l := range []domain.URLLink{
+		{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com/a"},
+		{UserID: "u1", ShortURL: "xyz34", LongURL: "https://example.com/b"},
+	} {
+		_, err := repo.Store(ctx, l)
+		require.NoError(t, err)
+	}
+
+	err := repo.Update(ctx, domain.URLLink{ShortURL: "xyz34", LongURL: "https://example.com/a"}, "u1")
+	assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkAlreadyInDB))
+
+	// неудачное изменение не оставляет версии
+	versions, err := repo.FindVersions(ctx, "xyz34")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
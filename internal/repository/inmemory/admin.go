@@ -0,0 +1,220 @@
+package inmemory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Запись журнала блокировок: блокировка домена или ее снятие
+type domainBlockRecord struct {
+	Block   domain.DomainBlock `json:"block"`
+	Removed bool               `json:"removed,omitempty"`
+}
+
+// Заблокированные домены в памяти с журналом на диске
+type InMemoryDomainBlockRepository struct {
+	mu      sync.RWMutex
+	blocks  map[string]domain.DomainBlock
+	journal *journal[domainBlockRecord]
+}
+
+// NewInMemoryDomainBlockRepository загружает блокировки из файла журнала.
+// Пустой путь - данные хранятся только в памяти
+func NewInMemoryDomainBlockRepository(path string) (*InMemoryDomainBlockRepository, error) {
+	repo := &InMemoryDomainBlockRepository{blocks: make(map[string]domain.DomainBlock)}
+	j, err := openJournal(path, repo.apply)
+	if err != nil {
+		return nil, err
+	}
+	repo.journal = j
+	return repo, nil
+}
+
+func (m *InMemoryDomainBlockRepository) apply(rec domainBlockRecord) {
+	if rec.Removed {
+		delete(m.blocks, rec.Block.Domain)
+		return
+	}
+	m.blocks[rec.Block.Domain] = rec.Block
+}
+
+func (m *InMemoryDomainBlockRepository) BlockDomain(ctx context.Context, block domain.DomainBlock) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec := domainBlockRecord{Block: block}
+	if err := m.journal.append(rec); err != nil {
+		return errors.Join(repoerrors.ErrorSaveDomainBlock, err)
+	}
+	m.apply(rec)
+	return nil
+}
+
+func (m *InMemoryDomainBlockRepository) UnblockDomain(ctx context.Context, domainName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	block, ok := m.blocks[domainName]
+	if !ok {
+		return repoerrors.ErrorDomainBlockNotFound
+	}
+	rec := domainBlockRecord{Block: block, Removed: true}
+	if err := m.journal.append(rec); err != nil {
+		return errors.Join(repoerrors.ErrorSaveDomainBlock, err)
+	}
+	m.apply(rec)
+	return nil
+}
+
+func (m *InMemoryDomainBlockRepository) FindBlockedDomains(ctx context.Context) ([]domain.DomainBlock, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []domain.DomainBlock
+	for _, block := range m.blocks {
+		result = append(result, block)
+	}
+	slices.SortFunc(result, func(a, b domain.DomainBlock) int {
+		return cmp.Compare(a.Domain, b.Domain)
+	})
+	return result, nil
+}
+
+func (m *InMemoryDomainBlockRepository) Close() error {
+	return m.journal.Close()
+}
+
+// Журнал аудита в памяти с копией на диске
+type InMemoryAuditRepository struct {
+	mu      sync.RWMutex
+	entries []domain.AuditEntry // по возрастанию номера
+	journal *journal[domain.AuditEntry]
+}
+
+// NewInMemoryAuditRepository загружает журнал аудита из файла.
+// Пустой путь - данные хранятся только в памяти. Записи, сохраненные
+// до появления цепочки хэшей, связываются в цепочку при загрузке
+func NewInMemoryAuditRepository(path string) (*InMemoryAuditRepository, error) {
+	repo := &InMemoryAuditRepository{}
+	legacy := true
+	j, err := openJournal(path, func(entry domain.AuditEntry) {
+		if entry.Hash != "" {
+			legacy = false
+		} else if legacy {
+			entry = entry.Seal(repo.lastHash())
+		}
+		repo.entries = append(repo.entries, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	repo.journal = j
+	return repo, nil
+}
+
+func (m *InMemoryAuditRepository) AppendAudit(ctx context.Context, entry domain.AuditEntry) (domain.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry.Seq = 1
+	if n := len(m.entries); n > 0 {
+		entry.Seq = m.entries[n-1].Seq + 1
+	}
+	entry = entry.Seal(m.lastHash())
+	if err := m.journal.append(entry); err != nil {
+		return domain.AuditEntry{}, errors.Join(repoerrors.ErrorInsertAudit, err)
+	}
+	m.entries = append(m.entries, entry)
+	return entry, nil
+}
+
+func (m *InMemoryAuditRepository) FindAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start, _ := slices.BinarySearchFunc(m.entries, filter.AfterSeq+1, func(e domain.AuditEntry, seq int64) int {
+		return cmp.Compare(e.Seq, seq)
+	})
+	var result []domain.AuditEntry
+	for _, entry := range m.entries[start:] {
+		if len(result) >= filter.Limit {
+			break
+		}
+		if (filter.Actor == "" || entry.Actor == filter.Actor) &&
+			(filter.Action == "" || entry.Action == filter.Action) &&
+			(filter.Target == "" || entry.Target == filter.Target) {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// хэш последней записи, пусто для пустого журнала
+func (m *InMemoryAuditRepository) lastHash() string {
+	if n := len(m.entries); n > 0 {
+		return m.entries[n-1].Hash
+	}
+	return ""
+}
+
+func (m *InMemoryAuditRepository) Close() error {
+	return m.journal.Close()
+}
+
+// FindUserLinks возвращает все ссылки автора, включая ссылки пространств
+func (m *InMemoryLinkRepository) FindUserLinks(ctx context.Context, userID string) ([]domain.URLLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []domain.URLLink
+	for _, link := range m.links {
+		if link.UserID == userID {
+			result = append(result, link)
+		}
+	}
+	return result, nil
+}
+
+func (m *InMemoryLinkRepository) SystemStats(ctx context.Context) (domain.SystemStats, error) {
+	var stats domain.SystemStats
+
+	m.mu.RLock()
+	owners := make(map[string]struct{})
+	for _, link := range m.links {
+		stats.Links++
+		if link.DeletedFlag {
+			stats.DeletedLinks++
+		}
+		owners[link.UserID] = struct{}{}
+	}
+	m.mu.RUnlock()
+	stats.LinkOwners = int64(len(owners))
+
+	m.InMemoryUserRepository.mu.RLock()
+	stats.Users = int64(len(m.InMemoryUserRepository.users))
+	m.InMemoryUserRepository.mu.RUnlock()
+
+	m.InMemoryWorkspaceRepository.mu.RLock()
+	stats.Workspaces = int64(len(m.InMemoryWorkspaceRepository.workspaces))
+	m.InMemoryWorkspaceRepository.mu.RUnlock()
+
+	m.InMemoryAPIKeyRepository.mu.RLock()
+	for _, key := range m.InMemoryAPIKeyRepository.keys {
+		if !key.Revoked() {
+			stats.ActiveAPIKeys++
+		}
+	}
+	m.InMemoryAPIKeyRepository.mu.RUnlock()
+
+	m.InMemoryDomainBlockRepository.mu.RLock()
+	stats.BlockedDomains = int64(len(m.InMemoryDomainBlockRepository.blocks))
+	m.InMemoryDomainBlockRepository.mu.RUnlock()
+
+	return stats, nil
+}
@@ -0,0 +1,91 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Хранилище API-ключей в памяти с журналом на диске
+type InMemoryAPIKeyRepository struct {
+	mu      sync.RWMutex
+	keys    map[string]domain.APIKey // по идентификатору
+	journal *journal[domain.APIKey]
+}
+
+// NewInMemoryAPIKeyRepository загружает ключи из файла журнала.
+// Пустой путь - ключи хранятся только в памяти
+func NewInMemoryAPIKeyRepository(path string) (*InMemoryAPIKeyRepository, error) {
+	repo := &InMemoryAPIKeyRepository{keys: make(map[string]domain.APIKey)}
+	j, err := openJournal(path, func(k domain.APIKey) { repo.keys[k.ID] = k })
+	if err != nil {
+		return nil, err
+	}
+	repo.journal = j
+	return repo, nil
+}
+
+func (m *InMemoryAPIKeyRepository) StoreAPIKey(ctx context.Context, key domain.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.journal.append(key); err != nil {
+		return errors.Join(repoerrors.ErrorInsertAPIKey, err)
+	}
+	m.keys[key.ID] = key
+	return nil
+}
+
+func (m *InMemoryAPIKeyRepository) FindAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return domain.APIKey{}, repoerrors.ErrorAPIKeyNotFound
+}
+
+func (m *InMemoryAPIKeyRepository) FindAPIKeys(ctx context.Context, userID string) ([]domain.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []domain.APIKey
+	for _, k := range m.keys {
+		if k.UserID == userID {
+			result = append(result, k)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result, nil
+}
+
+func (m *InMemoryAPIKeyRepository) RevokeAPIKey(ctx context.Context, userID, id string, revokedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.keys[id]
+	if !ok || k.UserID != userID {
+		return repoerrors.ErrorAPIKeyNotFound
+	}
+	if k.RevokedAt != nil {
+		return nil
+	}
+	k.RevokedAt = &revokedAt
+	if err := m.journal.append(k); err != nil {
+		return errors.Join(repoerrors.ErrorRevokeAPIKey, err)
+	}
+	m.keys[id] = k
+	return nil
+}
+
+func (m *InMemoryAPIKeyRepository) Close() error {
+	return m.journal.Close()
+}
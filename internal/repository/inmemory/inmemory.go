@@ -7,22 +7,53 @@ import (
 	"io"
 	"log"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/physicist2018/url-shortener-go/internal/domain"
 	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
 )
 
 type InMemoryLinkRepository struct {
-	links  map[string]domain.URLLink
-	mu     sync.RWMutex
-	dbfile *os.File
+	// API-ключи, пользователи, пространства, блокировки и аудит хранятся в соседних файлах
+	*InMemoryAPIKeyRepository
+	*InMemoryUserRepository
+	*InMemoryWorkspaceRepository
+	*InMemoryDomainBlockRepository
+	*InMemoryAuditRepository
+
+	links    map[string]domain.URLLink
+	versions map[string][]domain.LinkVersion // прежние состояния ссылок по возрастанию номера
+	mu       sync.RWMutex
+	dbfile   *os.File
+}
+
+// суффиксы соседних с файлом ссылок файлов
+const (
+	apiKeysFileSuffix    = ".apikeys"
+	usersFileSuffix      = ".users"
+	workspacesFileSuffix = ".workspaces"
+	blocklistFileSuffix  = ".blocklist"
+	auditFileSuffix      = ".audit"
+)
+
+// Строка файла ссылок: более поздняя строка заменяет раннюю,
+// а строка с Purged убирает ссылку вместе с историей. Replaced - версия,
+// которую заменило изменение ссылки, пишется в той же строке. Хэш пароля
+// в JSON ссылки не попадает, поэтому пишется отдельным полем
+type linkRecord struct {
+	domain.URLLink
+	Password string              `json:"password_hash,omitempty"`
+	Purged   bool                `json:"purged,omitempty"`
+	Replaced *domain.LinkVersion `json:"replaced,omitempty"`
 }
 
 func NewInMemoryLinkRepository(dbFilePath string) (*InMemoryLinkRepository, error) {
 	repo := &InMemoryLinkRepository{
-		links: make(map[string]domain.URLLink),
+		links:    make(map[string]domain.URLLink),
+		versions: make(map[string][]domain.LinkVersion),
 	}
 
 	// Открываем файл для добавления данных
@@ -40,6 +71,44 @@ func NewInMemoryLinkRepository(dbFilePath string) (*InMemoryLinkRepository, erro
 
 	repo.dbfile.Seek(0, 2) // Go to the end of the file
 
+	apiKeys, err := NewInMemoryAPIKeyRepository(dbFilePath + apiKeysFileSuffix)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	repo.InMemoryAPIKeyRepository = apiKeys
+
+	users, err := NewInMemoryUserRepository(dbFilePath + usersFileSuffix)
+	if err != nil {
+		apiKeys.Close()
+		file.Close()
+		return nil, err
+	}
+	repo.InMemoryUserRepository = users
+
+	workspaces, err := NewInMemoryWorkspaceRepository(dbFilePath + workspacesFileSuffix)
+	if err != nil {
+		users.Close()
+		apiKeys.Close()
+		file.Close()
+		return nil, err
+	}
+	repo.InMemoryWorkspaceRepository = workspaces
+
+	blocks, err := NewInMemoryDomainBlockRepository(dbFilePath + blocklistFileSuffix)
+	if err != nil {
+		repo.Close()
+		return nil, err
+	}
+	repo.InMemoryDomainBlockRepository = blocks
+
+	audit, err := NewInMemoryAuditRepository(dbFilePath + auditFileSuffix)
+	if err != nil {
+		repo.Close()
+		return nil, err
+	}
+	repo.InMemoryAuditRepository = audit
+
 	return repo, nil
 }
 
@@ -50,19 +119,66 @@ func (m *InMemoryLinkRepository) Store(ctx context.Context, urllink domain.URLLi
 	m.links[urllink.ShortURL] = urllink
 
 	// Добавляем данные в файл
-	data, err := json.Marshal(urllink)
-	if err != nil {
-		return domain.URLLink{}, err
-	}
-
-	_, err = m.dbfile.Write(append(data, '\n'))
-	if err != nil {
+	if err := m.writeRecord(linkRecord{URLLink: urllink}); err != nil {
 		return domain.URLLink{}, errors.Join(repoerrors.ErrorInsertShortLink, err)
 	}
 
 	return urllink, nil
 }
 
+// StoreBatch, как и SQL-хранилища, заменяет ссылку без ограничения доступа
+// на уже сокращенный адрес существующей ссылкой без ограничений, а ссылку
+// с паролем или лимитом переходов всегда сохраняет новой
+func (m *InMemoryLinkRepository) StoreBatch(ctx context.Context, links []domain.URLLink, maxActive int) ([]domain.URLLink, error) {
+	if len(links) == 0 {
+		return nil, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := make([]domain.URLLink, len(links))
+	// адреса, сокращенные предыдущими ссылками пакета
+	added := make(map[string]domain.URLLink)
+	var recs []linkRecord
+	for i, urllink := range links {
+		if !urllink.Restricted() {
+			if existing, ok := added[urllink.LongURL]; ok {
+				stored[i] = existing
+				continue
+			}
+			if existing, ok := m.findUnrestricted(urllink.LongURL); ok {
+				stored[i] = existing
+				continue
+			}
+			added[urllink.LongURL] = urllink
+		}
+		stored[i] = urllink
+		recs = append(recs, linkRecord{URLLink: urllink})
+	}
+
+	if maxActive > 0 && len(recs) > 0 && m.countActive(links[0].UserID)+len(recs) > maxActive {
+		return nil, repoerrors.ErrorLinkQuotaExceeded
+	}
+	// пакет дописывается в файл одной записью и попадает в память, только если она удалась
+	if err := m.writeRecords(recs); err != nil {
+		return nil, errors.Join(repoerrors.ErrorInsertShortLink, err)
+	}
+	for _, rec := range recs {
+		m.links[rec.ShortURL] = rec.URLLink
+	}
+	return stored, nil
+}
+
+// ссылка без ограничения доступа на адрес longURL; вызывается под m.mu
+func (m *InMemoryLinkRepository) findUnrestricted(longURL string) (domain.URLLink, bool) {
+	for _, urllink := range m.links {
+		if urllink.LongURL == longURL && !urllink.Restricted() {
+			return urllink, true
+		}
+	}
+	return domain.URLLink{}, false
+}
+
 func (m *InMemoryLinkRepository) Find(ctx context.Context, shortURL string) (domain.URLLink, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -75,6 +191,10 @@ func (m *InMemoryLinkRepository) Find(ctx context.Context, shortURL string) (dom
 	return urllink, nil
 }
 
+func (m *InMemoryLinkRepository) FindConsistent(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	return m.Find(ctx, shortURL)
+}
+
 func (m *InMemoryLinkRepository) FindAll(ctx context.Context, userID string) ([]domain.URLLink, error) {
 
 	m.mu.RLock()
@@ -83,7 +203,7 @@ func (m *InMemoryLinkRepository) FindAll(ctx context.Context, userID string) ([]
 	var result []domain.URLLink
 	for _, link := range m.links {
 		log.Println(link.UserID)
-		if link.UserID == userID {
+		if link.UserID == userID && link.WorkspaceID == "" {
 			result = append(result, link)
 		}
 	}
@@ -91,14 +211,38 @@ func (m *InMemoryLinkRepository) FindAll(ctx context.Context, userID string) ([]
 	return result, nil
 }
 
+func (m *InMemoryLinkRepository) FindAllInWorkspace(ctx context.Context, workspaceID string) ([]domain.URLLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []domain.URLLink
+	for _, link := range m.links {
+		if link.WorkspaceID == workspaceID {
+			result = append(result, link)
+		}
+	}
+	return result, nil
+}
+
 func (m *InMemoryLinkRepository) MarkDeletedBatch(ctx context.Context, links []domain.URLLink) error {
-	// пробегаемся по всем ссылкам в репе и метим на удаление те, где совпадает пользователь и короткая ссылка
+	// пробегаемся по всем ссылкам в репе и метим на удаление те, где совпадает владелец и короткая ссылка
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	for _, link := range links {
-		if urllink, ok := m.links[link.ShortURL]; ok && urllink.UserID == link.UserID {
+		urllink, ok := m.links[link.ShortURL]
+		if !ok {
+			continue
+		}
+		owned := urllink.WorkspaceID == "" && urllink.UserID == link.UserID
+		if link.WorkspaceID != "" {
+			owned = urllink.WorkspaceID == link.WorkspaceID
+		}
+		if owned {
 			urllink.DeletedFlag = true
+			if err := m.writeRecord(linkRecord{URLLink: urllink}); err != nil {
+				return errors.Join(repoerrors.ErrorMarkDeletedBatch, err)
+			}
 			m.links[link.ShortURL] = urllink
 		}
 	}
@@ -106,6 +250,178 @@ func (m *InMemoryLinkRepository) MarkDeletedBatch(ctx context.Context, links []d
 	return nil
 }
 
+func (m *InMemoryLinkRepository) ReassignLinks(ctx context.Context, fromUserID, toUserID string, maxActive int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if maxActive > 0 {
+		moved := m.countActive(fromUserID)
+		if moved > 0 && m.countActive(toUserID)+moved > maxActive {
+			return 0, repoerrors.ErrorLinkQuotaExceeded
+		}
+	}
+
+	var n int64
+	for shortURL, urllink := range m.links {
+		if urllink.UserID != fromUserID {
+			continue
+		}
+		urllink.UserID = toUserID
+
+		// при загрузке более поздняя строка файла заменяет раннюю
+		if err := m.writeRecord(linkRecord{URLLink: urllink}); err != nil {
+			return n, errors.Join(repoerrors.ErrorReassignLinks, err)
+		}
+		m.links[shortURL] = urllink
+		n++
+	}
+	return n, nil
+}
+
+func (m *InMemoryLinkRepository) MoveLink(ctx context.Context, from domain.URLLink, userID, workspaceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shortURL := from.ShortURL
+	urllink, ok := m.links[shortURL]
+	if !ok || urllink.DeletedFlag || urllink.UserID != from.UserID || urllink.WorkspaceID != from.WorkspaceID {
+		return repoerrors.ErrorShortLinkNotFound
+	}
+	urllink.UserID = userID
+	urllink.WorkspaceID = workspaceID
+
+	if err := m.writeRecord(linkRecord{URLLink: urllink}); err != nil {
+		return errors.Join(repoerrors.ErrorMoveLink, err)
+	}
+	m.links[shortURL] = urllink
+	return nil
+}
+
+func (m *InMemoryLinkRepository) SetDeleted(ctx context.Context, shortURL string, deleted bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	urllink, ok := m.links[shortURL]
+	if !ok {
+		return repoerrors.ErrorShortLinkNotFound
+	}
+	urllink.DeletedFlag = deleted
+	if err := m.writeRecord(linkRecord{URLLink: urllink}); err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	m.links[shortURL] = urllink
+	return nil
+}
+
+func (m *InMemoryLinkRepository) PurgeLink(ctx context.Context, shortURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	urllink, ok := m.links[shortURL]
+	if !ok {
+		return repoerrors.ErrorShortLinkNotFound
+	}
+	if err := m.writeRecord(linkRecord{URLLink: urllink, Purged: true}); err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	delete(m.links, shortURL)
+	delete(m.versions, shortURL)
+	return nil
+}
+
+func (m *InMemoryLinkRepository) Update(ctx context.Context, link domain.URLLink, editedBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	urllink, ok := m.links[link.ShortURL]
+	if !ok {
+		return repoerrors.ErrorShortLinkNotFound
+	}
+	if urllink.LongURL == link.LongURL && urllink.Quarantined == link.Quarantined && urllink.PasswordHash == link.PasswordHash {
+		return nil
+	}
+	replaced := domain.LinkVersion{
+		ShortURL:   link.ShortURL,
+		Version:    len(m.versions[link.ShortURL]) + 1,
+		LongURL:    urllink.LongURL,
+		ReplacedBy: editedBy,
+		ReplacedAt: time.Now().UTC(),
+	}
+	urllink.LongURL = link.LongURL
+	urllink.Quarantined = link.Quarantined
+	urllink.PasswordHash = link.PasswordHash
+	if err := m.writeRecord(linkRecord{URLLink: urllink, Replaced: &replaced}); err != nil {
+		return errors.Join(repoerrors.ErrorUpdateShortLink, err)
+	}
+	m.links[link.ShortURL] = urllink
+	m.versions[link.ShortURL] = append(m.versions[link.ShortURL], replaced)
+	return nil
+}
+
+func (m *InMemoryLinkRepository) FindVersions(ctx context.Context, shortURL string) ([]domain.LinkVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.versions[shortURL]), nil
+}
+
+func (m *InMemoryLinkRepository) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	urllink, ok := m.links[shortURL]
+	if !ok {
+		return 0, repoerrors.ErrorShortLinkNotFound
+	}
+	if urllink.ClicksRemaining <= 0 {
+		return 0, repoerrors.ErrorClicksExhausted
+	}
+	urllink.ClicksRemaining--
+	if err := m.writeRecord(linkRecord{URLLink: urllink}); err != nil {
+		return 0, errors.Join(repoerrors.ErrorConsumeClick, err)
+	}
+	m.links[shortURL] = urllink
+	return urllink.ClicksRemaining, nil
+}
+
+func (m *InMemoryLinkRepository) CountActiveLinks(ctx context.Context, userID string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.countActive(userID), nil
+}
+
+// число неудаленных ссылок пользователя, вызывается под мьютексом
+func (m *InMemoryLinkRepository) countActive(userID string) int {
+	count := 0
+	for _, link := range m.links {
+		if link.UserID == userID && !link.DeletedFlag {
+			count++
+		}
+	}
+	return count
+}
+
+// дописывает строку в файл ссылок, вызывается под мьютексом
+func (m *InMemoryLinkRepository) writeRecord(rec linkRecord) error {
+	return m.writeRecords([]linkRecord{rec})
+}
+
+// дописывает строки одной записью в файл, вызывается под мьютексом
+func (m *InMemoryLinkRepository) writeRecords(recs []linkRecord) error {
+	var data []byte
+	for _, rec := range recs {
+		rec.Password = rec.PasswordHash
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	_, err := m.dbfile.Write(data)
+	return err
+}
+
 func (m *InMemoryLinkRepository) Ping(ctx context.Context) error {
 	return nil
 }
@@ -127,19 +443,44 @@ func (m *InMemoryLinkRepository) load() error {
 			continue
 		}
 
-		var urllink domain.URLLink
-		if err := json.Unmarshal([]byte(line), &urllink); err != nil {
+		var rec linkRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
 			return err
 		}
-		m.links[urllink.ShortURL] = urllink
+		if rec.Purged {
+			delete(m.links, rec.ShortURL)
+			delete(m.versions, rec.ShortURL)
+			continue
+		}
+		if rec.Replaced != nil {
+			m.versions[rec.ShortURL] = append(m.versions[rec.ShortURL], *rec.Replaced)
+		}
+		rec.PasswordHash = rec.Password
+		m.links[rec.ShortURL] = rec.URLLink
 	}
 	return nil
 }
 
 func (m *InMemoryLinkRepository) Close() error {
+	var errs []error
 	// Вдруг файл не был открыт
 	if m.dbfile != nil {
-		return m.dbfile.Close()
+		errs = append(errs, m.dbfile.Close())
 	}
-	return nil
+	if m.InMemoryAPIKeyRepository != nil {
+		errs = append(errs, m.InMemoryAPIKeyRepository.Close())
+	}
+	if m.InMemoryUserRepository != nil {
+		errs = append(errs, m.InMemoryUserRepository.Close())
+	}
+	if m.InMemoryWorkspaceRepository != nil {
+		errs = append(errs, m.InMemoryWorkspaceRepository.Close())
+	}
+	if m.InMemoryDomainBlockRepository != nil {
+		errs = append(errs, m.InMemoryDomainBlockRepository.Close())
+	}
+	if m.InMemoryAuditRepository != nil {
+		errs = append(errs, m.InMemoryAuditRepository.Close())
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,168 @@
+package inmemory
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repotest"
+)
+
+func TestInMemoryLinkRepository_Conformance(t *testing.T) {
+	repotest.RunConformance(t, func(t *testing.T) domain.URLLinkRepo {
+		repo, err := NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestInMemoryAPIKeyRepository_Conformance(t *testing.T) {
+	repotest.RunAPIKeyConformance(t, func(t *testing.T) domain.APIKeyRepo {
+		repo, err := NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestInMemoryUserRepository_Conformance(t *testing.T) {
+	repotest.RunUserConformance(t, func(t *testing.T) domain.UserRepo {
+		repo, err := NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestInMemoryWorkspaceRepository_Conformance(t *testing.T) {
+	repotest.RunWorkspaceConformance(t, func(t *testing.T) domain.WorkspaceRepo {
+		repo, err := NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestInMemoryLinkRepository_AdminConformance(t *testing.T) {
+	repotest.RunAdminConformance(t, func(t *testing.T) repotest.AdminStore {
+		repo, err := NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+// Пометки удаления, безвозвратное удаление и журнал аудита переживают перезапуск
+func TestInMemoryLinkRepository_AdminPersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dbase.json")
+
+	repo, err := NewInMemoryLinkRepository(path)
+	require.NoError(t, err)
+	for _, l := range []domain.URLLink{
+		{UserID: "u1", ShortURL: "del", LongURL: "https://example.com/del"},
+		{UserID: "u1", ShortURL: "gone", LongURL: "https://example.com/gone"},
+	} {
+		_, err := repo.Store(ctx, l)
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.MarkDeletedBatch(ctx, []domain.URLLink{{UserID: "u1", ShortURL: "del"}}))
+	require.NoError(t, repo.PurgeLink(ctx, "gone"))
+	entry, err := repo.AppendAudit(ctx, domain.AuditEntry{Actor: "key:ops", Action: domain.AuditLinkPurge, Target: "gone", Result: domain.AuditResultOK})
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewInMemoryLinkRepository(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	found, err := reopened.Find(ctx, "del")
+	require.NoError(t, err)
+	assert.True(t, found.DeletedFlag)
+	_, err = reopened.Find(ctx, "gone")
+	assert.Error(t, err)
+
+	next, err := reopened.AppendAudit(ctx, domain.AuditEntry{Actor: "key:ops", Action: domain.AuditStatsView, Result: domain.AuditResultOK})
+	require.NoError(t, err)
+	assert.Equal(t, entry.Seq+1, next.Seq)
+	assert.Equal(t, entry.Hash, next.PrevHash)
+}
+
+func TestInMemoryLinkRepository_VersionsPersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dbase.json")
+
+	repo, err := NewInMemoryLinkRepository(path)
+	require.NoError(t, err)
+	_, err = repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com/typo"})
+	require.NoError(t, err)
+	require.NoError(t, repo.Update(ctx, domain.URLLink{ShortURL: "abc12", LongURL: "https://example.com/fixed"}, "u1"))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewInMemoryLinkRepository(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	found, err := reopened.Find(ctx, "abc12")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/fixed", found.LongURL)
+
+	versions, err := reopened.FindVersions(ctx, "abc12")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, "https://example.com/typo", versions[0].LongURL)
+
+	require.NoError(t, reopened.Update(ctx, domain.URLLink{ShortURL: "abc12", LongURL: "https://example.com/final"}, "u1"))
+	versions, err = reopened.FindVersions(ctx, "abc12")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[1].Version)
+}
+
+func TestInMemoryLinkRepository_PasswordPersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dbase.json")
+	hash := "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$a2V5"
+
+	repo, err := NewInMemoryLinkRepository(path)
+	require.NoError(t, err)
+	_, err = repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com/preview", PasswordHash: hash})
+	require.NoError(t, err)
+	require.NoError(t, repo.MoveLink(ctx, domain.URLLink{ShortURL: "abc12", UserID: "u1"}, "u1", "ws1"))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewInMemoryLinkRepository(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	found, err := reopened.Find(ctx, "abc12")
+	require.NoError(t, err)
+	assert.Equal(t, hash, found.PasswordHash)
+	assert.Equal(t, "ws1", found.WorkspaceID)
+}
+
+func TestInMemoryAPIKeyRepository_Persistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dbase.json")
+
+	repo, err := NewInMemoryLinkRepository(path)
+	require.NoError(t, err)
+	key := domain.APIKey{ID: "k1", UserID: "u1", Hash: "h1", Scopes: []string{domain.ScopeLinksRead}, CreatedAt: time.Now().UTC()}
+	require.NoError(t, repo.StoreAPIKey(ctx, key))
+	require.NoError(t, repo.RevokeAPIKey(ctx, "u1", "k1", time.Now()))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewInMemoryLinkRepository(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	found, err := reopened.FindAPIKeyByHash(ctx, "h1")
+	require.NoError(t, err)
+	assert.True(t, found.Revoked())
+}
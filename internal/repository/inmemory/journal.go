@@ -0,0 +1,70 @@
+package inmemory
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Журнал записей в файле JSON Lines. Записи только дописываются,
+// при загрузке более поздняя запись заменяет более раннюю
+type journal[T any] struct {
+	mu   sync.Mutex
+	file *os.File // nil - без сохранения на диск
+}
+
+// openJournal открывает журнал и передает каждую сохраненную запись в apply.
+// Пустой путь - журнал без сохранения на диск
+func openJournal[T any](path string, apply func(T)) (*journal[T], error) {
+	if path == "" {
+		return &journal[T]{}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var v T
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			file.Close()
+			return nil, err
+		}
+		apply(v)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &journal[T]{file: file}, nil
+}
+
+func (j *journal[T]) append(v T) error {
+	if j.file == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(append(data, '\n'))
+	return err
+}
+
+func (j *journal[T]) Close() error {
+	if j.file == nil {
+		return nil
+	}
+	return j.file.Close()
+}
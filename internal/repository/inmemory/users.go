@@ -0,0 +1,93 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Хранилище учетных записей в памяти с журналом на диске
+type InMemoryUserRepository struct {
+	mu      sync.RWMutex
+	users   map[string]domain.User // по идентификатору
+	logins  map[string]string      // логин -> идентификатор
+	journal *journal[domain.User]
+}
+
+// NewInMemoryUserRepository загружает пользователей из файла журнала.
+// Пустой путь - пользователи хранятся только в памяти
+func NewInMemoryUserRepository(path string) (*InMemoryUserRepository, error) {
+	repo := &InMemoryUserRepository{
+		users:  make(map[string]domain.User),
+		logins: make(map[string]string),
+	}
+	j, err := openJournal(path, func(u domain.User) {
+		repo.users[u.ID] = u
+		repo.logins[u.Login] = u.ID
+	})
+	if err != nil {
+		return nil, err
+	}
+	repo.journal = j
+	return repo, nil
+}
+
+func (m *InMemoryUserRepository) StoreUser(ctx context.Context, user domain.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.logins[user.Login]; exists {
+		return repoerrors.ErrorUserAlreadyExists
+	}
+	if err := m.journal.append(user); err != nil {
+		return errors.Join(repoerrors.ErrorInsertUser, err)
+	}
+	m.users[user.ID] = user
+	m.logins[user.Login] = user.ID
+	return nil
+}
+
+func (m *InMemoryUserRepository) UpdatePasswordHash(ctx context.Context, userID, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return repoerrors.ErrorUserNotFound
+	}
+	user.PasswordHash = passwordHash
+	if err := m.journal.append(user); err != nil {
+		return errors.Join(repoerrors.ErrorUpdateUser, err)
+	}
+	m.users[userID] = user
+	return nil
+}
+
+func (m *InMemoryUserRepository) FindUserByLogin(ctx context.Context, login string) (domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.logins[login]
+	if !ok {
+		return domain.User{}, repoerrors.ErrorUserNotFound
+	}
+	return m.users[id], nil
+}
+
+func (m *InMemoryUserRepository) FindUserByID(ctx context.Context, id string) (domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return domain.User{}, repoerrors.ErrorUserNotFound
+	}
+	return user, nil
+}
+
+func (m *InMemoryUserRepository) Close() error {
+	return m.journal.Close()
+}
@@ -0,0 +1,171 @@
+package inmemory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Запись журнала рабочих пространств: новое пространство,
+// сохранение участника или его удаление
+type workspaceRecord struct {
+	Workspace *domain.Workspace       `json:"workspace,omitempty"`
+	Member    *domain.WorkspaceMember `json:"member,omitempty"`
+	Removed   bool                    `json:"removed,omitempty"`
+}
+
+// Хранилище рабочих пространств в памяти с журналом на диске
+type InMemoryWorkspaceRepository struct {
+	mu         sync.RWMutex
+	workspaces map[string]domain.Workspace
+	members    map[string]map[string]domain.WorkspaceMember // пространство -> пользователь -> участник
+	journal    *journal[workspaceRecord]
+}
+
+// NewInMemoryWorkspaceRepository загружает рабочие пространства из файла журнала.
+// Пустой путь - данные хранятся только в памяти
+func NewInMemoryWorkspaceRepository(path string) (*InMemoryWorkspaceRepository, error) {
+	repo := &InMemoryWorkspaceRepository{
+		workspaces: make(map[string]domain.Workspace),
+		members:    make(map[string]map[string]domain.WorkspaceMember),
+	}
+	j, err := openJournal(path, repo.apply)
+	if err != nil {
+		return nil, err
+	}
+	repo.journal = j
+	return repo, nil
+}
+
+// вызывается под мьютексом или при загрузке
+func (m *InMemoryWorkspaceRepository) apply(rec workspaceRecord) {
+	if rec.Workspace != nil {
+		m.workspaces[rec.Workspace.ID] = *rec.Workspace
+	}
+	if rec.Member == nil {
+		return
+	}
+	member := *rec.Member
+	if rec.Removed {
+		delete(m.members[member.WorkspaceID], member.UserID)
+		return
+	}
+	if m.members[member.WorkspaceID] == nil {
+		m.members[member.WorkspaceID] = make(map[string]domain.WorkspaceMember)
+	}
+	// при смене роли время добавления сохраняется
+	if existing, ok := m.members[member.WorkspaceID][member.UserID]; ok {
+		member.AddedAt = existing.AddedAt
+	}
+	m.members[member.WorkspaceID][member.UserID] = member
+}
+
+func (m *InMemoryWorkspaceRepository) StoreWorkspace(ctx context.Context, workspace domain.Workspace, owner domain.WorkspaceMember) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.workspaces[workspace.ID]; exists {
+		return repoerrors.ErrorInsertWorkspace
+	}
+	rec := workspaceRecord{Workspace: &workspace, Member: &owner}
+	if err := m.journal.append(rec); err != nil {
+		return errors.Join(repoerrors.ErrorInsertWorkspace, err)
+	}
+	m.apply(rec)
+	return nil
+}
+
+func (m *InMemoryWorkspaceRepository) FindWorkspace(ctx context.Context, id string) (domain.Workspace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	workspace, ok := m.workspaces[id]
+	if !ok {
+		return domain.Workspace{}, repoerrors.ErrorWorkspaceNotFound
+	}
+	return workspace, nil
+}
+
+func (m *InMemoryWorkspaceRepository) FindUserWorkspaces(ctx context.Context, userID string) ([]domain.WorkspaceMembership, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []domain.WorkspaceMembership
+	for id, members := range m.members {
+		if member, ok := members[userID]; ok {
+			result = append(result, domain.WorkspaceMembership{Workspace: m.workspaces[id], Role: member.Role})
+		}
+	}
+	slices.SortFunc(result, func(a, b domain.WorkspaceMembership) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return result, nil
+}
+
+func (m *InMemoryWorkspaceRepository) FindMember(ctx context.Context, workspaceID, userID string) (domain.WorkspaceMember, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	member, ok := m.members[workspaceID][userID]
+	if !ok {
+		return domain.WorkspaceMember{}, repoerrors.ErrorMemberNotFound
+	}
+	return member, nil
+}
+
+func (m *InMemoryWorkspaceRepository) FindMembers(ctx context.Context, workspaceID string) ([]domain.WorkspaceMember, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []domain.WorkspaceMember
+	for _, member := range m.members[workspaceID] {
+		result = append(result, member)
+	}
+	slices.SortFunc(result, func(a, b domain.WorkspaceMember) int {
+		if c := a.AddedAt.Compare(b.AddedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.UserID, b.UserID)
+	})
+	return result, nil
+}
+
+func (m *InMemoryWorkspaceRepository) SaveMember(ctx context.Context, member domain.WorkspaceMember) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec := workspaceRecord{Member: &member}
+	if err := m.journal.append(rec); err != nil {
+		return errors.Join(repoerrors.ErrorSaveMember, err)
+	}
+	m.apply(rec)
+	return nil
+}
+
+func (m *InMemoryWorkspaceRepository) RemoveMember(ctx context.Context, workspaceID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	member, ok := m.members[workspaceID][userID]
+	if !ok {
+		return repoerrors.ErrorMemberNotFound
+	}
+	rec := workspaceRecord{Member: &member, Removed: true}
+	if err := m.journal.append(rec); err != nil {
+		return errors.Join(repoerrors.ErrorSaveMember, err)
+	}
+	m.apply(rec)
+	return nil
+}
+
+func (m *InMemoryWorkspaceRepository) Close() error {
+	return m.journal.Close()
+}
@@ -0,0 +1,327 @@
+// Пакет rediscache содержит декоратор domain.URLLinkRepo, который держит
+// общий для всех реплик кэш коротких ссылок в Redis-совместимом хранилище
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/pkg/resp"
+)
+
+const (
+	defaultKeyPrefix = "shortener:link:"
+	// значение-маркер для закэшированного промаха
+	notFoundMarker = "-"
+	// сколько неудавшихся удалений помнить до перехода в режим обхода кэша
+	maxPendingDeletes = 1024
+)
+
+// MaxTTL ограничивает время жизни записей: если удалить ключ из Redis не
+// удалось, устаревшая запись живет не дольше этого срока
+const MaxTTL = 10 * time.Minute
+
+type Options struct {
+	KeyPrefix   string
+	TTL         time.Duration // 0 или больше MaxTTL - MaxTTL
+	NegativeTTL time.Duration // 0 - промахи не кэшируются, больше MaxTTL - MaxTTL
+}
+
+// Запись кэша. Хэш пароля в общий кэш не попадает: для защищенной ссылки
+// хранится только признак, а пароль проверяется по ссылке с основного узла
+type cachedLink struct {
+	domain.URLLink
+	HasPassword bool `json:"has_password,omitempty"`
+}
+
+// Значения счетчиков кэша
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	Errors uint64 // ошибки обращения к кэшу, при которых запрос ушел в хранилище
+}
+
+// Ошибки кэша не ломают основной сценарий: запрос уходит в хранилище
+type RedisCachedLinkRepository struct {
+	repo   domain.URLLinkRepo
+	client *resp.Client
+	opts   Options
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+
+	// Ключи, удалить которые не удалось, со сроком, после которого запись
+	// истечет сама. Пока ключ здесь, Find читает его из хранилища
+	mu          sync.Mutex
+	pending     map[string]time.Time
+	bypassUntil time.Time // при переполнении pending кэш обходится целиком
+}
+
+func NewRedisCachedLinkRepository(repo domain.URLLinkRepo, client *resp.Client, opts Options) *RedisCachedLinkRepository {
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = defaultKeyPrefix
+	}
+	if opts.TTL <= 0 || opts.TTL > MaxTTL {
+		opts.TTL = MaxTTL
+	}
+	if opts.NegativeTTL > MaxTTL {
+		opts.NegativeTTL = MaxTTL
+	}
+	return &RedisCachedLinkRepository{
+		repo:    repo,
+		client:  client,
+		opts:    opts,
+		pending: make(map[string]time.Time),
+	}
+}
+
+func (r *RedisCachedLinkRepository) Store(ctx context.Context, urllink domain.URLLink) (domain.URLLink, error) {
+	link, err := r.repo.Store(ctx, urllink)
+	// код мог быть закэширован как отсутствующий
+	r.invalidate(ctx, urllink.ShortURL)
+	return link, err
+}
+
+func (r *RedisCachedLinkRepository) StoreBatch(ctx context.Context, links []domain.URLLink, maxActive int) ([]domain.URLLink, error) {
+	stored, err := r.repo.StoreBatch(ctx, links, maxActive)
+	for _, link := range links {
+		r.invalidate(ctx, link.ShortURL)
+	}
+	return stored, err
+}
+
+// FindConsistent идет в хранилище мимо общего кэша
+func (r *RedisCachedLinkRepository) FindConsistent(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	return r.repo.FindConsistent(ctx, shortURL)
+}
+
+func (r *RedisCachedLinkRepository) Find(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	// запись могла остаться после неудачной инвалидации
+	if r.stale(ctx, shortURL) {
+		r.misses.Add(1)
+		return r.repo.Find(ctx, shortURL)
+	}
+
+	val, err := r.client.Get(ctx, r.key(shortURL))
+	switch {
+	case err == nil:
+		if val == notFoundMarker {
+			r.hits.Add(1)
+			return domain.URLLink{}, repoerrors.ErrorShortLinkNotFound
+		}
+		var cached cachedLink
+		if err := json.Unmarshal([]byte(val), &cached); err == nil {
+			r.hits.Add(1)
+			if cached.HasPassword {
+				return r.repo.FindConsistent(ctx, shortURL)
+			}
+			return cached.URLLink, nil
+		}
+		r.errors.Add(1)
+	case errors.Is(err, resp.ErrNil):
+		r.misses.Add(1)
+	default:
+		r.errors.Add(1)
+	}
+
+	link, err := r.repo.Find(ctx, shortURL)
+	switch {
+	case err == nil:
+		if data, err := json.Marshal(cachedLink{URLLink: link, HasPassword: link.PasswordHash != ""}); err == nil {
+			r.set(ctx, shortURL, string(data), r.opts.TTL)
+		}
+	case errors.Is(err, repoerrors.ErrorShortLinkNotFound) && r.opts.NegativeTTL > 0:
+		r.set(ctx, shortURL, notFoundMarker, r.opts.NegativeTTL)
+	}
+	return link, err
+}
+
+func (r *RedisCachedLinkRepository) FindAll(ctx context.Context, userID string) ([]domain.URLLink, error) {
+	return r.repo.FindAll(ctx, userID)
+}
+
+func (r *RedisCachedLinkRepository) FindAllInWorkspace(ctx context.Context, workspaceID string) ([]domain.URLLink, error) {
+	return r.repo.FindAllInWorkspace(ctx, workspaceID)
+}
+
+func (r *RedisCachedLinkRepository) MarkDeletedBatch(ctx context.Context, links []domain.URLLink) error {
+	err := r.repo.MarkDeletedBatch(ctx, links)
+
+	keys := make([]string, len(links))
+	for i, l := range links {
+		keys[i] = r.key(l.ShortURL)
+	}
+	r.del(ctx, keys...)
+	return err
+}
+
+// Ключи в кэше перечислить нельзя, поэтому коды ссылок пользователя
+// берутся из хранилища до переноса
+func (r *RedisCachedLinkRepository) ReassignLinks(ctx context.Context, fromUserID, toUserID string, maxActive int) (int64, error) {
+	links, findErr := r.authoredLinks(ctx, fromUserID)
+	n, err := r.repo.ReassignLinks(ctx, fromUserID, toUserID, maxActive)
+	if findErr != nil {
+		r.errors.Add(1)
+		return n, err
+	}
+
+	keys := make([]string, len(links))
+	for i, l := range links {
+		keys[i] = r.key(l.ShortURL)
+	}
+	r.del(ctx, keys...)
+	return n, err
+}
+
+// FindAll не возвращает ссылки пространств, а ReassignLinks переносит и их,
+// поэтому при возможности ссылки автора берутся через domain.AdminRepo
+func (r *RedisCachedLinkRepository) authoredLinks(ctx context.Context, userID string) ([]domain.URLLink, error) {
+	if admin, ok := r.repo.(domain.AdminRepo); ok {
+		return admin.FindUserLinks(ctx, userID)
+	}
+	return r.repo.FindAll(ctx, userID)
+}
+
+func (r *RedisCachedLinkRepository) MoveLink(ctx context.Context, from domain.URLLink, userID, workspaceID string) error {
+	err := r.repo.MoveLink(ctx, from, userID, workspaceID)
+	r.invalidate(ctx, from.ShortURL)
+	return err
+}
+
+func (r *RedisCachedLinkRepository) SetDeleted(ctx context.Context, shortURL string, deleted bool) error {
+	err := r.repo.SetDeleted(ctx, shortURL, deleted)
+	r.invalidate(ctx, shortURL)
+	return err
+}
+
+func (r *RedisCachedLinkRepository) PurgeLink(ctx context.Context, shortURL string) error {
+	err := r.repo.PurgeLink(ctx, shortURL)
+	r.invalidate(ctx, shortURL)
+	return err
+}
+
+func (r *RedisCachedLinkRepository) Update(ctx context.Context, link domain.URLLink, editedBy string) error {
+	err := r.repo.Update(ctx, link, editedBy)
+	r.invalidate(ctx, link.ShortURL)
+	return err
+}
+
+func (r *RedisCachedLinkRepository) FindVersions(ctx context.Context, shortURL string) ([]domain.LinkVersion, error) {
+	return r.repo.FindVersions(ctx, shortURL)
+}
+
+func (r *RedisCachedLinkRepository) CountActiveLinks(ctx context.Context, userID string) (int, error) {
+	return r.repo.CountActiveLinks(ctx, userID)
+}
+
+// ConsumeClick всегда идет в хранилище, закэшированный счетчик только сбрасывается
+func (r *RedisCachedLinkRepository) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	remaining, err := r.repo.ConsumeClick(ctx, shortURL)
+	r.invalidate(ctx, shortURL)
+	return remaining, err
+}
+
+func (r *RedisCachedLinkRepository) Ping(ctx context.Context) error {
+	return r.repo.Ping(ctx)
+}
+
+func (r *RedisCachedLinkRepository) Close() error {
+	return errors.Join(r.client.Close(), r.repo.Close())
+}
+
+// Stats возвращает счетчики попаданий, промахов и ошибок кэша
+func (r *RedisCachedLinkRepository) Stats() Stats {
+	return Stats{
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+		Errors: r.errors.Load(),
+	}
+}
+
+func (r *RedisCachedLinkRepository) key(shortURL string) string {
+	return r.opts.KeyPrefix + shortURL
+}
+
+func (r *RedisCachedLinkRepository) set(ctx context.Context, shortURL, value string, ttl time.Duration) {
+	if err := r.client.Set(ctx, r.key(shortURL), value, ttl); err != nil {
+		r.errors.Add(1)
+	}
+}
+
+func (r *RedisCachedLinkRepository) invalidate(ctx context.Context, shortURL string) {
+	r.del(ctx, r.key(shortURL))
+}
+
+// del удаляет ключи вместе с теми, что не удалось удалить раньше.
+// При ошибке ключи запоминаются до следующей попытки или до истечения TTL
+func (r *RedisCachedLinkRepository) del(ctx context.Context, keys ...string) {
+	now := time.Now()
+	r.mu.Lock()
+	retry := make(map[string]time.Time, len(r.pending))
+	for k, until := range r.pending {
+		if now.After(until) {
+			delete(r.pending, k)
+			continue
+		}
+		retry[k] = until
+	}
+	r.mu.Unlock()
+
+	all := keys
+	for k := range retry {
+		all = append(all, k)
+	}
+	if len(all) == 0 {
+		return
+	}
+
+	if _, err := r.client.Del(ctx, all...); err != nil {
+		r.errors.Add(1)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		until := now.Add(max(r.opts.TTL, r.opts.NegativeTTL))
+		if len(r.pending)+len(keys) > maxPendingDeletes {
+			clear(r.pending)
+			r.bypassUntil = until
+			return
+		}
+		for _, k := range keys {
+			r.pending[k] = until
+		}
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, until := range retry {
+		// ключ мог снова не удалиться в параллельном вызове
+		if r.pending[k].Equal(until) {
+			delete(r.pending, k)
+		}
+	}
+}
+
+// stale сообщает, что запись ключа в Redis может быть устаревшей
+func (r *RedisCachedLinkRepository) stale(ctx context.Context, shortURL string) bool {
+	r.mu.Lock()
+	retry := len(r.pending) > 0
+	r.mu.Unlock()
+	if retry {
+		r.del(ctx)
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Before(r.bypassUntil) {
+		return true
+	}
+	until, ok := r.pending[r.key(shortURL)]
+	return ok && now.Before(until)
+}
@@ -0,0 +1,221 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/inmemory"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repotest"
+	"github.com/physicist2018/url-shortener-go/pkg/resp"
+	"github.com/physicist2018/url-shortener-go/pkg/resp/resptest"
+)
+
+// Репозиторий, считающий обращения к Find
+type countingRepo struct {
+	domain.URLLinkRepo
+	finds int
+}
+
+func (c *countingRepo) Find(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	c.finds++
+	return c.URLLinkRepo.Find(ctx, shortURL)
+}
+
+func newCountingRepo(t *testing.T) *countingRepo {
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	return &countingRepo{URLLinkRepo: repo}
+}
+
+func newServer(t *testing.T) *resptest.Server {
+	srv := resptest.NewServer()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newCachedRepo(t *testing.T, srv *resptest.Server, backend domain.URLLinkRepo, opts Options) *RedisCachedLinkRepository {
+	client := resp.NewClient(resp.Options{Addr: srv.Addr})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisCachedLinkRepository(backend, client, opts)
+}
+
+func TestRedisCachedLinkRepository_Conformance(t *testing.T) {
+	repotest.RunConformance(t, func(t *testing.T) domain.URLLinkRepo {
+		return newCachedRepo(t, newServer(t), newCountingRepo(t), Options{TTL: time.Minute, NegativeTTL: time.Minute})
+	})
+}
+
+func TestRedisCachedLinkRepository_HitMissAndTTL(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	backend := newCountingRepo(t)
+	repo := newCachedRepo(t, srv, backend, Options{TTL: time.Minute, NegativeTTL: time.Second})
+
+	_, err := repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		link, err := repo.Find(ctx, "abc12")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", link.LongURL)
+	}
+	assert.Equal(t, 1, backend.finds)
+
+	_, err = repo.Find(ctx, "nope1")
+	assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+	_, err = repo.Find(ctx, "nope1")
+	assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+	assert.Equal(t, 2, backend.finds)
+	assert.Equal(t, Stats{Hits: 3, Misses: 2}, repo.Stats())
+
+	srv.FastForward(2 * time.Minute)
+	repo.Find(ctx, "abc12")
+	assert.Equal(t, 3, backend.finds)
+}
+
+func TestRedisCachedLinkRepository_DeleteVisibleToOtherReplicas(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	backend := newCountingRepo(t)
+	replicaA := newCachedRepo(t, srv, backend, Options{TTL: time.Hour})
+	replicaB := newCachedRepo(t, srv, backend, Options{TTL: time.Hour})
+
+	link := domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"}
+	_, err := replicaA.Store(ctx, link)
+	require.NoError(t, err)
+
+	found, err := replicaB.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+	assert.False(t, found.DeletedFlag)
+
+	require.NoError(t, replicaA.MarkDeletedBatch(ctx, []domain.URLLink{link}))
+
+	found, err = replicaB.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+	assert.True(t, found.DeletedFlag)
+}
+
+func TestRedisCachedLinkRepository_RetryFailedInvalidation(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	backend := newCountingRepo(t)
+	replicaA := newCachedRepo(t, srv, backend, Options{TTL: time.Hour})
+	replicaB := newCachedRepo(t, srv, backend, Options{TTL: time.Hour})
+
+	link := domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"}
+	_, err := replicaA.Store(ctx, link)
+	require.NoError(t, err)
+	_, err = replicaA.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+
+	srv.FailNext("DEL", 1)
+	require.NoError(t, replicaA.MarkDeletedBatch(ctx, []domain.URLLink{link}))
+	assert.NotZero(t, replicaA.Stats().Errors)
+
+	// реплика, не сумевшая удалить ключ, сама устаревшую запись не отдает
+	found, err := replicaA.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+	assert.True(t, found.DeletedFlag)
+
+	// и при следующем обращении повторяет удаление для остальных
+	found, err = replicaB.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+	assert.True(t, found.DeletedFlag)
+}
+
+func TestRedisCachedLinkRepository_TTLCapped(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	backend := newCountingRepo(t)
+	repo := newCachedRepo(t, srv, backend, Options{TTL: time.Hour})
+
+	_, err := repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"})
+	require.NoError(t, err)
+	_, err = repo.Find(ctx, "abc12")
+	require.NoError(t, err)
+
+	srv.FastForward(MaxTTL + time.Second)
+	assert.Zero(t, srv.Keys())
+	_, err = repo.Find(ctx, "abc12")
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.finds)
+}
+
+func TestRedisCachedLinkRepository_PasswordHashNotCached(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	backend := newCountingRepo(t)
+	repo := newCachedRepo(t, srv, backend, Options{TTL: time.Minute})
+	client := resp.NewClient(resp.Options{Addr: srv.Addr})
+	t.Cleanup(func() { client.Close() })
+
+	const hash = "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$aGFzaA"
+	_, err := repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "sec01", LongURL: "https://example.com", PasswordHash: hash})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		link, err := repo.Find(ctx, "sec01")
+		require.NoError(t, err)
+		assert.Equal(t, hash, link.PasswordHash)
+	}
+
+	val, err := client.Get(ctx, defaultKeyPrefix+"sec01")
+	require.NoError(t, err)
+	assert.NotContains(t, val, hash)
+	assert.Contains(t, val, `"has_password":true`)
+}
+
+func TestRedisCachedLinkRepository_ReassignWorkspaceLinks(t *testing.T) {
+	ctx := context.Background()
+	backend, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	t.Cleanup(func() { backend.Close() })
+	repo := newCachedRepo(t, newServer(t), backend, Options{TTL: time.Hour})
+
+	links := []domain.URLLink{
+		{UserID: "u1", ShortURL: "own01", LongURL: "https://example.com/own"},
+		{UserID: "u1", WorkspaceID: "w1", ShortURL: "team1", LongURL: "https://example.com/team"},
+	}
+	for _, l := range links {
+		_, err := repo.Store(ctx, l)
+		require.NoError(t, err)
+		_, err = repo.Find(ctx, l.ShortURL)
+		require.NoError(t, err)
+	}
+
+	n, err := repo.ReassignLinks(ctx, "u1", "u2", 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	for _, l := range links {
+		found, err := repo.Find(ctx, l.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, "u2", found.UserID, l.ShortURL)
+	}
+}
+
+func TestRedisCachedLinkRepository_FailOpen(t *testing.T) {
+	ctx := context.Background()
+	srv := resptest.NewServer()
+	backend := newCountingRepo(t)
+	repo := newCachedRepo(t, srv, backend, Options{TTL: time.Minute})
+
+	_, err := repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"})
+	require.NoError(t, err)
+
+	srv.Close()
+
+	link, err := repo.Find(ctx, "abc12")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", link.LongURL)
+	assert.NotZero(t, repo.Stats().Errors)
+}
@@ -17,4 +17,37 @@ var (
 	ErrorShortURLCreatedByAnotherUser = fmt.Errorf("короткая ссылка для ресурса создана другим пользователем: ")
 	ErrorShortLinkHasBeenGone         = fmt.Errorf("короткая ссылка была удалена: ")
 	ErrorMarkDeletedBatch             = fmt.Errorf("ошибка пакетного удаления: ")
+	ErrorUnknownStorageBackend        = fmt.Errorf("неизвестный бэкенд хранилища: ")
+	ErrorMissingStorageParam          = fmt.Errorf("не задан параметр бэкенда хранилища: ")
+	ErrorUnknownRepoDecorator         = fmt.Errorf("неизвестный декоратор хранилища: ")
+	ErrorInvalidStorageParam          = fmt.Errorf("некорректный параметр бэкенда хранилища: ")
+	ErrorConnectingCache              = fmt.Errorf("ошибка подключения к кэшу: ")
+	ErrorInsertAPIKey                 = fmt.Errorf("ошибка сохранения API-ключа: ")
+	ErrorAPIKeyNotFound               = fmt.Errorf("API-ключ не найден: ")
+	ErrorSelectAPIKeys                = fmt.Errorf("ошибка выборки API-ключей: ")
+	ErrorRevokeAPIKey                 = fmt.Errorf("ошибка отзыва API-ключа: ")
+	ErrorReassignLinks                = fmt.Errorf("ошибка переноса ссылок другому пользователю: ")
+	ErrorInsertUser                   = fmt.Errorf("ошибка сохранения пользователя: ")
+	ErrorUserAlreadyExists            = fmt.Errorf("пользователь с таким логином уже существует: ")
+	ErrorUserNotFound                 = fmt.Errorf("пользователь не найден: ")
+	ErrorSelectUser                   = fmt.Errorf("ошибка выборки пользователя: ")
+	ErrorUpdateUser                   = fmt.Errorf("ошибка обновления пользователя: ")
+	ErrorMoveLink                     = fmt.Errorf("ошибка переноса ссылки: ")
+	ErrorInsertWorkspace              = fmt.Errorf("ошибка сохранения рабочего пространства: ")
+	ErrorWorkspaceNotFound            = fmt.Errorf("рабочее пространство не найдено: ")
+	ErrorSelectWorkspaces             = fmt.Errorf("ошибка выборки рабочих пространств: ")
+	ErrorMemberNotFound               = fmt.Errorf("участник рабочего пространства не найден: ")
+	ErrorSaveMember                   = fmt.Errorf("ошибка сохранения участника рабочего пространства: ")
+	ErrorUpdateShortLink              = fmt.Errorf("ошибка изменения короткой ссылки: ")
+	ErrorSelectStats                  = fmt.Errorf("ошибка подсчета статистики: ")
+	ErrorSaveDomainBlock              = fmt.Errorf("ошибка сохранения блокировки домена: ")
+	ErrorDomainBlockNotFound          = fmt.Errorf("блокировка домена не найдена: ")
+	ErrorSelectDomainBlocks           = fmt.Errorf("ошибка выборки заблокированных доменов: ")
+	ErrorInsertAudit                  = fmt.Errorf("ошибка записи в журнал аудита: ")
+	ErrorSelectAudit                  = fmt.Errorf("ошибка чтения журнала аудита: ")
+	ErrorSelectLinkVersions           = fmt.Errorf("ошибка выборки версий ссылки: ")
+	ErrorCountLinks                   = fmt.Errorf("ошибка подсчета ссылок пользователя: ")
+	ErrorClicksExhausted              = fmt.Errorf("переходы по ссылке исчерпаны: ")
+	ErrorConsumeClick                 = fmt.Errorf("ошибка учета перехода по ссылке: ")
+	ErrorLinkQuotaExceeded            = fmt.Errorf("превышена квота ссылок пользователя: ")
 )
@@ -0,0 +1,55 @@
+package repoerrors
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// Коды SQLSTATE, после которых операцию имеет смысл повторить
+var transientSQLStates = map[string]struct{}{
+	"40001": {}, // serialization_failure
+	"40P01": {}, // deadlock_detected
+	"08000": {}, // connection_exception
+	"08001": {}, // sqlclient_unable_to_establish_sqlconnection
+	"08003": {}, // connection_does_not_exist
+	"08004": {}, // sqlserver_rejected_establishment_of_sqlconnection
+	"08006": {}, // connection_failure
+	"53300": {}, // too_many_connections
+	"57P01": {}, // admin_shutdown
+	"57P03": {}, // cannot_connect_now
+}
+
+// Ошибка драйвера СУБД с кодом SQLSTATE
+type sqlStateError interface {
+	SQLState() string
+}
+
+// IsTransient сообщает, что ошибка временная (обрыв соединения, конфликт
+// сериализации) и операцию можно повторить. Нарушения ограничений
+// целостности (класс 23) и прочие ошибки СУБД временными не считаются
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		_, ok := transientSQLStates[stateErr.SQLState()]
+		return ok
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,41 @@
+package repoerrors
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stateError string
+
+func (e stateError) Error() string    { return "sql error " + string(e) }
+func (e stateError) SQLState() string { return string(e) }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"serialization failure", stateError("40001"), true},
+		{"deadlock", errors.Join(ErrorSQLInternal, stateError("40P01")), true},
+		{"admin shutdown", stateError("57P01"), true},
+		{"unique violation", errors.Join(ErrorShortLinkAlreadyInDB, stateError("23505")), false},
+		{"not null violation", stateError("23502"), false},
+		{"syntax error", stateError("42601"), false},
+		{"bad conn", driver.ErrBadConn, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"plain error", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsTransient(tt.err))
+		})
+	}
+}
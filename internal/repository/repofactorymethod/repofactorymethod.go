@@ -1,32 +1,338 @@
 package repofactorymethod
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/physicist2018/url-shortener-go/internal/domain"
 	"github.com/physicist2018/url-shortener-go/internal/repository/database/postgres"
+	"github.com/physicist2018/url-shortener-go/internal/repository/database/sqlite"
 	"github.com/physicist2018/url-shortener-go/internal/repository/inmemory"
+	"github.com/physicist2018/url-shortener-go/internal/repository/rediscache"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/pkg/resp"
+)
+
+// Имена параметров, которые конфигурация передает бэкендам
+const (
+	ParamFileStoragePath = "file-storage-path"
+	ParamDatabaseDSN     = "database-dsn"
+	ParamDatabaseReplica = "database-replica-dsns"
+	ParamDBMaxOpenConns  = "database-max-open-conns"
+	ParamDBMaxIdleConns  = "database-max-idle-conns"
+	ParamDBConnLifetime  = "database-conn-max-lifetime"
+	ParamDBConnectTime   = "database-connect-timeout"
+	ParamDBRetryAttempts = "database-retry-attempts"
+	ParamSQLitePath      = "sqlite-path"
+	ParamRedisAddr       = "redis-addr"
+	ParamRedisPassword   = "redis-password"
+	ParamRedisDB         = "redis-db"
+	ParamRedisTTL        = "redis-ttl"
+	ParamCacheNegTTL     = "cache-negative-ttl"
 )
 
-type RepoFactoryMethod struct{}
+// Параметры создания репозитория: имя параметра -> значение
+type Params map[string]string
+
+// Описание одного параметра бэкенда
+type ParamSpec struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Конструктор репозитория по его параметрам
+type Constructor func(params Params) (domain.URLLinkRepo, error)
+
+// Зарегистрированный бэкенд хранилища
+type Backend struct {
+	Name        string
+	Description string
+	Params      []ParamSpec
+	Constructor Constructor
+}
+
+// Обертка над уже созданным репозиторием
+type Wrapper func(repo domain.URLLinkRepo, params Params) (domain.URLLinkRepo, error)
+
+// Зарегистрированный декоратор репозитория (кэш и т.п.)
+type Decorator struct {
+	Name        string
+	Description string
+	Params      []ParamSpec
+	Wrap        Wrapper
+}
+
+type RepoFactoryMethod struct {
+	backends   map[string]Backend
+	decorators map[string]Decorator
+}
 
+// NewRepoFactoryMethod создает фабрику с зарегистрированными встроенными бэкендами
 func NewRepoFactoryMethod() *RepoFactoryMethod {
-	return &RepoFactoryMethod{}
+	r := &RepoFactoryMethod{
+		backends:   make(map[string]Backend),
+		decorators: make(map[string]Decorator),
+	}
+
+	r.mustRegister(Backend{
+		Name:        "inmemory",
+		Description: "хранение в памяти с сохранением в файл",
+		Params: []ParamSpec{
+			{Name: ParamFileStoragePath, Description: "имя файла персистентного хранилища", Required: true},
+		},
+		Constructor: r.createInMemoryRepo,
+	})
+	r.mustRegister(Backend{
+		Name:        "postgres",
+		Description: "база данных PostgreSQL",
+		Params: []ParamSpec{
+			{Name: ParamDatabaseDSN, Description: "строка подключения к базе данных", Required: true},
+			{Name: ParamDatabaseReplica, Description: "строки подключения к репликам для чтения через запятую"},
+			{Name: ParamDBMaxOpenConns, Description: "максимум открытых соединений"},
+			{Name: ParamDBMaxIdleConns, Description: "максимум простаивающих соединений"},
+			{Name: ParamDBConnLifetime, Description: "время жизни соединения"},
+			{Name: ParamDBConnectTime, Description: "сколько ждать базу данных при старте"},
+			{Name: ParamDBRetryAttempts, Description: "число попыток при временных ошибках"},
+		},
+		Constructor: r.createPostgresRepo,
+	})
+	r.mustRegister(Backend{
+		Name:        "sqlite",
+		Description: "файловая база данных SQLite",
+		Params: []ParamSpec{
+			{Name: ParamSQLitePath, Description: "путь к файлу базы SQLite", Required: true},
+		},
+		Constructor: r.createSQLiteRepo,
+	})
+
+	r.mustRegisterDecorator(Decorator{
+		Name:        "redis",
+		Description: "общий кэш коротких ссылок в Redis-совместимом хранилище",
+		Params: []ParamSpec{
+			{Name: ParamRedisAddr, Description: "адрес сервера host:port", Required: true},
+			{Name: ParamRedisPassword, Description: "пароль"},
+			{Name: ParamRedisDB, Description: "номер базы"},
+			{Name: ParamRedisTTL, Description: "время жизни ссылки в кэше"},
+			{Name: ParamCacheNegTTL, Description: "время жизни записи об отсутствующей ссылке"},
+		},
+		Wrap: r.wrapWithRedisCache,
+	})
+
+	return r
+}
+
+func (r *RepoFactoryMethod) createInMemoryRepo(params Params) (domain.URLLinkRepo, error) {
+	repo, err := inmemory.NewInMemoryLinkRepository(params[ParamFileStoragePath])
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (r *RepoFactoryMethod) createPostgresRepo(params Params) (domain.URLLinkRepo, error) {
+	opts := postgres.Options{ReplicaDSNs: params.listValue(ParamDatabaseReplica)}
+	var err error
+	if opts.MaxOpenConns, err = params.intValue(ParamDBMaxOpenConns); err != nil {
+		return nil, err
+	}
+	if opts.MaxIdleConns, err = params.intValue(ParamDBMaxIdleConns); err != nil {
+		return nil, err
+	}
+	if opts.ConnMaxLifetime, err = params.durationValue(ParamDBConnLifetime); err != nil {
+		return nil, err
+	}
+	if opts.ConnectTimeout, err = params.durationValue(ParamDBConnectTime); err != nil {
+		return nil, err
+	}
+	if opts.RetryAttempts, err = params.intValue(ParamDBRetryAttempts); err != nil {
+		return nil, err
+	}
+
+	repo, err := postgres.NewDBLinkRepositoryWithOptions(params[ParamDatabaseDSN], opts)
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (r *RepoFactoryMethod) createSQLiteRepo(params Params) (domain.URLLinkRepo, error) {
+	repo, err := sqlite.NewSQLiteLinkRepository(params[ParamSQLitePath])
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (r *RepoFactoryMethod) wrapWithRedisCache(repo domain.URLLinkRepo, params Params) (domain.URLLinkRepo, error) {
+	db, err := params.intValue(ParamRedisDB)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := params.durationValue(ParamRedisTTL)
+	if err != nil {
+		return nil, err
+	}
+	negativeTTL, err := params.durationValue(ParamCacheNegTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := resp.NewClient(resp.Options{
+		Addr:     params[ParamRedisAddr],
+		Password: params[ParamRedisPassword],
+		DB:       db,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, errors.Join(repoerrors.ErrorConnectingCache, err)
+	}
+
+	return rediscache.NewRedisCachedLinkRepository(repo, client, rediscache.Options{
+		TTL:         ttl,
+		NegativeTTL: negativeTTL,
+	}), nil
+}
+
+// Register добавляет бэкенд в реестр
+func (r *RepoFactoryMethod) Register(backend Backend) error {
+	if backend.Name == "" || backend.Constructor == nil {
+		return fmt.Errorf("бэкенд должен иметь имя и конструктор")
+	}
+	if _, ok := r.backends[backend.Name]; ok {
+		return fmt.Errorf("бэкенд %q уже зарегистрирован", backend.Name)
+	}
+	r.backends[backend.Name] = backend
+	return nil
+}
+
+// RegisterDecorator добавляет декоратор в реестр
+func (r *RepoFactoryMethod) RegisterDecorator(decorator Decorator) error {
+	if decorator.Name == "" || decorator.Wrap == nil {
+		return fmt.Errorf("декоратор должен иметь имя и обертку")
+	}
+	if _, ok := r.decorators[decorator.Name]; ok {
+		return fmt.Errorf("декоратор %q уже зарегистрирован", decorator.Name)
+	}
+	r.decorators[decorator.Name] = decorator
+	return nil
+}
+
+func (r *RepoFactoryMethod) mustRegisterDecorator(decorator Decorator) {
+	if err := r.RegisterDecorator(decorator); err != nil {
+		panic(err)
+	}
+}
+
+func (r *RepoFactoryMethod) mustRegister(backend Backend) {
+	if err := r.Register(backend); err != nil {
+		panic(err)
+	}
 }
-func (r *RepoFactoryMethod) createInMemoryRepo(dbname string) (*inmemory.InMemoryLinkRepository, error) {
-	return inmemory.NewInMemoryLinkRepository(dbname)
+
+// Available возвращает отсортированный список имен зарегистрированных бэкендов
+func (r *RepoFactoryMethod) Available() []string {
+	names := make([]string, 0, len(r.backends))
+	for name := range r.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
-func (r *RepoFactoryMethod) createPostgresRepo(connStr string) (*postgres.PostgresDBLinkRepository, error) {
-	return postgres.NewDBLinkRepository(connStr)
+// Backend возвращает описание зарегистрированного бэкенда
+func (r *RepoFactoryMethod) Backend(name string) (Backend, bool) {
+	backend, ok := r.backends[name]
+	return backend, ok
 }
 
 // Фабричный метод для создания репозитория
-func (r *RepoFactoryMethod) CreateRepo(repoType string, params string) (domain.URLLinkRepo, error) {
-	switch repoType {
-	case "inmemory":
-		return r.createInMemoryRepo(params)
-	case "postgres":
-		return r.createPostgresRepo(params)
-	default:
-		return nil, nil
+func (r *RepoFactoryMethod) CreateRepo(repoType string, params Params) (domain.URLLinkRepo, error) {
+	backend, ok := r.backends[repoType]
+	if !ok {
+		return nil, errors.Join(
+			repoerrors.ErrorUnknownStorageBackend,
+			fmt.Errorf("%q, доступные бэкенды: %s", repoType, strings.Join(r.Available(), ", ")),
+		)
+	}
+
+	if err := checkParams(backend.Name, backend.Params, params); err != nil {
+		return nil, err
+	}
+
+	return backend.Constructor(params)
+}
+
+// Decorate оборачивает репозиторий зарегистрированным декоратором
+func (r *RepoFactoryMethod) Decorate(name string, repo domain.URLLinkRepo, params Params) (domain.URLLinkRepo, error) {
+	decorator, ok := r.decorators[name]
+	if !ok {
+		names := make([]string, 0, len(r.decorators))
+		for n := range r.decorators {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, errors.Join(
+			repoerrors.ErrorUnknownRepoDecorator,
+			fmt.Errorf("%q, доступные декораторы: %s", name, strings.Join(names, ", ")),
+		)
+	}
+
+	if err := checkParams(decorator.Name, decorator.Params, params); err != nil {
+		return nil, err
+	}
+
+	return decorator.Wrap(repo, params)
+}
+
+func checkParams(name string, specs []ParamSpec, params Params) error {
+	for _, spec := range specs {
+		if spec.Required && params[spec.Name] == "" {
+			return errors.Join(
+				repoerrors.ErrorMissingStorageParam,
+				fmt.Errorf("%q требует параметр %q (%s)", name, spec.Name, spec.Description),
+			)
+		}
+	}
+	return nil
+}
+
+func (p Params) listValue(name string) []string {
+	var result []string
+	for _, v := range strings.Split(p[name], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func (p Params) intValue(name string) (int, error) {
+	if p[name] == "" {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(p[name])
+	if err != nil {
+		return 0, errors.Join(repoerrors.ErrorInvalidStorageParam, fmt.Errorf("%q: %w", name, err))
+	}
+	return v, nil
+}
+
+func (p Params) durationValue(name string) (time.Duration, error) {
+	if p[name] == "" {
+		return 0, nil
+	}
+	v, err := time.ParseDuration(p[name])
+	if err != nil {
+		return 0, errors.Join(repoerrors.ErrorInvalidStorageParam, fmt.Errorf("%q: %w", name, err))
 	}
+	return v, nil
 }
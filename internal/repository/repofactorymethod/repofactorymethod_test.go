@@ -0,0 +1,95 @@
+package repofactorymethod
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/pkg/resp/resptest"
+)
+
+func TestCreateRepo_UnknownBackend(t *testing.T) {
+	factory := NewRepoFactoryMethod()
+
+	repo, err := factory.CreateRepo("mongo", Params{})
+	assert.Nil(t, repo)
+	assert.True(t, errors.Is(err, repoerrors.ErrorUnknownStorageBackend))
+	assert.Contains(t, err.Error(), "inmemory, postgres, sqlite")
+}
+
+func TestCreateRepo_MissingParam(t *testing.T) {
+	factory := NewRepoFactoryMethod()
+
+	repo, err := factory.CreateRepo("postgres", Params{ParamFileStoragePath: "dbase.json"})
+	assert.Nil(t, repo)
+	assert.True(t, errors.Is(err, repoerrors.ErrorMissingStorageParam))
+	assert.Contains(t, err.Error(), ParamDatabaseDSN)
+}
+
+func TestCreateRepo_SQLite(t *testing.T) {
+	factory := NewRepoFactoryMethod()
+
+	repo, err := factory.CreateRepo("sqlite", Params{ParamSQLitePath: ":memory:"})
+	require.NoError(t, err)
+	defer repo.Close()
+	assert.NotNil(t, repo)
+}
+
+func TestRegister_CustomBackend(t *testing.T) {
+	factory := NewRepoFactoryMethod()
+	called := false
+
+	err := factory.Register(Backend{
+		Name: "custom",
+		Constructor: func(params Params) (domain.URLLinkRepo, error) {
+			called = true
+			return nil, nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, factory.Available(), "custom")
+
+	_, err = factory.CreateRepo("custom", Params{})
+	assert.NoError(t, err)
+	assert.True(t, called)
+
+	assert.Error(t, factory.Register(Backend{Name: "custom", Constructor: func(Params) (domain.URLLinkRepo, error) { return nil, nil }}))
+}
+
+func TestDecorate_Redis(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	factory := NewRepoFactoryMethod()
+
+	repo, err := factory.CreateRepo("sqlite", Params{ParamSQLitePath: ":memory:"})
+	require.NoError(t, err)
+
+	decorated, err := factory.Decorate("redis", repo, Params{ParamRedisAddr: srv.Addr, ParamRedisTTL: "1m"})
+	require.NoError(t, err)
+	defer decorated.Close()
+
+	ctx := context.Background()
+	_, err = decorated.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"})
+	require.NoError(t, err)
+	_, err = decorated.Find(ctx, "abc12")
+	require.NoError(t, err)
+	assert.Equal(t, 1, srv.Keys())
+}
+
+func TestDecorate_Errors(t *testing.T) {
+	factory := NewRepoFactoryMethod()
+
+	_, err := factory.Decorate("memcached", nil, Params{})
+	assert.True(t, errors.Is(err, repoerrors.ErrorUnknownRepoDecorator))
+
+	_, err = factory.Decorate("redis", nil, Params{})
+	assert.True(t, errors.Is(err, repoerrors.ErrorMissingStorageParam))
+
+	_, err = factory.Decorate("redis", nil, Params{ParamRedisAddr: "localhost:6379", ParamRedisTTL: "soon"})
+	assert.True(t, errors.Is(err, repoerrors.ErrorInvalidStorageParam))
+}
@@ -0,0 +1,164 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Хранилище со всеми данными, с которыми работает административный API
+type AdminStore interface {
+	domain.URLLinkRepo
+	domain.AdminRepo
+	domain.DomainBlockRepo
+	domain.AuditRepo
+}
+
+// Фабрика чистого хранилища для административного API
+type AdminStoreFactory func(t *testing.T) AdminStore
+
+// RunAdminConformance прогоняет набор тестов на административных данных хранилища.
+// Счетчики проверяются по приращению, поэтому набор можно гонять на долгоживущей базе
+func RunAdminConformance(t *testing.T, newRepo AdminStoreFactory) {
+	ctx := context.Background()
+
+	t.Run("FindUserLinks", func(t *testing.T) {
+		repo := newRepo(t)
+		author := uuid.New().String()
+		personal := newLink(author)
+		shared := newLink(author)
+		shared.WorkspaceID = uuid.New().String()
+		foreign := newLink(uuid.New().String())
+
+		for _, l := range []domain.URLLink{personal, shared, foreign} {
+			_, err := repo.Store(ctx, l)
+			require.NoError(t, err)
+		}
+		require.NoError(t, repo.SetDeleted(ctx, personal.ShortURL, true))
+
+		// в отличие от FindAll, видны ссылки пространств и удаленные ссылки
+		links, err := repo.FindUserLinks(ctx, author)
+		require.NoError(t, err)
+		require.Len(t, links, 2)
+		byCode := map[string]domain.URLLink{}
+		for _, l := range links {
+			byCode[l.ShortURL] = l
+		}
+		assert.True(t, byCode[personal.ShortURL].DeletedFlag)
+		assert.Equal(t, shared.WorkspaceID, byCode[shared.ShortURL].WorkspaceID)
+	})
+
+	t.Run("SystemStats", func(t *testing.T) {
+		repo := newRepo(t)
+		before, err := repo.SystemStats(ctx)
+		require.NoError(t, err)
+
+		owner := uuid.New().String()
+		first, second := newLink(owner), newLink(owner)
+		for _, l := range []domain.URLLink{first, second} {
+			_, err := repo.Store(ctx, l)
+			require.NoError(t, err)
+		}
+		require.NoError(t, repo.SetDeleted(ctx, first.ShortURL, true))
+		require.NoError(t, repo.BlockDomain(ctx, newDomainBlock()))
+
+		after, err := repo.SystemStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, before.Links+2, after.Links)
+		assert.Equal(t, before.DeletedLinks+1, after.DeletedLinks)
+		assert.Equal(t, before.LinkOwners+1, after.LinkOwners)
+		assert.Equal(t, before.BlockedDomains+1, after.BlockedDomains)
+	})
+
+	t.Run("Block and unblock domain", func(t *testing.T) {
+		repo := newRepo(t)
+		block := newDomainBlock()
+		require.NoError(t, repo.BlockDomain(ctx, block))
+
+		// повторная блокировка обновляет причину
+		block.Reason = "phishing"
+		require.NoError(t, repo.BlockDomain(ctx, block))
+
+		blocks, err := repo.FindBlockedDomains(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, blocks, block)
+
+		require.NoError(t, repo.UnblockDomain(ctx, block.Domain))
+		blocks, err = repo.FindBlockedDomains(ctx)
+		require.NoError(t, err)
+		assert.NotContains(t, blocks, block)
+
+		err = repo.UnblockDomain(ctx, block.Domain)
+		assert.True(t, errors.Is(err, repoerrors.ErrorDomainBlockNotFound))
+	})
+
+	t.Run("Audit log", func(t *testing.T) {
+		repo := newRepo(t)
+		actor := "user:" + uuid.New().String()
+
+		first, err := repo.AppendAudit(ctx, newAuditEntry(actor))
+		require.NoError(t, err)
+		second, err := repo.AppendAudit(ctx, newAuditEntry(actor))
+		require.NoError(t, err)
+		assert.Greater(t, second.Seq, first.Seq)
+
+		// записи связаны в цепочку, хэш вычислен от сохраненных значений
+		assert.Equal(t, first.Hash, second.PrevHash)
+		assert.Equal(t, first.ChainHash(), first.Hash)
+		assert.Equal(t, second.ChainHash(), second.Hash)
+
+		entries, err := repo.FindAuditEntries(ctx, domain.AuditFilter{AfterSeq: first.Seq - 1, Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []domain.AuditEntry{first, second}, entries)
+		for _, entry := range entries {
+			assert.Equal(t, entry.Hash, entry.ChainHash())
+		}
+
+		entries, err = repo.FindAuditEntries(ctx, domain.AuditFilter{AfterSeq: first.Seq, Limit: 10})
+		require.NoError(t, err)
+		require.NotEmpty(t, entries)
+		assert.Equal(t, second, entries[0])
+
+		entries, err = repo.FindAuditEntries(ctx, domain.AuditFilter{AfterSeq: second.Seq, Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+
+		entries, err = repo.FindAuditEntries(ctx, domain.AuditFilter{Limit: 10, Actor: actor, Target: second.Target})
+		require.NoError(t, err)
+		assert.Equal(t, []domain.AuditEntry{second}, entries)
+
+		entries, err = repo.FindAuditEntries(ctx, domain.AuditFilter{Limit: 10, Actor: actor, Action: domain.AuditLinkCreate})
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func newDomainBlock() domain.DomainBlock {
+	return domain.DomainBlock{
+		Domain:    uuid.New().String()[:8] + ".example",
+		Reason:    "spam",
+		CreatedBy: "key:ops",
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func newAuditEntry(actor string) domain.AuditEntry {
+	return domain.AuditEntry{
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		Actor:     actor,
+		Action:    domain.AuditLinkDelete,
+		Target:    uuid.New().String()[:8],
+		RequestID: uuid.New().String(),
+		Before:    `{"is_deleted":false}`,
+		After:     `{"is_deleted":true}`,
+		Result:    domain.AuditResultOK,
+	}
+}
@@ -0,0 +1,91 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Фабрика чистого хранилища API-ключей
+type APIKeyRepoFactory func(t *testing.T) domain.APIKeyRepo
+
+func newAPIKey(userID string, createdAt time.Time) domain.APIKey {
+	id := uuid.New().String()
+	return domain.APIKey{
+		ID:        id,
+		UserID:    userID,
+		Name:      "ci",
+		Prefix:    id[:8],
+		Hash:      uuid.New().String(),
+		Scopes:    []string{domain.ScopeLinksCreate, domain.ScopeLinksRead},
+		CreatedAt: createdAt.UTC().Truncate(time.Second),
+	}
+}
+
+// RunAPIKeyConformance прогоняет набор тестов на хранилище API-ключей
+func RunAPIKeyConformance(t *testing.T, newRepo APIKeyRepoFactory) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("Store and find by hash", func(t *testing.T) {
+		repo := newRepo(t)
+		key := newAPIKey(uuid.New().String(), now)
+
+		require.NoError(t, repo.StoreAPIKey(ctx, key))
+
+		found, err := repo.FindAPIKeyByHash(ctx, key.Hash)
+		require.NoError(t, err)
+		assert.Equal(t, key, found)
+	})
+
+	t.Run("Find missing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.FindAPIKeyByHash(ctx, uuid.New().String())
+		assert.True(t, errors.Is(err, repoerrors.ErrorAPIKeyNotFound))
+	})
+
+	t.Run("FindAPIKeys by user", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New().String()
+		older := newAPIKey(userID, now.Add(-time.Hour))
+		newer := newAPIKey(userID, now)
+		require.NoError(t, repo.StoreAPIKey(ctx, newer))
+		require.NoError(t, repo.StoreAPIKey(ctx, older))
+		require.NoError(t, repo.StoreAPIKey(ctx, newAPIKey(uuid.New().String(), now)))
+
+		keys, err := repo.FindAPIKeys(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, older.ID, keys[0].ID)
+		assert.Equal(t, newer.ID, keys[1].ID)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		repo := newRepo(t)
+		key := newAPIKey(uuid.New().String(), now)
+		require.NoError(t, repo.StoreAPIKey(ctx, key))
+
+		// чужой ключ отозвать нельзя
+		err := repo.RevokeAPIKey(ctx, uuid.New().String(), key.ID, now)
+		assert.True(t, errors.Is(err, repoerrors.ErrorAPIKeyNotFound))
+
+		revokedAt := now.UTC().Truncate(time.Second)
+		require.NoError(t, repo.RevokeAPIKey(ctx, key.UserID, key.ID, revokedAt))
+		// повторный отзыв не сдвигает время
+		require.NoError(t, repo.RevokeAPIKey(ctx, key.UserID, key.ID, revokedAt.Add(time.Hour)))
+
+		found, err := repo.FindAPIKeyByHash(ctx, key.Hash)
+		require.NoError(t, err)
+		require.NotNil(t, found.RevokedAt)
+		assert.True(t, revokedAt.Equal(*found.RevokedAt))
+	})
+}
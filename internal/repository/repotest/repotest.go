@@ -0,0 +1,536 @@
+// Пакет repotest содержит общий набор тестов, которому должна соответствовать
+// любая реализация domain.URLLinkRepo
+package repotest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Фабрика чистого репозитория для очередного подтеста
+type RepoFactory func(t *testing.T) domain.URLLinkRepo
+
+// Данные уникальны в пределах запуска, поэтому набор можно гонять
+// и на долгоживущей базе
+func newLink(userID string) domain.URLLink {
+	id := uuid.New().String()
+	return domain.URLLink{
+		UserID:   userID,
+		ShortURL: id[:8],
+		LongURL:  "https://example.com/" + id,
+	}
+}
+
+// ссылка с заданным адресом и новым коротким кодом
+func newLinkFor(userID, longURL string) domain.URLLink {
+	link := newLink(userID)
+	link.LongURL = longURL
+	return link
+}
+
+// RunConformance прогоняет набор тестов на репозитории, созданном фабрикой
+func RunConformance(t *testing.T, newRepo RepoFactory) {
+	ctx := context.Background()
+
+	t.Run("Store and Find", func(t *testing.T) {
+		repo := newRepo(t)
+		link := newLink(uuid.New().String())
+
+		stored, err := repo.Store(ctx, link)
+		require.NoError(t, err)
+		assert.Equal(t, link, stored)
+
+		found, err := repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, link.UserID, found.UserID)
+		assert.Equal(t, link.ShortURL, found.ShortURL)
+		assert.Equal(t, link.LongURL, found.LongURL)
+		assert.False(t, found.DeletedFlag)
+	})
+
+	t.Run("FindConsistent", func(t *testing.T) {
+		repo := newRepo(t)
+		link := newLink(uuid.New().String())
+		_, err := repo.Store(ctx, link)
+		require.NoError(t, err)
+		// прогреваем кэши декораторов
+		_, err = repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.SetDeleted(ctx, link.ShortURL, true))
+		found, err := repo.FindConsistent(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.True(t, found.DeletedFlag)
+
+		_, err = repo.FindConsistent(ctx, "missing-"+uuid.New().String()[:8])
+		assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+	})
+
+	t.Run("Find missing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.Find(ctx, uuid.New().String()[:8])
+		assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+	})
+
+	t.Run("FindAll by user", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New().String()
+		mine := []domain.URLLink{newLink(userID), newLink(userID)}
+		other := newLink(uuid.New().String())
+
+		for _, l := range append(mine, other) {
+			_, err := repo.Store(ctx, l)
+			require.NoError(t, err)
+		}
+
+		links, err := repo.FindAll(ctx, userID)
+		require.NoError(t, err)
+		assert.Len(t, links, len(mine))
+		for _, l := range links {
+			assert.Equal(t, userID, l.UserID)
+		}
+
+		links, err = repo.FindAll(ctx, uuid.New().String())
+		require.NoError(t, err)
+		assert.Empty(t, links)
+	})
+
+	t.Run("MarkDeletedBatch only for owner", func(t *testing.T) {
+		repo := newRepo(t)
+		owner := uuid.New().String()
+		link := newLink(owner)
+		foreign := newLink(uuid.New().String())
+
+		for _, l := range []domain.URLLink{link, foreign} {
+			_, err := repo.Store(ctx, l)
+			require.NoError(t, err)
+		}
+
+		err := repo.MarkDeletedBatch(ctx, []domain.URLLink{
+			{UserID: owner, ShortURL: link.ShortURL},
+			{UserID: owner, ShortURL: foreign.ShortURL},
+		})
+		require.NoError(t, err)
+
+		found, err := repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.True(t, found.DeletedFlag)
+
+		found, err = repo.Find(ctx, foreign.ShortURL)
+		require.NoError(t, err)
+		assert.False(t, found.DeletedFlag)
+	})
+
+	t.Run("ReassignLinks", func(t *testing.T) {
+		repo := newRepo(t)
+		from, to := uuid.New().String(), uuid.New().String()
+		moved := []domain.URLLink{newLink(from), newLink(from)}
+		untouched := newLink(uuid.New().String())
+
+		for _, l := range append(moved, untouched) {
+			_, err := repo.Store(ctx, l)
+			require.NoError(t, err)
+		}
+		// прогреваем кэши декораторов
+		_, err := repo.Find(ctx, moved[0].ShortURL)
+		require.NoError(t, err)
+
+		// у получателя уже есть ссылка: две перенесенные не помещаются в квоту
+		_, err = repo.Store(ctx, newLink(to))
+		require.NoError(t, err)
+		_, err = repo.ReassignLinks(ctx, from, to, 2)
+		assert.ErrorIs(t, err, repoerrors.ErrorLinkQuotaExceeded)
+		found, err := repo.Find(ctx, moved[0].ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, from, found.UserID)
+
+		n, err := repo.ReassignLinks(ctx, from, to, 3)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(moved)), n)
+
+		links, err := repo.FindAll(ctx, to)
+		require.NoError(t, err)
+		assert.Len(t, links, len(moved)+1)
+
+		links, err = repo.FindAll(ctx, from)
+		require.NoError(t, err)
+		assert.Empty(t, links)
+
+		found, err = repo.Find(ctx, moved[0].ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, to, found.UserID)
+
+		found, err = repo.Find(ctx, untouched.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, untouched.UserID, found.UserID)
+	})
+
+	t.Run("Workspace links", func(t *testing.T) {
+		repo := newRepo(t)
+		author, workspaceID := uuid.New().String(), uuid.New().String()
+		personal := newLink(author)
+		shared := newLink(author)
+		shared.WorkspaceID = workspaceID
+
+		for _, l := range []domain.URLLink{personal, shared} {
+			_, err := repo.Store(ctx, l)
+			require.NoError(t, err)
+		}
+
+		found, err := repo.Find(ctx, shared.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, workspaceID, found.WorkspaceID)
+
+		// ссылки пространства не попадают в личный список автора
+		links, err := repo.FindAll(ctx, author)
+		require.NoError(t, err)
+		require.Len(t, links, 1)
+		assert.Equal(t, personal.ShortURL, links[0].ShortURL)
+
+		links, err = repo.FindAllInWorkspace(ctx, workspaceID)
+		require.NoError(t, err)
+		require.Len(t, links, 1)
+		assert.Equal(t, shared.ShortURL, links[0].ShortURL)
+	})
+
+	t.Run("MarkDeletedBatch by workspace", func(t *testing.T) {
+		repo := newRepo(t)
+		author, workspaceID := uuid.New().String(), uuid.New().String()
+		shared := newLink(author)
+		shared.WorkspaceID = workspaceID
+		_, err := repo.Store(ctx, shared)
+		require.NoError(t, err)
+
+		// автор не удаляет ссылку пространства как личную, чужое пространство - тоже
+		err = repo.MarkDeletedBatch(ctx, []domain.URLLink{
+			{UserID: author, ShortURL: shared.ShortURL},
+			{UserID: author, WorkspaceID: uuid.New().String(), ShortURL: shared.ShortURL},
+		})
+		require.NoError(t, err)
+		found, err := repo.Find(ctx, shared.ShortURL)
+		require.NoError(t, err)
+		assert.False(t, found.DeletedFlag)
+
+		err = repo.MarkDeletedBatch(ctx, []domain.URLLink{
+			{UserID: uuid.New().String(), WorkspaceID: workspaceID, ShortURL: shared.ShortURL},
+		})
+		require.NoError(t, err)
+		found, err = repo.Find(ctx, shared.ShortURL)
+		require.NoError(t, err)
+		assert.True(t, found.DeletedFlag)
+	})
+
+	t.Run("MoveLink", func(t *testing.T) {
+		repo := newRepo(t)
+		author, workspaceID := uuid.New().String(), uuid.New().String()
+		link := newLink(author)
+		_, err := repo.Store(ctx, link)
+		require.NoError(t, err)
+		// прогреваем кэши декораторов
+		_, err = repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.MoveLink(ctx, link, author, workspaceID))
+		found, err := repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, workspaceID, found.WorkspaceID)
+
+		// ссылка уже перенесена: повторный перенос по старому состоянию не проходит
+		err = repo.MoveLink(ctx, link, uuid.New().String(), "")
+		assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+
+		links, err := repo.FindAll(ctx, author)
+		require.NoError(t, err)
+		assert.Empty(t, links)
+
+		// обратно в личные ссылки другого пользователя
+		other := uuid.New().String()
+		require.NoError(t, repo.MoveLink(ctx, found, other, ""))
+		links, err = repo.FindAll(ctx, other)
+		require.NoError(t, err)
+		require.Len(t, links, 1)
+		assert.Equal(t, link.ShortURL, links[0].ShortURL)
+
+		// удаленную ссылку не перенести
+		require.NoError(t, repo.MarkDeletedBatch(ctx, links))
+		err = repo.MoveLink(ctx, links[0], author, workspaceID)
+		assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+
+		err = repo.MoveLink(ctx, newLink(author), author, workspaceID)
+		assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+	})
+
+	t.Run("SetDeleted and PurgeLink", func(t *testing.T) {
+		repo := newRepo(t)
+		link := newLink(uuid.New().String())
+		_, err := repo.Store(ctx, link)
+		require.NoError(t, err)
+		// прогреваем кэши декораторов
+		_, err = repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.SetDeleted(ctx, link.ShortURL, true))
+		found, err := repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.True(t, found.DeletedFlag)
+
+		require.NoError(t, repo.SetDeleted(ctx, link.ShortURL, false))
+		found, err = repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.False(t, found.DeletedFlag)
+
+		require.NoError(t, repo.PurgeLink(ctx, link.ShortURL))
+		_, err = repo.Find(ctx, link.ShortURL)
+		assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+
+		// после удаления тот же адрес можно сократить заново
+		_, err = repo.Store(ctx, newLinkFor(link.UserID, link.LongURL))
+		require.NoError(t, err)
+
+		missing := "missing-" + uuid.New().String()[:8]
+		assert.True(t, errors.Is(repo.SetDeleted(ctx, missing, true), repoerrors.ErrorShortLinkNotFound))
+		assert.True(t, errors.Is(repo.PurgeLink(ctx, missing), repoerrors.ErrorShortLinkNotFound))
+	})
+
+	t.Run("Update and FindVersions", func(t *testing.T) {
+		repo := newRepo(t)
+		link := newLink(uuid.New().String())
+		_, err := repo.Store(ctx, link)
+		require.NoError(t, err)
+		// прогреваем кэши декораторов
+		_, err = repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+
+		versions, err := repo.FindVersions(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+
+		first, second := link.LongURL+"/v2", link.LongURL+"/v3"
+		require.NoError(t, repo.Update(ctx, domain.URLLink{ShortURL: link.ShortURL, LongURL: first}, link.UserID))
+		require.NoError(t, repo.Update(ctx, domain.URLLink{ShortURL: link.ShortURL, LongURL: second}, link.UserID))
+		// тот же адрес не создает новую версию
+		require.NoError(t, repo.Update(ctx, domain.URLLink{ShortURL: link.ShortURL, LongURL: second}, link.UserID))
+
+		found, err := repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, second, found.LongURL)
+		assert.Equal(t, link.UserID, found.UserID)
+
+		versions, err = repo.FindVersions(ctx, link.ShortURL)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, 1, versions[0].Version)
+		assert.Equal(t, link.LongURL, versions[0].LongURL)
+		assert.Equal(t, link.UserID, versions[0].ReplacedBy)
+		assert.False(t, versions[0].ReplacedAt.IsZero())
+		assert.Equal(t, 2, versions[1].Version)
+		assert.Equal(t, first, versions[1].LongURL)
+
+		// история удаляется вместе со ссылкой
+		require.NoError(t, repo.PurgeLink(ctx, link.ShortURL))
+		versions, err = repo.FindVersions(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+
+		missing := "missing-" + uuid.New().String()[:8]
+		err = repo.Update(ctx, domain.URLLink{ShortURL: missing, LongURL: first}, link.UserID)
+		assert.True(t, errors.Is(err, repoerrors.ErrorShortLinkNotFound))
+	})
+
+	t.Run("Quarantined link", func(t *testing.T) {
+		repo := newRepo(t)
+		link := newLink(uuid.New().String())
+		link.Quarantined = true
+		_, err := repo.Store(ctx, link)
+		require.NoError(t, err)
+
+		found, err := repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.True(t, found.Quarantined)
+		all, err := repo.FindAll(ctx, link.UserID)
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.True(t, all[0].Quarantined)
+
+		// новый адрес прошел проверку, ссылка выходит из карантина
+		released := domain.URLLink{ShortURL: link.ShortURL, LongURL: link.LongURL + "/fixed"}
+		require.NoError(t, repo.Update(ctx, released, link.UserID))
+		found, err = repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.False(t, found.Quarantined)
+	})
+
+	t.Run("Password hash", func(t *testing.T) {
+		repo := newRepo(t)
+		link := newLink(uuid.New().String())
+		link.PasswordHash = "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$a2V5"
+		_, err := repo.Store(ctx, link)
+		require.NoError(t, err)
+
+		// второе чтение может прийти из кэша
+		for i := 0; i < 2; i++ {
+			found, err := repo.Find(ctx, link.ShortURL)
+			require.NoError(t, err)
+			assert.Equal(t, link.PasswordHash, found.PasswordHash)
+		}
+
+		// снятие пароля
+		unprotected := domain.URLLink{ShortURL: link.ShortURL, LongURL: link.LongURL}
+		require.NoError(t, repo.Update(ctx, unprotected, link.UserID))
+		found, err := repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.Empty(t, found.PasswordHash)
+	})
+
+	t.Run("ConsumeClick under concurrent redirects", func(t *testing.T) {
+		repo := newRepo(t)
+		link := newLink(uuid.New().String())
+		link.MaxClicks = 5
+		link.ClicksRemaining = 5
+		_, err := repo.Store(ctx, link)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		var consumed, exhausted atomic.Int32
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.ConsumeClick(ctx, link.ShortURL)
+				switch {
+				case err == nil:
+					consumed.Add(1)
+				case errors.Is(err, repoerrors.ErrorClicksExhausted):
+					exhausted.Add(1)
+				default:
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(5), consumed.Load())
+		assert.Equal(t, int32(15), exhausted.Load())
+
+		found, err := repo.Find(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, 5, found.MaxClicks)
+		assert.Equal(t, 0, found.ClicksRemaining)
+	})
+
+	t.Run("CountActiveLinks", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New().String()
+		var links []domain.URLLink
+		for i := 0; i < 3; i++ {
+			link, err := repo.Store(ctx, newLink(userID))
+			require.NoError(t, err)
+			links = append(links, link)
+		}
+		_, err := repo.Store(ctx, newLink(uuid.New().String()))
+		require.NoError(t, err)
+
+		count, err := repo.CountActiveLinks(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		// удаленные ссылки не считаются
+		require.NoError(t, repo.MarkDeletedBatch(ctx, links[:1]))
+		count, err = repo.CountActiveLinks(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("StoreBatch quota", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New().String()
+		links, err := repo.StoreBatch(ctx, []domain.URLLink{newLink(userID), newLink(userID)}, 3)
+		require.NoError(t, err)
+		require.Len(t, links, 2)
+
+		// пакет сверх квоты не сохраняется частично
+		rejected := []domain.URLLink{newLink(userID), newLink(userID)}
+		_, err = repo.StoreBatch(ctx, rejected, 3)
+		assert.ErrorIs(t, err, repoerrors.ErrorLinkQuotaExceeded)
+		for _, link := range rejected {
+			_, err := repo.Find(ctx, link.ShortURL)
+			assert.ErrorIs(t, err, repoerrors.ErrorShortLinkNotFound)
+		}
+
+		// параллельные вставки не превышают квоту
+		var wg sync.WaitGroup
+		var stored, exceeded atomic.Int32
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.StoreBatch(ctx, []domain.URLLink{newLink(userID)}, 3)
+				switch {
+				case err == nil:
+					stored.Add(1)
+				case errors.Is(err, repoerrors.ErrorLinkQuotaExceeded):
+					exceeded.Add(1)
+				default:
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), stored.Load())
+		assert.Equal(t, int32(9), exceeded.Load())
+
+		count, err := repo.CountActiveLinks(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		// без квоты пакет сохраняется целиком
+		links, err = repo.StoreBatch(ctx, []domain.URLLink{newLink(userID), newLink(userID)}, 0)
+		require.NoError(t, err)
+		assert.Len(t, links, 2)
+	})
+
+	t.Run("StoreBatch duplicates", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New().String()
+		plain := newLink(userID)
+		secret := newLinkFor(userID, plain.LongURL)
+		secret.PasswordHash = "hash"
+		_, err := repo.StoreBatch(ctx, []domain.URLLink{secret}, 0)
+		require.NoError(t, err)
+
+		// ссылка с паролем не занимает адрес для ссылок без ограничений
+		stored, err := repo.StoreBatch(ctx, []domain.URLLink{plain, newLinkFor(userID, plain.LongURL)}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, plain.ShortURL, stored[0].ShortURL)
+		assert.Equal(t, plain.ShortURL, stored[1].ShortURL)
+
+		// ссылки с ограничением доступа всегда сохраняются новыми
+		limited := newLinkFor(userID, plain.LongURL)
+		limited.MaxClicks, limited.ClicksRemaining = 1, 1
+		another := newLinkFor(userID, plain.LongURL)
+		another.PasswordHash = "hash"
+		stored, err = repo.StoreBatch(ctx, []domain.URLLink{limited, another}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, limited.ShortURL, stored[0].ShortURL)
+		assert.Equal(t, another.ShortURL, stored[1].ShortURL)
+
+		count, err := repo.CountActiveLinks(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, 4, count)
+	})
+
+	t.Run("Ping", func(t *testing.T) {
+		repo := newRepo(t)
+		assert.NoError(t, repo.Ping(ctx))
+	})
+}
@@ -0,0 +1,81 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Фабрика чистого хранилища учетных записей
+type UserRepoFactory func(t *testing.T) domain.UserRepo
+
+func newUser() domain.User {
+	id := uuid.New().String()
+	return domain.User{
+		ID:           id,
+		Login:        "user-" + id[:8],
+		PasswordHash: "$argon2id$stub",
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// RunUserConformance прогоняет набор тестов на хранилище учетных записей
+func RunUserConformance(t *testing.T, newRepo UserRepoFactory) {
+	ctx := context.Background()
+
+	t.Run("Store and find", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		require.NoError(t, repo.StoreUser(ctx, user))
+
+		found, err := repo.FindUserByLogin(ctx, user.Login)
+		require.NoError(t, err)
+		assert.Equal(t, user, found)
+
+		found, err = repo.FindUserByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user, found)
+	})
+
+	t.Run("Duplicate login", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		require.NoError(t, repo.StoreUser(ctx, user))
+
+		duplicate := newUser()
+		duplicate.Login = user.Login
+		err := repo.StoreUser(ctx, duplicate)
+		assert.True(t, errors.Is(err, repoerrors.ErrorUserAlreadyExists))
+	})
+
+	t.Run("Find missing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.FindUserByLogin(ctx, "missing-"+uuid.New().String()[:8])
+		assert.True(t, errors.Is(err, repoerrors.ErrorUserNotFound))
+		_, err = repo.FindUserByID(ctx, uuid.New().String())
+		assert.True(t, errors.Is(err, repoerrors.ErrorUserNotFound))
+	})
+
+	t.Run("Update password hash", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		require.NoError(t, repo.StoreUser(ctx, user))
+
+		require.NoError(t, repo.UpdatePasswordHash(ctx, user.ID, "$argon2id$new"))
+		found, err := repo.FindUserByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "$argon2id$new", found.PasswordHash)
+
+		err = repo.UpdatePasswordHash(ctx, uuid.New().String(), "x")
+		assert.True(t, errors.Is(err, repoerrors.ErrorUserNotFound))
+	})
+}
@@ -0,0 +1,114 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+)
+
+// Фабрика чистого хранилища рабочих пространств
+type WorkspaceRepoFactory func(t *testing.T) domain.WorkspaceRepo
+
+func newWorkspace(ownerID string, createdAt time.Time) (domain.Workspace, domain.WorkspaceMember) {
+	workspace := domain.Workspace{
+		ID:        uuid.New().String(),
+		Name:      "team",
+		CreatedAt: createdAt.UTC().Truncate(time.Second),
+	}
+	return workspace, domain.WorkspaceMember{
+		WorkspaceID: workspace.ID,
+		UserID:      ownerID,
+		Role:        domain.RoleOwner,
+		AddedAt:     workspace.CreatedAt,
+	}
+}
+
+// RunWorkspaceConformance прогоняет набор тестов на хранилище рабочих пространств
+func RunWorkspaceConformance(t *testing.T, newRepo WorkspaceRepoFactory) {
+	ctx := context.Background()
+
+	t.Run("Store and find", func(t *testing.T) {
+		repo := newRepo(t)
+		ownerID := uuid.New().String()
+		workspace, owner := newWorkspace(ownerID, time.Now())
+		require.NoError(t, repo.StoreWorkspace(ctx, workspace, owner))
+
+		found, err := repo.FindWorkspace(ctx, workspace.ID)
+		require.NoError(t, err)
+		assert.Equal(t, workspace, found)
+
+		member, err := repo.FindMember(ctx, workspace.ID, ownerID)
+		require.NoError(t, err)
+		assert.Equal(t, owner, member)
+
+		_, err = repo.FindWorkspace(ctx, uuid.New().String())
+		assert.True(t, errors.Is(err, repoerrors.ErrorWorkspaceNotFound))
+	})
+
+	t.Run("User workspaces in creation order", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New().String()
+		now := time.Now()
+
+		first, owner := newWorkspace(userID, now.Add(-time.Hour))
+		require.NoError(t, repo.StoreWorkspace(ctx, first, owner))
+
+		second, other := newWorkspace(uuid.New().String(), now)
+		require.NoError(t, repo.StoreWorkspace(ctx, second, other))
+		require.NoError(t, repo.SaveMember(ctx, domain.WorkspaceMember{
+			WorkspaceID: second.ID, UserID: userID, Role: domain.RoleViewer, AddedAt: now.UTC().Truncate(time.Second),
+		}))
+
+		memberships, err := repo.FindUserWorkspaces(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, memberships, 2)
+		assert.Equal(t, domain.WorkspaceMembership{Workspace: first, Role: domain.RoleOwner}, memberships[0])
+		assert.Equal(t, domain.WorkspaceMembership{Workspace: second, Role: domain.RoleViewer}, memberships[1])
+
+		memberships, err = repo.FindUserWorkspaces(ctx, uuid.New().String())
+		require.NoError(t, err)
+		assert.Empty(t, memberships)
+	})
+
+	t.Run("Save, change role and remove member", func(t *testing.T) {
+		repo := newRepo(t)
+		workspace, owner := newWorkspace(uuid.New().String(), time.Now().Add(-time.Hour))
+		require.NoError(t, repo.StoreWorkspace(ctx, workspace, owner))
+
+		added := domain.WorkspaceMember{
+			WorkspaceID: workspace.ID,
+			UserID:      uuid.New().String(),
+			Role:        domain.RoleViewer,
+			AddedAt:     time.Now().UTC().Truncate(time.Second),
+		}
+		require.NoError(t, repo.SaveMember(ctx, added))
+
+		// смена роли не меняет время добавления
+		promoted := added
+		promoted.Role = domain.RoleEditor
+		promoted.AddedAt = added.AddedAt.Add(time.Hour)
+		require.NoError(t, repo.SaveMember(ctx, promoted))
+
+		members, err := repo.FindMembers(ctx, workspace.ID)
+		require.NoError(t, err)
+		require.Len(t, members, 2)
+		assert.Equal(t, owner, members[0])
+		assert.Equal(t, domain.RoleEditor, members[1].Role)
+		assert.Equal(t, added.AddedAt, members[1].AddedAt)
+
+		require.NoError(t, repo.RemoveMember(ctx, workspace.ID, added.UserID))
+		_, err = repo.FindMember(ctx, workspace.ID, added.UserID)
+		assert.True(t, errors.Is(err, repoerrors.ErrorMemberNotFound))
+
+		err = repo.RemoveMember(ctx, workspace.ID, added.UserID)
+		assert.True(t, errors.Is(err, repoerrors.ErrorMemberNotFound))
+	})
+}
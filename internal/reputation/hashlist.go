@@ -0,0 +1,157 @@
+package reputation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+)
+
+const (
+	minPrefixLen = 4 // байт, как у самых коротких префиксов Safe Browsing
+	maxHosts     = 5 // точное имя и до четырех родительских доменов
+	maxPaths     = 6 // путь с запросом, путь и до четырех каталогов от корня
+)
+
+var ErrInvalidHashList = errors.New("некорректный файл списка хешей")
+
+type hashEntry struct {
+	prefix []byte
+	reason string
+}
+
+// Локальный список опасных адресов в виде префиксов SHA-256, как в Safe
+// Browsing: адрес раскладывается на выражения хост/путь (evil.example/a/,
+// evil.example/ и так далее) и отмечается, если хеш любого из них начинается
+// с префикса из списка. Список можно получить у поставщика угроз, не раскрывая
+// ему проверяемые адреса.
+//
+// Формат файла - префикс в шестнадцатеричной записи (от 4 до 32 байт)
+// и необязательная категория угрозы, # начинает комментарий:
+//
+//	5e2bf57d malware
+//	a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90 phishing
+type HashList struct {
+	entries map[[minPrefixLen]byte][]hashEntry
+}
+
+// NewHashList загружает список из файла
+func NewHashList(path string) (*HashList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidHashList, err)
+	}
+	defer f.Close()
+	return parseHashList(f)
+}
+
+func (l *HashList) Check(ctx context.Context, rawURL string) (domain.ReputationVerdict, error) {
+	for _, expr := range lookupExpressions(rawURL) {
+		sum := sha256.Sum256([]byte(expr))
+		var key [minPrefixLen]byte
+		copy(key[:], sum[:])
+		for _, entry := range l.entries[key] {
+			if bytes.HasPrefix(sum[:], entry.prefix) {
+				return domain.ReputationVerdict{Flagged: true, Reason: entry.reason}, nil
+			}
+		}
+	}
+	return domain.ReputationVerdict{}, nil
+}
+
+func parseHashList(r io.Reader) (*HashList, error) {
+	list := &HashList{entries: make(map[[minPrefixLen]byte][]hashEntry)}
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("%w: строка %d: ожидается префикс хеша и категория", ErrInvalidHashList, lineNo)
+		}
+		prefix, err := hex.DecodeString(fields[0])
+		if err != nil || len(prefix) < minPrefixLen || len(prefix) > sha256.Size {
+			return nil, fmt.Errorf("%w: строка %d: префикс должен содержать от %d до %d байт в шестнадцатеричной записи",
+				ErrInvalidHashList, lineNo, minPrefixLen, sha256.Size)
+		}
+		entry := hashEntry{prefix: prefix, reason: "listed"}
+		if len(fields) == 2 {
+			entry.reason = fields[1]
+		}
+		var key [minPrefixLen]byte
+		copy(key[:], prefix)
+		list.entries[key] = append(list.entries[key], entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Join(ErrInvalidHashList, err)
+	}
+	return list, nil
+}
+
+// lookupExpressions раскладывает адрес на выражения хост/путь по правилам
+// Safe Browsing: хост без порта в нижнем регистре и его родительские домены
+// (кроме домена верхнего уровня), путь с запросом, путь без запроса и
+// каталоги от корня. Фрагмент, схема и порт в выражения не входят
+func lookupExpressions(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return nil
+	}
+
+	hosts := []string{host}
+	if _, err := netip.ParseAddr(host); err != nil {
+		labels := strings.Split(host, ".")
+		start := max(len(labels)-maxHosts, 1)
+		for i := start; i < len(labels)-1; i++ {
+			hosts = append(hosts, strings.Join(labels[i:], "."))
+		}
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	var paths []string
+	if u.RawQuery != "" {
+		paths = append(paths, path+"?"+u.RawQuery)
+	}
+	paths = append(paths, path)
+	dir := "/"
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if len(paths) >= maxPaths || dir == path {
+			break
+		}
+		paths = append(paths, dir)
+		dir += segment + "/"
+	}
+
+	seen := make(map[string]struct{}, len(hosts)*len(paths))
+	exprs := make([]string, 0, len(hosts)*len(paths))
+	for _, h := range hosts {
+		for _, p := range paths {
+			expr := h + p
+			if _, ok := seen[expr]; ok {
+				continue
+			}
+			seen[expr] = struct{}{}
+			exprs = append(exprs, expr)
+		}
+	}
+	return exprs
+}
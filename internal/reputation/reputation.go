@@ -0,0 +1,115 @@
+// Пакет reputation проверяет адреса назначения по спискам опасных сайтов:
+// локальному списку префиксов хешей в духе Safe Browsing и внешнему
+// сервису через вебхук. Проверки объединяются в Chain и кешируются Cache
+package reputation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+)
+
+const (
+	DefaultCacheTTL  = 10 * time.Minute
+	DefaultCacheSize = 10000
+)
+
+var ErrCheckFailed = errors.New("проверка репутации не выполнена")
+
+// Несколько проверок по очереди. Адрес опасен, если его отметила любая
+// из них; ошибка возвращается, только если ни одна проверка адрес не отметила
+type Chain []domain.ReputationChecker
+
+func (c Chain) Check(ctx context.Context, rawURL string) (domain.ReputationVerdict, error) {
+	var errs []error
+	for _, checker := range c {
+		verdict, err := checker.Check(ctx, rawURL)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if verdict.Flagged {
+			return verdict, nil
+		}
+	}
+	return domain.ReputationVerdict{}, errors.Join(errs...)
+}
+
+type cacheEntry struct {
+	verdict   domain.ReputationVerdict
+	expiresAt time.Time
+}
+
+// Кеш заключений проверки. Запоминаются только удачные проверки,
+// после ошибки адрес проверяется снова при следующем обращении
+type Cache struct {
+	checker    domain.ReputationChecker
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewCache оборачивает проверку кешем. Нулевые ttl и maxEntries
+// заменяются на DefaultCacheTTL и DefaultCacheSize
+func NewCache(checker domain.ReputationChecker, ttl time.Duration, maxEntries int) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheSize
+	}
+	return &Cache{
+		checker:    checker,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]cacheEntry),
+	}
+}
+
+func (c *Cache) Check(ctx context.Context, rawURL string) (domain.ReputationVerdict, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[rawURL]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiresAt) {
+		return entry.verdict, nil
+	}
+
+	verdict, err := c.checker.Check(ctx, rawURL)
+	if err != nil {
+		return domain.ReputationVerdict{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[rawURL] = cacheEntry{verdict: verdict, expiresAt: c.now().Add(c.ttl)}
+	return verdict, nil
+}
+
+// evict освобождает место: удаляет устаревшие записи, а если кеш
+// все еще заполнен больше чем на три четверти - произвольные. Вызывается под c.mu
+func (c *Cache) evict() {
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	excess := len(c.entries) - c.maxEntries*3/4
+	for key := range c.entries {
+		if excess <= 0 {
+			break
+		}
+		delete(c.entries, key)
+		excess--
+	}
+}
@@ -0,0 +1,194 @@
+package reputation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashPrefix(expr string, n int) string {
+	sum := sha256.Sum256([]byte(expr))
+	return hex.EncodeToString(sum[:n])
+}
+
+func TestLookupExpressions(t *testing.T) {
+	got := lookupExpressions("http://a.b.c.d.e.f.g:8080/1/2.html?param=1#frag")
+	assert.Equal(t, []string{
+		"a.b.c.d.e.f.g/1/2.html?param=1", "a.b.c.d.e.f.g/1/2.html", "a.b.c.d.e.f.g/", "a.b.c.d.e.f.g/1/",
+		"c.d.e.f.g/1/2.html?param=1", "c.d.e.f.g/1/2.html", "c.d.e.f.g/", "c.d.e.f.g/1/",
+		"d.e.f.g/1/2.html?param=1", "d.e.f.g/1/2.html", "d.e.f.g/", "d.e.f.g/1/",
+		"e.f.g/1/2.html?param=1", "e.f.g/1/2.html", "e.f.g/", "e.f.g/1/",
+		"f.g/1/2.html?param=1", "f.g/1/2.html", "f.g/", "f.g/1/",
+	}, got)
+
+	assert.Equal(t, []string{"1.2.3.4/"}, lookupExpressions("https://1.2.3.4"))
+}
+
+func TestHashList_Check(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "hashes.txt")
+	content := "# тестовый список\n" +
+		hashPrefix("evil.example/", 4) + " malware\n" +
+		hashPrefix("good.example/phish/", sha256.Size) + " phishing # весь каталог\n" +
+		hashPrefix("bad.example/", 8) + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	list, err := NewHashList(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		url    string
+		reason string
+	}{
+		{"https://evil.example/", "malware"},
+		{"https://www.EVIL.example/any/path?x=1", "malware"},
+		{"https://good.example/phish/login.html", "phishing"},
+		{"https://good.example/phish", ""},
+		{"https://good.example/", ""},
+		{"http://bad.example:8080/", "listed"},
+		{"https://notevil.example/", ""},
+	}
+	for _, tt := range tests {
+		verdict, err := list.Check(ctx, tt.url)
+		require.NoError(t, err)
+		assert.Equal(t, tt.reason != "", verdict.Flagged, tt.url)
+		assert.Equal(t, tt.reason, verdict.Reason, tt.url)
+	}
+}
+
+func TestHashList_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hashes.txt")
+	for _, content := range []string{
+		"zzzzzzzz",
+		"abcdef",
+		"5e2bf57d malware extra",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		_, err := NewHashList(path)
+		assert.True(t, errors.Is(err, ErrInvalidHashList), content)
+	}
+}
+
+func TestWebhook_Check(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			URL string `json:"url"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.URL {
+		case "https://phish.example/":
+			w.Write([]byte(`{"flagged": true, "reason": "phishing"}`))
+		case "https://slow.example/":
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte(`{"flagged": false}`))
+		case "https://broken.example/":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte(`{"flagged": false}`))
+		}
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook(server.URL, "secret", 100*time.Millisecond)
+
+	verdict, err := webhook.Check(ctx, "https://phish.example/")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ReputationVerdict{Flagged: true, Reason: "phishing"}, verdict)
+
+	verdict, err = webhook.Check(ctx, "https://example.com/")
+	require.NoError(t, err)
+	assert.False(t, verdict.Flagged)
+
+	_, err = webhook.Check(ctx, "https://broken.example/")
+	assert.True(t, errors.Is(err, ErrCheckFailed))
+
+	_, err = webhook.Check(ctx, "https://slow.example/")
+	assert.True(t, errors.Is(err, ErrCheckFailed))
+
+	_, err = NewWebhook(server.URL, "wrong", 0).Check(ctx, "https://example.com/")
+	assert.True(t, errors.Is(err, ErrCheckFailed))
+}
+
+type countingChecker struct {
+	calls   atomic.Int32
+	verdict domain.ReputationVerdict
+	err     error
+}
+
+func (c *countingChecker) Check(ctx context.Context, rawURL string) (domain.ReputationVerdict, error) {
+	c.calls.Add(1)
+	return c.verdict, c.err
+}
+
+func TestCache_Check(t *testing.T) {
+	ctx := context.Background()
+	checker := &countingChecker{verdict: domain.ReputationVerdict{Flagged: true, Reason: "malware"}}
+	cache := NewCache(checker, time.Minute, 2)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		verdict, err := cache.Check(ctx, "https://evil.example/")
+		require.NoError(t, err)
+		assert.True(t, verdict.Flagged)
+	}
+	assert.Equal(t, int32(1), checker.calls.Load())
+
+	now = now.Add(2 * time.Minute)
+	_, err := cache.Check(ctx, "https://evil.example/")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), checker.calls.Load())
+
+	// ошибки не кешируются
+	checker.err = ErrCheckFailed
+	for i := 0; i < 2; i++ {
+		_, err := cache.Check(ctx, "https://other.example/")
+		assert.True(t, errors.Is(err, ErrCheckFailed))
+	}
+	assert.Equal(t, int32(4), checker.calls.Load())
+
+	checker.err = nil
+	for _, u := range []string{"https://a.example/", "https://b.example/", "https://c.example/"} {
+		_, err := cache.Check(ctx, u)
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, len(cache.entries), 2)
+}
+
+func TestChain_Check(t *testing.T) {
+	ctx := context.Background()
+	failing := &countingChecker{err: ErrCheckFailed}
+	clean := &countingChecker{}
+	flagging := &countingChecker{verdict: domain.ReputationVerdict{Flagged: true, Reason: "phishing"}}
+
+	verdict, err := Chain{failing, flagging}.Check(ctx, "https://phish.example/")
+	require.NoError(t, err)
+	assert.True(t, verdict.Flagged)
+
+	_, err = Chain{clean, failing}.Check(ctx, "https://phish.example/")
+	assert.True(t, errors.Is(err, ErrCheckFailed))
+
+	verdict, err = Chain{clean}.Check(ctx, "https://example.com/")
+	require.NoError(t, err)
+	assert.False(t, verdict.Flagged)
+}
@@ -0,0 +1,73 @@
+package reputation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+)
+
+const (
+	DefaultWebhookTimeout = 2 * time.Second
+	maxWebhookResponse    = 64 << 10
+)
+
+// Проверка адреса внешним сервисом. Сервис получает POST с телом
+// {"url": "..."} и отвечает {"flagged": true, "reason": "phishing"}.
+// Ответ с кодом не из 2xx считается сбоем проверки
+type Webhook struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+// NewWebhook создает проверку через вебхук. Непустой token передается
+// в заголовке Authorization: Bearer; нулевой timeout заменяется на DefaultWebhookTimeout
+func NewWebhook(endpoint, token string, timeout time.Duration) *Webhook {
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	return &Webhook{
+		endpoint: endpoint,
+		token:    token,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (w *Webhook) Check(ctx context.Context, rawURL string) (domain.ReputationVerdict, error) {
+	body, err := json.Marshal(struct {
+		URL string `json:"url"`
+	}{rawURL})
+	if err != nil {
+		return domain.ReputationVerdict{}, errors.Join(ErrCheckFailed, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return domain.ReputationVerdict{}, errors.Join(ErrCheckFailed, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return domain.ReputationVerdict{}, errors.Join(ErrCheckFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return domain.ReputationVerdict{}, fmt.Errorf("%w: вебхук ответил %d", ErrCheckFailed, resp.StatusCode)
+	}
+
+	var verdict domain.ReputationVerdict
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxWebhookResponse)).Decode(&verdict); err != nil {
+		return domain.ReputationVerdict{}, errors.Join(ErrCheckFailed, err)
+	}
+	return verdict, nil
+}
@@ -3,31 +3,83 @@ package router
 import (
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
 	"github.com/physicist2018/url-shortener-go/internal/handler"
 	"github.com/physicist2018/url-shortener-go/internal/middlewares/authenticator"
 	"github.com/physicist2018/url-shortener-go/internal/middlewares/compressor"
 	"github.com/physicist2018/url-shortener-go/internal/middlewares/httplogger"
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/ratelimit"
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/requestid"
 	"github.com/rs/zerolog"
 )
 
-func NewRouter(linkHandler *handler.URLLinkHandler, logger zerolog.Logger) *chi.Mux {
+func NewRouter(linkHandler *handler.URLLinkHandler, apiKeyHandler *handler.APIKeyHandler, userHandler *handler.UserHandler, workspaceHandler *handler.WorkspaceHandler, adminHandler *handler.AdminHandler, auth *authenticator.Authenticator, limiter *ratelimit.Limiter, logger zerolog.Logger) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Мидлвары
+	r.Use(requestid.Middleware)
 	r.Use(httplogger.LoggerMiddleware(&logger))
 
 	r.Use(compressor.RequestDecompressionMiddleware)
 	r.Use(compressor.ResponseCompressionMiddleware(compressor.BestCompression))
-	r.Use(middleware.AllowContentType("text/plain", "application/json", "text/html", "application/x-gzip"))
+	r.Use(middleware.AllowContentType("text/plain", "application/json", "text/html", "application/x-gzip", "application/x-www-form-urlencoded"))
 	r.Use(middleware.Recoverer)
 
-	// Маршруты
-	r.Post("/", authenticator.AuthMiddlewareFunc(linkHandler.ShortenURL))
-	r.Post("/api/shorten", authenticator.AuthMiddlewareFunc(linkHandler.HandleGenerateShortURLJson))
-	r.Post("/api/shorten/batch", authenticator.AuthMiddlewareFunc(linkHandler.HandleGenerateShortURLJsonBatch))
-	r.Get("/{shortURL}", linkHandler.Redirect)
+	// Маршруты. Для запросов с API-ключом проверяются права ключа.
+	// Частота создания ссылок и переходов ограничивается, limiter может быть nil
+	r.Post("/", auth.AuthMiddlewareFunc(limiter.Route(ratelimit.RouteCreate, authenticator.RequireScope(domain.ScopeLinksCreate, linkHandler.ShortenURL))))
+	r.Post("/api/shorten", auth.AuthMiddlewareFunc(limiter.Route(ratelimit.RouteCreate, authenticator.RequireScope(domain.ScopeLinksCreate, linkHandler.HandleGenerateShortURLJson))))
+	r.Post("/api/shorten/batch", auth.AuthMiddlewareFunc(limiter.Route(ratelimit.RouteBatch, authenticator.RequireScope(domain.ScopeLinksCreate, linkHandler.HandleGenerateShortURLJsonBatch))))
+	// POST - отправка формы пароля защищенной ссылки, неверные пароли ограничиваются отдельно
+	redirect := limiter.Route(ratelimit.RouteRedirect, limiter.Failures(ratelimit.RoutePassword, linkHandler.Redirect))
+	r.Get("/{shortURL}", redirect)
+	r.Post("/{shortURL}", redirect)
 	r.Get("/ping", linkHandler.PingHandler)
-	r.Get("/api/user/urls", authenticator.AuthMiddlewareFunc(linkHandler.HandleGetAllShortedURLsForUserJSON))
-	r.Delete("/api/user/urls", authenticator.AuthMiddlewareFunc(linkHandler.HandleDeleteShortedURLsForUserJSON))
+	r.Get("/api/user/urls", auth.RequireAuthMiddlewareFunc(authenticator.RequireScope(domain.ScopeLinksRead, linkHandler.HandleGetAllShortedURLsForUserJSON)))
+	r.Delete("/api/user/urls", auth.RequireAuthMiddlewareFunc(authenticator.RequireScope(domain.ScopeLinksDelete, linkHandler.HandleDeleteShortedURLsForUserJSON)))
+	r.Patch("/api/user/urls/{shortURL}", auth.RequireAuthMiddlewareFunc(authenticator.RequireScope(domain.ScopeLinksUpdate, linkHandler.HandleUpdateShortedURLForUserJSON)))
+	r.Get("/api/user/urls/{shortURL}/versions", auth.RequireAuthMiddlewareFunc(authenticator.RequireScope(domain.ScopeLinksRead, linkHandler.HandleGetShortedURLVersionsForUserJSON)))
+	r.Get("/api/user/quota", auth.RequireAuthMiddlewareFunc(authenticator.RequireScope(domain.ScopeStatsRead, linkHandler.HandleGetQuotaJSON)))
+
+	// Учетные записи. Перенос ссылок требует текущей анонимной сессии
+	r.Post("/api/user/register", userHandler.HandleRegister)
+	r.Post("/api/user/login", limiter.Failures(ratelimit.RouteLogin, userHandler.HandleLogin))
+	// Вход через SSO, ручки отвечают 404, если OIDC не настроен
+	r.Get("/api/auth/oidc/login", auth.OIDCLogin)
+	r.Get("/api/auth/oidc/callback", auth.OIDCCallback)
+	r.Post("/api/user/claim", auth.RequireAuthMiddlewareFunc(authenticator.RequireSession(limiter.Failures(ratelimit.RouteLogin, userHandler.HandleClaim))))
+
+	// Управление API-ключами доступно только из сессии пользователя
+	r.Post("/api/user/keys", auth.RequireAuthMiddlewareFunc(authenticator.RequireSession(apiKeyHandler.HandleCreateAPIKey)))
+	r.Get("/api/user/keys", auth.RequireAuthMiddlewareFunc(authenticator.RequireSession(apiKeyHandler.HandleListAPIKeys)))
+	r.Delete("/api/user/keys/{id}", auth.RequireAuthMiddlewareFunc(authenticator.RequireSession(apiKeyHandler.HandleRevokeAPIKey)))
+
+	// Рабочие пространства. Составом и переносом ссылок управляют из сессии,
+	// со ссылками пространства можно работать и по API-ключу
+	r.Post("/api/workspaces", auth.RequireAuthMiddlewareFunc(authenticator.RequireSession(workspaceHandler.HandleCreateWorkspace)))
+	r.Get("/api/workspaces", auth.RequireAuthMiddlewareFunc(authenticator.RequireSession(workspaceHandler.HandleListWorkspaces)))
+	r.Get("/api/workspaces/{id}/members", auth.RequireAuthMiddlewareFunc(authenticator.RequireSession(workspaceHandler.HandleListMembers)))
+	r.Put("/api/workspaces/{id}/members/{userID}", auth.RequireAuthMiddlewareFunc(authenticator.RequireSession(workspaceHandler.HandleSetMember)))
+	r.Delete("/api/workspaces/{id}/members/{userID}", auth.RequireAuthMiddlewareFunc(authenticator.RequireSession(workspaceHandler.HandleRemoveMember)))
+	r.Post("/api/workspaces/{id}/links", auth.RequireAuthMiddlewareFunc(limiter.Route(ratelimit.RouteCreate, authenticator.RequireScope(domain.ScopeLinksCreate, workspaceHandler.HandleCreateLink))))
+	r.Get("/api/workspaces/{id}/links", auth.RequireAuthMiddlewareFunc(authenticator.RequireScope(domain.ScopeLinksRead, workspaceHandler.HandleListLinks)))
+	r.Delete("/api/workspaces/{id}/links", auth.RequireAuthMiddlewareFunc(authenticator.RequireScope(domain.ScopeLinksDelete, workspaceHandler.HandleDeleteLinks)))
+	r.Put("/api/user/urls/{shortURL}/workspace", auth.RequireAuthMiddlewareFunc(authenticator.RequireSession(workspaceHandler.HandleMoveLink)))
+
+	// Административный API для операторов, каждое действие пишется в журнал аудита
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(auth.RequireAdmin)
+		r.Get("/links/{shortURL}", adminHandler.HandleLookupLink)
+		r.Delete("/links/{shortURL}", adminHandler.HandleDeleteLink)
+		r.Post("/links/{shortURL}/restore", adminHandler.HandleRestoreLink)
+		r.Post("/links/{shortURL}/purge", adminHandler.HandlePurgeLink)
+		r.Get("/users/{userID}/links", adminHandler.HandleListUserLinks)
+		r.Get("/blocked-domains", adminHandler.HandleListBlockedDomains)
+		r.Post("/blocked-domains", adminHandler.HandleBlockDomain)
+		r.Delete("/blocked-domains/{domain}", adminHandler.HandleUnblockDomain)
+		r.Get("/stats", adminHandler.HandleStats)
+		r.Get("/audit", adminHandler.HandleAuditEntries)
+		r.Get("/audit/verify", adminHandler.HandleVerifyAudit)
+	})
 	return r
 }
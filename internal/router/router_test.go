@@ -0,0 +1,331 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/internal/deleter"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/handler"
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/authenticator"
+	"github.com/physicist2018/url-shortener-go/internal/middlewares/ratelimit"
+	"github.com/physicist2018/url-shortener-go/internal/mocks"
+)
+
+// Заглушка хранилища API-ключей: ключ совпадает с идентификатором
+type stubAPIKeys map[string]domain.APIKey
+
+func (s stubAPIKeys) ResolveAPIKey(_ context.Context, rawKey string) (domain.APIKey, error) {
+	if k, ok := s[rawKey]; ok {
+		return k, nil
+	}
+	return domain.APIKey{}, errors.New("not found")
+}
+
+func newTestRouter(t *testing.T, mockService *mocks.MockURLLinkService, apiKeys ...domain.APIKey) http.Handler {
+	return newTestRouterWithWorkspaces(t, mockService, mocks.NewMockWorkspaceService(gomock.NewController(t)), apiKeys...)
+}
+
+func newTestRouterWithWorkspaces(t *testing.T, mockService *mocks.MockURLLinkService, workspaceService *mocks.MockWorkspaceService, apiKeys ...domain.APIKey) http.Handler {
+	return newTestRouterWithServices(t, mockService, workspaceService, mocks.NewMockAdminService(gomock.NewController(t)), apiKeys...)
+}
+
+// Административный ключ тестового роутера
+const testAdminKey = "0123456789abcdef01234567"
+
+func newTestRouterWithServices(t *testing.T, mockService *mocks.MockURLLinkService, workspaceService *mocks.MockWorkspaceService, adminService *mocks.MockAdminService, apiKeys ...domain.APIKey) http.Handler {
+	return newTestRouterWithLimiter(t, mockService, workspaceService, adminService, nil, apiKeys...)
+}
+
+func newTestRouterWithLimiter(t *testing.T, mockService *mocks.MockURLLinkService, workspaceService *mocks.MockWorkspaceService, adminService *mocks.MockAdminService, limiter *ratelimit.Limiter, apiKeys ...domain.APIKey) http.Handler {
+	logger := zerolog.New(nil)
+	linkDeleter := deleter.NewDeleter(mockService, logger)
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	linkDeleter.Start(ctx, &wg)
+	linkHandler := handler.NewURLLinkHandler(mockService, "http://localhost", logger, linkDeleter)
+	t.Cleanup(func() {
+		linkHandler.Close()
+		cancel()
+		wg.Wait()
+	})
+
+	key, err := authenticator.GenerateKey("test", time.Now())
+	require.NoError(t, err)
+	keySet, err := authenticator.NewKeySet(key)
+	require.NoError(t, err)
+	auth := authenticator.NewAuthenticator(keySet, authenticator.DefaultCookieOptions())
+	resolver := stubAPIKeys{}
+	for _, k := range apiKeys {
+		resolver[k.ID] = k
+	}
+	auth.SetAPIKeyResolver(resolver)
+	adminKeys, err := authenticator.ParseAdminKeys("ops:" + testAdminKey)
+	require.NoError(t, err)
+	auth.SetAdmins(authenticator.AdminOptions{Keys: adminKeys})
+
+	apiKeyHandler := handler.NewAPIKeyHandler(mocks.NewMockAPIKeyService(gomock.NewController(t)), logger)
+
+	userHandler := handler.NewUserHandler(mocks.NewMockUserService(gomock.NewController(t)), auth, logger)
+
+	workspaceHandler := handler.NewWorkspaceHandler(workspaceService, "http://localhost", logger)
+
+	adminHandler := handler.NewAdminHandler(adminService, logger)
+
+	return NewRouter(linkHandler, apiKeyHandler, userHandler, workspaceHandler, adminHandler, auth, limiter, logger)
+}
+
+func TestNewRouter_CreateIssuesIdentity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	r := newTestRouter(t, mockService)
+
+	mockService.EXPECT().
+		CreateShortURL(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, link domain.URLLink) (domain.URLLink, error) {
+			assert.NotEmpty(t, link.UserID)
+			link.ShortURL = "abc12"
+			return link, nil
+		})
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("https://example.com"))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Len(t, w.Result().Cookies(), 1)
+}
+
+func TestNewRouter_UserEndpointsRequireIdentity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	r := newTestRouter(t, mockService)
+
+	// без куки сервис вызываться не должен
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/api/user/urls", bytes.NewBufferString(`["abc12"]`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, method)
+		assert.Empty(t, w.Result().Cookies(), method)
+	}
+}
+
+func TestNewRouter_UserEndpointsWithIdentity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	r := newTestRouter(t, mockService)
+
+	var userID string
+	mockService.EXPECT().
+		CreateShortURL(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, link domain.URLLink) (domain.URLLink, error) {
+			userID = link.UserID
+			link.ShortURL = "abc12"
+			return link, nil
+		})
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("https://example.com"))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Len(t, w.Result().Cookies(), 1)
+	session := w.Result().Cookies()[0]
+
+	mockService.EXPECT().
+		FindAll(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, id string) ([]domain.URLLink, error) {
+			assert.Equal(t, userID, id)
+			return []domain.URLLink{{UserID: id, ShortURL: "abc12", LongURL: "https://example.com"}}, nil
+		})
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestNewRouter_APIKeyScopes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockURLLinkService(ctrl)
+	readOnly := domain.APIKey{ID: "sk_read", UserID: "machine", Scopes: []string{domain.ScopeLinksRead}}
+	r := newTestRouter(t, mockService, readOnly)
+
+	mockService.EXPECT().
+		FindAll(gomock.Any(), "machine").
+		Return([]domain.URLLink{{UserID: "machine", ShortURL: "abc12", LongURL: "https://example.com"}}, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"read allowed", http.MethodGet, "/api/user/urls", "", http.StatusOK},
+		{"create forbidden", http.MethodPost, "/", "https://example.com", http.StatusForbidden},
+		{"delete forbidden", http.MethodDelete, "/api/user/urls", `["abc12"]`, http.StatusForbidden},
+		{"update forbidden", http.MethodPatch, "/api/user/urls/abc12", `{"url":"https://example.org"}`, http.StatusForbidden},
+		{"key management forbidden", http.MethodGet, "/api/user/keys", "", http.StatusForbidden},
+		{"quota needs stats scope", http.MethodGet, "/api/user/quota", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "text/plain")
+			req.Header.Set(authenticator.APIKeyHeader, readOnly.ID)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+			assert.Empty(t, w.Result().Cookies())
+		})
+	}
+
+	t.Run("unknown key", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("https://example.com"))
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set(authenticator.APIKeyHeader, "sk_unknown")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+	})
+}
+
+func TestNewRouter_OIDCDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	r := newTestRouter(t, mocks.NewMockURLLinkService(ctrl))
+
+	for _, target := range []string{"/api/auth/oidc/login", "/api/auth/oidc/callback?code=abc&state=xyz"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, target)
+	}
+}
+
+func TestNewRouter_WorkspaceAPIKeyScopes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	workspaceService := mocks.NewMockWorkspaceService(ctrl)
+	readOnly := domain.APIKey{ID: "sk_read", UserID: "machine", Scopes: []string{domain.ScopeLinksRead}}
+	r := newTestRouterWithWorkspaces(t, mocks.NewMockURLLinkService(ctrl), workspaceService, readOnly)
+
+	workspaceService.EXPECT().
+		ListLinks(gomock.Any(), "machine", "ws-1").
+		Return([]domain.URLLink{{UserID: "user-1", WorkspaceID: "ws-1", ShortURL: "abc12", LongURL: "https://example.com"}}, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"read links allowed", http.MethodGet, "/api/workspaces/ws-1/links", "", http.StatusOK},
+		{"create link forbidden", http.MethodPost, "/api/workspaces/ws-1/links", `{"url":"https://example.com"}`, http.StatusForbidden},
+		{"delete links forbidden", http.MethodDelete, "/api/workspaces/ws-1/links", `["abc12"]`, http.StatusForbidden},
+		{"member management forbidden", http.MethodPut, "/api/workspaces/ws-1/members/user-2", `{"role":"viewer"}`, http.StatusForbidden},
+		{"move forbidden", http.MethodPut, "/api/user/urls/abc12/workspace", `{"workspace_id":"ws-1"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(authenticator.APIKeyHeader, readOnly.ID)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestNewRouter_AdminAccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	adminService := mocks.NewMockAdminService(ctrl)
+	fullAccess := domain.APIKey{ID: "sk_full", UserID: "machine", Scopes: domain.APIKeyScopes}
+	r := newTestRouterWithServices(t, mocks.NewMockURLLinkService(ctrl), mocks.NewMockWorkspaceService(ctrl), adminService, fullAccess)
+
+	adminService.EXPECT().Stats(gomock.Any(), "key:ops").Return(domain.SystemStats{Links: 3}, nil)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"admin key", authenticator.AdminKeyHeader, testAdminKey, http.StatusOK},
+		{"wrong admin key", authenticator.AdminKeyHeader, "sk_full", http.StatusUnauthorized},
+		{"user API key forbidden", authenticator.APIKeyHeader, fullAccess.ID, http.StatusForbidden},
+		{"no credentials", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+			assert.Empty(t, w.Result().Cookies())
+		})
+	}
+}
+
+// Запросы без куки получают новую личность каждый раз, поэтому считаются
+// по адресу клиента, а API-ключ расходует свою корзину
+func TestNewRouter_RateLimit(t *testing.T) {
+	mockService := mocks.NewMockURLLinkService(gomock.NewController(t))
+	limits, err := ratelimit.ParseLimits("create=2/m,redirect=1/m")
+	require.NoError(t, err)
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), limits, zerolog.New(nil))
+	key := domain.APIKey{ID: "key1", UserID: "u1", Scopes: []string{domain.ScopeLinksCreate}}
+	r := newTestRouterWithLimiter(t, mockService, mocks.NewMockWorkspaceService(gomock.NewController(t)),
+		mocks.NewMockAdminService(gomock.NewController(t)), limiter, key)
+
+	mockService.EXPECT().CreateShortURL(gomock.Any(), gomock.Any()).Return(domain.URLLink{ShortURL: "abc12"}, nil).Times(3)
+	mockService.EXPECT().GetOriginalURL(gomock.Any(), gomock.Any()).Return(domain.URLLink{LongURL: "https://example.com"}, nil)
+
+	create := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("https://example.com"))
+		req.Header.Set("Content-Type", "text/plain")
+		if apiKey != "" {
+			req.Header.Set(authenticator.APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusCreated, create("").Code)
+	assert.Equal(t, http.StatusCreated, create("").Code)
+	limited := create("")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "30", limited.Header().Get("Retry-After"))
+	assert.Equal(t, "0", limited.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, http.StatusCreated, create("key1").Code)
+
+	for _, want := range []int{http.StatusTemporaryRedirect, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abc12", nil))
+		assert.Equal(t, want, w.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/rs/zerolog"
+)
+
+// Административные операции над данными всех пользователей.
+// Каждый вызов, успешный или нет, попадает в журнал аудита
+type AdminService struct {
+	log       zerolog.Logger
+	admin     domain.AdminRepo
+	audit     *AuditLog
+	users     domain.UserRepo
+	links     domain.URLLinkRepo
+	blocklist *DomainBlocklist
+	now       func() time.Time
+}
+
+// NewAdminService создает сервис операторов. links должен быть тем же
+// декорированным репозиторием, что и у остальных сервисов, чтобы изменения сбрасывали кэши
+func NewAdminService(admin domain.AdminRepo, audit *AuditLog, users domain.UserRepo, links domain.URLLinkRepo, blocklist *DomainBlocklist, logger zerolog.Logger) *AdminService {
+	return &AdminService{
+		log:       logger,
+		admin:     admin,
+		audit:     audit,
+		users:     users,
+		links:     links,
+		blocklist: blocklist,
+		now:       time.Now,
+	}
+}
+
+// LookupLink находит ссылку по коду вместе с логином владельца
+func (s *AdminService) LookupLink(ctx context.Context, actor, shortURL string) (domain.AdminLinkInfo, error) {
+	info, err := s.lookupLink(ctx, shortURL)
+	return info, s.record(ctx, domain.AuditEntry{Actor: actor, Action: domain.AuditLinkLookup, Target: shortURL}, err)
+}
+
+func (s *AdminService) lookupLink(ctx context.Context, shortURL string) (domain.AdminLinkInfo, error) {
+	link, err := s.findLink(ctx, shortURL)
+	if err != nil {
+		return domain.AdminLinkInfo{}, err
+	}
+	info := domain.AdminLinkInfo{URLLink: link}
+	user, err := s.users.FindUserByID(ctx, link.UserID)
+	switch {
+	case err == nil:
+		info.OwnerLogin = user.Login
+	case !errors.Is(err, repoerrors.ErrorUserNotFound):
+		return domain.AdminLinkInfo{}, err
+	}
+	return info, nil
+}
+
+// ListUserLinks возвращает все ссылки автора, включая ссылки пространств и удаленные
+func (s *AdminService) ListUserLinks(ctx context.Context, actor, userID string) ([]domain.URLLink, error) {
+	links, err := s.admin.FindUserLinks(ctx, userID)
+	return links, s.record(ctx, domain.AuditEntry{Actor: actor, Action: domain.AuditUserLinks, Target: userID}, err)
+}
+
+// DeleteLink помечает ссылку удаленной независимо от владельца
+func (s *AdminService) DeleteLink(ctx context.Context, actor, shortURL string) error {
+	return s.setDeleted(ctx, domain.AuditEntry{Actor: actor, Action: domain.AuditLinkDelete, Target: shortURL}, true)
+}
+
+// RestoreLink снимает пометку удаления
+func (s *AdminService) RestoreLink(ctx context.Context, actor, shortURL string) error {
+	return s.setDeleted(ctx, domain.AuditEntry{Actor: actor, Action: domain.AuditLinkRestore, Target: shortURL}, false)
+}
+
+func (s *AdminService) setDeleted(ctx context.Context, entry domain.AuditEntry, deleted bool) error {
+	link, err := s.findLink(ctx, entry.Target)
+	if err == nil {
+		err = s.links.SetDeleted(ctx, entry.Target, deleted)
+		if errors.Is(err, repoerrors.ErrorShortLinkNotFound) {
+			err = ErrLinkNotFound
+		}
+	}
+	if err == nil {
+		entry.Before = auditState(link)
+		link.DeletedFlag = deleted
+		entry.After = auditState(link)
+	}
+	return s.record(ctx, entry, err)
+}
+
+// PurgeLink удаляет ссылку безвозвратно. В журнал попадает ее последнее
+// состояние, иначе после удаления адрес назначения будет не узнать
+func (s *AdminService) PurgeLink(ctx context.Context, actor, shortURL string) error {
+	entry := domain.AuditEntry{Actor: actor, Action: domain.AuditLinkPurge, Target: shortURL}
+	link, err := s.findLink(ctx, shortURL)
+	if err == nil {
+		entry.Before = auditState(link)
+		err = s.links.PurgeLink(ctx, shortURL)
+		if errors.Is(err, repoerrors.ErrorShortLinkNotFound) {
+			err = ErrLinkNotFound
+		}
+	}
+	return s.record(ctx, entry, err)
+}
+
+// BlockDomain запрещает ссылки на домен и его поддомены
+func (s *AdminService) BlockDomain(ctx context.Context, actor, domainName, reason string) (domain.DomainBlock, error) {
+	block, err := s.blocklist.Block(ctx, domain.DomainBlock{
+		Domain:    domainName,
+		Reason:    reason,
+		CreatedBy: actor,
+		CreatedAt: s.now(),
+	})
+	if err == nil {
+		domainName = block.Domain
+	}
+	return block, s.record(ctx, domain.AuditEntry{Actor: actor, Action: domain.AuditDomainBlock, Target: domainName, Details: reason}, err)
+}
+
+func (s *AdminService) UnblockDomain(ctx context.Context, actor, domainName string) error {
+	err := s.blocklist.Unblock(ctx, domainName)
+	return s.record(ctx, domain.AuditEntry{Actor: actor, Action: domain.AuditDomainUnblock, Target: domainName}, err)
+}
+
+func (s *AdminService) ListBlockedDomains(ctx context.Context, actor string) ([]domain.DomainBlock, error) {
+	blocks, err := s.blocklist.List(ctx)
+	return blocks, s.record(ctx, domain.AuditEntry{Actor: actor, Action: domain.AuditDomainList}, err)
+}
+
+func (s *AdminService) Stats(ctx context.Context, actor string) (domain.SystemStats, error) {
+	stats, err := s.admin.SystemStats(ctx)
+	return stats, s.record(ctx, domain.AuditEntry{Actor: actor, Action: domain.AuditStatsView}, err)
+}
+
+// AuditEntries возвращает страницу журнала аудита. Просмотр журнала тоже в него попадает
+func (s *AdminService) AuditEntries(ctx context.Context, actor string, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	entries, err := s.audit.Find(ctx, filter)
+	return entries, s.record(ctx, domain.AuditEntry{Actor: actor, Action: domain.AuditLogView}, err)
+}
+
+// VerifyAudit проверяет цепочку хэшей журнала аудита
+func (s *AdminService) VerifyAudit(ctx context.Context, actor string) (domain.AuditVerification, error) {
+	result, err := s.audit.Verify(ctx)
+	entry := domain.AuditEntry{Actor: actor, Action: domain.AuditLogVerify}
+	if err == nil && !result.Valid {
+		entry.Details = fmt.Sprintf("запись %d: %s", result.BrokenSeq, result.Problem)
+	}
+	return result, s.record(ctx, entry, err)
+}
+
+func (s *AdminService) findLink(ctx context.Context, shortURL string) (domain.URLLink, error) {
+	link, err := s.links.FindConsistent(ctx, shortURL)
+	if errors.Is(err, repoerrors.ErrorShortLinkNotFound) {
+		return domain.URLLink{}, ErrLinkNotFound
+	}
+	return link, err
+}
+
+// record пишет действие в журнал аудита и возвращает его ошибку
+func (s *AdminService) record(ctx context.Context, entry domain.AuditEntry, err error) error {
+	entry.Result = domain.AuditResultOK
+	if err != nil {
+		entry.Result = err.Error()
+	}
+	stored, auditErr := s.audit.Record(ctx, entry)
+	if auditErr != nil {
+		return errors.Join(auditErr, err)
+	}
+
+	s.log.Info().
+		Int64("seq", stored.Seq).
+		Str("actor", entry.Actor).
+		Str("action", entry.Action).
+		Str("target", entry.Target).
+		Str("result", entry.Result).
+		Msg("действие оператора")
+	return err
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/inmemory"
+	"github.com/physicist2018/url-shortener-go/internal/stringgenstrategy"
+	"github.com/physicist2018/url-shortener-go/pkg/uniquestring"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAdminService(t *testing.T) (*AdminService, *URLLinkService, *inmemory.InMemoryLinkRepository) {
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	blocklist := NewDomainBlocklist(repo, time.Hour, zerolog.New(nil))
+	audit := NewAuditLog(repo, zerolog.New(nil))
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+	shortener.SetBlocklist(blocklist)
+	shortener.SetAuditLog(audit)
+	return NewAdminService(repo, audit, repo, repo, blocklist, zerolog.New(nil)), shortener, repo
+}
+
+// записи журнала аудита от имени actor
+func auditLog(t *testing.T, repo domain.AuditRepo, actor string) []domain.AuditEntry {
+	entries, err := repo.FindAuditEntries(context.Background(), domain.AuditFilter{Limit: 100, Actor: actor})
+	require.NoError(t, err)
+	return entries
+}
+
+func TestAdminService_Links(t *testing.T) {
+	ctx := context.Background()
+	s, _, repo := newTestAdminService(t)
+
+	require.NoError(t, repo.StoreUser(ctx, domain.User{ID: "u1", Login: "alice", CreatedAt: time.Now()}))
+	_, err := repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com/a"})
+	require.NoError(t, err)
+	_, err = repo.Store(ctx, domain.URLLink{UserID: "anon", ShortURL: "xyz34", LongURL: "https://example.com/b"})
+	require.NoError(t, err)
+
+	info, err := s.LookupLink(ctx, "key:ops", "abc12")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", info.OwnerLogin)
+	assert.Equal(t, "https://example.com/a", info.LongURL)
+
+	info, err = s.LookupLink(ctx, "key:ops", "xyz34")
+	require.NoError(t, err)
+	assert.Empty(t, info.OwnerLogin)
+
+	_, err = s.LookupLink(ctx, "key:ops", "missing")
+	assert.True(t, errors.Is(err, ErrLinkNotFound))
+
+	require.NoError(t, s.DeleteLink(ctx, "key:ops", "abc12"))
+	links, err := s.ListUserLinks(ctx, "key:ops", "u1")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.True(t, links[0].DeletedFlag)
+
+	require.NoError(t, s.RestoreLink(ctx, "key:ops", "abc12"))
+	found, err := repo.Find(ctx, "abc12")
+	require.NoError(t, err)
+	assert.False(t, found.DeletedFlag)
+
+	require.NoError(t, s.PurgeLink(ctx, "user:root", "abc12"))
+	assert.True(t, errors.Is(s.PurgeLink(ctx, "user:root", "abc12"), ErrLinkNotFound))
+
+	// каждое обращение, включая неудачные, оставляет запись в журнале
+	entries := auditLog(t, repo, "key:ops")
+	require.Len(t, entries, 6)
+	assert.Equal(t, domain.AuditLinkLookup, entries[2].Action)
+	assert.Equal(t, ErrLinkNotFound.Error(), entries[2].Result)
+	assert.Equal(t, domain.AuditLinkDelete, entries[3].Action)
+	assert.Contains(t, entries[3].Before, `"is_deleted":false`)
+	assert.Contains(t, entries[3].After, `"is_deleted":true`)
+
+	entries = auditLog(t, repo, "user:root")
+	require.Len(t, entries, 2)
+	assert.Equal(t, domain.AuditLinkPurge, entries[0].Action)
+	assert.Contains(t, entries[0].Before, "https://example.com/a")
+	assert.Empty(t, entries[0].After)
+	assert.Equal(t, domain.AuditResultOK, entries[0].Result)
+	assert.Equal(t, ErrLinkNotFound.Error(), entries[1].Result)
+}
+
+func TestAdminService_BlockDomain(t *testing.T) {
+	ctx := context.Background()
+	s, shortener, repo := newTestAdminService(t)
+
+	existing, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://cdn.evil.example/x"})
+	require.NoError(t, err)
+
+	_, err = s.BlockDomain(ctx, "key:ops", "bad/domain", "")
+	assert.True(t, errors.Is(err, ErrInvalidDomain))
+
+	block, err := s.BlockDomain(ctx, "key:ops", " Evil.Example. ", "phishing")
+	require.NoError(t, err)
+	assert.Equal(t, "evil.example", block.Domain)
+	assert.Equal(t, "key:ops", block.CreatedBy)
+
+	// блокировка распространяется на поддомены и на уже созданные ссылки
+	_, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://www.evil.example/login"})
+	assert.True(t, errors.Is(err, ErrDomainBlocked))
+	_, err = shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: existing.ShortURL})
+	assert.True(t, errors.Is(err, ErrDomainBlocked))
+	_, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://notevil.example/"})
+	require.NoError(t, err)
+
+	blocks, err := s.ListBlockedDomains(ctx, "key:ops")
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+
+	require.NoError(t, s.UnblockDomain(ctx, "key:ops", "evil.example"))
+	assert.True(t, errors.Is(s.UnblockDomain(ctx, "key:ops", "evil.example"), ErrDomainNotBlocked))
+	_, err = shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: existing.ShortURL})
+	require.NoError(t, err)
+
+	stats, err := s.Stats(ctx, "key:ops")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Links)
+	assert.Zero(t, stats.BlockedDomains)
+
+	entries := auditLog(t, repo, "key:ops")
+	require.Len(t, entries, 6)
+	assert.Equal(t, "evil.example", entries[1].Target)
+	assert.Equal(t, "phishing", entries[1].Details)
+}
+
+// Хранилище аудита, которое не принимает записи
+type failingAuditRepo struct{ domain.AuditRepo }
+
+func (failingAuditRepo) AppendAudit(ctx context.Context, entry domain.AuditEntry) (domain.AuditEntry, error) {
+	return domain.AuditEntry{}, errors.New("disk full")
+}
+
+func TestAdminService_AuditFailure(t *testing.T) {
+	ctx := context.Background()
+	s, _, repo := newTestAdminService(t)
+	s.audit = NewAuditLog(failingAuditRepo{}, zerolog.New(nil))
+
+	_, err := repo.Store(ctx, domain.URLLink{UserID: "u1", ShortURL: "abc12", LongURL: "https://example.com"})
+	require.NoError(t, err)
+
+	err = s.DeleteLink(ctx, "key:ops", "abc12")
+	assert.True(t, errors.Is(err, ErrAuditFailed))
+
+	err = s.DeleteLink(ctx, "key:ops", "missing")
+	assert.True(t, errors.Is(err, ErrAuditFailed))
+	assert.True(t, errors.Is(err, ErrLinkNotFound))
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// префикс, по которому ключ легко узнать, например в логах или при утечке
+	apiKeyPrefix     = "sk_"
+	apiKeySecretSize = 32
+	// сколько символов ключа хранится открыто для отображения
+	apiKeyDisplayLen = len(apiKeyPrefix) + 8
+	maxAPIKeyNameLen = 128
+)
+
+var (
+	ErrInvalidAPIKey   = errors.New("некорректный или отозванный API-ключ")
+	ErrInvalidScope    = errors.New("неизвестное право API-ключа")
+	ErrInvalidKeyName  = errors.New("некорректное имя API-ключа")
+	ErrAPIKeyNotFound  = errors.New("API-ключ не найден")
+	ErrNoScopesGranted = errors.New("API-ключу не выдано ни одного права")
+)
+
+type APIKeyService struct {
+	log  zerolog.Logger
+	repo domain.APIKeyRepo
+	now  func() time.Time
+}
+
+func NewAPIKeyService(repo domain.APIKeyRepo, logger zerolog.Logger) *APIKeyService {
+	return &APIKeyService{
+		repo: repo,
+		log:  logger,
+		now:  time.Now,
+	}
+}
+
+// CreateAPIKey создает ключ с заданными правами. Значение ключа возвращается
+// только здесь, в хранилище попадает его хэш
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (domain.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) > maxAPIKeyNameLen {
+		return domain.APIKey{}, "", ErrInvalidKeyName
+	}
+	if len(scopes) == 0 {
+		return domain.APIKey{}, "", ErrNoScopesGranted
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(domain.APIKeyScopes, scope) {
+			return domain.APIKey{}, "", fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+
+	secret := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return domain.APIKey{}, "", err
+	}
+	rawKey := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	granted := slices.Clone(scopes)
+	slices.Sort(granted)
+
+	key := domain.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    rawKey[:apiKeyDisplayLen],
+		Hash:      hashAPIKey(rawKey),
+		Scopes:    slices.Compact(granted),
+		CreatedAt: s.now().UTC().Truncate(time.Second),
+	}
+	if err := s.repo.StoreAPIKey(ctx, key); err != nil {
+		return domain.APIKey{}, "", err
+	}
+
+	s.log.Info().Str("userID", userID).Str("keyID", key.ID).Strs("scopes", key.Scopes).Msg("Создан API-ключ")
+	return key, rawKey, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, userID string) ([]domain.APIKey, error) {
+	return s.repo.FindAPIKeys(ctx, userID)
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	err := s.repo.RevokeAPIKey(ctx, userID, id, s.now().UTC().Truncate(time.Second))
+	if errors.Is(err, repoerrors.ErrorAPIKeyNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	if err == nil {
+		s.log.Info().Str("userID", userID).Str("keyID", id).Msg("API-ключ отозван")
+	}
+	return err
+}
+
+// ResolveAPIKey находит действующий ключ по его значению
+func (s *APIKeyService) ResolveAPIKey(ctx context.Context, rawKey string) (domain.APIKey, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return domain.APIKey{}, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.FindAPIKeyByHash(ctx, hashAPIKey(rawKey))
+	if errors.Is(err, repoerrors.ErrorAPIKeyNotFound) {
+		return domain.APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+	if key.Revoked() {
+		return domain.APIKey{}, ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+// Ключ содержит 256 бит случайных данных, поэтому медленный хэш
+// для паролей не нужен: достаточно SHA-256
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/inmemory"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAPIKeyService(t *testing.T) *APIKeyService {
+	repo, err := inmemory.NewInMemoryAPIKeyRepository("")
+	require.NoError(t, err)
+	return NewAPIKeyService(repo, zerolog.New(nil))
+}
+
+func TestAPIKeyService_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAPIKeyService(t)
+
+	key, rawKey, err := s.CreateAPIKey(ctx, "user-1", "ci", []string{domain.ScopeLinksRead, domain.ScopeLinksCreate, domain.ScopeLinksRead})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rawKey, key.Prefix))
+	assert.NotContains(t, key.Hash, rawKey)
+	assert.Equal(t, []string{domain.ScopeLinksCreate, domain.ScopeLinksRead}, key.Scopes)
+
+	resolved, err := s.ResolveAPIKey(ctx, rawKey)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", resolved.UserID)
+
+	_, err = s.ResolveAPIKey(ctx, rawKey+"x")
+	assert.True(t, errors.Is(err, ErrInvalidAPIKey))
+
+	// отозвать чужой ключ нельзя
+	assert.True(t, errors.Is(s.RevokeAPIKey(ctx, "user-2", key.ID), ErrAPIKeyNotFound))
+
+	require.NoError(t, s.RevokeAPIKey(ctx, "user-1", key.ID))
+	_, err = s.ResolveAPIKey(ctx, rawKey)
+	assert.True(t, errors.Is(err, ErrInvalidAPIKey))
+
+	keys, err := s.ListAPIKeys(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.True(t, keys[0].Revoked())
+}
+
+func TestAPIKeyService_CreateValidation(t *testing.T) {
+	ctx := context.Background()
+	s := newTestAPIKeyService(t)
+
+	_, _, err := s.CreateAPIKey(ctx, "user-1", "ci", nil)
+	assert.True(t, errors.Is(err, ErrNoScopesGranted))
+
+	_, _, err = s.CreateAPIKey(ctx, "user-1", "ci", []string{"admin"})
+	assert.True(t, errors.Is(err, ErrInvalidScope))
+
+	_, _, err = s.CreateAPIKey(ctx, "user-1", strings.Repeat("x", maxAPIKeyNameLen+1), []string{domain.ScopeLinksRead})
+	assert.True(t, errors.Is(err, ErrInvalidKeyName))
+}
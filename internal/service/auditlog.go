@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+// сколько ждать запись в журнал аудита, если запрос уже отменен
+const auditWriteTimeout = 5 * time.Second
+
+// Размер страницы при чтении журнала аудита
+const (
+	DefaultAuditPageSize = 100
+	MaxAuditPageSize     = 1000
+)
+
+// Действие, след которого не удалось сохранить, считается неуспешным
+var ErrAuditFailed = errors.New("не удалось записать действие в журнал аудита")
+
+// Журнал аудита: изменения ссылок и действия операторов.
+// Записи связаны в цепочку хэшей, Verify находит измененные и удаленные записи
+type AuditLog struct {
+	log  zerolog.Logger
+	repo domain.AuditRepo
+	now  func() time.Time
+}
+
+func NewAuditLog(repo domain.AuditRepo, logger zerolog.Logger) *AuditLog {
+	return &AuditLog{
+		log:  logger,
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// Record дописывает событие в журнал, проставив время и идентификатор запроса.
+// Запись делается и после отмены запроса, чтобы действие не осталось без следа
+func (a *AuditLog) Record(ctx context.Context, entry domain.AuditEntry) (domain.AuditEntry, error) {
+	entry.CreatedAt = a.now().UTC()
+	if requestID, ok := ctx.Value(domain.RequestIDKey{}).(string); ok {
+		entry.RequestID = requestID
+	}
+
+	auditCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditWriteTimeout)
+	defer cancel()
+	stored, err := a.repo.AppendAudit(auditCtx, entry)
+	if err != nil {
+		a.log.Error().
+			Err(err).
+			Str("actor", entry.Actor).
+			Str("action", entry.Action).
+			Str("target", entry.Target).
+			Str("result", entry.Result).
+			Msg("событие не записано в журнал аудита")
+		return domain.AuditEntry{}, errors.Join(ErrAuditFailed, err)
+	}
+	return stored, nil
+}
+
+// Find возвращает страницу журнала. Limit ограничивается MaxAuditPageSize
+func (a *AuditLog) Find(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditPageSize
+	}
+	filter.Limit = min(filter.Limit, MaxAuditPageSize)
+	return a.repo.FindAuditEntries(ctx, filter)
+}
+
+// Verify проходит журнал с первой записи и проверяет, что номера идут
+// без пропусков, каждая запись ссылается на хэш предыдущей и хэш совпадает
+// с содержимым. Удаление последних записей цепочка не выявляет - для этого
+// HeadHash нужно сохранять вне сервиса и сравнивать при следующей проверке
+func (a *AuditLog) Verify(ctx context.Context) (domain.AuditVerification, error) {
+	var result domain.AuditVerification
+	for {
+		page, err := a.repo.FindAuditEntries(ctx, domain.AuditFilter{AfterSeq: result.HeadSeq, Limit: MaxAuditPageSize})
+		if err != nil {
+			return domain.AuditVerification{}, err
+		}
+		for _, entry := range page {
+			var problem string
+			switch {
+			case entry.Seq != result.HeadSeq+1:
+				problem = fmt.Sprintf("отсутствуют записи с %d по %d", result.HeadSeq+1, entry.Seq-1)
+			case entry.PrevHash != result.HeadHash:
+				problem = "запись не ссылается на хэш предыдущей"
+			case entry.Hash != entry.ChainHash():
+				problem = "хэш не совпадает с содержимым записи"
+			}
+			if problem != "" {
+				result.BrokenSeq = entry.Seq
+				result.Problem = problem
+				return result, nil
+			}
+			result.Checked++
+			result.HeadSeq = entry.Seq
+			result.HeadHash = entry.Hash
+		}
+		if len(page) < MaxAuditPageSize {
+			result.Valid = true
+			return result, nil
+		}
+	}
+}
+
+// состояние ссылки для полей Before и After
+func auditState(link domain.URLLink) string {
+	state, err := json.Marshal(link)
+	if err != nil {
+		return ""
+	}
+	return string(state)
+}
+
+// владелец ссылок до и после переноса в журнале аудита
+func ownerState(userID string) string {
+	state, err := json.Marshal(struct {
+		UserID string `json:"user_id"`
+	}{userID})
+	if err != nil {
+		return ""
+	}
+	return string(state)
+}
+
+// автор действия со ссылкой от имени пользователя
+func userActor(userID string) string {
+	return "user:" + userID
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/inmemory"
+	"github.com/physicist2018/url-shortener-go/internal/stringgenstrategy"
+	"github.com/physicist2018/url-shortener-go/pkg/uniquestring"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog_LinkLifecycle(t *testing.T) {
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	audit := NewAuditLog(repo, zerolog.New(nil))
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+	shortener.SetAuditLog(audit)
+
+	ctx := context.WithValue(context.Background(), domain.RequestIDKey{}, "req-1")
+	mine, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/mine"})
+	require.NoError(t, err)
+	theirs, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u2", LongURL: "https://example.com/theirs"})
+	require.NoError(t, err)
+
+	// чужая и несуществующая ссылки не удаляются и в журнал не попадают
+	require.NoError(t, shortener.MarkURLsAsDeleted(context.Background(), []domain.URLLink{
+		{UserID: "u1", ShortURL: mine.ShortURL},
+		{UserID: "u1", ShortURL: theirs.ShortURL},
+		{UserID: "u1", ShortURL: "missing"},
+	}))
+
+	entries, err := audit.Find(context.Background(), domain.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, domain.AuditLinkCreate, entries[0].Action)
+	assert.Equal(t, "user:u1", entries[0].Actor)
+	assert.Equal(t, mine.ShortURL, entries[0].Target)
+	assert.Equal(t, "req-1", entries[0].RequestID)
+	assert.Empty(t, entries[0].Before)
+	assert.Contains(t, entries[0].After, "https://example.com/mine")
+
+	assert.Equal(t, domain.AuditLinkDelete, entries[2].Action)
+	assert.Equal(t, mine.ShortURL, entries[2].Target)
+	assert.Contains(t, entries[2].Before, `"is_deleted":false`)
+	assert.Contains(t, entries[2].After, `"is_deleted":true`)
+
+	result, err := audit.Verify(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, int64(3), result.Checked)
+	assert.Equal(t, entries[2].Hash, result.HeadHash)
+}
+
+func TestAuditLog_VerifyDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dbase.json.audit")
+
+	repo, err := inmemory.NewInMemoryAuditRepository(path)
+	require.NoError(t, err)
+	audit := NewAuditLog(repo, zerolog.New(nil))
+	for _, actor := range []string{"user:u1", "user:u2", "user:u3"} {
+		_, err := audit.Record(ctx, domain.AuditEntry{Actor: actor, Action: domain.AuditLinkCreate, Result: domain.AuditResultOK})
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.Close())
+
+	original, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(original), "\n")
+
+	tests := []struct {
+		name      string
+		content   string
+		wantValid bool
+		wantSeq   int64
+	}{
+		{"Intact", string(original), true, 0},
+		{"Edited entry", strings.Replace(string(original), `"actor":"user:u2"`, `"actor":"user:u9"`, 1), false, 2},
+		{"Removed entry", lines[0] + lines[2], false, 3},
+		{"Removed first entry", lines[1] + lines[2], false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+			repo, err := inmemory.NewInMemoryAuditRepository(path)
+			require.NoError(t, err)
+			defer repo.Close()
+
+			result, err := NewAuditLog(repo, zerolog.New(nil)).Verify(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantValid, result.Valid)
+			assert.Equal(t, tt.wantSeq, result.BrokenSeq)
+			if !tt.wantValid {
+				assert.NotEmpty(t, result.Problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	maxDomainLen = 253
+	// как часто подхватывать блокировки, сделанные другими экземплярами сервиса
+	DefaultBlocklistRefresh = time.Minute
+)
+
+var (
+	ErrDomainBlocked    = errors.New("домен назначения заблокирован")
+	ErrInvalidDomain    = errors.New("некорректное доменное имя")
+	ErrDomainNotBlocked = errors.New("домен не заблокирован")
+)
+
+// Проверка домена назначения ссылки
+type HostBlocklist interface {
+	IsBlocked(ctx context.Context, host string) bool
+}
+
+// Список заблокированных доменов с копией в памяти. Копия перечитывается
+// из хранилища не реже раза в refresh и сразу после изменений через этот экземпляр
+type DomainBlocklist struct {
+	log     zerolog.Logger
+	repo    domain.DomainBlockRepo
+	refresh time.Duration
+	now     func() time.Time
+
+	reloading sync.Mutex // перечитывает список один запрос, остальные видят прежнюю копию
+	mu        sync.RWMutex
+	domains   map[string]struct{}
+	loadedAt  time.Time
+}
+
+func NewDomainBlocklist(repo domain.DomainBlockRepo, refresh time.Duration, logger zerolog.Logger) *DomainBlocklist {
+	if refresh <= 0 {
+		refresh = DefaultBlocklistRefresh
+	}
+	return &DomainBlocklist{
+		log:     logger,
+		repo:    repo,
+		refresh: refresh,
+		now:     time.Now,
+	}
+}
+
+// NormalizeDomain приводит доменное имя к нижнему регистру без завершающей точки
+func NormalizeDomain(name string) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if name == "" || len(name) > maxDomainLen || strings.ContainsAny(name, "/:@?#[] \t") {
+		return "", ErrInvalidDomain
+	}
+	return name, nil
+}
+
+// IsBlocked сообщает, заблокирован ли host или один из его родительских доменов.
+// Если хранилище недоступно, используется последняя загруженная копия
+func (b *DomainBlocklist) IsBlocked(ctx context.Context, host string) bool {
+	if b.stale() && b.reloading.TryLock() {
+		if err := b.load(ctx); err != nil {
+			b.log.Error().Err(err).Msg("не удалось перечитать список заблокированных доменов")
+		}
+		b.reloading.Unlock()
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for host != "" {
+		if _, ok := b.domains[host]; ok {
+			return true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return false
+}
+
+// IsURLBlocked проверяет домен адреса назначения. Неразбираемый адрес не блокируется:
+// его корректность проверяют ручки
+func IsURLBlocked(ctx context.Context, blocklist HostBlocklist, rawURL string) bool {
+	if blocklist == nil {
+		return false
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return false
+	}
+	return blocklist.IsBlocked(ctx, parsed.Hostname())
+}
+
+// Block блокирует домен вместе с поддоменами
+func (b *DomainBlocklist) Block(ctx context.Context, block domain.DomainBlock) (domain.DomainBlock, error) {
+	name, err := NormalizeDomain(block.Domain)
+	if err != nil {
+		return domain.DomainBlock{}, err
+	}
+	block.Domain = name
+	block.CreatedAt = block.CreatedAt.UTC().Truncate(time.Second)
+	if err := b.repo.BlockDomain(ctx, block); err != nil {
+		return domain.DomainBlock{}, err
+	}
+	b.reload(ctx)
+	return block, nil
+}
+
+func (b *DomainBlocklist) Unblock(ctx context.Context, name string) error {
+	name, err := NormalizeDomain(name)
+	if err != nil {
+		return err
+	}
+	if err := b.repo.UnblockDomain(ctx, name); err != nil {
+		if errors.Is(err, repoerrors.ErrorDomainBlockNotFound) {
+			return ErrDomainNotBlocked
+		}
+		return err
+	}
+	b.reload(ctx)
+	return nil
+}
+
+func (b *DomainBlocklist) List(ctx context.Context) ([]domain.DomainBlock, error) {
+	return b.repo.FindBlockedDomains(ctx)
+}
+
+func (b *DomainBlocklist) stale() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.domains == nil || b.now().Sub(b.loadedAt) >= b.refresh
+}
+
+// перечитывает список после изменения; ошибка не мешает самому изменению
+func (b *DomainBlocklist) reload(ctx context.Context) {
+	b.reloading.Lock()
+	defer b.reloading.Unlock()
+	if err := b.load(ctx); err != nil {
+		b.log.Error().Err(err).Msg("не удалось перечитать список заблокированных доменов")
+	}
+}
+
+// вызывается под b.reloading
+func (b *DomainBlocklist) load(ctx context.Context) error {
+	blocks, err := b.repo.FindBlockedDomains(ctx)
+	if err != nil {
+		// повторим не раньше, чем через refresh
+		b.mu.Lock()
+		if b.domains == nil {
+			b.domains = map[string]struct{}{}
+		}
+		b.loadedAt = b.now()
+		b.mu.Unlock()
+		return err
+	}
+
+	domains := make(map[string]struct{}, len(blocks))
+	for _, block := range blocks {
+		domains[block.Domain] = struct{}{}
+	}
+	b.mu.Lock()
+	b.domains = domains
+	b.loadedAt = b.now()
+	b.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// сколько паролей по умолчанию хэшируется одновременно: каждый хэш argon2id
+	// занимает десятки мегабайт памяти
+	DefaultConcurrentHashes = 4
+	// сколько запрос ждет свободного слота, прежде чем получить ErrPasswordCheckBusy
+	hashSlotWait = 2 * time.Second
+)
+
+// все слоты для хэширования паролей заняты
+var ErrPasswordCheckBusy = errors.New("сервис перегружен проверкой паролей, повторите позже")
+
+// Семафор хэширования паролей. Пароли ссылок и учетных записей хэшируются
+// одним алгоритмом, поэтому сервисы должны делить один экземпляр, иначе
+// суммарная память на хэши растет с числом сервисов
+type HashSlots struct {
+	slots chan struct{}
+}
+
+func NewHashSlots(n int) *HashSlots {
+	return &HashSlots{slots: make(chan struct{}, n)}
+}
+
+// acquire ждет свободного слота не дольше hashSlotWait, чтобы поток
+// попыток не занял всю память сервиса. Слот освобождает возвращенная функция
+func (h *HashSlots) acquire(ctx context.Context) (func(), error) {
+	timer := time.NewTimer(hashSlotWait)
+	defer timer.Stop()
+	select {
+	case h.slots <- struct{}{}:
+		return func() { <-h.slots }, nil
+	case <-timer.C:
+		return nil, ErrPasswordCheckBusy
+	case <-ctx.Done():
+		return nil, errors.Join(ErrPasswordCheckBusy, ctx.Err())
+	}
+}
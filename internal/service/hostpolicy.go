@@ -0,0 +1,243 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var ErrInvalidHostPolicy = errors.New("некорректный файл политики хостов")
+
+// Вид правила политики хостов
+const (
+	hostRuleExact  = "exact"  // имя целиком
+	hostRuleSuffix = "suffix" // домен вместе с поддоменами
+	hostRuleRegex  = "regex"  // регулярное выражение по всему имени
+)
+
+type hostRule struct {
+	allow   bool
+	kind    string
+	pattern string
+	re      *regexp.Regexp
+}
+
+func (r hostRule) matches(host string) bool {
+	switch r.kind {
+	case hostRuleExact:
+		return host == r.pattern
+	case hostRuleSuffix:
+		return host == r.pattern || strings.HasSuffix(host, "."+r.pattern)
+	default:
+		return r.re.MatchString(host)
+	}
+}
+
+// Разобранный файл политики
+type hostRules struct {
+	rules        []hostRule
+	defaultAllow bool
+}
+
+// Политика хостов назначения из файла. Файл перечитывается по сигналу
+// и при изменении, при ошибке разбора остаются прежние правила.
+//
+// Формат файла - по правилу в строке, # начинает комментарий
+// (поэтому в шаблонах его быть не может):
+//
+//	deny suffix evil.example
+//	deny regex ^login-[a-z]+\.example\.com$
+//	allow exact login-ok.example.com
+//	default deny
+//
+// Разрешающее правило сильнее запрещающего. Хост, под который не подошло
+// ни одно правило, проходит, если не задано default deny - тогда файл
+// становится списком разрешенных хостов. Имена сравниваются в нижнем
+// регистре, интернационализированные домены записываются в Punycode
+type HostPolicy struct {
+	log  zerolog.Logger
+	path string
+
+	mu      sync.RWMutex
+	rules   hostRules
+	modTime time.Time
+	size    int64
+}
+
+// NewHostPolicy загружает политику из файла. Ошибка чтения или разбора
+// при старте возвращается, чтобы сервис не работал без ожидаемых правил
+func NewHostPolicy(path string, logger zerolog.Logger) (*HostPolicy, error) {
+	p := &HostPolicy{
+		log:  logger,
+		path: path,
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *HostPolicy) IsBlocked(ctx context.Context, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	blocked := !p.rules.defaultAllow
+	for _, rule := range p.rules.rules {
+		if !rule.matches(host) {
+			continue
+		}
+		if rule.allow {
+			return false
+		}
+		blocked = true
+	}
+	return blocked
+}
+
+// Reload перечитывает файл. При ошибке действуют прежние правила
+func (p *HostPolicy) Reload() error {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return errors.Join(ErrInvalidHostPolicy, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Join(ErrInvalidHostPolicy, err)
+	}
+	rules, err := parseHostPolicy(f)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.rules = rules
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	p.mu.Unlock()
+	return nil
+}
+
+// Start перечитывает политику при получении сигнала из reload и,
+// если interval больше нуля, при изменении времени или размера файла.
+// Горутина завершается с отменой ctx
+func (p *HostPolicy) Start(ctx context.Context, interval time.Duration, reload <-chan os.Signal) {
+	go func() {
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reload:
+				p.reload("получен сигнал")
+			case <-tick:
+				if p.changed() {
+					p.reload("файл изменен")
+				}
+			}
+		}
+	}()
+}
+
+func (p *HostPolicy) reload(reason string) {
+	if err := p.Reload(); err != nil {
+		p.log.Error().Err(err).Str("path", p.path).Msg("политика хостов не перечитана, действуют прежние правила")
+		return
+	}
+	p.mu.RLock()
+	count := len(p.rules.rules)
+	p.mu.RUnlock()
+	p.log.Info().Str("path", p.path).Str("reason", reason).Int("rules", count).Msg("политика хостов перечитана")
+}
+
+func (p *HostPolicy) changed() bool {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		// пропавший файл не отменяет действующие правила
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return !info.ModTime().Equal(p.modTime) || info.Size() != p.size
+}
+
+func parseHostPolicy(r io.Reader) (hostRules, error) {
+	rules := hostRules{defaultAllow: true}
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		invalid := func(problem string) error {
+			return fmt.Errorf("%w: строка %d: %s", ErrInvalidHostPolicy, lineNo, problem)
+		}
+
+		if fields[0] == "default" {
+			if len(fields) != 2 || fields[1] != "allow" && fields[1] != "deny" {
+				return hostRules{}, invalid("ожидается default allow или default deny")
+			}
+			rules.defaultAllow = fields[1] == "allow"
+			continue
+		}
+		if len(fields) != 3 {
+			return hostRules{}, invalid("ожидается allow|deny exact|suffix|regex шаблон")
+		}
+		rule := hostRule{kind: fields[1], pattern: fields[2]}
+		switch fields[0] {
+		case "allow":
+			rule.allow = true
+		case "deny":
+		default:
+			return hostRules{}, invalid("действие должно быть allow или deny")
+		}
+		switch rule.kind {
+		case hostRuleExact, hostRuleSuffix:
+			rule.pattern = strings.TrimSuffix(strings.ToLower(strings.TrimPrefix(rule.pattern, ".")), ".")
+			if rule.pattern == "" {
+				return hostRules{}, invalid("пустое имя хоста")
+			}
+		case hostRuleRegex:
+			re, err := regexp.Compile("^(?:" + rule.pattern + ")$")
+			if err != nil {
+				return hostRules{}, invalid(err.Error())
+			}
+			rule.re = re
+		default:
+			return hostRules{}, invalid("вид правила должен быть exact, suffix или regex")
+		}
+		rules.rules = append(rules.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return hostRules{}, errors.Join(ErrInvalidHostPolicy, err)
+	}
+	return rules, nil
+}
+
+// Несколько проверок доменов: хост заблокирован, если его блокирует любая
+type HostBlocklists []HostBlocklist
+
+func (l HostBlocklists) IsBlocked(ctx context.Context, host string) bool {
+	for _, blocklist := range l {
+		if blocklist.IsBlocked(ctx, host) {
+			return true
+		}
+	}
+	return false
+}
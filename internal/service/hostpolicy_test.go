@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePolicy(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+func TestHostPolicy_IsBlocked(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "hosts.policy")
+	writePolicy(t, path, `
+# фишинг
+deny suffix evil.example
+deny exact Bad.Example.COM.
+deny regex   login-[a-z]+\.example\.net
+allow exact safe.evil.example # проверенный поддомен
+`)
+	policy, err := NewHostPolicy(path, zerolog.New(nil))
+	require.NoError(t, err)
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"evil.example", true},
+		{"www.EVIL.example", true},
+		{"notevil.example", false},
+		{"safe.evil.example", false},
+		{"bad.example.com", true},
+		{"www.bad.example.com", false},
+		{"login-paypal.example.net", true},
+		{"login-paypal.example.net.attacker.io", false},
+		{"example.org", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, policy.IsBlocked(ctx, tt.host), tt.host)
+	}
+
+	// default deny превращает правила в список разрешенных хостов
+	writePolicy(t, path, "default deny\nallow suffix example.org\n")
+	require.NoError(t, policy.Reload())
+	assert.False(t, policy.IsBlocked(ctx, "docs.example.org"))
+	assert.True(t, policy.IsBlocked(ctx, "example.com"))
+}
+
+func TestHostPolicy_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.policy")
+
+	for _, content := range []string{
+		"block suffix evil.example",
+		"deny wildcard *.evil.example",
+		"deny regex (",
+		"deny suffix",
+		"default maybe",
+	} {
+		writePolicy(t, path, content)
+		_, err := NewHostPolicy(path, zerolog.New(nil))
+		assert.True(t, errors.Is(err, ErrInvalidHostPolicy), content)
+	}
+
+	_, err := NewHostPolicy(filepath.Join(t.TempDir(), "missing"), zerolog.New(nil))
+	assert.True(t, errors.Is(err, ErrInvalidHostPolicy))
+}
+
+func TestHostPolicy_HotReload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "hosts.policy")
+	writePolicy(t, path, "deny exact one.example\n")
+	policy, err := NewHostPolicy(path, zerolog.New(nil))
+	require.NoError(t, err)
+
+	reload := make(chan os.Signal, 1)
+	policy.Start(ctx, 10*time.Millisecond, reload)
+
+	// изменение файла подхватывается без сигнала
+	writePolicy(t, path, "deny exact one.example\ndeny exact two.example\n")
+	assert.Eventually(t, func() bool { return policy.IsBlocked(ctx, "two.example") }, time.Second, 5*time.Millisecond)
+
+	// испорченный файл не отменяет действующие правила
+	writePolicy(t, path, "deny regex (\n")
+	reload <- os.Interrupt
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, policy.IsBlocked(ctx, "two.example"))
+}
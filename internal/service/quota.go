@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+)
+
+// Виды квот
+const (
+	QuotaLinks = "links" // неудаленные ссылки пользователя
+	QuotaBatch = "batch" // ссылки в одном пакетном запросе
+)
+
+var ErrQuotaExceeded = errors.New("превышена квота")
+
+// Отказ из-за квоты: что ограничено, предел, сколько уже использовано
+// и сколько запрошено. errors.Is(err, ErrQuotaExceeded) выполняется всегда
+type QuotaError struct {
+	Quota     string `json:"quota"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Requested int    `json:"requested"`
+}
+
+func (e *QuotaError) Error() string {
+	switch e.Quota {
+	case QuotaBatch:
+		return fmt.Sprintf("%s: в пакете %d ссылок, допускается не больше %d", ErrQuotaExceeded, e.Requested, e.Limit)
+	default:
+		return fmt.Sprintf("%s: у пользователя %d ссылок из %d, запрошено еще %d", ErrQuotaExceeded, e.Used, e.Limit, e.Requested)
+	}
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// Квоты пользователя, 0 - без ограничения
+type Quotas struct {
+	MaxLinks     int // неудаленных ссылок, включая созданные в рабочих пространствах
+	MaxBatchSize int // ссылок в одном пакетном запросе
+}
+
+// linkQuotaError описывает отказ в создании requested ссылок. Квоту проверяет
+// хранилище вместе со вставкой, использование подсчитывается уже после отказа
+func (u *URLLinkService) linkQuotaError(ctx context.Context, userID string, requested int) error {
+	used, err := u.repo.CountActiveLinks(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return &QuotaError{Quota: QuotaLinks, Limit: u.quotas.MaxLinks, Used: used, Requested: requested}
+}
+
+// claimQuotaError описывает отказ в переносе ссылок анонимной личности в учетную запись
+func (s *UserService) claimQuotaError(ctx context.Context, anonymousUserID, userID string) error {
+	used, err := s.links.CountActiveLinks(ctx, userID)
+	if err != nil {
+		return err
+	}
+	requested, err := s.links.CountActiveLinks(ctx, anonymousUserID)
+	if err != nil {
+		return err
+	}
+	return &QuotaError{Quota: QuotaLinks, Limit: s.quotas.MaxLinks, Used: used, Requested: requested}
+}
+
+// Quota возвращает квоты пользователя и их использование
+func (u *URLLinkService) Quota(ctx context.Context, userID string) (domain.QuotaUsage, error) {
+	used, err := u.repo.CountActiveLinks(ctx, userID)
+	if err != nil {
+		return domain.QuotaUsage{}, err
+	}
+	return domain.QuotaUsage{
+		MaxLinks:     u.quotas.MaxLinks,
+		ActiveLinks:  used,
+		MaxBatchSize: u.quotas.MaxBatchSize,
+	}, nil
+}
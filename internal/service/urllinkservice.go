@@ -2,16 +2,47 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
 	"github.com/physicist2018/url-shortener-go/internal/stringgenstrategy"
+	"github.com/physicist2018/url-shortener-go/pkg/passhash"
 	"github.com/rs/zerolog"
 )
 
+var (
+	// проверка репутации недоступна, а сервис настроен не принимать непроверенные адреса
+	ErrReputationUnavailable = errors.New("не удалось проверить репутацию адреса назначения, повторите позже")
+	// адрес назначения признан опасным, переход по ссылке запрещен
+	ErrLinkQuarantined = errors.New("ссылка помещена в карантин")
+	// ссылка защищена паролем, а он не передан
+	ErrPasswordRequired = errors.New("для перехода по ссылке нужен пароль")
+	// пароль ссылки не совпал
+	ErrWrongPassword = errors.New("неверный пароль ссылки")
+	// лимит переходов по ссылке исчерпан, ссылка считается удаленной
+	ErrLinkExhausted    = errors.New("переходы по ссылке исчерпаны")
+	ErrInvalidMaxClicks = errors.New("max_clicks не может быть отрицательным")
+)
+
+// Приведение адреса назначения к каноническому виду, см. pkg/urlnorm
+type URLNormalizer interface {
+	Normalize(rawURL string) (string, error)
+}
+
 type URLLinkService struct {
-	log       zerolog.Logger
-	generator stringgenstrategy.StringGeneratorContext
-	repo      domain.URLLinkRepo
+	log        zerolog.Logger
+	generator  stringgenstrategy.StringGeneratorContext
+	repo       domain.URLLinkRepo
+	blocklist  HostBlocklist // nil - домены назначения не проверяются
+	audit      *AuditLog     // nil - изменения ссылок не журналируются
+	normalizer URLNormalizer // nil - адреса сохраняются как есть
+	policy     URLPolicy
+	reputation domain.ReputationChecker // nil - репутация адресов не проверяется
+	failClosed bool                     // отклонять адрес, если репутацию проверить не удалось
+	quotas     Quotas
+	hashes     *HashSlots
 }
 
 func NewURLLinkService(repo domain.URLLinkRepo, generator stringgenstrategy.StringGeneratorContext, logger zerolog.Logger) *URLLinkService {
@@ -19,29 +50,188 @@ func NewURLLinkService(repo domain.URLLinkRepo, generator stringgenstrategy.Stri
 		repo:      repo,
 		generator: generator,
 		log:       logger,
+		policy:    DefaultURLPolicy,
+		hashes:    NewHashSlots(DefaultConcurrentHashes),
 	}
 }
 
-// Метод создания короткой ссылки
+// SetHashSlots задает семафор хэширования, общий с другими сервисами
+func (u *URLLinkService) SetHashSlots(hashes *HashSlots) {
+	u.hashes = hashes
+}
+
+// SetBlocklist включает проверку домена назначения при создании ссылок и переходе по ним
+func (u *URLLinkService) SetBlocklist(blocklist HostBlocklist) {
+	u.blocklist = blocklist
+}
+
+// SetNormalizer включает приведение адресов назначения к каноническому виду
+// перед сохранением, чтобы одинаковые адреса не получали разные ссылки
+func (u *URLLinkService) SetNormalizer(normalizer URLNormalizer) {
+	u.normalizer = normalizer
+}
+
+// SetURLPolicy заменяет правила приема адресов назначения DefaultURLPolicy
+func (u *URLLinkService) SetURLPolicy(policy URLPolicy) {
+	u.policy = policy
+}
+
+// SetReputationChecker включает проверку репутации адресов назначения.
+// Отмеченные адреса сохраняются в карантине. Если проверка не удалась,
+// при failClosed ссылка не создается, иначе создается как обычно
+func (u *URLLinkService) SetReputationChecker(checker domain.ReputationChecker, failClosed bool) {
+	u.reputation = checker
+	u.failClosed = failClosed
+}
+
+// SetQuotas ограничивает число ссылок пользователя и размер пакетных запросов
+func (u *URLLinkService) SetQuotas(quotas Quotas) {
+	u.quotas = quotas
+}
+
+// SetAuditLog включает запись создания и удаления ссылок в журнал аудита
+func (u *URLLinkService) SetAuditLog(audit *AuditLog) {
+	u.audit = audit
+}
+
+// Метод создания короткой ссылки. Если адрес уже сокращен, возвращается
+// существующая ссылка вместе с ErrorShortLinkAlreadyInDB
 func (u *URLLinkService) CreateShortURL(ctx context.Context, link domain.URLLink) (domain.URLLink, error) {
-	shortURL := u.generator.GenerateString()
-	urllink := domain.URLLink{
-		ShortURL: shortURL,
-		LongURL:  link.LongURL,
-		UserID:   link.UserID,
+	prepared, err := u.prepareLink(ctx, link)
+	if err != nil {
+		return domain.URLLink{}, err
+	}
+	stored, err := u.storeLinks(ctx, []domain.URLLink{prepared})
+	if err != nil {
+		return domain.URLLink{}, err
+	}
+	if stored[0].ShortURL != prepared.ShortURL {
+		return stored[0], repoerrors.ErrorShortLinkAlreadyInDB
+	}
+	return stored[0], nil
+}
+
+// CreateShortURLBatch создает ссылки одного пользователя: все или ни одной.
+// Сначала проверяются все адреса, затем ссылки сохраняются одной транзакцией
+// вместе с проверкой квоты. На месте уже сокращенного адреса возвращается
+// существующая ссылка
+func (u *URLLinkService) CreateShortURLBatch(ctx context.Context, links []domain.URLLink) ([]domain.URLLink, error) {
+	if len(links) == 0 {
+		return nil, nil
+	}
+	if u.quotas.MaxBatchSize > 0 && len(links) > u.quotas.MaxBatchSize {
+		return nil, &QuotaError{Quota: QuotaBatch, Limit: u.quotas.MaxBatchSize, Requested: len(links)}
 	}
 
-	return u.repo.Store(ctx, urllink)
+	prepared := make([]domain.URLLink, len(links))
+	for i, link := range links {
+		var err error
+		if prepared[i], err = u.prepareLink(ctx, link); err != nil {
+			return nil, err
+		}
+	}
+	return u.storeLinks(ctx, prepared)
+}
+
+// prepareLink проверяет адрес назначения и параметры новой ссылки
+// и возвращает ее в виде для сохранения
+func (u *URLLinkService) prepareLink(ctx context.Context, link domain.URLLink) (domain.URLLink, error) {
+	longURL, err := u.prepareURL(link.LongURL)
+	if err != nil {
+		return domain.URLLink{}, err
+	}
+	if link.MaxClicks < 0 {
+		return domain.URLLink{}, ErrInvalidMaxClicks
+	}
+	passwordHash, err := u.hashLinkPassword(ctx, link.Password)
+	if err != nil {
+		return domain.URLLink{}, err
+	}
+	if IsURLBlocked(ctx, u.blocklist, longURL) {
+		return domain.URLLink{}, ErrDomainBlocked
+	}
+	quarantined, err := u.checkReputation(ctx, longURL)
+	if err != nil {
+		return domain.URLLink{}, err
+	}
+	return domain.URLLink{
+		ShortURL:        u.generator.GenerateString(),
+		LongURL:         longURL,
+		UserID:          link.UserID,
+		WorkspaceID:     link.WorkspaceID,
+		Quarantined:     quarantined,
+		PasswordHash:    passwordHash,
+		MaxClicks:       link.MaxClicks,
+		ClicksRemaining: link.MaxClicks,
+	}, nil
 }
 
-// метод получения оригинальной ссылки
-func (u *URLLinkService) GetOriginalURL(ctx context.Context, link domain.URLLink) (domain.URLLink, error) {
-	link, err := u.repo.Find(ctx, link.ShortURL)
+// storeLinks сохраняет подготовленные ссылки одного пользователя. Квоту
+// проверяет хранилище атомарно со вставкой; ссылки без пользователя
+// не считаются. Созданные ссылки попадают в журнал аудита
+func (u *URLLinkService) storeLinks(ctx context.Context, links []domain.URLLink) ([]domain.URLLink, error) {
+	userID := links[0].UserID
+	maxActive := u.quotas.MaxLinks
+	if userID == "" {
+		maxActive = 0
+	}
+
+	stored, err := u.repo.StoreBatch(ctx, links, maxActive)
+	switch {
+	case errors.Is(err, repoerrors.ErrorLinkQuotaExceeded):
+		return nil, u.linkQuotaError(ctx, userID, len(links))
+	case err != nil:
+		return nil, err
+	}
+
+	for i, link := range stored {
+		// на месте уже сокращенного адреса - существующая ссылка
+		if link.ShortURL != links[i].ShortURL {
+			continue
+		}
+		u.record(ctx, domain.AuditEntry{
+			Actor:  userActor(link.UserID),
+			Action: domain.AuditLinkCreate,
+			Target: link.ShortURL,
+			After:  auditState(link),
+		})
+	}
+	return stored, nil
+}
+
+// метод получения оригинальной ссылки. Для ссылки с паролем нужен
+// link.Password, без верного пароля возвращается только короткий код
+func (u *URLLinkService) GetOriginalURL(ctx context.Context, request domain.URLLink) (domain.URLLink, error) {
+	link, err := u.repo.Find(ctx, request.ShortURL)
 
 	if err != nil {
 		u.log.Info().Err(err)
 		return domain.URLLink{}, err
 	}
+	// пароль проверяется первым, чтобы не раскрыть адрес назначения на странице блокировки
+	if link.PasswordHash != "" && !link.DeletedFlag {
+		if err := u.checkLinkPassword(ctx, link, request.Password); err != nil {
+			return domain.URLLink{ShortURL: link.ShortURL}, err
+		}
+	}
+	// ссылка могла быть создана до блокировки домена
+	if IsURLBlocked(ctx, u.blocklist, link.LongURL) {
+		return link, ErrDomainBlocked
+	}
+	if link.Quarantined {
+		return link, ErrLinkQuarantined
+	}
+	// переход засчитывается, только когда он действительно будет выполнен
+	if link.MaxClicks > 0 && !link.DeletedFlag {
+		remaining, err := u.repo.ConsumeClick(ctx, link.ShortURL)
+		if errors.Is(err, repoerrors.ErrorClicksExhausted) {
+			return link, ErrLinkExhausted
+		}
+		if err != nil {
+			return domain.URLLink{}, err
+		}
+		link.ClicksRemaining = remaining
+	}
 
 	return link, nil
 }
@@ -50,11 +240,217 @@ func (u *URLLinkService) FindAll(ctx context.Context, userID string) ([]domain.U
 	return u.repo.FindAll(ctx, userID)
 }
 
+// UpdateLink меняет личную ссылку пользователя. Чужие, удаленные ссылки
+// и ссылки рабочих пространств для него не существуют
+func (u *URLLinkService) UpdateLink(ctx context.Context, userID, shortURL string, update domain.URLLinkUpdate) (domain.URLLink, error) {
+	link, err := u.ownedLink(ctx, userID, shortURL)
+	if err != nil {
+		return domain.URLLink{}, err
+	}
+	updated := link
+	if update.LongURL != nil {
+		longURL, err := u.prepareURL(*update.LongURL)
+		if err != nil {
+			return domain.URLLink{}, err
+		}
+		if longURL != link.LongURL {
+			if IsURLBlocked(ctx, u.blocklist, longURL) {
+				return domain.URLLink{}, ErrDomainBlocked
+			}
+			quarantined, err := u.checkReputation(ctx, longURL)
+			if err != nil {
+				return domain.URLLink{}, err
+			}
+			updated.LongURL = longURL
+			updated.Quarantined = quarantined
+		}
+	}
+	if update.Password != nil {
+		if updated.PasswordHash, err = u.hashLinkPassword(ctx, *update.Password); err != nil {
+			return domain.URLLink{}, err
+		}
+	}
+	if updated == link {
+		return link, nil
+	}
+
+	if err := u.repo.Update(ctx, updated, userID); err != nil {
+		if errors.Is(err, repoerrors.ErrorShortLinkNotFound) {
+			return domain.URLLink{}, ErrLinkNotFound
+		}
+		return domain.URLLink{}, err
+	}
+	u.record(ctx, domain.AuditEntry{
+		Actor:  userActor(userID),
+		Action: domain.AuditLinkEdit,
+		Target: shortURL,
+		Before: auditState(link),
+		After:  auditState(updated),
+	})
+	return updated, nil
+}
+
+// LinkVersions возвращает прежние адреса личной ссылки пользователя, от старых к новым
+func (u *URLLinkService) LinkVersions(ctx context.Context, userID, shortURL string) ([]domain.LinkVersion, error) {
+	if _, err := u.ownedLink(ctx, userID, shortURL); err != nil {
+		return nil, err
+	}
+	return u.repo.FindVersions(ctx, shortURL)
+}
+
+// prepareURL проверяет адрес назначения и приводит его к каноническому виду.
+// Канонический адрес проверяется еще раз: кодирование могло его удлинить
+func (u *URLLinkService) prepareURL(longURL string) (string, error) {
+	if err := u.policy.Validate(longURL); err != nil {
+		return "", err
+	}
+	if u.normalizer == nil {
+		return longURL, nil
+	}
+	normalized, err := u.normalizer.Normalize(longURL)
+	if err != nil {
+		return "", &InvalidURLError{Reason: fmt.Errorf("%w: %w", ErrURLMalformed, err)}
+	}
+	if err := u.policy.Validate(normalized); err != nil {
+		return "", err
+	}
+	return normalized, nil
+}
+
+// checkReputation сообщает, нужно ли поместить ссылку на адрес в карантин
+func (u *URLLinkService) checkReputation(ctx context.Context, longURL string) (bool, error) {
+	if u.reputation == nil {
+		return false, nil
+	}
+	verdict, err := u.reputation.Check(ctx, longURL)
+	if err != nil {
+		if u.failClosed {
+			u.log.Error().Err(err).Str("longURL", longURL).Msg("репутация адреса не проверена, ссылка не создана")
+			return false, errors.Join(ErrReputationUnavailable, err)
+		}
+		u.log.Warn().Err(err).Str("longURL", longURL).Msg("репутация адреса не проверена, ссылка создана без проверки")
+		return false, nil
+	}
+	if verdict.Flagged {
+		u.log.Warn().Str("longURL", longURL).Str("reason", verdict.Reason).Msg("адрес назначения отмечен проверкой репутации, ссылка в карантине")
+	}
+	return verdict.Flagged, nil
+}
+
+// hashLinkPassword проверяет длину пароля ссылки и возвращает его хэш.
+// Пустой пароль означает ссылку без пароля
+func (u *URLLinkService) hashLinkPassword(ctx context.Context, password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	if len(password) < minPasswordLen || len(password) > maxPasswordLen {
+		return "", ErrWeakPassword
+	}
+	release, err := u.hashes.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return passhash.Hash(password)
+}
+
+// checkLinkPassword сравнивает пароль перехода с хэшем ссылки
+func (u *URLLinkService) checkLinkPassword(ctx context.Context, link domain.URLLink, password string) error {
+	if password == "" {
+		return ErrPasswordRequired
+	}
+	release, err := u.hashes.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = passhash.Verify(link.PasswordHash, password)
+	release()
+	if err != nil {
+		if !errors.Is(err, passhash.ErrMismatch) {
+			u.log.Error().Err(err).Str("shortURL", link.ShortURL).Msg("Некорректный хэш пароля ссылки")
+		}
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// ownedLink читает ссылку мимо кэшей и реплик: по ней проверяются права на изменение
+func (u *URLLinkService) ownedLink(ctx context.Context, userID, shortURL string) (domain.URLLink, error) {
+	link, err := u.repo.FindConsistent(ctx, shortURL)
+	if errors.Is(err, repoerrors.ErrorShortLinkNotFound) {
+		return domain.URLLink{}, ErrLinkNotFound
+	}
+	if err != nil {
+		return domain.URLLink{}, err
+	}
+	if link.UserID != userID || link.WorkspaceID != "" || link.DeletedFlag {
+		return domain.URLLink{}, ErrLinkNotFound
+	}
+	return link, nil
+}
+
 // проверка соединения
 func (u *URLLinkService) Ping(ctx context.Context) error {
 	return u.repo.Ping(ctx)
 }
 
+// MarkURLsAsDeleted помечает удаленными ссылки, которыми владеет автор запроса,
+// чужие и несуществующие коды пропускаются. При включенном журнале аудита
+// состояние ссылок читается до удаления, чтобы записать, что именно удалено
 func (u *URLLinkService) MarkURLsAsDeleted(ctx context.Context, links []domain.URLLink) error {
-	return u.repo.MarkDeletedBatch(ctx, links)
+	if u.audit == nil {
+		return u.repo.MarkDeletedBatch(ctx, links)
+	}
+
+	// ссылка вместе с тем, кто ее удаляет
+	type deletion struct {
+		link  domain.URLLink
+		actor string
+	}
+	var deletions []deletion
+	seen := make(map[string]struct{}, len(links))
+	for _, l := range links {
+		if _, ok := seen[l.ShortURL]; ok {
+			continue
+		}
+		link, err := u.repo.FindConsistent(ctx, l.ShortURL)
+		if errors.Is(err, repoerrors.ErrorShortLinkNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		owned := link.WorkspaceID == l.WorkspaceID && (l.WorkspaceID != "" || link.UserID == l.UserID)
+		if owned && !link.DeletedFlag {
+			seen[l.ShortURL] = struct{}{}
+			deletions = append(deletions, deletion{link: link, actor: userActor(l.UserID)})
+		}
+	}
+
+	if err := u.repo.MarkDeletedBatch(ctx, links); err != nil {
+		return err
+	}
+	// удаление идет из фоновой очереди, поэтому идентификатора запроса у записи нет
+	for _, d := range deletions {
+		entry := domain.AuditEntry{
+			Actor:  d.actor,
+			Action: domain.AuditLinkDelete,
+			Target: d.link.ShortURL,
+			Before: auditState(d.link),
+		}
+		d.link.DeletedFlag = true
+		entry.After = auditState(d.link)
+		u.record(ctx, entry)
+	}
+	return nil
+}
+
+// record пишет событие пользователя в журнал аудита. Сбой журнала
+// не отменяет уже выполненное действие и только попадает в лог
+func (u *URLLinkService) record(ctx context.Context, entry domain.AuditEntry) {
+	if u.audit == nil {
+		return
+	}
+	entry.Result = domain.AuditResultOK
+	u.audit.Record(ctx, entry)
 }
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/database/sqlite"
+	"github.com/physicist2018/url-shortener-go/internal/repository/inmemory"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/internal/reputation"
+	"github.com/physicist2018/url-shortener-go/internal/stringgenstrategy"
+	"github.com/physicist2018/url-shortener-go/pkg/uniquestring"
+	"github.com/physicist2018/url-shortener-go/pkg/urlnorm"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLLinkService_UpdateLink(t *testing.T) {
+	ctx := context.Background()
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	audit := NewAuditLog(repo, zerolog.New(nil))
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+	shortener.SetAuditLog(audit)
+
+	link, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/typo"})
+	require.NoError(t, err)
+	fixed := "https://example.com/fixed"
+
+	// чужую ссылку нельзя ни изменить, ни посмотреть ее историю
+	_, err = shortener.UpdateLink(ctx, "u2", link.ShortURL, domain.URLLinkUpdate{LongURL: &fixed})
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+	_, err = shortener.LinkVersions(ctx, "u2", link.ShortURL)
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+	_, err = shortener.UpdateLink(ctx, "u1", "missing", domain.URLLinkUpdate{LongURL: &fixed})
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+
+	updated, err := shortener.UpdateLink(ctx, "u1", link.ShortURL, domain.URLLinkUpdate{LongURL: &fixed})
+	require.NoError(t, err)
+	assert.Equal(t, fixed, updated.LongURL)
+
+	found, err := shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: link.ShortURL})
+	require.NoError(t, err)
+	assert.Equal(t, fixed, found.LongURL)
+
+	versions, err := shortener.LinkVersions(ctx, "u1", link.ShortURL)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, "https://example.com/typo", versions[0].LongURL)
+	assert.Equal(t, "u1", versions[0].ReplacedBy)
+
+	entries, err := audit.Find(ctx, domain.AuditFilter{Action: domain.AuditLinkEdit})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0].Before, "https://example.com/typo")
+	assert.Contains(t, entries[0].After, fixed)
+
+	// удаленную ссылку изменить нельзя
+	require.NoError(t, shortener.MarkURLsAsDeleted(ctx, []domain.URLLink{{UserID: "u1", ShortURL: link.ShortURL}}))
+	_, err = shortener.UpdateLink(ctx, "u1", link.ShortURL, domain.URLLinkUpdate{LongURL: &fixed})
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+}
+
+func TestURLLinkService_NormalizesDestination(t *testing.T) {
+	ctx := context.Background()
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+	shortener.SetNormalizer(urlnorm.New(urlnorm.DefaultOptions))
+
+	link, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "HTTP://Example.com:80/a"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/a", link.LongURL)
+
+	update := "https://Example.com:443/%7eb"
+	updated, err := shortener.UpdateLink(ctx, "u1", link.ShortURL, domain.URLLinkUpdate{LongURL: &update})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/~b", updated.LongURL)
+
+	_, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "/relative"})
+	assert.ErrorIs(t, err, ErrInvalidURL)
+}
+
+func TestURLLinkService_Reputation(t *testing.T) {
+	ctx := context.Background()
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	// сервис репутации: phish.example опасен, down.example проверить не удается
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URL string `json:"url"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case strings.Contains(req.URL, "phish.example"):
+			w.Write([]byte(`{"flagged": true, "reason": "phishing"}`))
+		case strings.Contains(req.URL, "down.example"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"flagged": false}`))
+		}
+	}))
+	defer server.Close()
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+	shortener.SetReputationChecker(reputation.NewWebhook(server.URL, "", 0), false)
+
+	link, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://phish.example/login"})
+	require.NoError(t, err)
+	assert.True(t, link.Quarantined)
+	found, err := shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: link.ShortURL})
+	assert.ErrorIs(t, err, ErrLinkQuarantined)
+	assert.Equal(t, "https://phish.example/login", found.LongURL)
+
+	// исправленный адрес выводит ссылку из карантина
+	fixed := "https://example.com/login"
+	updated, err := shortener.UpdateLink(ctx, "u1", link.ShortURL, domain.URLLinkUpdate{LongURL: &fixed})
+	require.NoError(t, err)
+	assert.False(t, updated.Quarantined)
+	_, err = shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: link.ShortURL})
+	require.NoError(t, err)
+
+	// fail open: сбой проверки не мешает созданию ссылки
+	link, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://down.example/"})
+	require.NoError(t, err)
+	assert.False(t, link.Quarantined)
+
+	// fail closed: без проверки ссылка не создается
+	shortener.SetReputationChecker(reputation.NewWebhook(server.URL, "", 0), true)
+	_, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://down.example/other"})
+	assert.ErrorIs(t, err, ErrReputationUnavailable)
+}
+
+func TestURLLinkService_Quotas(t *testing.T) {
+	ctx := context.Background()
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+	shortener.SetQuotas(Quotas{MaxLinks: 3, MaxBatchSize: 2})
+
+	links, err := shortener.CreateShortURLBatch(ctx, []domain.URLLink{
+		{UserID: "u1", LongURL: "https://example.com/1"},
+		{UserID: "u1", LongURL: "https://example.com/2"},
+	})
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+
+	// пакет больше допустимого отклоняется целиком
+	_, err = shortener.CreateShortURLBatch(ctx, []domain.URLLink{
+		{UserID: "u2", LongURL: "https://example.com/a"},
+		{UserID: "u2", LongURL: "https://example.com/b"},
+		{UserID: "u2", LongURL: "https://example.com/c"},
+	})
+	var quotaErr *QuotaError
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, QuotaError{Quota: QuotaBatch, Limit: 2, Requested: 3}, *quotaErr)
+
+	// пакет, не помещающийся в квоту ссылок, не создается частично
+	_, err = shortener.CreateShortURLBatch(ctx, []domain.URLLink{
+		{UserID: "u1", LongURL: "https://example.com/3"},
+		{UserID: "u1", LongURL: "https://example.com/4"},
+	})
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, QuotaError{Quota: QuotaLinks, Limit: 3, Used: 2, Requested: 2}, *quotaErr)
+
+	_, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/3"})
+	require.NoError(t, err)
+	_, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/4"})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	usage, err := shortener.Quota(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.QuotaUsage{MaxLinks: 3, ActiveLinks: 3, MaxBatchSize: 2}, usage)
+
+	// удаление освобождает квоту
+	require.NoError(t, shortener.MarkURLsAsDeleted(ctx, []domain.URLLink{{UserID: "u1", ShortURL: links[0].ShortURL}}))
+	_, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/4"})
+	require.NoError(t, err)
+}
+
+func TestURLLinkService_Password(t *testing.T) {
+	ctx := context.Background()
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+
+	_, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/weak", Password: "short"})
+	assert.ErrorIs(t, err, ErrWeakPassword)
+
+	link, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/preview", Password: "correct horse"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(link.PasswordHash, "$argon2id$"))
+	assert.Empty(t, link.Password)
+
+	// без верного пароля адрес назначения не раскрывается
+	found, err := shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: link.ShortURL})
+	assert.ErrorIs(t, err, ErrPasswordRequired)
+	assert.Empty(t, found.LongURL)
+	found, err = shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: link.ShortURL, Password: "wrong horse"})
+	assert.ErrorIs(t, err, ErrWrongPassword)
+	assert.Empty(t, found.LongURL)
+
+	found, err = shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: link.ShortURL, Password: "correct horse"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/preview", found.LongURL)
+
+	// хэш пароля не попадает в журнал и ответы API
+	assert.NotContains(t, auditState(found), "argon2id")
+
+	// когда все слоты хэширования заняты, пароль не проверяется
+	for i := 0; i < cap(shortener.hashes.slots); i++ {
+		shortener.hashes.slots <- struct{}{}
+	}
+	busyCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	_, err = shortener.GetOriginalURL(busyCtx, domain.URLLink{ShortURL: link.ShortURL, Password: "correct horse"})
+	cancel()
+	assert.ErrorIs(t, err, ErrPasswordCheckBusy)
+	for i := 0; i < cap(shortener.hashes.slots); i++ {
+		<-shortener.hashes.slots
+	}
+
+	empty := ""
+	updated, err := shortener.UpdateLink(ctx, "u1", link.ShortURL, domain.URLLinkUpdate{Password: &empty})
+	require.NoError(t, err)
+	assert.Empty(t, updated.PasswordHash)
+	_, err = shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: link.ShortURL})
+	require.NoError(t, err)
+}
+
+func TestURLLinkService_RestrictedDuplicates(t *testing.T) {
+	ctx := context.Background()
+	// уникальность адреса обеспечивает частичный индекс SQL-хранилища
+	repo, err := sqlite.NewSQLiteLinkRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+
+	plain, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/plain"})
+	require.NoError(t, err)
+	duplicate, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u2", LongURL: "https://example.com/plain"})
+	assert.ErrorIs(t, err, repoerrors.ErrorShortLinkAlreadyInDB)
+	assert.Equal(t, plain.ShortURL, duplicate.ShortURL)
+
+	// ссылка с паролем на уже сокращенный адрес создается новой, пароль не теряется
+	protected, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u2", LongURL: "https://example.com/plain", Password: "correct horse"})
+	require.NoError(t, err)
+	assert.NotEqual(t, plain.ShortURL, protected.ShortURL)
+	assert.NotEmpty(t, protected.PasswordHash)
+
+	// чужая защищенная ссылка не выдается как дубликат и не занимает адрес
+	secret, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/secret", Password: "correct horse"})
+	require.NoError(t, err)
+	public, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u2", LongURL: "https://example.com/secret"})
+	require.NoError(t, err)
+	assert.NotEqual(t, secret.ShortURL, public.ShortURL)
+	assert.Empty(t, public.PasswordHash)
+	duplicate, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u3", LongURL: "https://example.com/secret"})
+	assert.ErrorIs(t, err, repoerrors.ErrorShortLinkAlreadyInDB)
+	assert.Equal(t, public.ShortURL, duplicate.ShortURL)
+
+	// лимит переходов тоже не теряется, а ограниченная ссылка не выдается повторно
+	limited, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/limited", MaxClicks: 3})
+	require.NoError(t, err)
+	duplicate, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u2", LongURL: "https://example.com/limited"})
+	require.NoError(t, err)
+	assert.NotEqual(t, limited.ShortURL, duplicate.ShortURL)
+	found, err := repo.Find(ctx, limited.ShortURL)
+	require.NoError(t, err)
+	assert.Equal(t, 3, found.ClicksRemaining)
+}
+
+func TestURLLinkService_MaxClicks(t *testing.T) {
+	ctx := context.Background()
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+
+	_, err = shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/negative", MaxClicks: -1})
+	assert.ErrorIs(t, err, ErrInvalidMaxClicks)
+
+	link, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/once", MaxClicks: 2, Password: "correct horse"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, link.ClicksRemaining)
+
+	// неудачная попытка перехода не засчитывается
+	_, err = shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: link.ShortURL, Password: "wrong horse"})
+	assert.ErrorIs(t, err, ErrWrongPassword)
+
+	for want := 1; want >= 0; want-- {
+		found, err := shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: link.ShortURL, Password: "correct horse"})
+		require.NoError(t, err)
+		assert.Equal(t, want, found.ClicksRemaining)
+	}
+	_, err = shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: link.ShortURL, Password: "correct horse"})
+	assert.ErrorIs(t, err, ErrLinkExhausted)
+
+	// у ссылок без лимита счетчик не меняется
+	unlimited, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/many"})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: unlimited.ShortURL})
+		require.NoError(t, err)
+	}
+}
+
+func TestURLLinkService_OneTimeLinks(t *testing.T) {
+	ctx := context.Background()
+	repo, err := sqlite.NewSQLiteLinkRepository(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+
+	// одноразовая ссылка на уже сокращенный адрес создается новой
+	plain, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u1", LongURL: "https://example.com/report"})
+	require.NoError(t, err)
+	first, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u2", LongURL: "https://example.com/report", MaxClicks: 1})
+	require.NoError(t, err)
+	assert.NotEqual(t, plain.ShortURL, first.ShortURL)
+	assert.Equal(t, 1, first.ClicksRemaining)
+
+	_, err = shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: first.ShortURL})
+	require.NoError(t, err)
+	_, err = shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: first.ShortURL})
+	assert.ErrorIs(t, err, ErrLinkExhausted)
+
+	// исчерпанная ссылка не мешает выдать следующую одноразовую на тот же адрес
+	second, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u2", LongURL: "https://example.com/report", MaxClicks: 1})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ShortURL, second.ShortURL)
+	found, err := shortener.GetOriginalURL(ctx, domain.URLLink{ShortURL: second.ShortURL})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/report", found.LongURL)
+
+	// обычная ссылка на адрес по-прежнему одна
+	duplicate, err := shortener.CreateShortURL(ctx, domain.URLLink{UserID: "u3", LongURL: "https://example.com/report"})
+	assert.ErrorIs(t, err, repoerrors.ErrorShortLinkAlreadyInDB)
+	assert.Equal(t, plain.ShortURL, duplicate.ShortURL)
+}
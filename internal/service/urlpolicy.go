@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Размер столбца original_url в базе
+const MaxURLLength = 512
+
+var (
+	// Адрес назначения не принят. Конкретную причину содержит InvalidURLError
+	ErrInvalidURL = errors.New("некорректный адрес назначения")
+
+	ErrURLMalformed   = errors.New("адрес назначения должен быть абсолютным URL со схемой и хостом")
+	ErrURLTooLong     = errors.New("адрес назначения слишком длинный")
+	ErrURLScheme      = errors.New("схема адреса назначения не разрешена")
+	ErrURLCredentials = errors.New("адрес назначения не должен содержать имя пользователя и пароль")
+	ErrURLPrivateHost = errors.New("адрес назначения указывает на локальную или внутреннюю сеть")
+)
+
+// Отказ в адресе назначения. errors.Is(err, ErrInvalidURL) выполняется
+// при любой причине, errors.Is(err, ErrURLScheme) и остальные - при своей
+type InvalidURLError struct {
+	Reason error
+}
+
+func (e *InvalidURLError) Error() string {
+	return e.Reason.Error()
+}
+
+func (e *InvalidURLError) Unwrap() []error {
+	return []error{ErrInvalidURL, e.Reason}
+}
+
+// Правила приема адресов назначения, общие для всех способов создать
+// или изменить ссылку
+type URLPolicy struct {
+	Schemes           []string // разрешенные схемы в нижнем регистре
+	MaxLength         int      // не больше MaxURLLength; 0 - MaxURLLength
+	AllowPrivateHosts bool     // разрешить адреса локальной и внутренних сетей
+}
+
+var DefaultURLPolicy = URLPolicy{
+	Schemes:   []string{"http", "https"},
+	MaxLength: MaxURLLength,
+}
+
+// Адреса, которые не ведут в интернет, помимо частных и локальных
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // адреса операторского NAT
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// Validate проверяет адрес назначения. Хост проверяется без обращения
+// к DNS: отклоняются IP-адреса внутренних сетей, в том числе записанные
+// в сокращенной или шестнадцатеричной форме, localhost и имена без точки
+func (p URLPolicy) Validate(rawURL string) error {
+	maxLength := p.MaxLength
+	if maxLength <= 0 || maxLength > MaxURLLength {
+		maxLength = MaxURLLength
+	}
+	if len(rawURL) > maxLength {
+		return &InvalidURLError{Reason: fmt.Errorf("%w: больше %d байт", ErrURLTooLong, maxLength)}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &InvalidURLError{Reason: ErrURLMalformed}
+	}
+	if u.Scheme != "" && !slices.Contains(p.Schemes, u.Scheme) {
+		return &InvalidURLError{Reason: fmt.Errorf("%w: %s", ErrURLScheme, u.Scheme)}
+	}
+	if u.Scheme == "" || u.Host == "" || u.Opaque != "" {
+		return &InvalidURLError{Reason: ErrURLMalformed}
+	}
+	if u.User != nil {
+		return &InvalidURLError{Reason: ErrURLCredentials}
+	}
+	if !p.AllowPrivateHosts && isPrivateHost(u.Hostname()) {
+		return &InvalidURLError{Reason: ErrURLPrivateHost}
+	}
+	return nil
+}
+
+func isPrivateHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	addr, ok := parseHostIP(host)
+	if !ok {
+		return host == "localhost" || !strings.Contains(host, ".") ||
+			strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local") ||
+			strings.HasSuffix(host, ".internal")
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHostIP разбирает IP-адрес в хосте, включая формы IPv4,
+// которые браузеры понимают наравне с обычной: 2130706433, 0x7f.1, 0177.0.0.1
+func parseHostIP(host string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.WithZone(""), true
+	}
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+	var value uint64
+	for i, part := range parts {
+		n, err := parseIPv4Part(part)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		// последняя часть занимает все оставшиеся байты адреса
+		bits := 8
+		if i == len(parts)-1 {
+			bits = 8 * (4 - i)
+		}
+		if n >= 1<<bits {
+			return netip.Addr{}, false
+		}
+		value = value<<bits | n
+	}
+	return netip.AddrFrom4([4]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}), true
+}
+
+// часть IPv4 в записи inet_aton: 0x - шестнадцатеричная, ведущий 0 - восьмеричная
+func parseIPv4Part(part string) (uint64, error) {
+	switch {
+	case len(part) > 2 && (part[:2] == "0x" || part[:2] == "0X"):
+		return strconv.ParseUint(part[2:], 16, 32)
+	case len(part) > 1 && part[0] == '0':
+		return strconv.ParseUint(part[1:], 8, 32)
+	default:
+		return strconv.ParseUint(part, 10, 32)
+	}
+}
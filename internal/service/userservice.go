@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/physicist2018/url-shortener-go/pkg/passhash"
+	"github.com/rs/zerolog"
+)
+
+const (
+	minLoginLen    = 3
+	maxLoginLen    = 64
+	minPasswordLen = 8
+	maxPasswordLen = 256
+)
+
+var (
+	ErrInvalidLogin       = errors.New("логин должен быть от 3 до 64 символов: латинские буквы, цифры и . _ - @ +")
+	ErrWeakPassword       = errors.New("пароль должен быть от 8 до 256 символов")
+	ErrLoginTaken         = errors.New("логин уже занят")
+	ErrInvalidCredentials = errors.New("неверный логин или пароль")
+	// ссылки можно забрать только у анонимной личности
+	ErrNotAnonymous = errors.New("текущая сессия уже принадлежит учетной записи")
+)
+
+type UserService struct {
+	log    zerolog.Logger
+	users  domain.UserRepo
+	links  domain.URLLinkRepo
+	hash   func(password string) (string, error)
+	hashes *HashSlots
+	quotas Quotas
+	audit  *AuditLog
+	now    func() time.Time
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func NewUserService(users domain.UserRepo, links domain.URLLinkRepo, logger zerolog.Logger) *UserService {
+	return &UserService{
+		log:    logger,
+		users:  users,
+		links:  links,
+		hash:   passhash.Hash,
+		hashes: NewHashSlots(DefaultConcurrentHashes),
+		now:    time.Now,
+	}
+}
+
+// SetQuotas задает квоты, которые проверяются при переносе ссылок в учетную запись
+func (s *UserService) SetQuotas(quotas Quotas) {
+	s.quotas = quotas
+}
+
+// SetAuditLog включает запись переноса анонимных ссылок в журнал аудита
+func (s *UserService) SetAuditLog(audit *AuditLog) {
+	s.audit = audit
+}
+
+// SetHashSlots задает семафор хэширования, общий с сервисом ссылок
+func (s *UserService) SetHashSlots(hashes *HashSlots) {
+	s.hashes = hashes
+}
+
+// Register создает учетную запись
+func (s *UserService) Register(ctx context.Context, login, password string) (domain.User, error) {
+	login, err := normalizeLogin(login)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if len(password) < minPasswordLen || len(password) > maxPasswordLen {
+		return domain.User{}, ErrWeakPassword
+	}
+
+	release, err := s.hashes.acquire(ctx)
+	if err != nil {
+		return domain.User{}, err
+	}
+	hash, err := s.hash(password)
+	release()
+	if err != nil {
+		return domain.User{}, err
+	}
+	user := domain.User{
+		ID:           uuid.New().String(),
+		Login:        login,
+		PasswordHash: hash,
+		CreatedAt:    s.now().UTC().Truncate(time.Second),
+	}
+	if err := s.users.StoreUser(ctx, user); err != nil {
+		if errors.Is(err, repoerrors.ErrorUserAlreadyExists) {
+			return domain.User{}, ErrLoginTaken
+		}
+		return domain.User{}, err
+	}
+
+	s.log.Info().Str("userID", user.ID).Str("login", login).Msg("Зарегистрирован пользователь")
+	return user, nil
+}
+
+// Login проверяет логин и пароль. Устаревший хэш (bcrypt или старые параметры
+// argon2id) после успешного входа пересчитывается
+func (s *UserService) Login(ctx context.Context, login, password string) (domain.User, error) {
+	login, err := normalizeLogin(login)
+	if err != nil {
+		return domain.User{}, ErrInvalidCredentials
+	}
+
+	user, err := s.users.FindUserByLogin(ctx, login)
+	if err != nil && !errors.Is(err, repoerrors.ErrorUserNotFound) {
+		return domain.User{}, err
+	}
+	// слот держится на проверку пароля и пересчет устаревшего хэша
+	release, slotErr := s.hashes.acquire(ctx)
+	if slotErr != nil {
+		return domain.User{}, slotErr
+	}
+	defer release()
+
+	if err != nil {
+		// тратим столько же времени, сколько на проверку пароля,
+		// чтобы по времени ответа нельзя было перебирать логины
+		passhash.Verify(s.dummy(), password)
+		return domain.User{}, ErrInvalidCredentials
+	}
+
+	if err := passhash.Verify(user.PasswordHash, password); err != nil {
+		if !errors.Is(err, passhash.ErrMismatch) {
+			s.log.Error().Err(err).Str("userID", user.ID).Msg("Некорректный хэш пароля")
+		}
+		return domain.User{}, ErrInvalidCredentials
+	}
+
+	if passhash.NeedsRehash(user.PasswordHash) {
+		if hash, err := s.hash(password); err == nil {
+			if err := s.users.UpdatePasswordHash(ctx, user.ID, hash); err != nil {
+				s.log.Warn().Err(err).Str("userID", user.ID).Msg("Не удалось обновить хэш пароля")
+			}
+		}
+	}
+	return user, nil
+}
+
+// ClaimLinks входит в учетную запись и переносит в нее ссылки
+// текущей анонимной личности. Что личность выдана анонимной кукой,
+// а не OIDC или JWT, проверяет вызывающий; здесь отсекаются учетные записи
+func (s *UserService) ClaimLinks(ctx context.Context, anonymousUserID, login, password string) (domain.User, int64, error) {
+	user, err := s.Login(ctx, login, password)
+	if err != nil {
+		return domain.User{}, 0, err
+	}
+	if anonymousUserID == user.ID {
+		return user, 0, nil
+	}
+
+	_, err = s.users.FindUserByID(ctx, anonymousUserID)
+	switch {
+	case err == nil:
+		return domain.User{}, 0, ErrNotAnonymous
+	case !errors.Is(err, repoerrors.ErrorUserNotFound):
+		return domain.User{}, 0, err
+	}
+
+	// квоту учетной записи проверяет хранилище вместе с переносом
+	n, err := s.links.ReassignLinks(ctx, anonymousUserID, user.ID, s.quotas.MaxLinks)
+	if errors.Is(err, repoerrors.ErrorLinkQuotaExceeded) {
+		return domain.User{}, 0, s.claimQuotaError(ctx, anonymousUserID, user.ID)
+	}
+	if err != nil {
+		return domain.User{}, n, err
+	}
+
+	if n > 0 {
+		s.record(ctx, domain.AuditEntry{
+			Actor:   userActor(user.ID),
+			Action:  domain.AuditLinksClaim,
+			Target:  anonymousUserID,
+			Before:  ownerState(anonymousUserID),
+			After:   ownerState(user.ID),
+			Details: fmt.Sprintf("перенесено ссылок: %d", n),
+		})
+	}
+	s.log.Info().Str("from", anonymousUserID).Str("userID", user.ID).Int64("links", n).Msg("Ссылки перенесены в учетную запись")
+	return user, n, nil
+}
+
+// record пишет перенос ссылок в журнал аудита. Сбой журнала перенос не отменяет
+func (s *UserService) record(ctx context.Context, entry domain.AuditEntry) {
+	if s.audit == nil {
+		return
+	}
+	entry.Result = domain.AuditResultOK
+	s.audit.Record(ctx, entry)
+}
+
+func (s *UserService) dummy() string {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = s.hash(uuid.New().String())
+	})
+	return s.dummyHash
+}
+
+func normalizeLogin(login string) (string, error) {
+	login = strings.ToLower(strings.TrimSpace(login))
+	if len(login) < minLoginLen || len(login) > maxLoginLen {
+		return "", ErrInvalidLogin
+	}
+	for _, r := range login {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', strings.ContainsRune("._-@+", r):
+		default:
+			return "", ErrInvalidLogin
+		}
+	}
+	return login, nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/inmemory"
+	"github.com/physicist2018/url-shortener-go/pkg/passhash"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestUserService(t *testing.T) (*UserService, *inmemory.InMemoryLinkRepository) {
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	s := NewUserService(repo, repo, zerolog.New(nil))
+	s.SetAuditLog(NewAuditLog(repo, zerolog.New(nil)))
+	// облегченные параметры, чтобы тесты не тратили память
+	s.hash = func(password string) (string, error) {
+		return passhash.HashWithParams(password, passhash.Params{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32})
+	}
+	return s, repo
+}
+
+func TestUserService_RegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUserService(t)
+
+	user, err := s.Register(ctx, " Alice@Example.com ", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", user.Login)
+	assert.NotContains(t, user.PasswordHash, "correct horse")
+
+	_, err = s.Register(ctx, "alice@example.com", "another password")
+	assert.True(t, errors.Is(err, ErrLoginTaken))
+
+	logged, err := s.Login(ctx, "ALICE@example.com", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, logged.ID)
+
+	_, err = s.Login(ctx, "alice@example.com", "wrong password")
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+	_, err = s.Login(ctx, "nobody", "correct horse")
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+}
+
+func TestUserService_RegisterValidation(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUserService(t)
+
+	_, err := s.Register(ctx, "ab", "correct horse")
+	assert.True(t, errors.Is(err, ErrInvalidLogin))
+	_, err = s.Register(ctx, "bob smith", "correct horse")
+	assert.True(t, errors.Is(err, ErrInvalidLogin))
+	_, err = s.Register(ctx, "bob", "short")
+	assert.True(t, errors.Is(err, ErrWeakPassword))
+}
+
+func TestUserService_LoginRehashesBcrypt(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestUserService(t)
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, repo.StoreUser(ctx, domain.User{ID: "u1", Login: "legacy", PasswordHash: string(legacy)}))
+
+	_, err = s.Login(ctx, "legacy", "correct horse")
+	require.NoError(t, err)
+
+	user, err := repo.FindUserByID(ctx, "u1")
+	require.NoError(t, err)
+	assert.Contains(t, user.PasswordHash, "$argon2id$")
+}
+
+func TestUserService_SharedHashSlots(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUserService(t)
+	_, err := s.Register(ctx, "alice", "correct horse")
+	require.NoError(t, err)
+
+	// слоты заняты проверкой паролей ссылок того же семафора
+	hashes := NewHashSlots(1)
+	s.SetHashSlots(hashes)
+	release, err := hashes.acquire(ctx)
+	require.NoError(t, err)
+
+	busyCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	_, err = s.Login(busyCtx, "alice", "correct horse")
+	assert.ErrorIs(t, err, ErrPasswordCheckBusy)
+	_, err = s.Login(busyCtx, "nobody", "correct horse")
+	assert.ErrorIs(t, err, ErrPasswordCheckBusy)
+	_, err = s.Register(busyCtx, "bob", "correct horse")
+	assert.ErrorIs(t, err, ErrPasswordCheckBusy)
+	cancel()
+
+	release()
+	_, err = s.Login(ctx, "alice", "correct horse")
+	require.NoError(t, err)
+}
+
+func TestUserService_ClaimLinks(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestUserService(t)
+
+	user, err := s.Register(ctx, "alice", "correct horse")
+	require.NoError(t, err)
+
+	for _, code := range []string{"aaa11", "bbb22"} {
+		_, err := repo.Store(ctx, domain.URLLink{UserID: "anon", ShortURL: code, LongURL: "https://example.com/" + code})
+		require.NoError(t, err)
+	}
+
+	_, _, err = s.ClaimLinks(ctx, "anon", "alice", "wrong password")
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+
+	claimed, n, err := s.ClaimLinks(ctx, "anon", "alice", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, claimed.ID)
+	assert.Equal(t, int64(2), n)
+
+	links, err := repo.FindAll(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Len(t, links, 2)
+
+	entries, err := repo.FindAuditEntries(ctx, domain.AuditFilter{Action: domain.AuditLinksClaim, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "user:"+user.ID, entries[0].Actor)
+	assert.Equal(t, "anon", entries[0].Target)
+	assert.JSONEq(t, `{"user_id":"anon"}`, entries[0].Before)
+	assert.JSONEq(t, `{"user_id":"`+user.ID+`"}`, entries[0].After)
+
+	// забрать ссылки у другой учетной записи нельзя
+	other, err := s.Register(ctx, "bob", "correct horse")
+	require.NoError(t, err)
+	_, _, err = s.ClaimLinks(ctx, other.ID, "alice", "correct horse")
+	assert.True(t, errors.Is(err, ErrNotAnonymous))
+}
+
+func TestUserService_ClaimLinksQuota(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestUserService(t)
+	s.SetQuotas(Quotas{MaxLinks: 2})
+
+	user, err := s.Register(ctx, "alice", "correct horse")
+	require.NoError(t, err)
+	_, err = repo.Store(ctx, domain.URLLink{UserID: user.ID, ShortURL: "own11", LongURL: "https://example.com/own"})
+	require.NoError(t, err)
+	for _, code := range []string{"aaa11", "bbb22"} {
+		_, err := repo.Store(ctx, domain.URLLink{UserID: "anon", ShortURL: code, LongURL: "https://example.com/" + code})
+		require.NoError(t, err)
+	}
+
+	_, _, err = s.ClaimLinks(ctx, "anon", "alice", "correct horse")
+	var exceeded *QuotaError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, QuotaError{Quota: QuotaLinks, Limit: 2, Used: 1, Requested: 2}, *exceeded)
+
+	// ссылки остались у анонимной личности
+	links, err := repo.FindAll(ctx, "anon")
+	require.NoError(t, err)
+	assert.Len(t, links, 2)
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/repoerrors"
+	"github.com/rs/zerolog"
+)
+
+const maxWorkspaceNameLen = 128
+
+var (
+	// возвращается и тем, кто не состоит в пространстве, чтобы не раскрывать его существование
+	ErrWorkspaceNotFound    = errors.New("рабочее пространство не найдено")
+	ErrWorkspaceForbidden   = errors.New("недостаточно прав в рабочем пространстве")
+	ErrInvalidWorkspaceName = errors.New("название рабочего пространства должно быть от 1 до 128 символов")
+	ErrInvalidRole          = errors.New("роль должна быть owner, editor или viewer")
+	ErrLastOwner            = errors.New("у рабочего пространства должен остаться хотя бы один владелец")
+	ErrLinkNotFound         = errors.New("ссылка не найдена")
+)
+
+type WorkspaceService struct {
+	log        zerolog.Logger
+	workspaces domain.WorkspaceRepo
+	links      domain.URLLinkRepo
+	shortener  domain.URLLinkService
+	audit      *AuditLog
+	now        func() time.Time
+}
+
+// NewWorkspaceService создает сервис рабочих пространств. Новые ссылки
+// создаются через shortener, чтобы генерация кодов была общей с личными ссылками
+func NewWorkspaceService(workspaces domain.WorkspaceRepo, links domain.URLLinkRepo, shortener domain.URLLinkService, logger zerolog.Logger) *WorkspaceService {
+	return &WorkspaceService{
+		log:        logger,
+		workspaces: workspaces,
+		links:      links,
+		shortener:  shortener,
+		now:        time.Now,
+	}
+}
+
+// SetAuditLog включает запись переносов ссылок в журнал аудита
+func (s *WorkspaceService) SetAuditLog(audit *AuditLog) {
+	s.audit = audit
+}
+
+// CreateWorkspace создает пространство, создатель становится его владельцем
+func (s *WorkspaceService) CreateWorkspace(ctx context.Context, userID, name string) (domain.Workspace, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxWorkspaceNameLen {
+		return domain.Workspace{}, ErrInvalidWorkspaceName
+	}
+
+	now := s.now().UTC().Truncate(time.Second)
+	workspace := domain.Workspace{
+		ID:        uuid.New().String(),
+		Name:      name,
+		CreatedAt: now,
+	}
+	owner := domain.WorkspaceMember{
+		WorkspaceID: workspace.ID,
+		UserID:      userID,
+		Role:        domain.RoleOwner,
+		AddedAt:     now,
+	}
+	if err := s.workspaces.StoreWorkspace(ctx, workspace, owner); err != nil {
+		return domain.Workspace{}, err
+	}
+	return workspace, nil
+}
+
+func (s *WorkspaceService) ListWorkspaces(ctx context.Context, userID string) ([]domain.WorkspaceMembership, error) {
+	return s.workspaces.FindUserWorkspaces(ctx, userID)
+}
+
+// ListMembers доступен любому участнику пространства
+func (s *WorkspaceService) ListMembers(ctx context.Context, actorID, workspaceID string) ([]domain.WorkspaceMember, error) {
+	if _, err := s.role(ctx, workspaceID, actorID); err != nil {
+		return nil, err
+	}
+	return s.workspaces.FindMembers(ctx, workspaceID)
+}
+
+// SetMemberRole добавляет участника или меняет его роль. Доступно владельцам
+func (s *WorkspaceService) SetMemberRole(ctx context.Context, actorID, workspaceID, userID string, role domain.WorkspaceRole) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	if err := s.require(ctx, workspaceID, actorID, domain.WorkspaceRole.CanManageMembers); err != nil {
+		return err
+	}
+
+	current, err := s.workspaces.FindMember(ctx, workspaceID, userID)
+	switch {
+	case err == nil:
+		if current.Role == domain.RoleOwner && role != domain.RoleOwner {
+			if err := s.ensureAnotherOwner(ctx, workspaceID, userID); err != nil {
+				return err
+			}
+		}
+	case !errors.Is(err, repoerrors.ErrorMemberNotFound):
+		return err
+	}
+
+	return s.workspaces.SaveMember(ctx, domain.WorkspaceMember{
+		WorkspaceID: workspaceID,
+		UserID:      userID,
+		Role:        role,
+		AddedAt:     s.now().UTC().Truncate(time.Second),
+	})
+}
+
+// RemoveMember исключает участника. Владелец исключает любого,
+// остальные могут только покинуть пространство сами
+func (s *WorkspaceService) RemoveMember(ctx context.Context, actorID, workspaceID, userID string) error {
+	actorRole, err := s.role(ctx, workspaceID, actorID)
+	if err != nil {
+		return err
+	}
+	if actorID != userID && !actorRole.CanManageMembers() {
+		return ErrWorkspaceForbidden
+	}
+
+	member, err := s.workspaces.FindMember(ctx, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrorMemberNotFound) {
+			return ErrWorkspaceNotFound
+		}
+		return err
+	}
+	if member.Role == domain.RoleOwner {
+		if err := s.ensureAnotherOwner(ctx, workspaceID, userID); err != nil {
+			return err
+		}
+	}
+	return s.workspaces.RemoveMember(ctx, workspaceID, userID)
+}
+
+// CreateLink сокращает ссылку от имени пространства. Доступно владельцам и редакторам
+func (s *WorkspaceService) CreateLink(ctx context.Context, actorID, workspaceID, longURL string) (domain.URLLink, error) {
+	if err := s.require(ctx, workspaceID, actorID, domain.WorkspaceRole.CanEditLinks); err != nil {
+		return domain.URLLink{}, err
+	}
+	return s.shortener.CreateShortURL(ctx, domain.URLLink{
+		UserID:      actorID,
+		WorkspaceID: workspaceID,
+		LongURL:     longURL,
+	})
+}
+
+// ListLinks доступен любому участнику пространства
+func (s *WorkspaceService) ListLinks(ctx context.Context, actorID, workspaceID string) ([]domain.URLLink, error) {
+	if _, err := s.role(ctx, workspaceID, actorID); err != nil {
+		return nil, err
+	}
+	return s.links.FindAllInWorkspace(ctx, workspaceID)
+}
+
+// DeleteLinks помечает ссылки пространства удаленными. Чужие коды игнорируются
+func (s *WorkspaceService) DeleteLinks(ctx context.Context, actorID, workspaceID string, shortURLs []string) error {
+	if err := s.require(ctx, workspaceID, actorID, domain.WorkspaceRole.CanEditLinks); err != nil {
+		return err
+	}
+	links := make([]domain.URLLink, len(shortURLs))
+	for i, shortURL := range shortURLs {
+		links[i] = domain.URLLink{UserID: actorID, WorkspaceID: workspaceID, ShortURL: shortURL}
+	}
+	// через сервис ссылок, чтобы удаление попало в журнал аудита
+	return s.shortener.MarkURLsAsDeleted(ctx, links)
+}
+
+// MoveLink переносит ссылку в пространство toWorkspaceID, а при пустом
+// toWorkspaceID - в личные ссылки actorID. Нужны права на изменение ссылок
+// и в исходном, и в целевом месте
+func (s *WorkspaceService) MoveLink(ctx context.Context, actorID, shortURL, toWorkspaceID string) error {
+	link, err := s.links.FindConsistent(ctx, shortURL)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrorShortLinkNotFound) {
+			return ErrLinkNotFound
+		}
+		return err
+	}
+	if link.DeletedFlag {
+		return ErrLinkNotFound
+	}
+
+	// внутри пространства ссылку переносит редактор, наружу - только владелец
+	allowed := domain.WorkspaceRole.CanEditLinks
+	if toWorkspaceID != link.WorkspaceID {
+		allowed = domain.WorkspaceRole.CanMoveLinksOut
+	}
+	if link.WorkspaceID == "" {
+		if link.UserID != actorID {
+			return ErrLinkNotFound
+		}
+	} else if err := s.require(ctx, link.WorkspaceID, actorID, allowed); err != nil {
+		if errors.Is(err, ErrWorkspaceNotFound) {
+			return ErrLinkNotFound
+		}
+		return err
+	}
+
+	// в пространстве за ссылкой сохраняется автор, личная ссылка переходит к actorID
+	userID := link.UserID
+	if toWorkspaceID == "" {
+		userID = actorID
+	} else if err := s.require(ctx, toWorkspaceID, actorID, domain.WorkspaceRole.CanEditLinks); err != nil {
+		return err
+	}
+
+	// права проверены по прочитанному состоянию ссылки: хранилище перенесет ее,
+	// только если с тех пор она не удалена и не сменила владельца
+	if err := s.links.MoveLink(ctx, link, userID, toWorkspaceID); err != nil {
+		if errors.Is(err, repoerrors.ErrorShortLinkNotFound) {
+			return ErrLinkNotFound
+		}
+		return err
+	}
+	moved := link
+	moved.UserID, moved.WorkspaceID = userID, toWorkspaceID
+	s.record(ctx, domain.AuditEntry{
+		Actor:  userActor(actorID),
+		Action: domain.AuditLinkMove,
+		Target: shortURL,
+		Before: auditState(link),
+		After:  auditState(moved),
+	})
+	s.log.Info().
+		Str("short_url", shortURL).
+		Str("from", link.WorkspaceID).
+		Str("to", toWorkspaceID).
+		Msg("ссылка перенесена")
+	return nil
+}
+
+// record пишет перенос в журнал аудита. Сбой журнала перенос не отменяет
+func (s *WorkspaceService) record(ctx context.Context, entry domain.AuditEntry) {
+	if s.audit == nil {
+		return
+	}
+	entry.Result = domain.AuditResultOK
+	s.audit.Record(ctx, entry)
+}
+
+// роль пользователя; не состоящему в пространстве - ErrWorkspaceNotFound
+func (s *WorkspaceService) role(ctx context.Context, workspaceID, userID string) (domain.WorkspaceRole, error) {
+	member, err := s.workspaces.FindMember(ctx, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrorMemberNotFound) {
+			return "", ErrWorkspaceNotFound
+		}
+		return "", err
+	}
+	return member.Role, nil
+}
+
+func (s *WorkspaceService) require(ctx context.Context, workspaceID, userID string, allowed func(domain.WorkspaceRole) bool) error {
+	role, err := s.role(ctx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if !allowed(role) {
+		return ErrWorkspaceForbidden
+	}
+	return nil
+}
+
+// проверяет, что кроме userID в пространстве есть другой владелец
+func (s *WorkspaceService) ensureAnotherOwner(ctx context.Context, workspaceID, userID string) error {
+	members, err := s.workspaces.FindMembers(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if m.Role == domain.RoleOwner && m.UserID != userID {
+			return nil
+		}
+	}
+	return ErrLastOwner
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/physicist2018/url-shortener-go/internal/domain"
+	"github.com/physicist2018/url-shortener-go/internal/repository/inmemory"
+	"github.com/physicist2018/url-shortener-go/internal/stringgenstrategy"
+	"github.com/physicist2018/url-shortener-go/pkg/uniquestring"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWorkspaceService(t *testing.T) (*WorkspaceService, *inmemory.InMemoryLinkRepository) {
+	repo, err := inmemory.NewInMemoryLinkRepository(filepath.Join(t.TempDir(), "dbase.json"))
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	generator := stringgenstrategy.StringGeneratorContext{}
+	generator.SetStrategy(uniquestring.NewRandomStringDefault())
+	shortener := NewURLLinkService(repo, generator, zerolog.New(nil))
+	return NewWorkspaceService(repo, repo, shortener, zerolog.New(nil)), repo
+}
+
+func TestWorkspaceService_Roles(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestWorkspaceService(t)
+
+	_, err := s.CreateWorkspace(ctx, "owner", "  ")
+	assert.True(t, errors.Is(err, ErrInvalidWorkspaceName))
+
+	ws, err := s.CreateWorkspace(ctx, "owner", " Marketing ")
+	require.NoError(t, err)
+	assert.Equal(t, "Marketing", ws.Name)
+
+	require.NoError(t, s.SetMemberRole(ctx, "owner", ws.ID, "editor", domain.RoleEditor))
+	require.NoError(t, s.SetMemberRole(ctx, "owner", ws.ID, "viewer", domain.RoleViewer))
+	assert.True(t, errors.Is(s.SetMemberRole(ctx, "owner", ws.ID, "x", "admin"), ErrInvalidRole))
+
+	// управлять участниками может только владелец, посторонний пространства не видит
+	assert.True(t, errors.Is(s.SetMemberRole(ctx, "editor", ws.ID, "viewer", domain.RoleEditor), ErrWorkspaceForbidden))
+	_, err = s.ListMembers(ctx, "stranger", ws.ID)
+	assert.True(t, errors.Is(err, ErrWorkspaceNotFound))
+
+	members, err := s.ListMembers(ctx, "viewer", ws.ID)
+	require.NoError(t, err)
+	assert.Len(t, members, 3)
+
+	memberships, err := s.ListWorkspaces(ctx, "editor")
+	require.NoError(t, err)
+	require.Len(t, memberships, 1)
+	assert.Equal(t, domain.RoleEditor, memberships[0].Role)
+
+	// единственного владельца нельзя понизить или исключить
+	assert.True(t, errors.Is(s.SetMemberRole(ctx, "owner", ws.ID, "owner", domain.RoleEditor), ErrLastOwner))
+	assert.True(t, errors.Is(s.RemoveMember(ctx, "owner", ws.ID, "owner"), ErrLastOwner))
+
+	// участник может выйти сам, но не исключить другого
+	assert.True(t, errors.Is(s.RemoveMember(ctx, "viewer", ws.ID, "editor"), ErrWorkspaceForbidden))
+	require.NoError(t, s.RemoveMember(ctx, "viewer", ws.ID, "viewer"))
+
+	require.NoError(t, s.SetMemberRole(ctx, "owner", ws.ID, "editor", domain.RoleOwner))
+	require.NoError(t, s.RemoveMember(ctx, "editor", ws.ID, "owner"))
+}
+
+func TestWorkspaceService_Links(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestWorkspaceService(t)
+
+	ws, err := s.CreateWorkspace(ctx, "owner", "team")
+	require.NoError(t, err)
+	require.NoError(t, s.SetMemberRole(ctx, "owner", ws.ID, "editor", domain.RoleEditor))
+	require.NoError(t, s.SetMemberRole(ctx, "owner", ws.ID, "viewer", domain.RoleViewer))
+
+	_, err = s.CreateLink(ctx, "viewer", ws.ID, "https://example.com/viewer")
+	assert.True(t, errors.Is(err, ErrWorkspaceForbidden))
+
+	link, err := s.CreateLink(ctx, "editor", ws.ID, "https://example.com/shared")
+	require.NoError(t, err)
+	assert.Equal(t, ws.ID, link.WorkspaceID)
+	assert.Equal(t, "editor", link.UserID)
+
+	links, err := s.ListLinks(ctx, "viewer", ws.ID)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+
+	// ссылку пространства удаляет любой редактор, зритель - нет
+	assert.True(t, errors.Is(s.DeleteLinks(ctx, "viewer", ws.ID, []string{link.ShortURL}), ErrWorkspaceForbidden))
+	require.NoError(t, s.DeleteLinks(ctx, "owner", ws.ID, []string{link.ShortURL}))
+	found, err := repo.Find(ctx, link.ShortURL)
+	require.NoError(t, err)
+	assert.True(t, found.DeletedFlag)
+}
+
+func TestWorkspaceService_MoveLink(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestWorkspaceService(t)
+
+	source, err := s.CreateWorkspace(ctx, "alice", "source")
+	require.NoError(t, err)
+	target, err := s.CreateWorkspace(ctx, "alice", "target")
+	require.NoError(t, err)
+	require.NoError(t, s.SetMemberRole(ctx, "alice", target.ID, "bob", domain.RoleViewer))
+
+	personal, err := repo.Store(ctx, domain.URLLink{UserID: "alice", ShortURL: "abc12", LongURL: "https://example.com"})
+	require.NoError(t, err)
+
+	// чужую личную ссылку не видно, в пространство без прав редактора не перенести
+	assert.True(t, errors.Is(s.MoveLink(ctx, "bob", personal.ShortURL, target.ID), ErrLinkNotFound))
+	assert.True(t, errors.Is(s.MoveLink(ctx, "alice", "missing", target.ID), ErrLinkNotFound))
+
+	require.NoError(t, s.MoveLink(ctx, "alice", personal.ShortURL, source.ID))
+	require.NoError(t, s.MoveLink(ctx, "alice", personal.ShortURL, target.ID))
+	found, err := repo.Find(ctx, personal.ShortURL)
+	require.NoError(t, err)
+	assert.Equal(t, target.ID, found.WorkspaceID)
+	assert.Equal(t, "alice", found.UserID)
+
+	// зритель целевого пространства не может забрать ссылку себе
+	assert.True(t, errors.Is(s.MoveLink(ctx, "bob", personal.ShortURL, ""), ErrWorkspaceForbidden))
+
+	// редактор не может вынести ссылку ни в личные, ни в другое пространство
+	require.NoError(t, s.SetMemberRole(ctx, "alice", target.ID, "bob", domain.RoleEditor))
+	require.NoError(t, s.SetMemberRole(ctx, "alice", source.ID, "bob", domain.RoleOwner))
+	assert.True(t, errors.Is(s.MoveLink(ctx, "bob", personal.ShortURL, ""), ErrWorkspaceForbidden))
+	assert.True(t, errors.Is(s.MoveLink(ctx, "bob", personal.ShortURL, source.ID), ErrWorkspaceForbidden))
+	require.NoError(t, s.MoveLink(ctx, "bob", personal.ShortURL, target.ID))
+
+	require.NoError(t, s.SetMemberRole(ctx, "alice", target.ID, "bob", domain.RoleOwner))
+	require.NoError(t, s.MoveLink(ctx, "bob", personal.ShortURL, ""))
+	links, err := repo.FindAll(ctx, "bob")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, personal.ShortURL, links[0].ShortURL)
+
+	// удаленную ссылку перенести нельзя
+	require.NoError(t, repo.MarkDeletedBatch(ctx, links))
+	assert.True(t, errors.Is(s.MoveLink(ctx, "bob", personal.ShortURL, target.ID), ErrLinkNotFound))
+}
+
+func TestWorkspaceService_MoveLinkAudit(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestWorkspaceService(t)
+	s.SetAuditLog(NewAuditLog(repo, zerolog.New(nil)))
+
+	ws, err := s.CreateWorkspace(ctx, "alice", "team")
+	require.NoError(t, err)
+	_, err = repo.Store(ctx, domain.URLLink{UserID: "alice", ShortURL: "abc12", LongURL: "https://example.com"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.MoveLink(ctx, "alice", "abc12", ws.ID))
+	assert.True(t, errors.Is(s.MoveLink(ctx, "bob", "abc12", ""), ErrLinkNotFound))
+
+	entries, err := repo.FindAuditEntries(ctx, domain.AuditFilter{Action: domain.AuditLinkMove, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "user:alice", entries[0].Actor)
+	assert.Equal(t, "abc12", entries[0].Target)
+	assert.NotContains(t, entries[0].Before, "workspace_id")
+	assert.Contains(t, entries[0].After, `"workspace_id":"`+ws.ID+`"`)
+}
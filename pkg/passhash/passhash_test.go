@@ -0,0 +1,49 @@
+package passhash
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// облегченные параметры, чтобы тесты не тратили память
+var testParams = Params{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+func TestArgon2id(t *testing.T) {
+	hash, err := HashWithParams("correct horse", testParams)
+	require.NoError(t, err)
+	assert.Contains(t, hash, "$argon2id$v=19$m=1024,t=1,p=1$")
+
+	assert.NoError(t, Verify(hash, "correct horse"))
+	assert.True(t, errors.Is(Verify(hash, "battery staple"), ErrMismatch))
+
+	other, err := HashWithParams("correct horse", testParams)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "соль должна быть случайной")
+
+	assert.True(t, NeedsRehash(hash))
+}
+
+func TestBcrypt(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	assert.NoError(t, Verify(string(hash), "correct horse"))
+	assert.True(t, errors.Is(Verify(string(hash), "battery staple"), ErrMismatch))
+	assert.True(t, NeedsRehash(string(hash)))
+}
+
+func TestInvalidHash(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$!!!",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5",
+	} {
+		assert.True(t, errors.Is(Verify(hash, "x"), ErrInvalidHash), hash)
+	}
+}
@@ -0,0 +1,329 @@
+// Пакет resp реализует минимальный клиент протокола RESP2 (Redis и совместимые
+// с ним хранилища). Поддерживаются только команды, нужные сервису
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPoolSize = 10
+	defaultTimeout  = 3 * time.Second
+)
+
+var (
+	// ключ отсутствует (nil bulk string)
+	ErrNil = errors.New("resp: nil")
+	// клиент закрыт
+	ErrClosed = errors.New("resp: клиент закрыт")
+)
+
+// Ошибка, которую вернул сервер
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Значение ответа сервера
+type Value struct {
+	Kind  byte // '+', '-', ':', '$' или '*'
+	Str   string
+	Int   int64
+	Array []Value
+	Null  bool
+}
+
+type Options struct {
+	Addr     string
+	Password string
+	DB       int
+	PoolSize int
+	Timeout  time.Duration // таймаут операции, если в контексте нет дедлайна
+}
+
+type Client struct {
+	opts   Options
+	pool   chan *conn
+	mu     sync.Mutex
+	closed bool
+}
+
+type conn struct {
+	netConn net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+}
+
+func NewClient(opts Options) *Client {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = defaultPoolSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	return &Client{
+		opts: opts,
+		pool: make(chan *conn, opts.PoolSize),
+	}
+}
+
+// Do отправляет команду и возвращает ответ. Ответ-ошибка сервера
+// возвращается как значение типа Error
+func (c *Client) Do(ctx context.Context, args ...string) (Value, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return Value{}, err
+	}
+
+	v, err := cn.roundTrip(ctx, c.opts.Timeout, args)
+	var serverErr Error
+	if err != nil && !errors.As(err, &serverErr) {
+		// после сетевой ошибки состояние соединения неизвестно
+		cn.netConn.Close()
+		return Value{}, err
+	}
+	c.put(cn)
+	return v, err
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Get возвращает значение ключа или ErrNil
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	v, err := c.Do(ctx, "GET", key)
+	if err != nil {
+		return "", err
+	}
+	if v.Null {
+		return "", ErrNil
+	}
+	return v.Str, nil
+}
+
+// Set сохраняет значение, ttl = 0 - без срока жизни
+func (c *Client) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	args := []string{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Del удаляет ключи и возвращает число удаленных
+func (c *Client) Del(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	v, err := c.Do(ctx, append([]string{"DEL"}, keys...)...)
+	return v.Int, err
+}
+
+// IncrBy атомарно увеличивает счетчик и возвращает новое значение
+func (c *Client) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	v, err := c.Do(ctx, "INCRBY", key, strconv.FormatInt(delta, 10))
+	return v.Int, err
+}
+
+// PExpire задает срок жизни ключа
+func (c *Client) PExpire(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := c.Do(ctx, "PEXPIRE", key, strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// PTTL возвращает оставшийся срок жизни ключа.
+// Отрицательное значение - ключ без срока жизни или отсутствует
+func (c *Client) PTTL(ctx context.Context, key string) (time.Duration, error) {
+	v, err := c.Do(ctx, "PTTL", key)
+	if err != nil {
+		return 0, err
+	}
+	if v.Int < 0 {
+		return time.Duration(v.Int), nil
+	}
+	return time.Duration(v.Int) * time.Millisecond, nil
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.pool)
+	for cn := range c.pool {
+		cn.netConn.Close()
+	}
+	return nil
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	select {
+	case cn := <-c.pool:
+		c.mu.Unlock()
+		return cn, nil
+	default:
+	}
+	c.mu.Unlock()
+	return c.dial(ctx)
+}
+
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		cn.netConn.Close()
+		return
+	}
+	select {
+	case c.pool <- cn:
+	default:
+		cn.netConn.Close()
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := net.Dialer{Timeout: c.opts.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{
+		netConn: netConn,
+		r:       bufio.NewReader(netConn),
+		w:       bufio.NewWriter(netConn),
+	}
+
+	if c.opts.Password != "" {
+		if _, err := cn.roundTrip(ctx, c.opts.Timeout, []string{"AUTH", c.opts.Password}); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := cn.roundTrip(ctx, c.opts.Timeout, []string{"SELECT", strconv.Itoa(c.opts.DB)}); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (cn *conn) roundTrip(ctx context.Context, timeout time.Duration, args []string) (Value, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	if err := cn.netConn.SetDeadline(deadline); err != nil {
+		return Value{}, err
+	}
+
+	if err := WriteCommand(cn.w, args...); err != nil {
+		return Value{}, err
+	}
+	if err := cn.w.Flush(); err != nil {
+		return Value{}, err
+	}
+
+	v, err := ReadValue(cn.r)
+	if err != nil {
+		return Value{}, err
+	}
+	if v.Kind == '-' {
+		return v, Error(v.Str)
+	}
+	return v, nil
+}
+
+// WriteCommand пишет команду как массив bulk-строк
+func WriteCommand(w io.Writer, args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ReadValue читает одно значение RESP2
+func ReadValue(r *bufio.Reader) (Value, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return Value{}, err
+	}
+	if len(line) == 0 {
+		return Value{}, fmt.Errorf("resp: пустая строка ответа")
+	}
+
+	v := Value{Kind: line[0]}
+	payload := line[1:]
+
+	switch v.Kind {
+	case '+', '-':
+		v.Str = payload
+	case ':':
+		if v.Int, err = strconv.ParseInt(payload, 10, 64); err != nil {
+			return Value{}, fmt.Errorf("resp: некорректное целое %q", payload)
+		}
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return Value{}, fmt.Errorf("resp: некорректная длина строки %q", payload)
+		}
+		if n < 0 {
+			v.Null = true
+			return v, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return Value{}, err
+		}
+		v.Str = string(buf[:n])
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return Value{}, fmt.Errorf("resp: некорректная длина массива %q", payload)
+		}
+		if n < 0 {
+			v.Null = true
+			return v, nil
+		}
+		v.Array = make([]Value, n)
+		for i := range v.Array {
+			if v.Array[i], err = ReadValue(r); err != nil {
+				return Value{}, err
+			}
+		}
+	default:
+		return Value{}, fmt.Errorf("resp: неизвестный тип ответа %q", v.Kind)
+	}
+	return v, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
@@ -0,0 +1,63 @@
+package resp_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/physicist2018/url-shortener-go/pkg/resp"
+	"github.com/physicist2018/url-shortener-go/pkg/resp/resptest"
+)
+
+func TestClient_Commands(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	client := resp.NewClient(resp.Options{Addr: srv.Addr})
+	defer client.Close()
+	ctx := context.Background()
+
+	require.NoError(t, client.Ping(ctx))
+
+	_, err := client.Get(ctx, "missing")
+	assert.True(t, errors.Is(err, resp.ErrNil))
+
+	require.NoError(t, client.Set(ctx, "key", "value\r\nwith crlf", time.Minute))
+	val, err := client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value\r\nwith crlf", val)
+
+	n, err := client.IncrBy(ctx, "counter", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	deleted, err := client.Del(ctx, "key", "counter", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	_, err = client.Do(ctx, "NOSUCHCOMMAND")
+	var serverErr resp.Error
+	assert.True(t, errors.As(err, &serverErr))
+
+	// после ошибки сервера соединение остается рабочим
+	assert.NoError(t, client.Ping(ctx))
+}
+
+func TestClient_Auth(t *testing.T) {
+	srv := resptest.NewUnstartedServer()
+	srv.Password = "secret"
+	srv.Start()
+	defer srv.Close()
+	ctx := context.Background()
+
+	anonymous := resp.NewClient(resp.Options{Addr: srv.Addr})
+	defer anonymous.Close()
+	assert.Error(t, anonymous.Ping(ctx))
+
+	client := resp.NewClient(resp.Options{Addr: srv.Addr, Password: "secret"})
+	defer client.Close()
+	assert.NoError(t, client.Ping(ctx))
+}
@@ -0,0 +1,333 @@
+// Пакет resptest содержит встроенный RESP-сервер для тестов, по аналогии
+// с httptest. Реализовано подмножество команд Redis, которым пользуется сервис
+package resptest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/url-shortener-go/pkg/resp"
+)
+
+type item struct {
+	value     string
+	expiresAt time.Time
+}
+
+type Server struct {
+	Addr     string
+	Password string // если задан, то до AUTH команды отклоняются
+
+	ln    net.Listener
+	mu    sync.Mutex
+	data  map[string]item
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+	now   func() time.Time
+	fails map[string]int
+}
+
+// NewServer запускает сервер на свободном локальном порту
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer открывает порт, но не принимает соединения до вызова Start.
+// Позволяет задать поля сервера, например Password
+func NewUnstartedServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("resptest: не удалось открыть порт: %v", err))
+	}
+	return &Server{
+		Addr:  ln.Addr().String(),
+		ln:    ln,
+		data:  make(map[string]item),
+		conns: make(map[net.Conn]struct{}),
+		now:   time.Now,
+		fails: make(map[string]int),
+	}
+}
+
+func (s *Server) Start() {
+	s.wg.Add(1)
+	go s.serve()
+}
+
+// FastForward сдвигает часы сервера, чтобы проверять истечение ключей
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.now
+	s.now = func() time.Time { return prev().Add(d) }
+}
+
+// FailNext заставляет следующие n вызовов команды cmd вернуть ошибку,
+// чтобы проверять реакцию клиента на временные сбои
+func (s *Server) FailNext(cmd string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fails[strings.ToUpper(cmd)] += n
+}
+
+// Keys возвращает число живых ключей
+func (s *Server) Keys() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k := range s.data {
+		if _, ok := s.lookup(k); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	authed := s.Password == ""
+
+	for {
+		v, err := resp.ReadValue(r)
+		if err != nil {
+			return
+		}
+		if v.Kind != '*' || len(v.Array) == 0 {
+			writeError(w, "ERR protocol error")
+			w.Flush()
+			continue
+		}
+
+		args := make([]string, len(v.Array))
+		for i, a := range v.Array {
+			args[i] = a.Str
+		}
+		cmd := strings.ToUpper(args[0])
+
+		switch {
+		case cmd == "AUTH":
+			if len(args) == 2 && args[1] == s.Password {
+				authed = true
+				io.WriteString(w, "+OK\r\n")
+			} else {
+				writeError(w, "WRONGPASS invalid password")
+			}
+		case !authed:
+			writeError(w, "NOAUTH Authentication required.")
+		default:
+			s.exec(w, cmd, args[1:])
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(w *bufio.Writer, cmd string, args []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fails[cmd] > 0 {
+		s.fails[cmd]--
+		writeError(w, "ERR injected failure")
+		return
+	}
+
+	switch cmd {
+	case "PING":
+		io.WriteString(w, "+PONG\r\n")
+	case "SELECT":
+		io.WriteString(w, "+OK\r\n")
+	case "GET":
+		if len(args) != 1 {
+			writeError(w, "ERR wrong number of arguments for 'get' command")
+			return
+		}
+		if it, ok := s.lookup(args[0]); ok {
+			writeBulk(w, it.value)
+		} else {
+			io.WriteString(w, "$-1\r\n")
+		}
+	case "SET":
+		s.set(w, args)
+	case "DEL":
+		var n int64
+		for _, k := range args {
+			if _, ok := s.lookup(k); ok {
+				delete(s.data, k)
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "INCR", "INCRBY":
+		delta := int64(1)
+		if cmd == "INCRBY" {
+			if len(args) != 2 {
+				writeError(w, "ERR wrong number of arguments for 'incrby' command")
+				return
+			}
+			var err error
+			if delta, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				writeError(w, "ERR value is not an integer or out of range")
+				return
+			}
+		}
+		it, _ := s.lookup(args[0])
+		cur := int64(0)
+		if it.value != "" {
+			var err error
+			if cur, err = strconv.ParseInt(it.value, 10, 64); err != nil {
+				writeError(w, "ERR value is not an integer or out of range")
+				return
+			}
+		}
+		cur += delta
+		it.value = strconv.FormatInt(cur, 10)
+		s.data[args[0]] = it
+		writeInt(w, cur)
+	case "PEXPIRE":
+		if len(args) != 2 {
+			writeError(w, "ERR wrong number of arguments for 'pexpire' command")
+			return
+		}
+		ms, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			return
+		}
+		it, ok := s.lookup(args[0])
+		if !ok {
+			writeInt(w, 0)
+			return
+		}
+		it.expiresAt = s.now().Add(time.Duration(ms) * time.Millisecond)
+		s.data[args[0]] = it
+		writeInt(w, 1)
+	case "PTTL":
+		it, ok := s.lookup(args[0])
+		switch {
+		case !ok:
+			writeInt(w, -2)
+		case it.expiresAt.IsZero():
+			writeInt(w, -1)
+		default:
+			writeInt(w, it.expiresAt.Sub(s.now()).Milliseconds())
+		}
+	case "FLUSHALL":
+		s.data = make(map[string]item)
+		io.WriteString(w, "+OK\r\n")
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", cmd))
+	}
+}
+
+// SET key value [EX seconds | PX milliseconds] [NX]
+func (s *Server) set(w *bufio.Writer, args []string) {
+	if len(args) < 2 {
+		writeError(w, "ERR wrong number of arguments for 'set' command")
+		return
+	}
+	it := item{value: args[1]}
+	nx := false
+
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				writeError(w, "ERR syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				writeError(w, "ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Millisecond
+			if strings.ToUpper(args[i]) == "EX" {
+				unit = time.Second
+			}
+			it.expiresAt = s.now().Add(time.Duration(n) * unit)
+			i++
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+
+	if _, exists := s.lookup(args[0]); exists && nx {
+		io.WriteString(w, "$-1\r\n")
+		return
+	}
+	s.data[args[0]] = it
+	io.WriteString(w, "+OK\r\n")
+}
+
+// вызывается под мьютексом
+func (s *Server) lookup(key string) (item, bool) {
+	it, ok := s.data[key]
+	if !ok {
+		return item{}, false
+	}
+	if !it.expiresAt.IsZero() && !s.now().Before(it.expiresAt) {
+		delete(s.data, key)
+		return item{}, false
+	}
+	return it, true
+}
+
+func writeError(w io.Writer, msg string) {
+	fmt.Fprintf(w, "-%s\r\n", msg)
+}
+
+func writeInt(w io.Writer, n int64) {
+	fmt.Fprintf(w, ":%d\r\n", n)
+}
+
+func writeBulk(w io.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
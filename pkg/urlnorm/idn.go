@@ -0,0 +1,42 @@
+package urlnorm
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// предельная длина метки домена по RFC 1035
+const maxLabelLength = 63
+
+// hostToASCII переводит интернационализированное имя хоста в Punycode по
+// профилю поиска UTS #46: метки сопоставляются (регистр, полноширинные
+// символы и точки), приводятся к NFC и проверяются. ASCII-хосты, включая
+// IP-адреса, не меняются. Профиль поиска не проверяет длину меток, поэтому
+// она проверяется отдельно
+func hostToASCII(host string) (string, error) {
+	if isASCII(host) {
+		return host, nil
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", errors.Join(ErrInvalidHost, err)
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		if len(label) > maxLabelLength {
+			return "", ErrInvalidHost
+		}
+	}
+	return ascii, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
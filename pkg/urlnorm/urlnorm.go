@@ -0,0 +1,228 @@
+// Пакет urlnorm приводит адреса к каноническому виду, чтобы одинаковые
+// по смыслу адреса (HTTP://Example.com:80/a и http://example.com/a)
+// записывались одной строкой. Каждое правило включается отдельно
+package urlnorm
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var (
+	// адрес без схемы или хоста
+	ErrNotAbsolute = errors.New("urlnorm: нужен абсолютный адрес со схемой и хостом")
+	// хост не удалось перевести в Punycode
+	ErrInvalidHost = errors.New("urlnorm: некорректное имя хоста")
+)
+
+// Правила нормализации
+type Options struct {
+	// схема и хост в нижнем регистре. Схему url.Parse приводит к нижнему
+	// регистру всегда, правило касается хоста
+	Lowercase bool
+	// убрать порт, совпадающий с портом схемы по умолчанию
+	DropDefaultPort bool
+	// раскодировать %XX незарезервированных символов, остальные записать заглавными
+	NormalizeEncoding bool
+	// перевести интернационализированный хост в Punycode
+	IDNToASCII bool
+	// упорядочить параметры запроса по имени, порядок значений одного параметра сохраняется
+	SortQuery bool
+	// убрать параметры отслеживания из TrackingParams
+	StripTracking bool
+	// имена параметров отслеживания; имя с * на конце - префикс
+	TrackingParams []string
+}
+
+// Правила по умолчанию не меняют смысл адреса: порядок параметров
+// и параметры отслеживания могут быть важны для сайта назначения
+var DefaultOptions = Options{
+	Lowercase:         true,
+	DropDefaultPort:   true,
+	NormalizeEncoding: true,
+	IDNToASCII:        true,
+	TrackingParams:    DefaultTrackingParams,
+}
+
+// Параметры отслеживания рекламных систем
+var DefaultTrackingParams = []string{"utm_*", "fbclid"}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+type Normalizer struct {
+	opts Options
+}
+
+// New создает нормализатор. Имена параметров отслеживания
+// сравниваются без учета регистра, пустые имена пропускаются
+func New(opts Options) *Normalizer {
+	params := make([]string, 0, len(opts.TrackingParams))
+	for _, p := range opts.TrackingParams {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			params = append(params, p)
+		}
+	}
+	opts.TrackingParams = params
+	return &Normalizer{opts: opts}
+}
+
+// Normalize возвращает адрес в каноническом виде
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", ErrNotAbsolute
+	}
+
+	host, port := u.Hostname(), u.Port()
+	if n.opts.Lowercase {
+		host = strings.ToLower(host)
+	}
+	if n.opts.IDNToASCII {
+		if host, err = hostToASCII(host); err != nil {
+			return "", err
+		}
+	}
+	if n.opts.DropDefaultPort && defaultPorts[u.Scheme] == port {
+		port = ""
+	}
+	u.Host = joinHostPort(host, port)
+
+	if n.opts.NormalizeEncoding {
+		path := normalizeEscapes(u.EscapedPath())
+		if u.Path, err = url.PathUnescape(path); err != nil {
+			return "", err
+		}
+		u.RawPath = path
+		fragment := normalizeEscapes(u.EscapedFragment())
+		if u.Fragment, err = url.PathUnescape(fragment); err != nil {
+			return "", err
+		}
+		u.RawFragment = fragment
+	}
+	u.RawQuery = n.normalizeQuery(u.RawQuery)
+	if u.RawQuery != "" {
+		u.ForceQuery = false
+	}
+	return u.String(), nil
+}
+
+// normalizeQuery работает с исходной строкой запроса, а не с url.Values,
+// чтобы не перекодировать параметры, которые правила не затронули
+func (n *Normalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" || !n.opts.NormalizeEncoding && !n.opts.SortQuery && !n.opts.StripTracking {
+		return rawQuery
+	}
+
+	type param struct {
+		raw  string
+		name string
+	}
+	var params []param
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		if n.opts.NormalizeEncoding {
+			raw = normalizeEscapes(raw)
+		}
+		name, _, _ := strings.Cut(raw, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if n.opts.StripTracking && n.isTracking(name) {
+			continue
+		}
+		params = append(params, param{raw: raw, name: name})
+	}
+	if n.opts.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool { return params[i].name < params[j].name })
+	}
+
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.raw
+	}
+	return strings.Join(parts, "&")
+}
+
+func (n *Normalizer) isTracking(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range n.opts.TrackingParams {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEscapes раскодирует %XX незарезервированных символов
+// (RFC 3986, раздел 6.2.2.2) и записывает остальные заглавными буквами.
+// Некорректные последовательности остаются как есть
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(c) {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('%')
+				b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+			}
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func joinHostPort(host, port string) string {
+	if port != "" {
+		return net.JoinHostPort(host, port)
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
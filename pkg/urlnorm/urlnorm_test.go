@@ -0,0 +1,73 @@
+package urlnorm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	all := DefaultOptions
+	all.SortQuery = true
+	all.StripTracking = true
+
+	tests := []struct {
+		name string
+		opts Options
+		in   string
+		want string
+	}{
+		{"Scheme, host and default port", DefaultOptions, "HTTP://Example.COM:80/a", "http://example.com/a"},
+		{"Default HTTPS port", DefaultOptions, "https://example.com:443/", "https://example.com/"},
+		{"Non-default port kept", DefaultOptions, "https://example.com:8443/", "https://example.com:8443/"},
+		{"Path case kept", DefaultOptions, "https://example.com/A/B", "https://example.com/A/B"},
+		{"Unreserved escapes decoded", DefaultOptions, "https://example.com/%7Euser/%61", "https://example.com/~user/a"},
+		{"Reserved escapes uppercased", DefaultOptions, "https://example.com/a%2fb?q=%3d", "https://example.com/a%2Fb?q=%3D"},
+		{"Non-ASCII path", DefaultOptions, "https://example.com/путь", "https://example.com/%D0%BF%D1%83%D1%82%D1%8C"},
+		{"IDN host", DefaultOptions, "https://Bücher.example/", "https://xn--bcher-kva.example/"},
+		{"Cyrillic host", DefaultOptions, "http://пример.испытание/", "http://xn--e1afmkfd.xn--80akhbyknj4f/"},
+		{"IPv6 host", DefaultOptions, "http://[::1]:80/", "http://[::1]/"},
+		{"Query order kept by default", DefaultOptions, "https://example.com/?b=2&a=1&utm_source=x", "https://example.com/?b=2&a=1&utm_source=x"},
+		{"Sorted query", all, "https://example.com/?b=2&a=1&a=0", "https://example.com/?a=1&a=0&b=2"},
+		{"Tracking stripped", all, "https://example.com/?utm_source=x&id=7&fbclid=abc&UTM_Medium=y", "https://example.com/?id=7"},
+		{"Only tracking params", all, "https://example.com/a?utm_source=x", "https://example.com/a"},
+		{"All rules off", Options{}, "http://Example.com:80/%7e", "http://Example.com:80/%7e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.opts).Normalize(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNormalize_Errors(t *testing.T) {
+	n := New(DefaultOptions)
+
+	_, err := n.Normalize("/relative/path")
+	assert.True(t, errors.Is(err, ErrNotAbsolute))
+
+	// метка длиннее 63 байт после кодирования
+	_, err = n.Normalize("https://" + strings.Repeat("ü", 70) + ".example/")
+	assert.True(t, errors.Is(err, ErrInvalidHost))
+}
+
+func TestHostToASCII(t *testing.T) {
+	tests := map[string]string{
+		"bücher.example":       "xn--bcher-kva.example",
+		"bu\u0308cher.example": "xn--bcher-kva.example", // u + комбинируемый умляут
+		"BÜCHER.example":       "xn--bcher-kva.example",
+		"пример。испытание":     "xn--e1afmkfd.xn--80akhbyknj4f",
+		"ｂücher.example":       "xn--bcher-kva.example", // полноширинная b
+		"他们为什么不说中文.example":    "xn--ihqwcrb4cv8a8dqg056pqjye.example",
+	}
+	for in, want := range tests {
+		got, err := hostToASCII(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+}